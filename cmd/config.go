@@ -0,0 +1,366 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/boxboxjason/gitlab-sync/pkg/helpers"
+
+	"github.com/goccy/go-yaml"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const (
+	// CONFIG_FILE_NAME is the name of the gitlab-sync configuration file.
+	CONFIG_FILE_NAME = "gitlab-sync.yaml"
+
+	settingSourceFlag    = "flag"
+	settingSourceDefault = "default"
+	redactedValue        = "<redacted>"
+)
+
+// settingBinding ties a CLI flag to the environment variable and configuration profile key it is resolved from.
+// - flag: the flag name, which is also the key of the setting in the configuration profiles
+// - env: the environment variable of the setting (none when empty)
+// - secret: whether the value must be redacted when printed.
+type settingBinding struct {
+	flag   string
+	env    string
+	secret bool
+}
+
+// settingBindings lists the settings resolvable from the environment and the configuration profiles.
+var settingBindings = []settingBinding{
+	{flag: "source-url", env: "SOURCE_GITLAB_URL"},
+	{flag: "source-token", env: "SOURCE_GITLAB_TOKEN", secret: true},
+	{flag: "source-token-file", env: "SOURCE_GITLAB_TOKEN_FILE"},
+	{flag: "source-big", env: "SOURCE_GITLAB_BIG"},
+	{flag: "source-size", env: "SOURCE_GITLAB_SIZE"},
+	{flag: "source-rate-limit", env: "SOURCE_GITLAB_RATE_LIMIT"},
+	{flag: "source-ca-file", env: "SOURCE_GITLAB_CA_FILE"},
+	{flag: "source-client-cert", env: "SOURCE_GITLAB_CLIENT_CERT"},
+	{flag: "source-client-key", env: "SOURCE_GITLAB_CLIENT_KEY"},
+	{flag: "source-insecure-skip-verify", env: "SOURCE_GITLAB_INSECURE_SKIP_VERIFY"},
+	{flag: "source-proxy", env: "SOURCE_GITLAB_PROXY", secret: true},
+	{flag: "destination-url", env: "DESTINATION_GITLAB_URL"},
+	{flag: "destination-token", env: "DESTINATION_GITLAB_TOKEN", secret: true},
+	{flag: "destination-token-file", env: "DESTINATION_GITLAB_TOKEN_FILE"},
+	{flag: "credential-helper", env: "GITLAB_SYNC_CREDENTIAL_HELPER"},
+	{flag: "destination-big", env: "DESTINATION_GITLAB_BIG"},
+	{flag: "destination-size", env: "DESTINATION_GITLAB_SIZE"},
+	{flag: "destination-rate-limit", env: "DESTINATION_GITLAB_RATE_LIMIT"},
+	{flag: "destination-ca-file", env: "DESTINATION_GITLAB_CA_FILE"},
+	{flag: "destination-client-cert", env: "DESTINATION_GITLAB_CLIENT_CERT"},
+	{flag: "destination-client-key", env: "DESTINATION_GITLAB_CLIENT_KEY"},
+	{flag: "destination-insecure-skip-verify", env: "DESTINATION_GITLAB_INSECURE_SKIP_VERIFY"},
+	{flag: "destination-proxy", env: "DESTINATION_GITLAB_PROXY", secret: true},
+	{flag: "destination-force-premium"},
+	{flag: "destination-force-freemium"},
+	{flag: "mirror-mapping", env: "MIRROR_MAPPING"},
+	{flag: "retry"},
+	{flag: "dry-run"},
+	{flag: "no-prompt", env: "NO_PROMPT"},
+	{flag: "verbose"},
+	{flag: "log-file", env: "GITLAB_SYNC_LOG_FILE"},
+	{flag: "state-dir", env: "GITLAB_SYNC_STATE_DIR"},
+	{flag: "since", env: "GITLAB_SYNC_SINCE"},
+	{flag: "full"},
+	{flag: "git-cache-dir", env: "GITLAB_SYNC_GIT_CACHE_DIR"},
+	{flag: "git-cache-max-size", env: "GITLAB_SYNC_GIT_CACHE_MAX_SIZE"},
+	{flag: "api-concurrency", env: "GITLAB_SYNC_API_CONCURRENCY"},
+	{flag: "git-concurrency", env: "GITLAB_SYNC_GIT_CONCURRENCY"},
+	{flag: "entity-concurrency", env: "GITLAB_SYNC_ENTITY_CONCURRENCY"},
+	{flag: "timeout", env: "GITLAB_SYNC_TIMEOUT"},
+	{flag: "project-timeout", env: "GITLAB_SYNC_PROJECT_TIMEOUT"},
+}
+
+// pathSettings are the settings holding file paths, resolved relative to the configuration file directory.
+var pathSettings = []string{
+	"source-token-file", "destination-token-file",
+	"source-ca-file", "source-client-cert", "source-client-key",
+	"destination-ca-file", "destination-client-cert", "destination-client-key",
+	"mirror-mapping", "log-file", "state-dir", "git-cache-dir",
+}
+
+// cliConfig is the content of the gitlab-sync configuration file
+// - default_profile: the profile used when --profile is not set
+// - profiles: the named profiles, mapping setting names (the flag names) to their values.
+type cliConfig struct {
+	DefaultProfile string                    `yaml:"default_profile"`
+	Profiles       map[string]map[string]any `yaml:"profiles"`
+}
+
+// configOptions holds the configuration file and profile selected on the command line,
+// along with the source of each setting once they are resolved.
+type configOptions struct {
+	sources     map[string]string
+	path        string
+	profile     string
+	loadedPath  string
+	loadedName  string
+	profileKeys map[string]any
+}
+
+// addConfigFlags adds the configuration file and profile persistent flags to the root command,
+// and resolves the settings of every command before it runs.
+func addConfigFlags(rootCmd *cobra.Command, options *configOptions) {
+	rootCmd.PersistentFlags().StringVar(&options.path, "config", os.Getenv("GITLAB_SYNC_CONFIG"), "Path to the configuration file (defaults to ./"+CONFIG_FILE_NAME+", then the user configuration directory) (env GITLAB_SYNC_CONFIG)")
+	rootCmd.PersistentFlags().StringVar(&options.profile, "profile", os.Getenv("GITLAB_SYNC_PROFILE"), "Name of the configuration profile to use (env GITLAB_SYNC_PROFILE)")
+	_ = rootCmd.MarkPersistentFlagFilename("config", "yaml", "yml")
+
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, cmdArgs []string) error {
+		return options.resolveSettings(cmd.Flags())
+	}
+}
+
+// addConfigCommands adds the configuration commands (config show) to the root command.
+// The show command accepts the flags of the root command, to print the settings a mirroring run would use.
+func addConfigCommands(rootCmd *cobra.Command, options *configOptions) {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the gitlab-sync configuration",
+		Args:  cobra.NoArgs,
+	}
+
+	showCmd := &cobra.Command{
+		Use:   "show",
+		Short: "Print the effective settings, along with their source",
+		Long: "Print the effective mirroring settings, resolved from the flags, the environment variables, the configuration profile and the defaults (in that order).\n" +
+			"Tokens are redacted.",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, cmdArgs []string) error {
+			return options.writeSettings(cmd.Flags(), cmd.OutOrStdout())
+		},
+	}
+	showCmd.Flags().AddFlagSet(rootCmd.Flags())
+
+	configCmd.AddCommand(showCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+// annotateEnvUsage appends the environment variable of the bound flags to their usage.
+func annotateEnvUsage(flags *pflag.FlagSet) {
+	for _, binding := range settingBindings {
+		if flag := flags.Lookup(binding.flag); flag != nil && binding.env != "" {
+			flag.Usage += " (env " + binding.env + ")"
+		}
+	}
+}
+
+// resolveSettings resolves the bound flags that were not set on the command line,
+// from their environment variable first, then from the selected configuration profile.
+// The flags left unset keep their default value.
+func (o *configOptions) resolveSettings(flags *pflag.FlagSet) error {
+	o.sources = make(map[string]string)
+
+	bound := slices.DeleteFunc(slices.Clone(settingBindings), func(binding settingBinding) bool {
+		return flags.Lookup(binding.flag) == nil
+	})
+	if len(bound) == 0 {
+		return nil
+	}
+
+	err := o.loadProfile()
+	if err != nil {
+		return err
+	}
+
+	for _, binding := range bound {
+		flag := flags.Lookup(binding.flag)
+
+		if flag.Changed {
+			o.sources[binding.flag] = settingSourceFlag
+
+			continue
+		}
+
+		if value := strings.TrimSpace(os.Getenv(binding.env)); binding.env != "" && value != "" {
+			// Boolean environment variables are enabled by any non empty value
+			if flag.Value.Type() == "bool" {
+				value = "true"
+			}
+
+			err = flags.Set(binding.flag, value)
+			if err != nil {
+				return fmt.Errorf("invalid value for %s: %w", binding.env, err)
+			}
+
+			o.sources[binding.flag] = "env " + binding.env
+
+			continue
+		}
+
+		if value, ok := o.profileKeys[binding.flag]; ok {
+			formatted, valueErr := o.profileValue(binding.flag, value)
+			if valueErr == nil {
+				valueErr = flags.Set(binding.flag, formatted)
+			}
+
+			if valueErr != nil {
+				return fmt.Errorf("invalid value for %s in profile %s of %s: %w", binding.flag, o.loadedName, o.loadedPath, valueErr)
+			}
+
+			o.sources[binding.flag] = "profile " + o.loadedName
+
+			continue
+		}
+
+		o.sources[binding.flag] = settingSourceDefault
+	}
+
+	return nil
+}
+
+// loadProfile reads the configuration file and selects the profile to apply:
+// the --profile one, or the default profile of the file. No profile is applied without a configuration file.
+func (o *configOptions) loadProfile() error {
+	o.loadedPath, o.loadedName, o.profileKeys = "", "", nil
+
+	path, err := findConfigFile(strings.TrimSpace(o.path))
+	if err != nil {
+		return err
+	}
+
+	profileName := strings.TrimSpace(o.profile)
+
+	if path == "" {
+		if profileName != "" {
+			return fmt.Errorf("profile %s requested but no %s configuration file was found", profileName, CONFIG_FILE_NAME)
+		}
+
+		return nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read configuration file: %w", err)
+	}
+
+	config := &cliConfig{}
+
+	err = yaml.UnmarshalWithOptions(content, config, yaml.Strict())
+	if err != nil {
+		return fmt.Errorf("failed to decode configuration file %s: %w", path, err)
+	}
+
+	if profileName == "" {
+		profileName = strings.TrimSpace(config.DefaultProfile)
+	}
+
+	if profileName == "" {
+		return nil
+	}
+
+	profile, ok := config.Profiles[profileName]
+	if !ok {
+		return fmt.Errorf("profile %s not found in %s (available profiles: %s)", profileName, path, strings.Join(slices.Sorted(maps.Keys(config.Profiles)), ", "))
+	}
+
+	var errs []error
+
+	for _, key := range slices.Sorted(maps.Keys(profile)) {
+		if !slices.ContainsFunc(settingBindings, func(binding settingBinding) bool { return binding.flag == key }) {
+			errs = append(errs, fmt.Errorf("unknown setting %s in profile %s of %s", key, profileName, path))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	o.loadedPath, o.loadedName, o.profileKeys = path, profileName, profile
+
+	return nil
+}
+
+// profileValue formats a configuration profile value as a flag value.
+// Strings are interpolated with the environment variables, and relative file paths
+// are resolved from the configuration file directory.
+func (o *configOptions) profileValue(key string, value any) (string, error) {
+	formatted, ok := value.(string)
+	if !ok {
+		return fmt.Sprint(value), nil
+	}
+
+	formatted, err := helpers.InterpolateEnv(formatted)
+	if err != nil {
+		return "", err
+	}
+
+	if slices.Contains(pathSettings, key) && formatted != "" && formatted != helpers.STDIN_PATH && !filepath.IsAbs(formatted) {
+		formatted = filepath.Join(filepath.Dir(o.loadedPath), formatted)
+	}
+
+	return formatted, nil
+}
+
+// findConfigFile returns the path of the configuration file to load.
+// An explicit path must exist, otherwise ./gitlab-sync.yaml (or .yml) then the one of the user configuration directory
+// are looked up. An empty path is returned when no configuration file is found.
+func findConfigFile(path string) (string, error) {
+	if path != "" {
+		if _, err := os.Stat(path); err != nil {
+			return "", fmt.Errorf("failed to open configuration file: %w", err)
+		}
+
+		return path, nil
+	}
+
+	candidates := []string{CONFIG_FILE_NAME, strings.TrimSuffix(CONFIG_FILE_NAME, ".yaml") + ".yml"}
+	if configDir, err := os.UserConfigDir(); err == nil {
+		candidates = append(candidates, filepath.Join(configDir, "gitlab-sync", CONFIG_FILE_NAME))
+	}
+
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate, nil
+		}
+	}
+
+	return "", nil
+}
+
+// writeSettings prints the effective value and source of the bound flags, redacting the secrets.
+func (o *configOptions) writeSettings(flags *pflag.FlagSet, out io.Writer) error {
+	writer := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	if o.loadedPath != "" {
+		_, err := fmt.Fprintf(writer, "profile\t%s\t(%s)\n", o.loadedName, o.loadedPath)
+		if err != nil {
+			return fmt.Errorf("failed to print settings: %w", err)
+		}
+	}
+
+	for _, binding := range settingBindings {
+		flag := flags.Lookup(binding.flag)
+		if flag == nil {
+			continue
+		}
+
+		value := flag.Value.String()
+		if binding.secret && value != "" {
+			value = redactedValue
+		}
+
+		_, err := fmt.Fprintf(writer, "%s\t%s\t(%s)\n", binding.flag, value, o.sources[binding.flag])
+		if err != nil {
+			return fmt.Errorf("failed to print settings: %w", err)
+		}
+	}
+
+	err := writer.Flush()
+	if err != nil {
+		return fmt.Errorf("failed to print settings: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/boxboxjason/gitlab-sync/internal/utils"
+)
+
+const testConfigContent = `default_profile: staging
+profiles:
+  staging:
+    source-url: https://staging.example.com
+    source-token: ${GITLAB_SYNC_TEST_TOKEN:-staging-token}
+    destination-url: https://mirror.example.com
+    destination-big: true
+    retry: 5
+    mirror-mapping: mappings/staging.yaml
+    git-cache-dir: git-cache
+    git-cache-max-size: 20480MiB
+  production:
+    source-url: https://production.example.com
+    destination-force-premium: true
+`
+
+// executeConfigShow runs the config show command with the given arguments and returns its output.
+func executeConfigShow(t *testing.T, cmdArgs ...string) (string, error) {
+	t.Helper()
+
+	var (
+		args              utils.ParserArgs
+		mirrorMappingPath string
+		logFile           string
+		out               bytes.Buffer
+	)
+
+	rootCmd := buildRootCmd(&args, &mirrorMappingPath, &logFile)
+	rootCmd.SetOut(&out)
+	rootCmd.SetErr(&out)
+	rootCmd.SetArgs(append([]string{"config", "show"}, cmdArgs...))
+
+	err := rootCmd.Execute()
+
+	return out.String(), err
+}
+
+// writeTestConfig writes a configuration file in a temporary directory and returns its path.
+func writeTestConfig(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), CONFIG_FILE_NAME)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write configuration file: %v", err)
+	}
+
+	return path
+}
+
+func TestConfigShowResolutionOrder(t *testing.T) {
+	configPath := writeTestConfig(t, testConfigContent)
+
+	t.Setenv("DESTINATION_GITLAB_URL", "https://env.example.com")
+	t.Setenv("SOURCE_GITLAB_URL", "https://env-source.example.com")
+	t.Setenv("GITLAB_SYNC_TEST_TOKEN", "")
+	t.Setenv("SOURCE_GITLAB_TOKEN", "")
+
+	output, err := executeConfigShow(t, "--config", configPath, "--source-url", "https://flag.example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expectedLines := map[string][]string{
+		"source-url":         {"https://flag.example.com", "(flag)"},
+		"destination-url":    {"https://env.example.com", "(env DESTINATION_GITLAB_URL)"},
+		"destination-big":    {"true", "(profile staging)"},
+		"retry":              {"5", "(profile staging)"},
+		"source-token":       {redactedValue, "(profile staging)"},
+		"dry-run":            {"false", "(default)"},
+		"mirror-mapping":     {filepath.Join(filepath.Dir(configPath), "mappings", "staging.yaml")},
+		"git-cache-dir":      {filepath.Join(filepath.Dir(configPath), "git-cache")},
+		"git-cache-max-size": {"20GiB", "(profile staging)"},
+		"destination-token":  {"(default)"},
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		for _, expected := range expectedLines[fields[0]] {
+			if !strings.Contains(line, expected) {
+				t.Errorf("expected setting line %q to contain %q", line, expected)
+			}
+		}
+
+		delete(expectedLines, fields[0])
+	}
+
+	if len(expectedLines) > 0 {
+		t.Errorf("expected settings %v to be printed, got:\n%s", expectedLines, output)
+	}
+
+	if strings.Contains(output, "staging-token") {
+		t.Errorf("expected the source token to be redacted, got:\n%s", output)
+	}
+}
+
+func TestConfigShowProfileSelection(t *testing.T) {
+	configPath := writeTestConfig(t, testConfigContent)
+
+	t.Setenv("SOURCE_GITLAB_URL", "")
+	t.Setenv("GITLAB_SYNC_PROFILE", "")
+
+	output, err := executeConfigShow(t, "--config", configPath, "--profile", "production")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, expected := range []string{"https://production.example.com", "profile production"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected output to contain %q, got:\n%s", expected, output)
+		}
+	}
+}
+
+func TestConfigShowErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       string
+		cmdArgs       []string
+		expectedError string
+	}{
+		{
+			name:          "Unknown profile",
+			content:       testConfigContent,
+			cmdArgs:       []string{"--profile", "qa"},
+			expectedError: "profile qa not found",
+		},
+		{
+			name:          "Unknown setting",
+			content:       "profiles:\n  ci:\n    source-uri: https://example.com\n",
+			cmdArgs:       []string{"--profile", "ci"},
+			expectedError: "unknown setting source-uri in profile ci",
+		},
+		{
+			name:          "Invalid setting value",
+			content:       "profiles:\n  ci:\n    retry: many\n",
+			cmdArgs:       []string{"--profile", "ci"},
+			expectedError: "invalid value for retry in profile ci",
+		},
+		{
+			name:          "Invalid size value",
+			content:       "profiles:\n  ci:\n    git-cache-max-size: 20GO\n",
+			cmdArgs:       []string{"--profile", "ci"},
+			expectedError: "invalid value for git-cache-max-size in profile ci",
+		},
+		{
+			name:          "Unknown configuration field",
+			content:       "default-profile: ci\nprofiles: {}\n",
+			expectedError: "failed to decode configuration file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GITLAB_SYNC_PROFILE", "")
+
+			configPath := writeTestConfig(t, tt.content)
+
+			_, err := executeConfigShow(t, append([]string{"--config", configPath}, tt.cmdArgs...)...)
+			if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+				t.Errorf("expected error containing %q, got %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestConfigShowWithoutConfigFile(t *testing.T) {
+	directory := t.TempDir()
+	t.Chdir(directory)
+	t.Setenv("HOME", directory)
+	t.Setenv("XDG_CONFIG_HOME", directory)
+	t.Setenv("GITLAB_SYNC_CONFIG", "")
+	t.Setenv("GITLAB_SYNC_PROFILE", "")
+
+	if _, err := executeConfigShow(t); err != nil {
+		t.Errorf("expected no error without configuration file, got %v", err)
+	}
+
+	if _, err := executeConfigShow(t, "--profile", "staging"); err == nil {
+		t.Error("expected an error when a profile is requested without configuration file")
+	}
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/boxboxjason/gitlab-sync/pkg/helpers"
+
+	"go.uber.org/zap"
+	"golang.org/x/term"
+)
+
+// resolveGitlabToken resolves the token of a GitLab instance from, in order: the token argument,
+// the token file (the standard input for "-") and the credential helper.
+// An empty token is returned when none of them provides one.
+func resolveGitlabToken(gitlabURL, token, tokenFile, credentialHelper string, stdin io.Reader) (string, error) {
+	return helpers.ResolveToken(gitlabURL,
+		helpers.StaticToken(token),
+		&helpers.TokenFile{Path: tokenFile, Stdin: stdin},
+		&helpers.CredentialHelper{Command: credentialHelper, Stderr: os.Stderr},
+	)
+}
+
+// checkTokenFiles checks that at most one token is read from the standard input.
+func checkTokenFiles(tokenFiles ...string) error {
+	stdinReaders := 0
+
+	for _, tokenFile := range tokenFiles {
+		if strings.TrimSpace(tokenFile) == helpers.STDIN_PATH {
+			stdinReaders++
+		}
+	}
+
+	if stdinReaders > 1 {
+		return errors.New("only one token can be read from the standard input")
+	}
+
+	return nil
+}
+
+// promptForSecretInput prompts the user for a secret and returns the trimmed response.
+// The input is not echoed when the standard input is a terminal, otherwise it is read like any other input.
+func promptForSecretInput(prompt string) string {
+	stdinFd := int(os.Stdin.Fd()) //nolint:gosec // file descriptors fit in an int
+	if !term.IsTerminal(stdinFd) {
+		return promptForInput(prompt)
+	}
+
+	_, err := fmt.Fprintf(os.Stdout, "%s: ", prompt)
+	if err != nil {
+		zap.L().Fatal("Error writing prompt", zap.Error(err))
+	}
+
+	secret, err := term.ReadPassword(stdinFd)
+	if err != nil {
+		zap.L().Fatal("Error reading input", zap.Error(err))
+	}
+
+	// The newline typed by the user is not echoed either
+	_, err = fmt.Fprintln(os.Stdout)
+	if err != nil {
+		zap.L().Fatal("Error writing prompt", zap.Error(err))
+	}
+
+	return strings.TrimSpace(string(secret))
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveGitlabToken(t *testing.T) {
+	t.Parallel()
+
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenPath, []byte("file-token\n"), 0o600); err != nil {
+		t.Fatalf("failed to write token file: %v", err)
+	}
+
+	helperPath := filepath.Join(t.TempDir(), "helper")
+	if err := os.WriteFile(helperPath, []byte("#!/bin/sh\ncat > /dev/null\necho password=helper-token\n"), 0o700); err != nil {
+		t.Fatalf("failed to write credential helper: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		token     string
+		tokenFile string
+		helper    string
+		expected  string
+	}{
+		{
+			name:      "Token argument wins",
+			token:     "flag-token",
+			tokenFile: tokenPath,
+			helper:    helperPath,
+			expected:  "flag-token",
+		},
+		{
+			name:      "Token file",
+			tokenFile: tokenPath,
+			helper:    helperPath,
+			expected:  "file-token",
+		},
+		{
+			name:      "Standard input",
+			tokenFile: "-",
+			expected:  "stdin-token",
+		},
+		{
+			name:     "Credential helper",
+			helper:   helperPath,
+			expected: "helper-token",
+		},
+		{
+			name:     "No token",
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			token, err := resolveGitlabToken("https://gitlab.example.com", tt.token, tt.tokenFile, tt.helper, strings.NewReader("stdin-token\n"))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if token != tt.expected {
+				t.Errorf("expected token %q, got %q", tt.expected, token)
+			}
+		})
+	}
+}
+
+func TestCheckTokenFiles(t *testing.T) {
+	t.Parallel()
+
+	if err := checkTokenFiles("-", "destination-token"); err != nil {
+		t.Errorf("expected a single standard input token to be allowed, got %v", err)
+	}
+
+	if err := checkTokenFiles("-", " - "); err == nil {
+		t.Error("expected an error when both tokens are read from the standard input")
+	}
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/boxboxjason/gitlab-sync/internal/mirroring"
+	"github.com/boxboxjason/gitlab-sync/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+// errDoctorFailed wraps the errors of the doctor command, which are printed by cobra.
+var errDoctorFailed = errors.New("preflight checks failed")
+
+// addDoctorCommand adds the doctor command, running the preflight checks of a mirroring run, to the root command.
+// It accepts the flags of the root command, the mirror mapping being optional.
+func addDoctorCommand(rootCmd *cobra.Command, args *utils.ParserArgs, mirrorMappingPath, logFile *string) {
+	doctorCmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the instances, tokens and features of a mirroring run without changing anything",
+		Long: "Report the version, license, user and token (scopes and expiry) of both GitLab instances, check that the destination root namespaces " +
+			"of the mirror mapping exist with the Owner or Maintainer role, and give a verdict on the pull mirroring, CI/CD catalog, issue timestamps and ownership claiming features.\n" +
+			"Exits with a non-zero code when a check fails.",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, cmdArgs []string) error {
+			SetupZapLogger(args.Verbose, strings.TrimSpace(*logFile))
+
+			err := runDoctor(args, strings.TrimSpace(*mirrorMappingPath), cmd.InOrStdin(), cmd.OutOrStdout())
+			if err != nil && !errors.Is(err, errDoctorFailed) {
+				return fmt.Errorf("%w: %w", errDoctorFailed, err)
+			}
+
+			return err
+		},
+	}
+	doctorCmd.Flags().AddFlagSet(rootCmd.Flags())
+
+	rootCmd.AddCommand(doctorCmd)
+}
+
+// runDoctor resolves the instances URLs and tokens, loads the mirror mapping when set, and prints the preflight checks report.
+// The tokens may be read from in (the standard input).
+func runDoctor(args *utils.ParserArgs, mirrorMappingPath string, in io.Reader, out io.Writer) error {
+	args.SourceGitlabURL = strings.TrimSpace(args.SourceGitlabURL)
+	args.DestinationGitlabURL = strings.TrimSpace(args.DestinationGitlabURL)
+
+	if args.SourceGitlabURL == "" || args.DestinationGitlabURL == "" {
+		return errors.New("source and destination GitLab URLs are mandatory")
+	}
+
+	if args.Retry < 0 {
+		args.Retry = 10000
+	}
+
+	err := checkTokenFiles(args.SourceTokenFile, args.DestinationTokenFile)
+	if err != nil {
+		return err
+	}
+
+	args.SourceGitlabToken, err = resolveGitlabToken(args.SourceGitlabURL, args.SourceGitlabToken, args.SourceTokenFile, args.CredentialHelper, in)
+	if err != nil {
+		return fmt.Errorf("failed to resolve the Source GitLab Token: %w", err)
+	}
+
+	args.DestinationGitlabToken, err = resolveGitlabToken(args.DestinationGitlabURL, args.DestinationGitlabToken, args.DestinationTokenFile, args.CredentialHelper, in)
+	if err != nil {
+		return fmt.Errorf("failed to resolve the Destination GitLab Token: %w", err)
+	}
+
+	if args.DestinationGitlabToken == "" {
+		return errors.New("destination GitLab token is mandatory")
+	}
+
+	if mirrorMappingPath != "" {
+		mapping, mappingErrors := utils.OpenMirrorMapping(mirrorMappingPath)
+		if mappingErrors != nil {
+			return fmt.Errorf("invalid mirror mapping %s: %w", mirrorMappingPath, errors.Join(mappingErrors...))
+		}
+
+		args.MirrorMapping = mapping
+	}
+
+	report := mirroring.Diagnose(args)
+
+	err = writeDoctorReport(report, out)
+	if err != nil {
+		return err
+	}
+
+	if report.Failed() {
+		return errDoctorFailed
+	}
+
+	return nil
+}
+
+// writeDoctorReport prints the preflight checks, grouped by section.
+func writeDoctorReport(report *mirroring.DoctorReport, out io.Writer) error {
+	writer := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	for index, section := range report.Sections {
+		if index > 0 {
+			fmt.Fprintln(writer)
+		}
+
+		fmt.Fprintln(writer, section.Title)
+
+		for _, check := range section.Checks {
+			if check.Detail == "" {
+				fmt.Fprintf(writer, "  %s\t%s\n", check.Status, check.Name)
+			} else {
+				fmt.Fprintf(writer, "  %s\t%s\t%s\n", check.Status, check.Name, check.Detail)
+			}
+		}
+	}
+
+	err := writer.Flush()
+	if err != nil {
+		return fmt.Errorf("failed to print the doctor report: %w", err)
+	}
+
+	return nil
+}
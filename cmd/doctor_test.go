@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/boxboxjason/gitlab-sync/internal/mirroring"
+	"github.com/boxboxjason/gitlab-sync/internal/utils"
+)
+
+func TestWriteDoctorReport(t *testing.T) {
+	t.Parallel()
+
+	report := &mirroring.DoctorReport{Sections: []*mirroring.DoctorSection{
+		{Title: "destination (https://gitlab.example.com)", Checks: []*mirroring.DoctorCheck{
+			{Name: "version", Status: mirroring.DOCTOR_INFO, Detail: "19.4.0"},
+			{Name: "token scopes", Status: mirroring.DOCTOR_FAIL, Detail: "missing api"},
+		}},
+		{Title: "features", Checks: []*mirroring.DoctorCheck{
+			{Name: "pull mirroring", Status: mirroring.DOCTOR_PASS},
+		}},
+	}}
+
+	var out bytes.Buffer
+	if err := writeDoctorReport(report, &out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, expected := range []string{"destination (https://gitlab.example.com)\n", "  INFO  version", "  FAIL  token scopes  missing api", "\nfeatures\n", "  PASS  pull mirroring\n"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected the report to contain %q, got:\n%s", expected, out.String())
+		}
+	}
+}
+
+func TestRunDoctorErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		args          *utils.ParserArgs
+		mappingPath   string
+		expectedError string
+	}{
+		{
+			name:          "Missing destination URL",
+			args:          &utils.ParserArgs{SourceGitlabURL: "https://gitlab.example.com"},
+			expectedError: "URLs are mandatory",
+		},
+		{
+			name:          "Missing destination token",
+			args:          &utils.ParserArgs{SourceGitlabURL: "https://gitlab.example.com", DestinationGitlabURL: "https://mirror.example.com"},
+			expectedError: "destination GitLab token is mandatory",
+		},
+		{
+			name:          "Both tokens from the standard input",
+			args:          &utils.ParserArgs{SourceGitlabURL: "https://gitlab.example.com", DestinationGitlabURL: "https://mirror.example.com", SourceTokenFile: "-", DestinationTokenFile: "-"},
+			expectedError: "standard input",
+		},
+		{
+			name:          "Missing mirror mapping",
+			args:          &utils.ParserArgs{SourceGitlabURL: "https://gitlab.example.com", DestinationGitlabURL: "https://mirror.example.com", DestinationGitlabToken: "token"},
+			mappingPath:   "missing.yaml",
+			expectedError: "invalid mirror mapping",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := runDoctor(tt.args, tt.mappingPath, strings.NewReader(""), &bytes.Buffer{})
+			if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+				t.Errorf("expected error containing %q, got %v", tt.expectedError, err)
+			}
+
+			if errors.Is(err, errDoctorFailed) {
+				t.Errorf("expected a setup error rather than failed checks, got %v", err)
+			}
+		})
+	}
+}
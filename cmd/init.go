@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/boxboxjason/gitlab-sync/internal/mirroring"
+	"github.com/boxboxjason/gitlab-sync/internal/utils"
+	"github.com/boxboxjason/gitlab-sync/pkg/helpers"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	mappingFilePermission = 0o600
+	stdoutPath            = "-"
+)
+
+// errMappingGeneration wraps the errors of the init command, which are printed by cobra.
+var errMappingGeneration = errors.New("failed to generate mirror mapping")
+
+// initArgs defines the command line arguments of the init command
+// - source_gitlab_url: the URL of the source GitLab instance
+// - source_gitlab_token: the token for the source GitLab instance
+// - source_token_file: the file the source token is read from ("-" for the standard input)
+// - credential_helper: the git style credential helper command the source token is retrieved from
+// - source_transport: the TLS and proxy settings of the source GitLab instance
+// - from_group: the source group whose tree is discovered
+// - destination_root: the destination path of the source group
+// - output: the path of the generated mapping file ("-" for the standard output)
+// - format: the format of the generated mapping file (guessed from the output extension by default)
+// - collapse: which entities get their own mapping entry (none, groups, root)
+// - force: whether to overwrite an existing output file.
+type initArgs struct {
+	SourceTransport   helpers.TransportOptions
+	SourceGitlabURL   string
+	SourceGitlabToken string
+	SourceTokenFile   string
+	CredentialHelper  string
+	FromGroup         string
+	DestinationRoot   string
+	Output            string
+	Format            string
+	Collapse          string
+	Retry             int
+	Force             bool
+	Verbose           bool
+}
+
+// addInitCommand adds the init command, generating a mirror mapping from a source group tree, to the root command.
+func addInitCommand(rootCmd *cobra.Command) {
+	args := &initArgs{}
+
+	initCmd := &cobra.Command{
+		Use:   "init",
+		Short: "Generate a mirror mapping file from a source group tree",
+		Long: "Discover the subgroups and projects of a source group and write a mirror mapping copying the whole tree under a destination root.\n" +
+			"Every project and subgroup is written as an explicit entry with default options (keeping its source visibility), ready to be edited.\n" +
+			"Use --collapse to only write the group entries (groups) or the root group entry (root).",
+		Example:      "gitlab-sync init --source-url https://gitlab.example.com --from-group org --dest-root mirror/org -o mirror.yaml",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, cmdArgs []string) error {
+			SetupZapLogger(args.Verbose, "")
+
+			err := runInit(cmd.Context(), args, cmd.InOrStdin(), cmd.OutOrStdout())
+			if err != nil {
+				return fmt.Errorf("%w: %w", errMappingGeneration, err)
+			}
+
+			return nil
+		},
+	}
+
+	initCmd.Flags().StringVar(&args.SourceGitlabURL, "source-url", "", "Source GitLab URL")
+	initCmd.Flags().StringVar(&args.SourceGitlabToken, "source-token", "", "Source GitLab Token")
+	initCmd.Flags().StringVar(&args.SourceTokenFile, "source-token-file", "", "Path to a file containing the Source GitLab Token (- for the standard input)")
+	initCmd.Flags().StringVar(&args.CredentialHelper, "credential-helper", "", "git style credential helper command the Source GitLab Token is retrieved from")
+	addTransportFlags(initCmd.Flags(), "source", "Source GitLab", &args.SourceTransport)
+	initCmd.Flags().StringVar(&args.FromGroup, "from-group", "", "Path of the source group to discover")
+	initCmd.Flags().StringVar(&args.DestinationRoot, "dest-root", "", "Destination path of the source group (defaults to the source group path)")
+	initCmd.Flags().StringVarP(&args.Output, "output", "o", stdoutPath, "Path of the generated mirror mapping file (- for the standard output)")
+	initCmd.Flags().StringVar(&args.Format, "format", "", "Format of the generated mirror mapping (json, yaml or toml), guessed from the output file extension by default")
+	initCmd.Flags().StringVar(&args.Collapse, "collapse", mirroring.COLLAPSE_NONE, "Collapse the generated entries: none (every project and group), groups (group entries only) or root (the source group entry only)")
+	initCmd.Flags().BoolVar(&args.Force, "force", false, "Overwrite the output file if it already exists")
+	initCmd.Flags().IntVarP(&args.Retry, "retry", "r", defaultRetryCount, "Number of retries for failed requests")
+	initCmd.Flags().BoolVarP(&args.Verbose, "verbose", "v", false, "Enable verbose output")
+	_ = initCmd.MarkFlagRequired("from-group")
+	annotateEnvUsage(initCmd.Flags())
+	_ = initCmd.MarkFlagFilename("output", "json", "yaml", "yml", "toml")
+	_ = initCmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions(utils.MappingFormats, cobra.ShellCompDirectiveNoFileComp))
+	_ = initCmd.RegisterFlagCompletionFunc("collapse", cobra.FixedCompletions([]string{mirroring.COLLAPSE_NONE, mirroring.COLLAPSE_GROUPS, mirroring.COLLAPSE_ROOT}, cobra.ShellCompDirectiveNoFileComp))
+
+	rootCmd.AddCommand(initCmd)
+}
+
+// runInit discovers the source group tree and writes the generated mirror mapping
+// to the output file, or to out when the output is the standard output.
+// The source token may be read from in (the standard input).
+func runInit(ctx context.Context, args *initArgs, in io.Reader, out io.Writer) error {
+	sourceGitlabURL := strings.TrimSpace(args.SourceGitlabURL)
+	if sourceGitlabURL == "" {
+		return errors.New("source GitLab URL is mandatory (--source-url, SOURCE_GITLAB_URL or the source-url setting of the configuration profile)")
+	}
+
+	if args.Retry < 0 {
+		args.Retry = 10000
+	}
+
+	output := strings.TrimSpace(args.Output)
+	if output == "" {
+		output = stdoutPath
+	}
+
+	format := strings.ToLower(strings.TrimSpace(args.Format))
+	if format == "" {
+		format = utils.MAPPING_FORMAT_JSON
+		if output != stdoutPath {
+			format = utils.DetectMappingFormat(output, nil)
+		}
+	}
+
+	// Checked before the source group traversal, which may take a while on large trees
+	if !slices.Contains(utils.MappingFormats, format) {
+		return fmt.Errorf("unsupported mirror mapping format: %s (must be one of %s)", format, strings.Join(utils.MappingFormats, ", "))
+	}
+
+	if output != stdoutPath && !args.Force {
+		if _, err := os.Stat(output); err == nil {
+			return fmt.Errorf("output file %s already exists (use --force to overwrite it)", output)
+		}
+	}
+
+	sourceGitlabToken, err := resolveGitlabToken(sourceGitlabURL, args.SourceGitlabToken, args.SourceTokenFile, args.CredentialHelper, in)
+	if err != nil {
+		return err
+	}
+
+	sourceGitlab, err := mirroring.NewGitlabInstance(&mirroring.GitlabInstanceOpts{
+		GitlabURL:    sourceGitlabURL,
+		GitlabToken:  sourceGitlabToken,
+		Transport:    &args.SourceTransport,
+		Role:         mirroring.ROLE_SOURCE,
+		InstanceSize: mirroring.INSTANCE_SIZE_BIG,
+		MaxRetries:   args.Retry,
+	})
+	if err != nil {
+		return err
+	}
+
+	mapping, generationErrors := sourceGitlab.GenerateMirrorMapping(ctx, args.FromGroup, args.DestinationRoot, args.Collapse)
+	if len(generationErrors) > 0 {
+		return errors.Join(generationErrors...)
+	}
+
+	content, err := utils.EncodeMirrorMapping(mapping, format)
+	if err != nil {
+		return err
+	}
+
+	if output == stdoutPath {
+		_, err = out.Write(content)
+		if err != nil {
+			return fmt.Errorf("failed to write mirror mapping: %w", err)
+		}
+
+		return nil
+	}
+
+	err = os.WriteFile(output, content, mappingFilePermission)
+	if err != nil {
+		return fmt.Errorf("failed to write mirror mapping file %s: %w", output, err)
+	}
+
+	_, err = fmt.Fprintf(out, "Wrote %s (%d projects, %d groups)\n", output, len(mapping.Projects), len(mapping.Groups))
+	if err != nil {
+		return fmt.Errorf("failed to print generation result: %w", err)
+	}
+
+	return nil
+}
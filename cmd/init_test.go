@@ -0,0 +1,245 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/boxboxjason/gitlab-sync/internal/mirroring"
+	"github.com/boxboxjason/gitlab-sync/internal/utils"
+)
+
+const testInitToken = "init-token"
+
+// setupInitTestServer starts a GitLab API mock serving the org group, holding the org/api project and the org/infra subgroup.
+// The current user endpoint only accepts the testInitToken token.
+func setupInitTestServer(t *testing.T) string {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	writeJSON := func(pattern, body string) {
+		mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, body)
+		})
+	}
+
+	mux.HandleFunc("/api/v4/user", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != testInitToken {
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id": 1, "username": "testuser"}`)
+	})
+
+	writeJSON("/api/v4/groups/org", `{"id": 1, "name": "Org", "full_path": "org", "visibility": "internal"}`)
+	writeJSON("/api/v4/groups/1/subgroups", `[{"id": 2, "name": "Infra", "full_path": "org/infra", "visibility": "private"}]`)
+	writeJSON("/api/v4/groups/1/projects", `[{"id": 1, "name": "API", "path": "api", "path_with_namespace": "org/api", "visibility": "private"}]`)
+	writeJSON("/api/v4/groups/2/subgroups", `[]`)
+	writeJSON("/api/v4/groups/2/projects", `[]`)
+
+	return server.URL
+}
+
+func TestRunInit(t *testing.T) {
+	tests := []struct {
+		name          string
+		output        string
+		format        string
+		collapse      string
+		existing      bool
+		force         bool
+		expectedError bool
+		expectedCount string
+	}{
+		{
+			name:          "YAML file",
+			output:        "mirror.yaml",
+			collapse:      mirroring.COLLAPSE_NONE,
+			expectedCount: "(1 projects, 2 groups)",
+		},
+		{
+			name:          "TOML file collapsed to groups",
+			output:        "mirror.toml",
+			collapse:      mirroring.COLLAPSE_GROUPS,
+			expectedCount: "(0 projects, 2 groups)",
+		},
+		{
+			name:          "Explicit format",
+			output:        "mirror.conf",
+			format:        "json",
+			collapse:      mirroring.COLLAPSE_ROOT,
+			expectedCount: "(0 projects, 1 groups)",
+		},
+		{
+			name:          "Existing output file",
+			output:        "mirror.json",
+			collapse:      mirroring.COLLAPSE_NONE,
+			existing:      true,
+			expectedError: true,
+		},
+		{
+			name:          "Overwritten output file",
+			output:        "mirror.json",
+			collapse:      mirroring.COLLAPSE_NONE,
+			existing:      true,
+			force:         true,
+			expectedCount: "(1 projects, 2 groups)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			output := filepath.Join(t.TempDir(), tt.output)
+			if tt.existing {
+				if err := os.WriteFile(output, []byte("{}"), 0o600); err != nil {
+					t.Fatalf("failed to write existing output file: %v", err)
+				}
+			}
+
+			var out bytes.Buffer
+
+			err := runInit(t.Context(), &initArgs{
+				SourceGitlabURL: setupInitTestServer(t),
+				FromGroup:       "org",
+				DestinationRoot: "mirror/org",
+				Output:          output,
+				Format:          tt.format,
+				Collapse:        tt.collapse,
+				Force:           tt.force,
+			}, nil, &out)
+			if (err != nil) != tt.expectedError {
+				t.Fatalf("expected error: %v, got: %v", tt.expectedError, err)
+			}
+
+			if tt.expectedError {
+				return
+			}
+
+			if !strings.Contains(out.String(), tt.expectedCount) {
+				t.Errorf("expected output to contain %q, got %q", tt.expectedCount, out.String())
+			}
+
+			// The generated mapping must be valid
+			var validateOut, validateErrOut bytes.Buffer
+			if err := validateMapping(output, &validateOut, &validateErrOut); err != nil {
+				t.Fatalf("expected the generated mapping to be valid, got %v: %s", err, validateErrOut.String())
+			}
+
+			mapping, _ := utils.OpenMirrorMapping(output)
+			if options, ok := mapping.Groups["org"]; !ok || options.DestinationPath != "mirror/org" || *options.Visibility != "internal" {
+				t.Errorf("expected the org group to be mirrored to mirror/org with its source visibility, got %+v", options)
+			}
+		})
+	}
+}
+
+func TestRunInitStdout(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+
+	err := runInit(t.Context(), &initArgs{
+		SourceGitlabURL: setupInitTestServer(t),
+		FromGroup:       "org",
+		DestinationRoot: "mirror/org",
+		Output:          stdoutPath,
+		Format:          utils.MAPPING_FORMAT_YAML,
+		Collapse:        mirroring.COLLAPSE_NONE,
+	}, nil, &out)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, expected := range []string{"org/api:", "destination_path: mirror/org/infra", "visibility: private"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected the generated mapping to contain %q, got:\n%s", expected, out.String())
+		}
+	}
+}
+
+func TestRunInitMissingSourceURL(t *testing.T) {
+	t.Parallel()
+
+	err := runInit(t.Context(), &initArgs{FromGroup: "org", Collapse: mirroring.COLLAPSE_NONE}, nil, &bytes.Buffer{})
+	if err == nil {
+		t.Fatal("expected an error when the source URL is missing")
+	}
+}
+
+func TestRunInitInvalidFormat(t *testing.T) {
+	t.Parallel()
+
+	// The format is rejected before any request is sent to the source instance
+	err := runInit(t.Context(), &initArgs{
+		SourceGitlabURL:   "http://127.0.0.1:0",
+		SourceGitlabToken: testInitToken,
+		FromGroup:         "org",
+		Format:            "xml",
+		Collapse:          mirroring.COLLAPSE_NONE,
+	}, nil, &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "unsupported mirror mapping format") {
+		t.Fatalf("expected an unsupported format error, got %v", err)
+	}
+}
+
+func TestRunInitTokenSources(t *testing.T) {
+	tests := []struct {
+		name          string
+		token         string
+		tokenFile     string
+		stdin         string
+		expectedError bool
+	}{
+		{
+			name:      "Token from the standard input",
+			tokenFile: "-",
+			stdin:     testInitToken + "\n",
+		},
+		{
+			name:  "Token argument",
+			token: testInitToken,
+		},
+		{
+			name:          "Invalid token",
+			token:         "wrong-token",
+			expectedError: true,
+		},
+		{
+			name:          "Empty standard input",
+			tokenFile:     "-",
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := runInit(t.Context(), &initArgs{
+				SourceGitlabURL:   setupInitTestServer(t),
+				SourceGitlabToken: tt.token,
+				SourceTokenFile:   tt.tokenFile,
+				FromGroup:         "org",
+				Output:            stdoutPath,
+				Collapse:          mirroring.COLLAPSE_ROOT,
+			}, strings.NewReader(tt.stdin), &bytes.Buffer{})
+			if (err != nil) != tt.expectedError {
+				t.Fatalf("expected error: %v, got: %v", tt.expectedError, err)
+			}
+		})
+	}
+}
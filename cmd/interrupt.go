@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// interruptibleContext returns the context of a mirroring run, along with the channel closed to stop scheduling new work.
+// The first SIGINT / SIGTERM closes the channel, so the in-flight work is drained, and the second one cancels the context
+// to abort it. The context is also cancelled once the timeout elapses (no limit when zero).
+// The returned function releases the signal handlers.
+func interruptibleContext(timeout time.Duration) (context.Context, <-chan struct{}, context.CancelFunc) {
+	ctx, cancel := runContext(timeout)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	stop := make(chan struct{})
+	go watchInterrupts(ctx, signals, stop, cancel)
+
+	return ctx, stop, func() {
+		signal.Stop(signals)
+		cancel()
+	}
+}
+
+// runContext returns the context of a mirroring run, cancelled once the timeout elapses (no limit when zero).
+func runContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+// watchInterrupts closes stop on the first received signal, and aborts the run on the second one.
+// It returns once the run context is done.
+func watchInterrupts(ctx context.Context, signals <-chan os.Signal, stop chan<- struct{}, abort context.CancelFunc) {
+	select {
+	case received := <-signals:
+		zap.L().Warn("Interrupt received, finishing the in-flight work before stopping (interrupt again to abort it)", zap.String("signal", received.String()))
+		close(stop)
+	case <-ctx.Done():
+		return
+	}
+
+	select {
+	case received := <-signals:
+		zap.L().Warn("Second interrupt received, aborting the in-flight work", zap.String("signal", received.String()))
+		abort()
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+const TEST_INTERRUPT_TIMEOUT = time.Second
+
+func TestWatchInterrupts(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	signals := make(chan os.Signal, 1)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		watchInterrupts(ctx, signals, stop, cancel)
+	}()
+
+	// The first signal stops scheduling new work without aborting the in-flight one
+	signals <- os.Interrupt
+
+	select {
+	case <-stop:
+	case <-time.After(TEST_INTERRUPT_TIMEOUT):
+		t.Fatal("expected the first signal to close the stop channel")
+	}
+
+	if ctx.Err() != nil {
+		t.Fatalf("expected the first signal not to abort the run, got %v", ctx.Err())
+	}
+
+	// The second signal aborts the in-flight work
+	signals <- syscall.SIGTERM
+
+	select {
+	case <-done:
+	case <-time.After(TEST_INTERRUPT_TIMEOUT):
+		t.Fatal("expected the second signal to abort the run")
+	}
+
+	if ctx.Err() == nil {
+		t.Error("expected the second signal to cancel the run context")
+	}
+}
+
+func TestWatchInterruptsRunDone(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	stop := make(chan struct{})
+
+	// Returns without closing the stop channel once the run is over
+	watchInterrupts(ctx, make(chan os.Signal), stop, cancel)
+
+	select {
+	case <-stop:
+		t.Error("expected the stop channel to stay open without a signal")
+	default:
+	}
+}
+
+func TestRunContext(t *testing.T) {
+	tests := []struct {
+		name             string
+		timeout          time.Duration
+		expectedDeadline bool
+	}{
+		{name: "No timeout"},
+		{name: "Timeout", timeout: time.Hour, expectedDeadline: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := runContext(tt.timeout)
+			defer cancel()
+
+			if _, ok := ctx.Deadline(); ok != tt.expectedDeadline {
+				t.Errorf("expected a deadline: %v, got %v", tt.expectedDeadline, ok)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -46,7 +47,11 @@ func Execute() {
 
 	err = rootCmd.Execute()
 	if err != nil {
-		zap.L().Error(err.Error())
+		// The validate command prints its own errors, the init and doctor commands ones are printed by cobra
+		if !errors.Is(err, errInvalidMapping) && !errors.Is(err, errMappingGeneration) && !errors.Is(err, errDoctorFailed) {
+			zap.L().Error(err.Error())
+		}
+
 		os.Exit(1)
 	}
 }
@@ -62,24 +67,59 @@ func buildRootCmd(args *utils.ParserArgs, mirrorMappingPath, logFile *string) *c
 		},
 	}
 
-	rootCmd.Flags().StringVar(&args.SourceGitlabURL, "source-url", os.Getenv("SOURCE_GITLAB_URL"), "Source GitLab URL")
-	rootCmd.Flags().StringVar(&args.SourceGitlabToken, "source-token", os.Getenv("SOURCE_GITLAB_TOKEN"), "Source GitLab Token")
-	rootCmd.Flags().BoolVar(&args.SourceGitlabIsBig, "source-big", strings.TrimSpace(os.Getenv("SOURCE_GITLAB_BIG")) != "", "Source GitLab is a big instance")
-	rootCmd.Flags().StringVar(&args.DestinationGitlabURL, "destination-url", os.Getenv("DESTINATION_GITLAB_URL"), "Destination GitLab URL")
-	rootCmd.Flags().StringVar(&args.DestinationGitlabToken, "destination-token", os.Getenv("DESTINATION_GITLAB_TOKEN"), "Destination GitLab Token")
-	rootCmd.Flags().BoolVar(&args.DestinationGitlabIsBig, "destination-big", strings.TrimSpace(os.Getenv("DESTINATION_GITLAB_BIG")) != "", "Destination GitLab is a big instance")
+	rootCmd.Flags().StringVar(&args.SourceGitlabURL, "source-url", "", "Source GitLab URL")
+	rootCmd.Flags().StringVar(&args.SourceGitlabToken, "source-token", "", "Source GitLab Token")
+	rootCmd.Flags().StringVar(&args.SourceTokenFile, "source-token-file", "", "Path to a file containing the Source GitLab Token, or several tokens one per line (- for the standard input)")
+	rootCmd.Flags().BoolVar(&args.SourceGitlabIsBig, "source-big", false, "Source GitLab is a big instance (shorthand for --source-size big)")
+	rootCmd.Flags().StringVar(&args.SourceGitlabSize, "source-size", mirroring.INSTANCE_SIZE_AUTO, "Source GitLab fetch strategy: auto (estimated from the mirror mapping and the X-Total headers), small (list the mapped namespaces) or big (fetch the mapping entries one by one)")
+	rootCmd.Flags().Float64Var(&args.SourceRateLimit, "source-rate-limit", 0, "Maximum number of API requests per second sent to the Source GitLab (0 for no fixed limit, the rate limit headers are still honored)")
+	rootCmd.Flags().StringVar(&args.DestinationGitlabURL, "destination-url", "", "Destination GitLab URL")
+	rootCmd.Flags().StringVar(&args.DestinationGitlabToken, "destination-token", "", "Destination GitLab Token")
+	rootCmd.Flags().StringVar(&args.DestinationTokenFile, "destination-token-file", "", "Path to a file containing the Destination GitLab Token, or several tokens one per line (- for the standard input)")
+	rootCmd.Flags().StringVar(&args.CredentialHelper, "credential-helper", "", "git style credential helper command the GitLab Tokens are retrieved from")
+	rootCmd.Flags().BoolVar(&args.DestinationGitlabIsBig, "destination-big", false, "Destination GitLab is a big instance (shorthand for --destination-size big)")
+	rootCmd.Flags().StringVar(&args.DestinationGitlabSize, "destination-size", mirroring.INSTANCE_SIZE_AUTO, "Destination GitLab fetch strategy: auto (estimated from the mirror mapping and the X-Total headers), small (list the mapped namespaces) or big (fetch the mapping entries one by one)")
+	rootCmd.Flags().Float64Var(&args.DestinationRateLimit, "destination-rate-limit", 0, "Maximum number of API requests per second sent to the Destination GitLab (0 for no fixed limit, the rate limit headers are still honored)")
 	rootCmd.Flags().BoolVarP(&args.ForcePremium, "destination-force-premium", "p", false, "Force the destination GitLab to be treated as a premium instance")
 	rootCmd.Flags().BoolVarP(&args.ForceNonPremium, "destination-force-freemium", "f", false, "Force the destination GitLab to be treated as a non premium instance")
 	rootCmd.Flags().BoolVarP(&args.Verbose, "verbose", "v", false, "Enable verbose output")
-	rootCmd.Flags().BoolVarP(&args.NoPrompt, "no-prompt", "n", strings.TrimSpace(os.Getenv("NO_PROMPT")) != "", "Disable prompting for missing values")
-	rootCmd.Flags().StringVar(mirrorMappingPath, "mirror-mapping", os.Getenv("MIRROR_MAPPING"), "Path to the mirror mapping file")
+	rootCmd.Flags().BoolVarP(&args.NoPrompt, "no-prompt", "n", false, "Disable prompting for missing values")
+	rootCmd.Flags().StringVar(mirrorMappingPath, "mirror-mapping", "", "Path to the mirror mapping file (JSON, YAML or TOML)")
 	rootCmd.Flags().BoolVar(&args.DryRun, "dry-run", false, "Perform a dry run without making any changes")
 	rootCmd.Flags().IntVarP(&args.Retry, "retry", "r", defaultRetryCount, "Number of retries for failed requests")
-	rootCmd.Flags().StringVar(logFile, "log-file", strings.TrimSpace(os.Getenv("GITLAB_SYNC_LOG_FILE")), "Path to the log file")
-	_ = rootCmd.MarkFlagFilename("mirror-mapping", "json")
+	rootCmd.Flags().StringVar(logFile, "log-file", "", "Path to the log file")
+	rootCmd.Flags().StringVar(&args.StateDir, "state-dir", "", "Directory of the inventory cache kept between runs, to revalidate the namespace listings and skip the updates of the projects unchanged since their last sync")
+	rootCmd.Flags().StringVar(&args.Since, "since", "", "Incremental sync mode (requires --state-dir): last-success skips the projects without source activity since their last successful sync")
+	rootCmd.Flags().StringVar(&args.GitCacheDir, "git-cache-dir", "", "Directory of the bare mirrors kept between runs, to only fetch and push the git updates of the projects")
+	rootCmd.Flags().Var(&args.GitCacheMaxSize, "git-cache-max-size", "Size of the git cache above which the least recently used mirrors are evicted, e.g. 20GiB (0 for no limit)")
+	rootCmd.Flags().BoolVar(&args.Full, "full", false, "Ignore the inventory cache and the watermarks of the state directory, and sync every project")
+	rootCmd.Flags().IntVar(&args.APIConcurrency, "api-concurrency", mirroring.DEFAULT_API_CONCURRENCY, "Maximum number of concurrent GitLab API tasks (fetches and project syncs)")
+	rootCmd.Flags().IntVar(&args.GitConcurrency, "git-concurrency", mirroring.DEFAULT_GIT_CONCURRENCY, "Maximum number of concurrent local git mirrorings (bare clones)")
+	rootCmd.Flags().DurationVar(&args.Timeout, "timeout", 0, "Maximum duration of the mirroring, the in-flight work being aborted once it elapses (0 for no limit)")
+	rootCmd.Flags().DurationVar(&args.ProjectTimeout, "project-timeout", 0, "Maximum duration of each project sync (0 for no limit)")
+	rootCmd.Flags().IntVar(&args.EntityConcurrency, "entity-concurrency", mirroring.DEFAULT_ENTITY_CONCURRENCY, "Maximum number of concurrent issue and release creations")
+	addTransportFlags(rootCmd.Flags(), "source", "Source GitLab", &args.SourceTransport)
+	addTransportFlags(rootCmd.Flags(), "destination", "Destination GitLab", &args.DestinationTransport)
+	_ = rootCmd.MarkFlagFilename("mirror-mapping", "json", "yaml", "yml", "toml")
 	_ = rootCmd.MarkFlagFilename("log-file", "log", "txt")
+	_ = rootCmd.MarkFlagFilename("source-token-file")
+	_ = rootCmd.MarkFlagFilename("destination-token-file")
+	_ = rootCmd.MarkFlagDirname("state-dir")
+	_ = rootCmd.MarkFlagDirname("git-cache-dir")
+	_ = rootCmd.RegisterFlagCompletionFunc("source-size", cobra.FixedCompletions(mirroring.InstanceSizes, cobra.ShellCompDirectiveNoFileComp))
+	_ = rootCmd.RegisterFlagCompletionFunc("destination-size", cobra.FixedCompletions(mirroring.InstanceSizes, cobra.ShellCompDirectiveNoFileComp))
+	_ = rootCmd.RegisterFlagCompletionFunc("since", cobra.FixedCompletions([]string{mirroring.SINCE_LAST_SUCCESS}, cobra.ShellCompDirectiveNoFileComp))
+	annotateEnvUsage(rootCmd.Flags())
+
+	// Settings not set on the command line are resolved from the environment, then from the configuration profile
+	config := &configOptions{}
+	addConfigFlags(rootCmd, config)
 
 	addCompletionCommand(rootCmd)
+	addMappingCommands(rootCmd)
+	addInitCommand(rootCmd)
+	addDoctorCommand(rootCmd, args, mirrorMappingPath, logFile)
+	addConfigCommands(rootCmd, config)
 
 	return rootCmd
 }
@@ -127,8 +167,59 @@ func executeMirroringCommand(args *utils.ParserArgs, mirrorMappingPath, logFile
 		zap.L().Fatal("retry count must be -1 (no limit) or strictly greater than 0")
 	}
 
+	for _, size := range []string{args.SourceGitlabSize, args.DestinationGitlabSize} {
+		if err := mirroring.ValidateInstanceSize(size); err != nil {
+			zap.L().Fatal("Invalid instance size", zap.Error(err))
+		}
+	}
+
+	concurrencies := []struct {
+		name  string
+		value int
+	}{{"api-concurrency", args.APIConcurrency}, {"git-concurrency", args.GitConcurrency}, {"entity-concurrency", args.EntityConcurrency}}
+	for _, concurrency := range concurrencies {
+		if err := mirroring.ValidateConcurrency(concurrency.name, concurrency.value); err != nil {
+			zap.L().Fatal("Invalid concurrency", zap.Error(err))
+		}
+	}
+
+	if args.Timeout < 0 || args.ProjectTimeout < 0 {
+		zap.L().Fatal("The timeouts must be positive durations, or 0 for no limit")
+	}
+
+	if args.SourceRateLimit < 0 || args.DestinationRateLimit < 0 {
+		zap.L().Fatal("The rate limits must be positive numbers of requests per second, or 0 for no fixed limit")
+	}
+
+	if err := mirroring.ValidateSince(args.Since); err != nil {
+		zap.L().Fatal("Invalid incremental sync mode", zap.Error(err))
+	} else if args.Since != "" && args.StateDir == "" {
+		zap.L().Fatal("The --since mode requires a --state-dir to record the project watermarks")
+	}
+
+	if args.GitCacheMaxSize > 0 && args.GitCacheDir == "" {
+		zap.L().Fatal("The --git-cache-max-size setting requires a --git-cache-dir to keep the bare mirrors in")
+	}
+
 	args.SourceGitlabURL = promptForMandatoryInput(args.SourceGitlabURL, "Input Source GitLab URL (MANDATORY)", "Source GitLab URL is mandatory", "Source GitLab URL", args.NoPrompt, false)
 	args.DestinationGitlabURL = promptForMandatoryInput(args.DestinationGitlabURL, "Input Destination GitLab URL (MANDATORY)", "Destination GitLab URL is mandatory", "Destination GitLab URL", args.NoPrompt, false)
+
+	// Resolve the tokens from their files, the standard input or the credential helper
+	err := checkTokenFiles(args.SourceTokenFile, args.DestinationTokenFile)
+	if err != nil {
+		zap.L().Fatal("Invalid token files", zap.Error(err))
+	}
+
+	args.SourceGitlabToken, err = resolveGitlabToken(args.SourceGitlabURL, args.SourceGitlabToken, args.SourceTokenFile, args.CredentialHelper, os.Stdin)
+	if err != nil {
+		zap.L().Fatal("Failed to resolve the Source GitLab Token", zap.Error(err))
+	}
+
+	args.DestinationGitlabToken, err = resolveGitlabToken(args.DestinationGitlabURL, args.DestinationGitlabToken, args.DestinationTokenFile, args.CredentialHelper, os.Stdin)
+	if err != nil {
+		zap.L().Fatal("Failed to resolve the Destination GitLab Token", zap.Error(err))
+	}
+
 	args.DestinationGitlabToken = promptForMandatoryInput(args.DestinationGitlabToken, "Input Destination GitLab Token with api permissions (MANDATORY)", "Destination GitLab Token is mandatory", "Destination GitLab Token set", args.NoPrompt, true)
 
 	*mirrorMappingPath = promptForMandatoryInput(*mirrorMappingPath, "Input Mirror Mapping file path (MANDATORY)", "Mirror Mapping file path is mandatory", "Mirror Mapping file path set", args.NoPrompt, false)
@@ -143,7 +234,10 @@ func executeMirroringCommand(args *utils.ParserArgs, mirrorMappingPath, logFile
 
 	args.MirrorMapping = mapping
 
-	mirroringErrors := mirroring.MirrorGitlabs(args)
+	ctx, stop, cancel := interruptibleContext(args.Timeout)
+	mirroringErrors := mirroring.MirrorGitlabs(ctx, stop, args)
+
+	cancel()
 	if mirroringErrors == nil {
 		zap.L().Info("Mirroring completed successfully")
 
@@ -190,7 +284,7 @@ func promptForInput(prompt string) string {
 
 // promptForMandatoryInput prompts the user for mandatory input and returns the trimmed response.
 // If the input is empty, it will log a fatal error message and exit the program.
-// It also logs the input value if hideOutput is false.
+// It also logs the input value if hideOutput is false, hidden inputs are not echoed to the terminal.
 func promptForMandatoryInput(defaultValue, prompt, errorMsg, loggerMsg string, promptsDisabled, hideOutput bool) string {
 	input := strings.TrimSpace(defaultValue)
 	if input != "" {
@@ -201,7 +295,12 @@ func promptForMandatoryInput(defaultValue, prompt, errorMsg, loggerMsg string, p
 		zap.L().Fatal("Prompting is disabled")
 	}
 
-	input = promptForInput(prompt)
+	if hideOutput {
+		input = promptForSecretInput(prompt)
+	} else {
+		input = promptForInput(prompt)
+	}
+
 	if input == "" {
 		zap.L().Fatal(errorMsg)
 	}
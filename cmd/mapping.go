@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/boxboxjason/gitlab-sync/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+// errInvalidMapping is returned by the validate command when the mirror mapping is invalid.
+var errInvalidMapping = errors.New("invalid mirror mapping")
+
+// addMappingCommands adds the offline mirror mapping commands (validate, schema) to the root command.
+func addMappingCommands(rootCmd *cobra.Command) {
+	validateCmd := &cobra.Command{
+		Use:   "validate <mapping>",
+		Short: "Validate a mirror mapping file offline",
+		Long: "Validate a mirror mapping file (and the files it includes) without any token or network access.\n" +
+			"Every error is printed along with the JSON path of the faulty entry.",
+		Args:          cobra.ExactArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, cmdArgs []string) error {
+			return validateMapping(cmdArgs[0], cmd.OutOrStdout(), cmd.ErrOrStderr())
+		},
+	}
+	validateCmd.ValidArgsFunction = func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"json", "yaml", "yml", "toml"}, cobra.ShellCompDirectiveFilterFileExt
+	}
+
+	schemaCmd := &cobra.Command{
+		Use:   "schema",
+		Short: "Print the JSON Schema of the mirror mapping files",
+		Long: "Print the JSON Schema of the mirror mapping files, so editors can validate and autocomplete them.\n" +
+			"Reference it with the \"$schema\" field of JSON mappings, or a yaml-language-server comment in YAML mappings.",
+		Args:                  cobra.NoArgs,
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, cmdArgs []string) error {
+			return writeMappingSchema(cmd.OutOrStdout())
+		},
+	}
+
+	rootCmd.AddCommand(validateCmd, schemaCmd)
+}
+
+// validateMapping opens and checks a mirror mapping file.
+// The errors are written to errOut, prefixed with the JSON path of the faulty entry.
+func validateMapping(path string, out, errOut io.Writer) error {
+	mapping, mappingErrors := utils.OpenMirrorMapping(path)
+	if len(mappingErrors) > 0 {
+		for _, mappingError := range mappingErrors {
+			_, err := fmt.Fprintf(errOut, "%s: %v\n", utils.MappingErrorPath(mappingError), mappingError)
+			if err != nil {
+				return fmt.Errorf("failed to print validation errors: %w", err)
+			}
+		}
+
+		return fmt.Errorf("%w: %d error(s) found in %s", errInvalidMapping, len(mappingErrors), path)
+	}
+
+	_, err := fmt.Fprintf(out, "%s is valid (%d projects, %d groups, %d project patterns, %d group patterns)\n",
+		path, len(mapping.Projects), len(mapping.Groups), len(mapping.Patterns(utils.PROJECT)), len(mapping.Patterns(utils.GROUP)))
+	if err != nil {
+		return fmt.Errorf("failed to print validation result: %w", err)
+	}
+
+	return nil
+}
+
+// writeMappingSchema writes the indented JSON Schema of the mirror mapping files.
+func writeMappingSchema(out io.Writer) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+
+	err := encoder.Encode(utils.MappingSchema())
+	if err != nil {
+		return fmt.Errorf("failed to write mirror mapping schema: %w", err)
+	}
+
+	return nil
+}
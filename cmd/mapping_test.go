@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/boxboxjason/gitlab-sync/internal/utils"
+)
+
+func TestValidateMapping(t *testing.T) {
+	tests := []struct {
+		name             string
+		fileName         string
+		content          string
+		expectedError    bool
+		expectedMessages []string
+	}{
+		{
+			name:             "Valid mapping",
+			fileName:         "mirror.yaml",
+			content:          "groups:\n  org:\n    destination_path: mirror/org\n  org/*-charts:\n    destination_path: charts/{name}\n",
+			expectedMessages: []string{"is valid (0 projects, 1 groups, 0 project patterns, 1 group patterns)"},
+		},
+		{
+			name:          "Unknown field",
+			fileName:      "mirror.json",
+			content:       `{"groups": {"org": {"destination_path": "mirror/org", "mirror_issue": true}}}`,
+			expectedError: true,
+			expectedMessages: []string{
+				`$.groups["org"].mirror_issue: unknown field mirror_issue`,
+			},
+		},
+		{
+			name:          "Invalid entries",
+			fileName:      "mirror.toml",
+			content:       "[groups.org]\ndestination_path = \"mirror/org\"\nvisibility = \"secret\"\n\n[projects.\"org/api\"]\ndestination_path = \"api\"\n",
+			expectedError: true,
+			expectedMessages: []string{
+				`$.groups["org"].visibility: invalid group visibility: secret`,
+				`$.projects["org/api"].destination_path: invalid project destination path (must be in a namespace): api`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), tt.fileName)
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatalf("failed to write mapping file: %v", err)
+			}
+
+			var out, errOut bytes.Buffer
+
+			err := validateMapping(path, &out, &errOut)
+			if (err != nil) != tt.expectedError {
+				t.Fatalf("expected error: %v, got: %v", tt.expectedError, err)
+			}
+
+			if err != nil && !errors.Is(err, errInvalidMapping) {
+				t.Errorf("expected an invalid mapping error, got %v", err)
+			}
+
+			output := out.String() + errOut.String()
+			for _, message := range tt.expectedMessages {
+				if !strings.Contains(output, message) {
+					t.Errorf("expected output to contain %q, got %q", message, output)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteMappingSchema(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	if err := writeMappingSchema(&out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var schema map[string]any
+	if err := json.Unmarshal(out.Bytes(), &schema); err != nil {
+		t.Fatalf("expected a JSON document, got %v", err)
+	}
+
+	properties, ok := schema["properties"].(map[string]any)
+	if !ok {
+		t.Fatalf("expected schema properties, got %v", schema)
+	}
+
+	for _, property := range []string{"projects", "groups", "rewrite", "include", "$schema"} {
+		if _, ok := properties[property]; !ok {
+			t.Errorf("expected schema property %s", property)
+		}
+	}
+}
+
+func TestBuildRootCmdMappingCommands(t *testing.T) {
+	t.Parallel()
+
+	var (
+		args              utils.ParserArgs
+		mirrorMappingPath string
+		logFile           string
+	)
+
+	rootCmd := buildRootCmd(&args, &mirrorMappingPath, &logFile)
+	for _, name := range []string{"validate", "schema", "init", "doctor"} {
+		if command, _, err := rootCmd.Find([]string{name}); err != nil || command.Name() != name {
+			t.Errorf("expected the %s command to be registered, got %v", name, err)
+		}
+	}
+}
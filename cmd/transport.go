@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"github.com/boxboxjason/gitlab-sync/pkg/helpers"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// addTransportFlags adds the TLS and proxy flags of a GitLab instance, prefixed with its role (source or destination).
+// The label names the instance in the flag descriptions (Source GitLab).
+func addTransportFlags(flags *pflag.FlagSet, role, label string, opts *helpers.TransportOptions) {
+	flags.StringVar(&opts.CAFile, role+"-ca-file", "", "Path to a PEM bundle of certificate authorities trusted for the "+label)
+	flags.StringVar(&opts.ClientCertFile, role+"-client-cert", "", "Path to the PEM client certificate used to authenticate to the "+label)
+	flags.StringVar(&opts.ClientKeyFile, role+"-client-key", "", "Path to the PEM client key used to authenticate to the "+label)
+	flags.BoolVar(&opts.InsecureSkipVerify, role+"-insecure-skip-verify", false, "Skip the TLS certificate verification of the "+label+" (insecure)")
+	flags.StringVar(&opts.ProxyURL, role+"-proxy", "", "Proxy URL used to reach the "+label+" (defaults to the HTTP_PROXY / HTTPS_PROXY environment variables)")
+
+	for _, name := range []string{role + "-ca-file", role + "-client-cert", role + "-client-key"} {
+		_ = cobra.MarkFlagFilename(flags, name, "pem", "crt", "key")
+	}
+}
@@ -0,0 +1,232 @@
+package mirroring
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/boxboxjason/gitlab-sync/internal/utils"
+
+	"github.com/Masterminds/semver/v3"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+	"go.uber.org/zap"
+)
+
+// Capability is an optional GitLab feature the mirroring relies on.
+type Capability string
+
+const (
+	// CICD_CATALOG_SEMVER_THRESHOLD is the minimal destination GitLab version supporting the CI/CD catalog mirroring.
+	CICD_CATALOG_SEMVER_THRESHOLD = "19.3"
+
+	// CAPABILITY_PULL_MIRROR is the project pull mirror API, letting the destination instance mirror the repositories itself.
+	CAPABILITY_PULL_MIRROR Capability = "pull mirror API"
+	// CAPABILITY_CICD_CATALOG is the cicd_catalog_enabled project attribute, adding projects to the CI/CD catalog.
+	CAPABILITY_CICD_CATALOG Capability = "cicd_catalog_enabled project attribute"
+	// CAPABILITY_ISSUE_CREATED_AT is the created_at attribute of the created issues, keeping their source creation date.
+	CAPABILITY_ISSUE_CREATED_AT Capability = "created_at attribute on issues"
+)
+
+// capabilityRequirement describes what a capability requires from a GitLab instance
+// - min_version: the minimal GitLab version
+// - fallback: what the mirroring does when the capability is not supported
+// - premium: whether a Premium (or Ultimate) license is required
+// - owner: whether administrator rights, or the Owner role on the destination namespaces, are required.
+type capabilityRequirement struct {
+	minVersion string
+	fallback   string
+	premium    bool
+	owner      bool
+}
+
+// capabilityRequirements lists the requirements of every capability.
+var capabilityRequirements = map[Capability]capabilityRequirement{
+	CAPABILITY_PULL_MIRROR: {
+		minVersion: INSTANCE_SEMVER_THRESHOLD,
+		premium:    true,
+		fallback:   "repositories are pulled and pushed from this machine instead (takes a lot longer)",
+	},
+	CAPABILITY_CICD_CATALOG: {
+		minVersion: CICD_CATALOG_SEMVER_THRESHOLD,
+		fallback:   "projects are not added to the CI/CD catalog",
+	},
+	CAPABILITY_ISSUE_CREATED_AT: {
+		owner:    true,
+		fallback: "issues are created with the current date",
+	},
+}
+
+// Capabilities holds the capabilities of a GitLab instance, resolved once from its version, license and user.
+// The reasons of the unsupported capabilities are kept to explain the degraded behaviors.
+type Capabilities struct {
+	Version     *semver.Version
+	unsupported map[Capability]string
+	warned      sync.Map
+	Premium     bool
+	Owner       bool
+}
+
+// NewCapabilities resolves the capabilities of a GitLab instance.
+// A nil version (unknown) disables the version dependent capabilities.
+// owner is set when the user is an administrator, or owns the destination namespaces.
+func NewCapabilities(version *semver.Version, premium, owner bool) *Capabilities {
+	capabilities := &Capabilities{
+		Version:     version,
+		Premium:     premium,
+		Owner:       owner,
+		unsupported: make(map[Capability]string),
+	}
+
+	for capability, requirement := range capabilityRequirements {
+		if reason := requirement.unmet(version, premium, owner); reason != "" {
+			capabilities.unsupported[capability] = reason
+		}
+	}
+
+	return capabilities
+}
+
+// unmet returns why the requirement is not met by the instance, empty if it is.
+func (r capabilityRequirement) unmet(version *semver.Version, premium, owner bool) string {
+	var reasons []string
+
+	if r.minVersion != "" {
+		switch {
+		case version == nil:
+			reasons = append(reasons, "requires GitLab >= "+r.minVersion+" (unknown version)")
+		case version.LessThan(semver.MustParse(r.minVersion)):
+			reasons = append(reasons, "requires GitLab >= "+r.minVersion+" (found "+version.Original()+")")
+		}
+	}
+
+	if r.premium && !premium {
+		reasons = append(reasons, "requires a Premium or Ultimate license")
+	}
+
+	if r.owner && !owner {
+		reasons = append(reasons, "requires administrator rights, or the Owner role on the destination namespaces")
+	}
+
+	return strings.Join(reasons, ", ")
+}
+
+// Supports checks if the capability is supported.
+func (c *Capabilities) Supports(capability Capability) bool {
+	_, unsupported := c.unsupported[capability]
+
+	return !unsupported
+}
+
+// Reason returns why the capability is not supported, along with the resulting degraded behavior.
+// It returns an empty string for supported capabilities.
+func (c *Capabilities) Reason(capability Capability) string {
+	reason, unsupported := c.unsupported[capability]
+	if !unsupported {
+		return ""
+	}
+
+	return reason + ", " + capabilityRequirements[capability].fallback
+}
+
+// resolvePremium combines the detected license with the --destination-force-premium / --destination-force-freemium flags.
+func resolvePremium(isPremium, forcePremium, forceNonPremium bool) bool {
+	return !forceNonPremium && (isPremium || forcePremium)
+}
+
+// LoadCapabilities resolves the instance capabilities from its metadata and license, once before the sync steps.
+// The license may be forced, since reading it requires administrator rights.
+// Failing lookups do not stop the mirroring: the capabilities depending on them are disabled,
+// and the lookup errors are returned to be reported as warnings.
+// The Owner role is read on the destination root namespaces of the mirror mapping (administrators owning every namespace),
+// the requests relying on it still fall back to the degraded behavior when a nested namespace rejects them.
+func (g *GitlabInstance) LoadCapabilities(ctx context.Context, forcePremium, forceNonPremium bool, mirrorMapping *utils.MirrorMapping) error {
+	var errs []error
+
+	version, err := g.GetVersion()
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	isPremium := false
+
+	if !forcePremium && !forceNonPremium {
+		isPremium, err = g.IsLicensePremium()
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	owner := g.IsAdmin || g.ownsRootNamespaces(ctx, mirrorMapping)
+	g.Capabilities = NewCapabilities(version, resolvePremium(isPremium, forcePremium, forceNonPremium), owner)
+
+	return errors.Join(errs...)
+}
+
+// ownsRootNamespaces checks if the user has the Owner role on every destination root namespace of the mirror mapping.
+// The missing top level groups are created by the mirroring, their creator owning them.
+func (g *GitlabInstance) ownsRootNamespaces(ctx context.Context, mirrorMapping *utils.MirrorMapping) bool {
+	rootNamespaces := destinationRootNamespaces(mirrorMapping)
+	if len(rootNamespaces) == 0 {
+		return false
+	}
+
+	for namespacePath, topLevel := range rootNamespaces {
+		namespace, _, err := g.Gitlab.Namespaces.GetNamespace(namespacePath, gitlab.WithContext(ctx))
+		if err != nil {
+			if topLevel && errors.Is(err, gitlab.ErrNotFound) {
+				continue
+			}
+
+			return false
+		}
+
+		if g.namespaceAccessLevel(ctx, namespace) < gitlab.OwnerPermissions {
+			return false
+		}
+	}
+
+	return true
+}
+
+// namespaceAccessLevel returns the access level of the user on a namespace.
+// Administrators and owners of their personal namespace are considered owners.
+func (g *GitlabInstance) namespaceAccessLevel(ctx context.Context, namespace *gitlab.Namespace) gitlab.AccessLevelValue {
+	if g.IsAdmin {
+		return gitlab.OwnerPermissions
+	}
+
+	if namespace.Kind != "group" {
+		if namespace.FullPath == g.Username {
+			return gitlab.OwnerPermissions
+		}
+
+		return gitlab.NoPermissions
+	}
+
+	member, _, err := g.Gitlab.GroupMembers.GetInheritedGroupMember(namespace.ID, g.UserID, gitlab.WithContext(ctx))
+	if err != nil {
+		return gitlab.NoPermissions
+	}
+
+	return member.AccessLevel
+}
+
+// Supports checks if the instance supports the capability, instances without loaded capabilities supporting none.
+// The first check of an unsupported capability logs a warning describing the degraded behavior,
+// so that the sync steps skip the feature instead of failing for every project.
+func (g *GitlabInstance) Supports(capability Capability) bool {
+	if g.Capabilities == nil {
+		return false
+	}
+
+	if g.Capabilities.Supports(capability) {
+		return true
+	}
+
+	if _, warned := g.Capabilities.warned.LoadOrStore(capability, struct{}{}); !warned {
+		zap.L().Warn("GitLab instance does not support "+string(capability), zap.String(ROLE, g.Role), zap.String("reason", g.Capabilities.Reason(capability)))
+	}
+
+	return false
+}
@@ -0,0 +1,311 @@
+package mirroring
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/boxboxjason/gitlab-sync/internal/utils"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+func TestNewCapabilities(t *testing.T) {
+	tests := []struct {
+		name        string
+		version     *semver.Version
+		premium     bool
+		owner       bool
+		supported   []Capability
+		unsupported map[Capability]string
+	}{
+		{
+			name:      "Recent premium instance as administrator or owner",
+			version:   semver.MustParse("19.4.0"),
+			premium:   true,
+			owner:     true,
+			supported: []Capability{CAPABILITY_PULL_MIRROR, CAPABILITY_CICD_CATALOG, CAPABILITY_ISSUE_CREATED_AT},
+		},
+		{
+			name:      "Premium instance without the CI/CD catalog",
+			version:   semver.MustParse("18.0.0"),
+			premium:   true,
+			supported: []Capability{CAPABILITY_PULL_MIRROR},
+			unsupported: map[Capability]string{
+				CAPABILITY_CICD_CATALOG:     "requires GitLab >= " + CICD_CATALOG_SEMVER_THRESHOLD + " (found 18.0.0)",
+				CAPABILITY_ISSUE_CREATED_AT: "requires administrator rights, or the Owner role",
+			},
+		},
+		{
+			name:    "Old free instance",
+			version: semver.MustParse("17.0.0"),
+			owner:   true,
+			unsupported: map[Capability]string{
+				CAPABILITY_PULL_MIRROR:  "requires GitLab >= " + INSTANCE_SEMVER_THRESHOLD + " (found 17.0.0), requires a Premium or Ultimate license",
+				CAPABILITY_CICD_CATALOG: "requires GitLab >= " + CICD_CATALOG_SEMVER_THRESHOLD,
+			},
+			supported: []Capability{CAPABILITY_ISSUE_CREATED_AT},
+		},
+		{
+			name:    "Unknown version",
+			version: nil,
+			premium: true,
+			unsupported: map[Capability]string{
+				CAPABILITY_PULL_MIRROR:  "unknown version",
+				CAPABILITY_CICD_CATALOG: "unknown version",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			capabilities := NewCapabilities(tt.version, tt.premium, tt.owner)
+
+			for _, capability := range tt.supported {
+				if !capabilities.Supports(capability) {
+					t.Errorf("expected %s to be supported, got reason %q", capability, capabilities.Reason(capability))
+				}
+
+				if reason := capabilities.Reason(capability); reason != "" {
+					t.Errorf("expected no reason for supported %s, got %q", capability, reason)
+				}
+			}
+
+			for capability, expectedReason := range tt.unsupported {
+				if capabilities.Supports(capability) {
+					t.Errorf("expected %s to be unsupported", capability)
+				}
+
+				reason := capabilities.Reason(capability)
+				if !strings.Contains(reason, expectedReason) || !strings.HasSuffix(reason, capabilityRequirements[capability].fallback) {
+					t.Errorf("expected reason of %s to contain %q and the fallback, got %q", capability, expectedReason, reason)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadCapabilities(t *testing.T) {
+	const supportedVersion = "18.0.0"
+	const unsupportedVersion = "17.0.0"
+	tests := []struct {
+		name            string
+		licensePlan     string
+		version         string
+		expectedError   bool
+		expectedResult  bool
+		forcePremium    bool
+		forceNonPremium bool
+	}{
+		{
+			name:           "Premium license, good version",
+			licensePlan:    PREMIUM_PLAN,
+			version:        supportedVersion,
+			expectedResult: true,
+		},
+		{
+			name:           "Ultimate license, good version",
+			licensePlan:    ULTIMATE_PLAN,
+			version:        supportedVersion,
+			expectedResult: true,
+		},
+		{
+			name:           "Free license, good version",
+			licensePlan:    "free",
+			version:        supportedVersion,
+			expectedResult: false,
+		},
+		{
+			name:           "Free license, good version, force premium",
+			licensePlan:    "free",
+			version:        supportedVersion,
+			expectedResult: true,
+			forcePremium:   true,
+		},
+		{
+			name:            "Premium license, good version, force non premium",
+			licensePlan:     PREMIUM_PLAN,
+			version:         supportedVersion,
+			expectedResult:  false,
+			forceNonPremium: true,
+		},
+		{
+			name:           "Premium license, threshold version",
+			licensePlan:    PREMIUM_PLAN,
+			version:        INSTANCE_SEMVER_THRESHOLD + ".0",
+			expectedResult: true,
+		},
+		{
+			name:           "Premium license, good version with suffix",
+			licensePlan:    PREMIUM_PLAN,
+			version:        "17.9.3-ce.0",
+			expectedResult: true,
+		},
+		{
+			name:           "Premium license, invalid version",
+			licensePlan:    PREMIUM_PLAN,
+			version:        "invalid.version",
+			expectedError:  true,
+			expectedResult: false,
+		},
+		{
+			name:           "Unreadable license, good version",
+			version:        supportedVersion,
+			expectedError:  true,
+			expectedResult: false,
+		},
+		{
+			name:           "Unreadable license, good version, force premium",
+			version:        supportedVersion,
+			forcePremium:   true,
+			expectedResult: true,
+		},
+		{
+			name:           "Premium license, bad version",
+			licensePlan:    PREMIUM_PLAN,
+			version:        unsupportedVersion,
+			expectedResult: false,
+		},
+		{
+			name:           "Ultimate license, bad version",
+			licensePlan:    ULTIMATE_PLAN,
+			version:        unsupportedVersion,
+			expectedResult: false,
+		},
+		{
+			name:           "Bad license, good version",
+			licensePlan:    "bad_license",
+			version:        supportedVersion,
+			expectedResult: false,
+		},
+		{
+			name:           "Bad license, bad version",
+			licensePlan:    "bad_license",
+			version:        unsupportedVersion,
+			expectedResult: false,
+		},
+		{
+			name:           "Error API response",
+			licensePlan:    "",
+			version:        "",
+			expectedError:  true,
+			expectedResult: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			mux, gitlabInstance := setupEmptyTestServer(t, ROLE_DESTINATION, INSTANCE_SIZE_SMALL)
+			if tt.licensePlan != "" {
+				mux.HandleFunc("/api/v4/license", func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(200)
+					w.Write([]byte(`{"plan": "` + tt.licensePlan + `", "expired": false}`))
+				})
+			}
+			if tt.version != "" {
+				mux.HandleFunc("/api/v4/metadata", func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(200)
+					w.Write([]byte(`{"version": "` + tt.version + `"}`))
+				})
+			}
+
+			err := gitlabInstance.LoadCapabilities(t.Context(), tt.forcePremium, tt.forceNonPremium, &utils.MirrorMapping{})
+			if (err != nil) != tt.expectedError {
+				t.Fatalf(EXPECTED_ERROR_MESSAGE, tt.expectedError, err)
+			}
+
+			if gitlabInstance.Capabilities == nil {
+				t.Fatal("expected the capabilities to be loaded, even when the lookups fail")
+			}
+
+			if pullMirrorAvailable := gitlabInstance.Supports(CAPABILITY_PULL_MIRROR); pullMirrorAvailable != tt.expectedResult {
+				t.Errorf("expected pull mirror support %v, got %v", tt.expectedResult, pullMirrorAvailable)
+			}
+
+			// A mapping without destination namespace grants no Owner role
+			if gitlabInstance.Supports(CAPABILITY_ISSUE_CREATED_AT) {
+				t.Error("expected the issue creation dates not to be supported without the Owner role")
+			}
+		})
+	}
+}
+
+func TestLoadCapabilitiesOwner(t *testing.T) {
+	tests := []struct {
+		name            string
+		destinationPath string
+		accessLevel     int
+		admin           bool
+		expectedOwner   bool
+	}{
+		{name: "Owner of the root namespace", destinationPath: "mirror/org", accessLevel: 50, expectedOwner: true},
+		{name: "Maintainer of the root namespace", destinationPath: "mirror/org", accessLevel: 40, expectedOwner: false},
+		{name: "Administrator", destinationPath: "mirror/org", accessLevel: 30, admin: true, expectedOwner: true},
+		{name: "Missing root namespace", destinationPath: "missing/org", expectedOwner: false},
+		{name: "Missing top level group, created by the mirroring", destinationPath: "top", expectedOwner: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mux, gitlabInstance := setupEmptyTestServer(t, ROLE_DESTINATION, INSTANCE_SIZE_SMALL)
+			gitlabInstance.IsAdmin = tt.admin
+
+			mux.HandleFunc("/api/v4/namespaces/mirror", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set(HEADER_CONTENT_TYPE, HEADER_ACCEPT)
+				fmt.Fprint(w, `{"id": 10, "path": "mirror", "kind": "group", "full_path": "mirror"}`)
+			})
+			mux.HandleFunc("/api/v4/groups/10/members/all/1", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set(HEADER_CONTENT_TYPE, HEADER_ACCEPT)
+				fmt.Fprintf(w, `{"id": 1, "username": "testuser", "access_level": %d}`, tt.accessLevel)
+			})
+
+			mirrorMapping := &utils.MirrorMapping{
+				Groups: map[string]*utils.MirroringOptions{"org": {DestinationPath: tt.destinationPath}},
+			}
+
+			// The version and license lookups fail, only the Owner role matters here
+			_ = gitlabInstance.LoadCapabilities(t.Context(), false, false, mirrorMapping)
+
+			if gitlabInstance.Capabilities.Owner != tt.expectedOwner {
+				t.Errorf("expected owner %v, got %v", tt.expectedOwner, gitlabInstance.Capabilities.Owner)
+			}
+
+			if supported := gitlabInstance.Supports(CAPABILITY_ISSUE_CREATED_AT); supported != tt.expectedOwner {
+				t.Errorf("expected the issue creation dates support to be %v, got %v", tt.expectedOwner, supported)
+			}
+		})
+	}
+}
+
+func TestGitlabInstanceSupports(t *testing.T) {
+	t.Parallel()
+
+	gitlabInstance := &GitlabInstance{Role: ROLE_DESTINATION}
+	if gitlabInstance.Supports(CAPABILITY_PULL_MIRROR) {
+		t.Error("expected an instance without loaded capabilities to support nothing")
+	}
+
+	gitlabInstance.Capabilities = NewCapabilities(semver.MustParse("19.4.0"), false, false)
+	if !gitlabInstance.Supports(CAPABILITY_CICD_CATALOG) {
+		t.Error("expected the CI/CD catalog to be supported")
+	}
+
+	for range 2 {
+		if gitlabInstance.Supports(CAPABILITY_PULL_MIRROR) {
+			t.Error("expected the pull mirror to be unsupported without a premium license")
+		}
+	}
+
+	if _, warned := gitlabInstance.Capabilities.warned.Load(CAPABILITY_PULL_MIRROR); !warned {
+		t.Error("expected the unsupported capability warning to be recorded")
+	}
+
+	if _, warned := gitlabInstance.Capabilities.warned.Load(CAPABILITY_CICD_CATALOG); warned {
+		t.Error("expected no warning for a supported capability")
+	}
+}
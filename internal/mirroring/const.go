@@ -14,4 +14,15 @@ const (
 	INSTANCE_SIZE_SMALL = "small"
 	// INSTANCE_SIZE_BIG is the identifier for big instances.
 	INSTANCE_SIZE_BIG = "big"
+	// INSTANCE_SIZE_AUTO is the identifier for instances whose size is estimated before fetching.
+	INSTANCE_SIZE_AUTO = "auto"
+)
+
+const (
+	// COLLAPSE_NONE generates an explicit mapping entry for every group and project of the tree.
+	COLLAPSE_NONE = "none"
+	// COLLAPSE_GROUPS generates an explicit mapping entry for every group of the tree, projects are inherited.
+	COLLAPSE_GROUPS = "groups"
+	// COLLAPSE_ROOT generates a single mapping entry for the root group, its whole tree is inherited.
+	COLLAPSE_ROOT = "root"
 )
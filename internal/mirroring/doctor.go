@@ -0,0 +1,353 @@
+package mirroring
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/boxboxjason/gitlab-sync/internal/utils"
+	"github.com/boxboxjason/gitlab-sync/pkg/helpers"
+
+	"github.com/Masterminds/semver/v3"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+const (
+	// DOCTOR_PASS is the status of a successful preflight check.
+	DOCTOR_PASS = "PASS"
+	// DOCTOR_INFO is the status of an informational preflight check.
+	DOCTOR_INFO = "INFO"
+	// DOCTOR_WARN is the status of a preflight check that will not make the mirroring fail, but deserves attention.
+	DOCTOR_WARN = "WARN"
+	// DOCTOR_FAIL is the status of a failed preflight check.
+	DOCTOR_FAIL = "FAIL"
+
+	// API_SCOPE is the token scope granting complete read / write access to the API and the repositories.
+	API_SCOPE = "api"
+
+	tokenExpiryWarningDelay = 7 * 24 * time.Hour
+	doctorDateLayout        = "2006-01-02"
+)
+
+// DoctorCheck is the result of a preflight check
+// - name: what was checked
+// - status: PASS, INFO, WARN or FAIL
+// - detail: the observed value, or the reason of the failure.
+type DoctorCheck struct {
+	Name   string
+	Status string
+	Detail string
+}
+
+// DoctorSection groups the preflight checks of a GitLab instance, or the feature verdicts.
+type DoctorSection struct {
+	Title  string
+	Checks []*DoctorCheck
+}
+
+// DoctorReport is the result of the doctor preflight checks.
+type DoctorReport struct {
+	Sections []*DoctorSection
+}
+
+// instanceDiagnosis holds what the doctor learned about a GitLab instance, reused by the feature verdicts.
+// namespacesOwned is set when every destination root namespace of the mapping grants the Owner role.
+type instanceDiagnosis struct {
+	instance        *GitlabInstance
+	version         *semver.Version
+	user            *gitlab.User
+	section         *DoctorSection
+	isPremium       bool
+	namespacesOwned bool
+}
+
+// add appends a check to the section.
+func (s *DoctorSection) add(name, status, detail string) {
+	s.Checks = append(s.Checks, &DoctorCheck{Name: name, Status: status, Detail: detail})
+}
+
+// Failed checks if any of the report checks failed.
+func (r *DoctorReport) Failed() bool {
+	for _, section := range r.Sections {
+		for _, check := range section.Checks {
+			if check.Status == DOCTOR_FAIL {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Diagnose runs the preflight checks of a mirroring run without changing anything:
+// it reports the version, license, user and token of both instances, checks the destination root
+// namespaces of the mirror mapping (when set), and gives a verdict on the optional mirroring features.
+func Diagnose(gitlabMirrorArgs *utils.ParserArgs) *DoctorReport {
+	sourceOpts, destinationOpts := mirroringInstanceOpts(gitlabMirrorArgs)
+
+	source := diagnoseInstance(sourceOpts, []string{"read_api", "read_repository"})
+	destination := diagnoseInstance(destinationOpts, []string{API_SCOPE, "write_repository"})
+
+	if destination.user != nil && gitlabMirrorArgs.MirrorMapping != nil {
+		destination.diagnoseNamespaces(gitlabMirrorArgs.MirrorMapping)
+	}
+
+	return &DoctorReport{Sections: []*DoctorSection{
+		source.section,
+		destination.section,
+		destination.diagnoseFeatures(gitlabMirrorArgs),
+	}}
+}
+
+// diagnoseInstance connects to a GitLab instance and reports its version, license, user and token.
+// The token must grant the required scopes (or the api scope).
+func diagnoseInstance(opts *GitlabInstanceOpts, requiredScopes []string) *instanceDiagnosis {
+	diagnosis := &instanceDiagnosis{section: &DoctorSection{Title: opts.Role + " (" + opts.GitlabURL + ")"}}
+
+	instance, err := NewGitlabInstance(opts)
+	if err != nil {
+		diagnosis.section.add("connection", DOCTOR_FAIL, err.Error())
+
+		return diagnosis
+	}
+
+	diagnosis.instance = instance
+
+	diagnosis.version, err = instance.GetVersion()
+	if err != nil {
+		diagnosis.section.add("version", DOCTOR_FAIL, err.Error())
+	} else {
+		diagnosis.section.add("version", DOCTOR_INFO, diagnosis.version.Original())
+	}
+
+	diagnosis.isPremium, err = instance.IsLicensePremium()
+
+	switch {
+	case err != nil:
+		diagnosis.section.add("license", DOCTOR_WARN, err.Error())
+	case diagnosis.isPremium:
+		diagnosis.section.add("license", DOCTOR_INFO, "premium or ultimate")
+	default:
+		diagnosis.section.add("license", DOCTOR_INFO, "free (or expired)")
+	}
+
+	if opts.GitlabToken == "" {
+		diagnosis.section.add("token", DOCTOR_WARN, "no token, only the public groups and projects are visible")
+
+		return diagnosis
+	}
+
+	diagnosis.user, _, err = instance.Gitlab.Users.CurrentUser()
+	if err != nil {
+		diagnosis.section.add("user", DOCTOR_FAIL, err.Error())
+	} else {
+		diagnosis.section.add("user", DOCTOR_INFO, diagnosis.user.Username)
+		diagnosis.section.add("administrator", DOCTOR_INFO, fmt.Sprintf("%t", diagnosis.user.IsAdmin))
+	}
+
+	diagnosis.diagnoseToken(requiredScopes)
+
+	return diagnosis
+}
+
+// diagnoseToken reports the scopes and expiry of the instance token, from the personal access token self endpoint.
+func (d *instanceDiagnosis) diagnoseToken(requiredScopes []string) {
+	token, _, err := d.instance.Gitlab.PersonalAccessTokens.GetSinglePersonalAccessToken()
+	if err != nil {
+		d.section.add("token", DOCTOR_WARN, "failed to get the token details: "+err.Error())
+
+		return
+	}
+
+	if token.Revoked || !token.Active {
+		d.section.add("token", DOCTOR_FAIL, "token "+token.Name+" is revoked or inactive")
+
+		return
+	}
+
+	missingScopes := slices.DeleteFunc(slices.Clone(requiredScopes), func(scope string) bool {
+		return slices.Contains(token.Scopes, scope) || slices.Contains(token.Scopes, API_SCOPE)
+	})
+	if len(missingScopes) > 0 {
+		d.section.add("token scopes", DOCTOR_FAIL, "missing "+strings.Join(missingScopes, ", ")+" (has "+strings.Join(token.Scopes, ", ")+")")
+	} else {
+		d.section.add("token scopes", DOCTOR_PASS, strings.Join(token.Scopes, ", "))
+	}
+
+	if token.ExpiresAt == nil {
+		d.section.add("token expiry", DOCTOR_PASS, "never expires")
+
+		return
+	}
+
+	expiresAt := time.Time(*token.ExpiresAt)
+	expiry := "expires on " + expiresAt.Format(doctorDateLayout)
+
+	switch remaining := time.Until(expiresAt); {
+	case remaining < 0:
+		d.section.add("token expiry", DOCTOR_FAIL, "expired on "+expiresAt.Format(doctorDateLayout))
+	case remaining < tokenExpiryWarningDelay:
+		d.section.add("token expiry", DOCTOR_WARN, expiry)
+	default:
+		d.section.add("token expiry", DOCTOR_PASS, expiry)
+	}
+}
+
+// diagnoseNamespaces checks that the destination root namespaces of the mirror mapping exist
+// and grant the Owner or Maintainer role to the user (administrators can write everywhere).
+// Missing top level groups are only reported, since they are created by the mirroring.
+func (d *instanceDiagnosis) diagnoseNamespaces(mirrorMapping *utils.MirrorMapping) {
+	rootNamespaces := destinationRootNamespaces(mirrorMapping)
+	paths := slices.Sorted(maps.Keys(rootNamespaces))
+
+	d.namespacesOwned = len(paths) > 0
+
+	for _, namespacePath := range paths {
+		name := "namespace " + namespacePath
+
+		namespace, _, err := d.instance.Gitlab.Namespaces.GetNamespace(namespacePath)
+		if err != nil {
+			if rootNamespaces[namespacePath] {
+				d.section.add(name, DOCTOR_WARN, "not found, will be created as a top level group (requires the permission to create groups)")
+			} else {
+				d.section.add(name, DOCTOR_FAIL, "not found: "+err.Error())
+				d.namespacesOwned = false
+			}
+
+			continue
+		}
+
+		accessLevel := d.instance.namespaceAccessLevel(context.Background(), namespace)
+		d.namespacesOwned = d.namespacesOwned && accessLevel >= gitlab.OwnerPermissions
+
+		if accessLevel >= gitlab.MaintainerPermissions {
+			d.section.add(name, DOCTOR_PASS, accessLevelName(accessLevel))
+		} else {
+			d.section.add(name, DOCTOR_FAIL, accessLevelName(accessLevel)+", requires the Owner or Maintainer role")
+		}
+	}
+}
+
+// diagnoseFeatures gives a verdict on the optional mirroring features, on the destination instance.
+// The failing features that no entry of the mirror mapping enables are only reported as warnings.
+func (d *instanceDiagnosis) diagnoseFeatures(gitlabMirrorArgs *utils.ParserArgs) *DoctorSection {
+	section := &DoctorSection{Title: "features"}
+
+	if d.instance == nil {
+		section.add("mirroring", DOCTOR_FAIL, "the destination GitLab instance is unreachable")
+
+		return section
+	}
+
+	isAdmin := d.user != nil && d.user.IsAdmin
+	capabilities := NewCapabilities(d.version, resolvePremium(d.isPremium, gitlabMirrorArgs.ForcePremium, gitlabMirrorArgs.ForceNonPremium), isAdmin || d.namespacesOwned)
+	mirrorMapping := gitlabMirrorArgs.MirrorMapping
+
+	if capabilities.Supports(CAPABILITY_PULL_MIRROR) {
+		section.add("pull mirroring", DOCTOR_PASS, "repositories are mirrored by the destination instance")
+	} else {
+		section.add("pull mirroring", DOCTOR_WARN, capabilities.Reason(CAPABILITY_PULL_MIRROR))
+	}
+
+	if capabilities.Supports(CAPABILITY_CICD_CATALOG) {
+		section.add("CI/CD catalog", DOCTOR_PASS, "")
+	} else {
+		section.addFeatureFailure("CI/CD catalog", capabilities.Reason(CAPABILITY_CICD_CATALOG), mirrorMapping, func(options *utils.MirroringOptions) *bool { return options.CI_CD_Catalog })
+	}
+
+	if capabilities.Supports(CAPABILITY_ISSUE_CREATED_AT) {
+		section.add("issue timestamps", DOCTOR_PASS, "")
+	} else {
+		section.addFeatureFailure("issue timestamps", capabilities.Reason(CAPABILITY_ISSUE_CREATED_AT), mirrorMapping, func(options *utils.MirroringOptions) *bool { return options.MirrorIssues })
+	}
+
+	if capabilities.Owner {
+		section.add("ownership claiming", DOCTOR_PASS, "")
+	} else {
+		section.addFeatureFailure("ownership claiming", "requires administrator rights, or the Owner role on the destination namespaces", mirrorMapping, func(options *utils.MirroringOptions) *bool { return options.ClaimOwnership })
+	}
+
+	return section
+}
+
+// addFeatureFailure reports a feature that will not work, as a warning when no entry of the mirror mapping enables it.
+func (s *DoctorSection) addFeatureFailure(name, detail string, mirrorMapping *utils.MirrorMapping, option func(*utils.MirroringOptions) *bool) {
+	if mirrorMapping != nil && !isFeatureEnabled(mirrorMapping, option) {
+		s.add(name, DOCTOR_WARN, detail+" (not enabled by the mirror mapping)")
+
+		return
+	}
+
+	s.add(name, DOCTOR_FAIL, detail)
+}
+
+// isFeatureEnabled checks if any entry of the mirror mapping enables the feature option.
+func isFeatureEnabled(mirrorMapping *utils.MirrorMapping, option func(*utils.MirroringOptions) *bool) bool {
+	for _, entries := range []map[string]*utils.MirroringOptions{mirrorMapping.ProjectsSnapshot(), mirrorMapping.GroupsSnapshot()} {
+		for _, options := range entries {
+			if helpers.Deref(option(options), false) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// destinationRootNamespaces returns the destination namespaces the mirror mapping entries are created in,
+// that are not created by the mapping itself. A root namespace is mapped to true when it is a top level
+// group of the mapping, which gets created when missing.
+func destinationRootNamespaces(mirrorMapping *utils.MirrorMapping) map[string]bool {
+	groupsSnapshot := mirrorMapping.GroupsSnapshot()
+	destinationGroups := make(map[string]struct{}, len(groupsSnapshot))
+
+	for _, options := range groupsSnapshot {
+		destinationGroups[options.DestinationPath] = struct{}{}
+	}
+
+	rootNamespaces := make(map[string]bool)
+
+	for _, entries := range []map[string]*utils.MirroringOptions{mirrorMapping.ProjectsSnapshot(), groupsSnapshot} {
+		for _, options := range entries {
+			// Climb up to the highest ancestor created by the mapping
+			topPath := options.DestinationPath
+			for {
+				if _, ok := destinationGroups[path.Dir(topPath)]; !ok {
+					break
+				}
+
+				topPath = path.Dir(topPath)
+			}
+
+			if parentPath := path.Dir(topPath); parentPath != "." {
+				rootNamespaces[parentPath] = false
+			} else if _, ok := destinationGroups[topPath]; ok {
+				rootNamespaces[topPath] = true
+			}
+		}
+	}
+
+	return rootNamespaces
+}
+
+// accessLevelName returns the name of a GitLab access level.
+func accessLevelName(accessLevel gitlab.AccessLevelValue) string {
+	switch {
+	case accessLevel >= gitlab.OwnerPermissions:
+		return "owner"
+	case accessLevel >= gitlab.MaintainerPermissions:
+		return "maintainer"
+	case accessLevel >= gitlab.DeveloperPermissions:
+		return "developer"
+	case accessLevel >= gitlab.ReporterPermissions:
+		return "reporter"
+	case accessLevel >= gitlab.GuestPermissions:
+		return "guest"
+	default:
+		return "no access"
+	}
+}
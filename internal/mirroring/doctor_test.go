@@ -0,0 +1,211 @@
+package mirroring
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/boxboxjason/gitlab-sync/internal/utils"
+)
+
+// doctorTestInstance describes the GitLab instance served by setupDoctorTestServer.
+type doctorTestInstance struct {
+	version     string
+	plan        string
+	scopes      string
+	expiresAt   string
+	accessLevel int
+	admin       bool
+}
+
+// setupDoctorTestServer starts a GitLab API mock serving the endpoints used by the doctor checks.
+// The mirror namespace (group 10) is the only existing namespace.
+func setupDoctorTestServer(t *testing.T, instance *doctorTestInstance) string {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	writeJSON := func(pattern, body string) {
+		mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(HEADER_CONTENT_TYPE, HEADER_ACCEPT)
+			fmt.Fprint(w, body)
+		})
+	}
+
+	writeJSON("/api/v4/metadata", fmt.Sprintf(`{"version": %q}`, instance.version))
+	writeJSON("/api/v4/license", fmt.Sprintf(`{"plan": %q, "expired": false}`, instance.plan))
+	writeJSON("/api/v4/user", fmt.Sprintf(`{"id": 1, "username": "testuser", "is_admin": %t}`, instance.admin))
+	writeJSON("/api/v4/personal_access_tokens/self", fmt.Sprintf(`{"id": 1, "name": "sync", "active": true, "scopes": %s, "expires_at": %s}`, instance.scopes, instance.expiresAt))
+	writeJSON("/api/v4/namespaces/mirror", `{"id": 10, "path": "mirror", "kind": "group", "full_path": "mirror"}`)
+	writeJSON("/api/v4/groups/10/members/all/1", fmt.Sprintf(`{"id": 1, "username": "testuser", "access_level": %d}`, instance.accessLevel))
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, fmt.Sprintf("Undefined route accessed: %s %s", r.Method, r.URL.Path), http.StatusNotFound)
+	})
+
+	return server.URL
+}
+
+// findDoctorCheck returns the check of the report section with the given name, nil if not found.
+func findDoctorCheck(report *DoctorReport, sectionIndex int, name string) *DoctorCheck {
+	for _, check := range report.Sections[sectionIndex].Checks {
+		if check.Name == name {
+			return check
+		}
+	}
+
+	return nil
+}
+
+func TestDiagnose(t *testing.T) {
+	farExpiry := fmt.Sprintf("%q", time.Now().AddDate(1, 0, 0).Format(doctorDateLayout))
+	nearExpiry := fmt.Sprintf("%q", time.Now().AddDate(0, 0, 3).Format(doctorDateLayout))
+
+	tests := []struct {
+		name           string
+		destination    *doctorTestInstance
+		catalogEnabled bool
+		expectedFailed bool
+		expected       map[string]string
+	}{
+		{
+			name:        "Administrator on a recent premium instance",
+			destination: &doctorTestInstance{version: "19.4.0", plan: PREMIUM_PLAN, scopes: `["api"]`, expiresAt: "null", admin: true},
+			expected: map[string]string{
+				"token scopes":       DOCTOR_PASS,
+				"token expiry":       DOCTOR_PASS,
+				"namespace mirror":   DOCTOR_PASS,
+				"pull mirroring":     DOCTOR_PASS,
+				"CI/CD catalog":      DOCTOR_PASS,
+				"issue timestamps":   DOCTOR_PASS,
+				"ownership claiming": DOCTOR_PASS,
+			},
+		},
+		{
+			name:        "Owner on an older free instance",
+			destination: &doctorTestInstance{version: "17.2.0", plan: "free", scopes: `["api"]`, expiresAt: farExpiry, accessLevel: 50},
+			expected: map[string]string{
+				"token expiry":       DOCTOR_PASS,
+				"namespace mirror":   DOCTOR_PASS,
+				"pull mirroring":     DOCTOR_WARN,
+				"CI/CD catalog":      DOCTOR_WARN,
+				"issue timestamps":   DOCTOR_PASS,
+				"ownership claiming": DOCTOR_PASS,
+			},
+		},
+		{
+			name:           "Developer with a limited token",
+			destination:    &doctorTestInstance{version: "18.0.0", plan: "free", scopes: `["read_api", "write_repository"]`, expiresAt: nearExpiry, accessLevel: 30},
+			catalogEnabled: true,
+			expectedFailed: true,
+			expected: map[string]string{
+				"token scopes":       DOCTOR_FAIL,
+				"token expiry":       DOCTOR_WARN,
+				"namespace mirror":   DOCTOR_FAIL,
+				"CI/CD catalog":      DOCTOR_FAIL,
+				"issue timestamps":   DOCTOR_WARN,
+				"ownership claiming": DOCTOR_WARN,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			sourceURL := setupDoctorTestServer(t, &doctorTestInstance{version: "17.0.0", plan: "free", scopes: `["read_api", "read_repository"]`, expiresAt: "null"})
+
+			report := Diagnose(&utils.ParserArgs{
+				SourceGitlabURL:        sourceURL,
+				SourceGitlabToken:      "source-token",
+				DestinationGitlabURL:   setupDoctorTestServer(t, tt.destination),
+				DestinationGitlabToken: "destination-token",
+				MirrorMapping: &utils.MirrorMapping{
+					Projects: map[string]*utils.MirroringOptions{
+						"org/api": {DestinationPath: "mirror/org/api", CI_CD_Catalog: new(tt.catalogEnabled)},
+					},
+					Groups: map[string]*utils.MirroringOptions{
+						"org": {DestinationPath: "mirror/org"},
+					},
+				},
+			})
+
+			if report.Failed() != tt.expectedFailed {
+				t.Errorf("expected failed report: %v, got %v", tt.expectedFailed, report.Failed())
+			}
+
+			if check := findDoctorCheck(report, 0, "token scopes"); check == nil || check.Status != DOCTOR_PASS {
+				t.Errorf("expected the source token scopes to pass, got %+v", check)
+			}
+
+			for name, expectedStatus := range tt.expected {
+				check := findDoctorCheck(report, 1, name)
+				if check == nil {
+					check = findDoctorCheck(report, 2, name)
+				}
+
+				if check == nil || check.Status != expectedStatus {
+					t.Errorf("expected check %s to be %s, got %+v", name, expectedStatus, check)
+				}
+			}
+		})
+	}
+}
+
+func TestDiagnoseUnreachableDestination(t *testing.T) {
+	t.Parallel()
+
+	sourceURL := setupDoctorTestServer(t, &doctorTestInstance{version: "17.0.0", plan: "free", scopes: `["api"]`, expiresAt: "null"})
+
+	report := Diagnose(&utils.ParserArgs{
+		SourceGitlabURL:        sourceURL,
+		DestinationGitlabURL:   "http://127.0.0.1:1",
+		DestinationGitlabToken: "destination-token",
+	})
+
+	if !report.Failed() {
+		t.Error("expected the report to fail for an unreachable destination")
+	}
+
+	if check := findDoctorCheck(report, 0, "token"); check == nil || check.Status != DOCTOR_WARN {
+		t.Errorf("expected a warning for the anonymous source, got %+v", check)
+	}
+
+	if check := findDoctorCheck(report, 1, "connection"); check == nil || check.Status != DOCTOR_FAIL {
+		t.Errorf("expected the destination connection to fail, got %+v", check)
+	}
+}
+
+func TestDestinationRootNamespaces(t *testing.T) {
+	t.Parallel()
+
+	mirrorMapping := &utils.MirrorMapping{
+		Projects: map[string]*utils.MirroringOptions{
+			"org/api":        {DestinationPath: "mirror/org/api"},
+			"org/infra/tool": {DestinationPath: "mirror/org/infra/tool"},
+			"other/project":  {DestinationPath: "users/alice/project"},
+		},
+		Groups: map[string]*utils.MirroringOptions{
+			"org":       {DestinationPath: "mirror/org"},
+			"org/infra": {DestinationPath: "mirror/org/infra"},
+			"top":       {DestinationPath: "top"},
+		},
+	}
+
+	expected := map[string]bool{"mirror": false, "users/alice": false, "top": true}
+
+	rootNamespaces := destinationRootNamespaces(mirrorMapping)
+	if len(rootNamespaces) != len(expected) {
+		t.Fatalf("expected root namespaces %v, got %v", expected, rootNamespaces)
+	}
+
+	for namespacePath, topLevel := range expected {
+		if value, ok := rootNamespaces[namespacePath]; !ok || value != topLevel {
+			t.Errorf("expected root namespace %s (top level: %v), got %v", namespacePath, topLevel, rootNamespaces)
+		}
+	}
+}
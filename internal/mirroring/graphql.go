@@ -0,0 +1,445 @@
+package mirroring
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/boxboxjason/gitlab-sync/internal/utils"
+	"github.com/boxboxjason/gitlab-sync/pkg/helpers"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+	"go.uber.org/zap"
+)
+
+const (
+	// graphQLBatchSize is the number of full paths resolved by a single GraphQL request.
+	graphQLBatchSize = 50
+	// graphQLPageSize is the number of nodes requested per page of a GraphQL connection.
+	graphQLPageSize = 100
+
+	graphQLGroupFields   = `id name fullPath description visibility webUrl avatarUrl`
+	graphQLProjectFields = `id name path fullPath description topics visibility archived webUrl httpUrlToRepo avatarUrl lastActivityAt repository { rootRef empty }`
+)
+
+// graphQLResponse is the envelope of a GraphQL API response, data being decoded in place.
+type graphQLResponse struct {
+	Data   any            `json:"data"`
+	Errors []graphQLError `json:"errors"`
+}
+
+type graphQLError struct {
+	Message string `json:"message"`
+}
+
+type graphQLPageInfo struct {
+	EndCursor   string `json:"endCursor"`
+	HasNextPage bool   `json:"hasNextPage"`
+}
+
+// graphQLConnection is a page of a GraphQL connection (cursor pagination).
+type graphQLConnection[T any] struct {
+	Nodes    []*T            `json:"nodes"`
+	PageInfo graphQLPageInfo `json:"pageInfo"`
+}
+
+type graphQLGroup struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	FullPath    string `json:"fullPath"`
+	Description string `json:"description"`
+	Visibility  string `json:"visibility"`
+	WebURL      string `json:"webUrl"`
+	AvatarURL   string `json:"avatarUrl"`
+}
+
+type graphQLProject struct {
+	LastActivityAt *time.Time `json:"lastActivityAt"`
+	Repository     *struct {
+		RootRef string `json:"rootRef"`
+		Empty   bool   `json:"empty"`
+	} `json:"repository"`
+	ID            string   `json:"id"`
+	Name          string   `json:"name"`
+	Path          string   `json:"path"`
+	FullPath      string   `json:"fullPath"`
+	Description   string   `json:"description"`
+	Visibility    string   `json:"visibility"`
+	WebURL        string   `json:"webUrl"`
+	HTTPURLToRepo string   `json:"httpUrlToRepo"`
+	AvatarURL     string   `json:"avatarUrl"`
+	Topics        []string `json:"topics"`
+	Archived      bool     `json:"archived"`
+}
+
+// ============================================================ //
+//                 GRAPHQL BACKEND SELECTION                    //
+// ============================================================ //
+
+// useGraphQL checks if the big instance strategy fetches the instance through the GraphQL API.
+func (g *GitlabInstance) useGraphQL(mirrorMapping *utils.MirrorMapping) bool {
+	return g.IsBig() && g.graphQLEligible(mirrorMapping)
+}
+
+// graphQLEligible checks if the instance can be fetched through the GraphQL API.
+// Only the source instance is concerned: the mirror settings of the destination projects are not exposed by GraphQL.
+// The fork project filter relies on REST only attributes, mappings using it are fetched through the REST API.
+func (g *GitlabInstance) graphQLEligible(mirrorMapping *utils.MirrorMapping) bool {
+	return g.IsSource() && !g.graphQLUnavailable.Load() && !usesForkFilter(mirrorMapping)
+}
+
+// disableGraphQL switches the instance back to the REST API after a GraphQL failure, warning once.
+func (g *GitlabInstance) disableGraphQL(err error) {
+	if g.graphQLUnavailable.CompareAndSwap(false, true) {
+		zap.L().Warn("GraphQL API unavailable, falling back to the REST API", zap.String(ROLE, g.Role), zap.Error(err))
+	}
+}
+
+// usesForkFilter checks if a group of the mirror mapping filters its projects on their fork status.
+func usesForkFilter(mirrorMapping *utils.MirrorMapping) bool {
+	for _, options := range mirrorMapping.GroupsSnapshot() {
+		if options != nil && options.Filters != nil && options.Filters.Fork != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ============================================================ //
+//                 GRAPHQL FETCH FUNCTIONS                      //
+// ============================================================ //
+
+// fetchAndProcessProjectsGraphQL resolves the mapped projects by batches of full paths and stores them in the instance cache.
+// The returned error is set when the GraphQL API failed, the projects missing from the instance are reported in the error slice.
+func (g *GitlabInstance) fetchAndProcessProjectsGraphQL(ctx context.Context, projectFilters *map[string]struct{}, mirrorMapping *utils.MirrorMapping) ([]error, error) {
+	projectPaths := slices.Sorted(maps.Keys(*projectFilters))
+
+	nodes, err := resolveGraphQL[graphQLProject](ctx, g, "project", graphQLProjectFields, projectPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	// The interruption is reported once the mirroring stops, the projects left unresolved are not missing
+	if g.Scheduler.interrupted(ctx, poolAPI) != nil {
+		return nil, nil
+	}
+
+	errs := make([]error, 0)
+
+	for _, projectPath := range projectPaths {
+		node, ok := nodes[projectPath]
+		if !ok {
+			errs = append(errs, fmt.Errorf("failed to retrieve project %s: %w", projectPath, gitlab.ErrNotFound))
+
+			continue
+		}
+
+		project, err := node.toGitlab()
+		if err != nil {
+			return nil, err
+		}
+
+		g.storeProject(project, filepath.Dir(project.PathWithNamespace), mirrorMapping)
+	}
+
+	return helpers.MergeErrors(errs), nil
+}
+
+// fetchAndProcessGroupsGraphQL resolves the mapped groups by batches of full paths, walks their descendant groups and projects
+// with cursor pagination and stores them in the instance cache.
+// Every namespace tree is walked once, from its outermost mapped group: the descendants are attributed to their closest mapped group,
+// and those below an excluded subgroup are skipped, like in the recursive REST walk.
+// The returned error is set when the GraphQL API failed, the groups missing from the instance are reported in the error slice.
+func (g *GitlabInstance) fetchAndProcessGroupsGraphQL(ctx context.Context, groupFilters *map[string]struct{}, mirrorMapping *utils.MirrorMapping) ([]error, error) {
+	roots := namespaceRoots(*groupFilters)
+
+	nodes, err := resolveGraphQL[graphQLGroup](ctx, g, "group", graphQLGroupFields, roots)
+	if err != nil {
+		return nil, err
+	}
+
+	var waitGroup sync.WaitGroup
+
+	errCh := make(chan error, len(nodes))
+
+	for rootPath, node := range nodes {
+		rootGroup, err := node.toGitlab()
+		if err != nil {
+			return nil, err
+		}
+
+		g.StoreGroup(rootGroup, rootPath, mirrorMapping)
+
+		err = g.Scheduler.spawn(ctx, poolAPI, &waitGroup, func() {
+			err := g.walkGroupGraphQL(ctx, rootPath, groupFilters, mirrorMapping)
+			if err != nil {
+				errCh <- err
+			}
+		})
+		if err != nil {
+			// The interruption is reported once the mirroring stops
+			break
+		}
+	}
+
+	waitGroup.Wait()
+	close(errCh)
+
+	if err := errors.Join(helpers.MergeErrors(errCh)...); err != nil {
+		return nil, err
+	}
+
+	// The interruption is reported once the mirroring stops, the groups left unwalked are not missing
+	if g.Scheduler.interrupted(ctx, poolAPI) != nil {
+		return nil, nil
+	}
+
+	errs := make([]error, 0)
+
+	for _, groupPath := range slices.Sorted(maps.Keys(*groupFilters)) {
+		if g.GetGroup(groupPath) == nil {
+			errs = append(errs, fmt.Errorf("failed to retrieve group %s: %w", groupPath, gitlab.ErrNotFound))
+		}
+	}
+
+	return helpers.MergeErrors(errs), nil
+}
+
+// walkGroupGraphQL walks the descendant groups and the projects of a mapped group,
+// storing those reached from a mapped group. The archived projects are left out.
+func (g *GitlabInstance) walkGroupGraphQL(ctx context.Context, rootPath string, groupFilters *map[string]struct{}, mirrorMapping *utils.MirrorMapping) error {
+	err := walkGraphQL(ctx, g, rootPath, "descendantGroups", graphQLGroupFields, func(nodes []*graphQLGroup) error {
+		for _, node := range nodes {
+			group, err := node.toGitlab()
+			if err != nil {
+				return err
+			}
+
+			if originPath, ok := g.graphQLOrigin(group.FullPath, groupFilters, mirrorMapping); ok {
+				g.StoreGroup(group, originPath, mirrorMapping)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return walkGraphQL(ctx, g, rootPath, "projects", graphQLProjectFields, func(nodes []*graphQLProject) error {
+		for _, node := range nodes {
+			project, err := node.toGitlab()
+			if err != nil {
+				return err
+			}
+
+			if originPath, ok := g.graphQLOrigin(project.PathWithNamespace, groupFilters, mirrorMapping); ok && !project.Archived {
+				g.storeProject(project, originPath, mirrorMapping)
+			}
+		}
+
+		return nil
+	})
+}
+
+// graphQLOrigin returns the mapped group a walked group or project is fetched from, its closest ancestor (or itself) in the group filters.
+// It returns false when the path, or one of its ancestors below the mapped group, is excluded.
+func (g *GitlabInstance) graphQLOrigin(path string, groupFilters *map[string]struct{}, mirrorMapping *utils.MirrorMapping) (string, bool) {
+	var descendantPaths []string
+
+	for currentPath := path; currentPath != "." && currentPath != "/"; currentPath = filepath.Dir(currentPath) {
+		if _, ok := (*groupFilters)[currentPath]; !ok {
+			descendantPaths = append(descendantPaths, currentPath)
+
+			continue
+		}
+
+		for _, descendantPath := range descendantPaths {
+			if g.isExcluded(descendantPath, currentPath, mirrorMapping) {
+				return "", false
+			}
+		}
+
+		return currentPath, true
+	}
+
+	return "", false
+}
+
+// ============================================================ //
+//                 GRAPHQL REQUEST FUNCTIONS                    //
+// ============================================================ //
+
+// graphQL runs a GraphQL query, decoding the response data into data.
+// Errors reported in the response body fail the query.
+func (g *GitlabInstance) graphQL(ctx context.Context, query string, variables map[string]any, data any) error {
+	response := graphQLResponse{Data: data}
+
+	_, err := g.Reader().GraphQL.Do(gitlab.GraphQLQuery{Query: query, Variables: variables}, &response, gitlab.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("GraphQL request failed: %w", err)
+	}
+
+	if len(response.Errors) > 0 {
+		messages := make([]string, 0, len(response.Errors))
+		for _, responseError := range response.Errors {
+			messages = append(messages, responseError.Message)
+		}
+
+		return fmt.Errorf("GraphQL request failed: %s", strings.Join(messages, "; "))
+	}
+
+	return nil
+}
+
+// resolveGraphQL resolves groups or projects from their full paths, graphQLBatchSize paths per request.
+// The paths are aliased in the query, the entities missing from the instance are absent from the returned map.
+// The resolution stops once the scheduler stops, the interruption being reported once the mirroring stops.
+func resolveGraphQL[T any](ctx context.Context, g *GitlabInstance, field, fields string, paths []string) (map[string]*T, error) {
+	resolved := make(map[string]*T, len(paths))
+
+	for batch := range slices.Chunk(paths, graphQLBatchSize) {
+		if g.Scheduler.interrupted(ctx, poolAPI) != nil {
+			return resolved, nil
+		}
+
+		parameters := make([]string, 0, len(batch))
+		selections := make([]string, 0, len(batch))
+		variables := make(map[string]any, len(batch))
+
+		for index, path := range batch {
+			parameters = append(parameters, fmt.Sprintf("$path%d: ID!", index))
+			selections = append(selections, fmt.Sprintf("entity%d: %s(fullPath: $path%d) { %s }", index, field, index, fields))
+			variables["path"+strconv.Itoa(index)] = path
+		}
+
+		query := fmt.Sprintf("query(%s) { %s }", strings.Join(parameters, ", "), strings.Join(selections, " "))
+
+		data := make(map[string]*T, len(batch))
+		if err := g.graphQL(ctx, query, variables, &data); err != nil {
+			return nil, fmt.Errorf("failed to resolve %ss: %w", field, err)
+		}
+
+		for index, path := range batch {
+			if node := data["entity"+strconv.Itoa(index)]; node != nil {
+				resolved[path] = node
+			}
+		}
+	}
+
+	return resolved, nil
+}
+
+// walkGraphQL walks a connection of a group (descendantGroups or projects, subgroups included) with cursor pagination,
+// handing every page of nodes to handlePage as soon as it is received.
+// The walk stops once the scheduler stops, the interruption being reported once the mirroring stops.
+func walkGraphQL[T any](ctx context.Context, g *GitlabInstance, groupPath, connection, fields string, handlePage func([]*T) error) error {
+	arguments := fmt.Sprintf("first: %d, after: $after", graphQLPageSize)
+	if connection == "projects" {
+		arguments = "includeSubgroups: true, " + arguments
+	}
+
+	query := fmt.Sprintf("query($fullPath: ID!, $after: String) { group(fullPath: $fullPath) { items: %s(%s) { nodes { %s } pageInfo { endCursor hasNextPage } } } }", connection, arguments, fields)
+	variables := map[string]any{"fullPath": groupPath, "after": nil}
+
+	for {
+		if g.Scheduler.interrupted(ctx, poolAPI) != nil {
+			return nil
+		}
+
+		var data struct {
+			Group *struct {
+				Items graphQLConnection[T] `json:"items"`
+			} `json:"group"`
+		}
+
+		if err := g.graphQL(ctx, query, variables, &data); err != nil {
+			return fmt.Errorf("failed to walk the %s of group %s: %w", connection, groupPath, err)
+		}
+
+		if data.Group == nil {
+			return fmt.Errorf("failed to walk the %s of group %s: %w", connection, groupPath, gitlab.ErrNotFound)
+		}
+
+		if err := handlePage(data.Group.Items.Nodes); err != nil {
+			return err
+		}
+
+		if !data.Group.Items.PageInfo.HasNextPage {
+			return nil
+		}
+
+		variables["after"] = data.Group.Items.PageInfo.EndCursor
+	}
+}
+
+// ============================================================ //
+//                 GRAPHQL CONVERSION FUNCTIONS                 //
+// ============================================================ //
+
+// parseGlobalID returns the numeric ID of a GraphQL global ID (gid://gitlab/Project/42).
+func parseGlobalID(globalID string) (int64, error) {
+	id, err := strconv.ParseInt(globalID[strings.LastIndex(globalID, "/")+1:], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid GraphQL global ID %q: %w", globalID, err)
+	}
+
+	return id, nil
+}
+
+// toGitlab converts a GraphQL group node into the REST group representation stored in the instance cache.
+func (node *graphQLGroup) toGitlab() (*gitlab.Group, error) {
+	id, err := parseGlobalID(node.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &gitlab.Group{
+		ID:          id,
+		Name:        node.Name,
+		Path:        filepath.Base(node.FullPath),
+		FullPath:    node.FullPath,
+		Description: node.Description,
+		Visibility:  gitlab.VisibilityValue(node.Visibility),
+		WebURL:      node.WebURL,
+		AvatarURL:   node.AvatarURL,
+	}, nil
+}
+
+// toGitlab converts a GraphQL project node into the REST project representation stored in the instance cache.
+func (node *graphQLProject) toGitlab() (*gitlab.Project, error) {
+	id, err := parseGlobalID(node.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	project := &gitlab.Project{
+		ID:                id,
+		Name:              node.Name,
+		Path:              node.Path,
+		PathWithNamespace: node.FullPath,
+		Description:       node.Description,
+		Topics:            node.Topics,
+		Visibility:        gitlab.VisibilityValue(node.Visibility),
+		Archived:          node.Archived,
+		WebURL:            node.WebURL,
+		HTTPURLToRepo:     node.HTTPURLToRepo,
+		AvatarURL:         node.AvatarURL,
+		LastActivityAt:    node.LastActivityAt,
+	}
+
+	if node.Repository != nil {
+		project.DefaultBranch = node.Repository.RootRef
+		project.EmptyRepo = node.Repository.Empty
+	}
+
+	return project, nil
+}
@@ -0,0 +1,242 @@
+package mirroring
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/boxboxjason/gitlab-sync/internal/utils"
+)
+
+// graphQLRequest is the body of a GraphQL request received by the test server.
+type graphQLRequest struct {
+	Variables map[string]any `json:"variables"`
+	Query     string         `json:"query"`
+}
+
+// setupGraphQLHandler registers a GraphQL endpoint answering the requests with respond.
+func setupGraphQLHandler(t *testing.T, mux *http.ServeMux, respond func(request graphQLRequest) string) {
+	t.Helper()
+
+	mux.HandleFunc("/api/graphql", func(w http.ResponseWriter, r *http.Request) {
+		var request graphQLRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeJSONResponse(w, http.StatusBadRequest, `{"message": "invalid request"}`)
+
+			return
+		}
+
+		writeJSONResponse(w, http.StatusOK, respond(request))
+	})
+}
+
+func graphQLGroupNode(id int, fullPath string) string {
+	return fmt.Sprintf(`{"id": "gid://gitlab/Group/%d", "name": "%s", "fullPath": "%s", "visibility": "private", "webUrl": "https://gitlab.example.com/%s"}`, id, fullPath[strings.LastIndex(fullPath, "/")+1:], fullPath, fullPath)
+}
+
+func graphQLProjectNode(id int, fullPath string, archived bool) string {
+	return fmt.Sprintf(`{"id": "gid://gitlab/Project/%d", "name": "%s", "path": "%s", "fullPath": "%s", "visibility": "public", "archived": %t, "topics": ["go"], "lastActivityAt": "2026-01-02T03:04:05Z", "repository": {"rootRef": "main", "empty": false}}`, id, fullPath[strings.LastIndex(fullPath, "/")+1:], fullPath[strings.LastIndex(fullPath, "/")+1:], fullPath, archived)
+}
+
+func TestFetchAndProcessProjectsGraphQL(t *testing.T) {
+	t.Parallel()
+
+	mux, gitlabInstance := setupEmptyTestServer(t, ROLE_SOURCE, INSTANCE_SIZE_BIG)
+	setupGraphQLHandler(t, mux, func(request graphQLRequest) string {
+		data := make([]string, 0, len(request.Variables))
+
+		for index := range len(request.Variables) {
+			node := "null"
+			if path := request.Variables[fmt.Sprintf("path%d", index)]; path == "org/api" {
+				node = graphQLProjectNode(42, "org/api", false)
+			}
+
+			data = append(data, fmt.Sprintf(`"entity%d": %s`, index, node))
+		}
+
+		return `{"data": {` + strings.Join(data, ", ") + `}}`
+	})
+
+	projectFilters := map[string]struct{}{"org/api": {}, "org/missing": {}}
+	mirrorMapping := &utils.MirrorMapping{
+		Projects: map[string]*utils.MirroringOptions{
+			"org/api":     {DestinationPath: "mirror/api"},
+			"org/missing": {DestinationPath: "mirror/missing"},
+		},
+	}
+
+	errs := gitlabInstance.FetchAndProcessProjectsBigInstance(t.Context(), &projectFilters, mirrorMapping)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "org/missing") {
+		t.Fatalf("expected a single error for the missing project, got %v", errs)
+	}
+
+	project := gitlabInstance.GetProject("org/api")
+	if project == nil {
+		t.Fatal("expected the resolved project to be cached")
+	}
+
+	if project.ID != 42 || project.DefaultBranch != "main" || project.Visibility != "public" || project.LastActivityAt == nil {
+		t.Errorf("unexpected project conversion: %+v", project)
+	}
+
+	if gitlabInstance.graphQLUnavailable.Load() {
+		t.Error("expected the GraphQL API to remain in use")
+	}
+}
+
+func TestFetchAndProcessGroupsGraphQL(t *testing.T) {
+	t.Parallel()
+
+	mux, gitlabInstance := setupEmptyTestServer(t, ROLE_SOURCE, INSTANCE_SIZE_BIG)
+	setupGraphQLHandler(t, mux, func(request graphQLRequest) string {
+		switch {
+		case strings.Contains(request.Query, "descendantGroups"):
+			if request.Variables["after"] == nil {
+				return `{"data": {"group": {"items": {"nodes": [` + graphQLGroupNode(2, "org/team") + `, ` + graphQLGroupNode(3, "org/sandbox") + `], "pageInfo": {"endCursor": "page2", "hasNextPage": true}}}}}`
+			}
+
+			return `{"data": {"group": {"items": {"nodes": [` + graphQLGroupNode(4, "org/sandbox/deep") + `, ` + graphQLGroupNode(5, "org/team/nested") + `], "pageInfo": {"endCursor": "", "hasNextPage": false}}}}}`
+		case strings.Contains(request.Query, "projects"):
+			return `{"data": {"group": {"items": {"nodes": [` + strings.Join([]string{
+				graphQLProjectNode(10, "org/team/api", false),
+				graphQLProjectNode(11, "org/sandbox/deep/tool", false),
+				graphQLProjectNode(12, "org/team/legacy", true),
+				graphQLProjectNode(13, "org/team/nested/cli", false),
+			}, ", ") + `], "pageInfo": {"endCursor": "", "hasNextPage": false}}}}}`
+		default:
+			return `{"data": {"entity0": ` + graphQLGroupNode(1, "org") + `, "entity1": null}}`
+		}
+	})
+
+	groupFilters := map[string]struct{}{"org": {}, "org/team/nested": {}, "ghost": {}}
+	mirrorMapping := &utils.MirrorMapping{
+		Projects: map[string]*utils.MirroringOptions{},
+		Groups: map[string]*utils.MirroringOptions{
+			"org":             {DestinationPath: "mirror", Exclude: []string{"sandbox"}},
+			"org/team/nested": {DestinationPath: "elsewhere/nested"},
+			"ghost":           {DestinationPath: "mirror/ghost"},
+		},
+	}
+
+	errs := gitlabInstance.FetchAndProcessGroupsLargeInstance(t.Context(), &groupFilters, mirrorMapping)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "ghost") {
+		t.Fatalf("expected a single error for the missing group, got %v", errs)
+	}
+
+	expectedGroups := []string{"org", "org/team", "org/team/nested"}
+	if groups := slices.Sorted(maps.Keys(gitlabInstance.Groups)); !slices.Equal(groups, expectedGroups) {
+		t.Errorf("expected groups %v, got %v", expectedGroups, groups)
+	}
+
+	expectedProjects := []string{"org/team/api", "org/team/nested/cli"}
+	if projects := slices.Sorted(maps.Keys(gitlabInstance.Projects)); !slices.Equal(projects, expectedProjects) {
+		t.Errorf("expected projects %v, got %v", expectedProjects, projects)
+	}
+
+	if options, ok := mirrorMapping.GetProject("org/team/nested/cli"); !ok || options.DestinationPath != "elsewhere/nested/cli" {
+		t.Errorf("expected the nested project to inherit from its closest mapped group, got %+v", options)
+	}
+}
+
+func TestFetchAndProcessProjectsGraphQLFallback(t *testing.T) {
+	tests := []struct {
+		name          string
+		respond       func(request graphQLRequest) string
+		filters       *utils.ProjectFilters
+		expectFailure bool
+	}{
+		{
+			name:          "GraphQL errors",
+			respond:       func(graphQLRequest) string { return `{"errors": [{"message": "Field 'project' doesn't exist"}]}` },
+			expectFailure: true,
+		},
+		{
+			name:    "Fork filter",
+			respond: func(graphQLRequest) string { return `{"data": {}}` },
+			filters: &utils.ProjectFilters{Fork: new(false)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mux, gitlabInstance := setupTestServer(t, ROLE_SOURCE, INSTANCE_SIZE_BIG)
+			setupGraphQLHandler(t, mux, tt.respond)
+
+			projectFilters := map[string]struct{}{TEST_PROJECT.PathWithNamespace: {}}
+			mirrorMapping := &utils.MirrorMapping{
+				Projects: map[string]*utils.MirroringOptions{TEST_PROJECT.PathWithNamespace: {DestinationPath: "mirror/project"}},
+				Groups:   map[string]*utils.MirroringOptions{TEST_GROUP.FullPath: {DestinationPath: "mirror", Filters: tt.filters}},
+			}
+
+			if errs := gitlabInstance.FetchAndProcessProjectsBigInstance(t.Context(), &projectFilters, mirrorMapping); errs != nil {
+				t.Fatalf("expected the REST API to fetch the project, got %v", errs)
+			}
+
+			if gitlabInstance.GetProject(TEST_PROJECT.PathWithNamespace) == nil {
+				t.Error("expected the project to be cached")
+			}
+
+			if unavailable := gitlabInstance.graphQLUnavailable.Load(); unavailable != tt.expectFailure {
+				t.Errorf("expected GraphQL unavailability %v, got %v", tt.expectFailure, unavailable)
+			}
+		})
+	}
+}
+
+func TestUseGraphQL(t *testing.T) {
+	tests := []struct {
+		name     string
+		role     string
+		size     string
+		expected bool
+	}{
+		{name: "Big source instance", role: ROLE_SOURCE, size: INSTANCE_SIZE_BIG, expected: true},
+		{name: "Small source instance", role: ROLE_SOURCE, size: INSTANCE_SIZE_SMALL},
+		{name: "Big destination instance", role: ROLE_DESTINATION, size: INSTANCE_SIZE_BIG},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			gitlabInstance := &GitlabInstance{Role: tt.role, InstanceSize: tt.size}
+			if useGraphQL := gitlabInstance.useGraphQL(&utils.MirrorMapping{}); useGraphQL != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, useGraphQL)
+			}
+		})
+	}
+}
+
+func TestParseGlobalID(t *testing.T) {
+	tests := []struct {
+		name          string
+		globalID      string
+		expected      int64
+		expectedError bool
+	}{
+		{name: "Project ID", globalID: "gid://gitlab/Project/42", expected: 42},
+		{name: "Group ID", globalID: "gid://gitlab/Group/7", expected: 7},
+		{name: "Invalid ID", globalID: "gid://gitlab/Group/abc", expectedError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			id, err := parseGlobalID(tt.globalID)
+			if (err != nil) != tt.expectedError {
+				t.Fatalf(EXPECTED_ERROR_MESSAGE, tt.expectedError, err)
+			}
+
+			if id != tt.expected {
+				t.Errorf("expected ID %d, got %d", tt.expected, id)
+			}
+		})
+	}
+}
@@ -1,9 +1,12 @@
 package mirroring
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"maps"
 	"path/filepath"
+	"slices"
 	"sync"
 	"time"
 
@@ -15,9 +18,8 @@ import (
 )
 
 const (
-	groupsPerPage       = 100
-	subgroupWorkerCount = 2
-	ownerAccessLevel    = 50
+	groupsPerPage    = 100
+	ownerAccessLevel = 50
 )
 
 // ============================================================ //
@@ -27,7 +29,8 @@ const (
 // CreateGroups creates GitLab groups in the destination GitLab instance based on the mirror mapping.
 // It retrieves the source group path for each destination group and creates the group in the destination instance.
 // The function also handles the copying of group avatars from the source to the destination instance.
-func (destinationGitlab *GitlabInstance) CreateGroups(sourceGitlab *GitlabInstance, mirrorMapping *utils.MirrorMapping) []error {
+// Once the scheduler stops, the remaining groups are left untouched.
+func (destinationGitlab *GitlabInstance) CreateGroups(ctx context.Context, sourceGitlab *GitlabInstance, mirrorMapping *utils.MirrorMapping) []error {
 	zap.L().Info("Creating groups in GitLab Instance", zap.String(ROLE, ROLE_DESTINATION))
 
 	// Reverse the mirror mapping to get the source group path for each destination group
@@ -36,7 +39,12 @@ func (destinationGitlab *GitlabInstance) CreateGroups(sourceGitlab *GitlabInstan
 	errorChan := make(chan []error, len(destinationGroupPaths))
 	// Iterate over the groups in alphabetical order (little hack to ensure parent groups are created before children)
 	for _, destinationGroupPath := range destinationGroupPaths {
-		_, err := destinationGitlab.CreateGroup(destinationGroupPath, sourceGitlab, mirrorMapping, &reversedMirrorMap)
+		if destinationGitlab.Scheduler.interrupted(ctx, poolAPI) != nil {
+			// The interruption is reported once the mirroring stops
+			break
+		}
+
+		_, err := destinationGitlab.CreateGroup(ctx, destinationGroupPath, sourceGitlab, mirrorMapping, &reversedMirrorMap)
 		if err != nil {
 			errorChan <- err
 		}
@@ -50,7 +58,7 @@ func (destinationGitlab *GitlabInstance) CreateGroups(sourceGitlab *GitlabInstan
 // CreateGroup creates a GitLab group in the destination GitLab instance based on the source group and mirror mapping.
 // It checks if the group already exists in the destination instance and creates it if not.
 // The function also handles the copying of group avatars from the source to the destination instance.
-func (destinationGitlab *GitlabInstance) CreateGroup(destinationGroupPath string, sourceGitlab *GitlabInstance, mirrorMapping *utils.MirrorMapping, reversedMirrorMap *map[string]string) (*gitlab.Group, []error) {
+func (destinationGitlab *GitlabInstance) CreateGroup(ctx context.Context, destinationGroupPath string, sourceGitlab *GitlabInstance, mirrorMapping *utils.MirrorMapping, reversedMirrorMap *map[string]string) (*gitlab.Group, []error) {
 	// Retrieve the corresponding source group path
 	sourceGroupPath := (*reversedMirrorMap)[destinationGroupPath]
 	zap.L().Debug("Mirroring group", zap.String(ROLE_SOURCE, sourceGroupPath), zap.String(ROLE_DESTINATION, destinationGroupPath))
@@ -74,12 +82,12 @@ func (destinationGitlab *GitlabInstance) CreateGroup(destinationGroupPath string
 	if destinationGroup == nil {
 		zap.L().Debug("Group not found, creating new group in GitLab Instance", zap.String("group", destinationGroupPath), zap.String(ROLE, ROLE_DESTINATION))
 
-		destinationGroup, err = destinationGitlab.CreateGroupFromSource(sourceGroup, groupCreationOptions)
+		destinationGroup, err = destinationGitlab.CreateGroupFromSource(ctx, sourceGroup, groupCreationOptions)
 		if err != nil {
 			return nil, []error{fmt.Errorf("failed to create group %s in destination GitLab instance: %w", destinationGroupPath, err)}
 		} else {
 			// Copy the group avatar from the source to the destination instance
-			errArray := sourceGitlab.updateGroupFromSource(destinationGitlab, destinationGroup, sourceGroup, groupCreationOptions)
+			errArray := sourceGitlab.updateGroupFromSource(ctx, destinationGitlab, destinationGroup, sourceGroup, groupCreationOptions)
 			if errArray != nil {
 				return destinationGroup, errArray
 			}
@@ -93,12 +101,13 @@ func (destinationGitlab *GitlabInstance) CreateGroup(destinationGroupPath string
 
 // CreateGroupFromSource creates a GitLab group in the destination GitLab instance based on the source group.
 // It sets the group name, path, description, visibility, and default branch based on the source group.
+// The group path is the last segment of the destination path, and its name is rewritten by the mapping name rules.
 // The function also handles the setting of the parent ID for the group.
 // It returns the created group or an error if the creation fails.
-func (g *GitlabInstance) CreateGroupFromSource(sourceGroup *gitlab.Group, copyOptions *utils.MirroringOptions) (*gitlab.Group, error) {
+func (g *GitlabInstance) CreateGroupFromSource(ctx context.Context, sourceGroup *gitlab.Group, copyOptions *utils.MirroringOptions) (*gitlab.Group, error) {
 	groupCreationArgs := &gitlab.CreateGroupOptions{
-		Name:          &sourceGroup.Name,
-		Path:          &sourceGroup.Path,
+		Name:          new(copyOptions.DestinationName(sourceGroup.FullPath, sourceGroup.Name)),
+		Path:          new(filepath.Base(copyOptions.DestinationPath)),
 		Description:   &sourceGroup.Description,
 		Visibility:    &sourceGroup.Visibility,
 		DefaultBranch: &sourceGroup.DefaultBranch,
@@ -108,7 +117,7 @@ func (g *GitlabInstance) CreateGroupFromSource(sourceGroup *gitlab.Group, copyOp
 	// This is used to set the parent ID for the group
 	zap.L().Debug("Retrieving group namespace ID", zap.String(ROLE, ROLE_DESTINATION), zap.String(ROLE_DESTINATION, copyOptions.DestinationPath))
 
-	parentGroupID, err := g.GetParentNamespaceID(copyOptions.DestinationPath)
+	parentGroupID, err := g.GetParentNamespaceID(ctx, copyOptions.DestinationPath)
 	if err != nil {
 		return nil, err
 	}
@@ -120,7 +129,7 @@ func (g *GitlabInstance) CreateGroupFromSource(sourceGroup *gitlab.Group, copyOp
 	// Create the group in the destination GitLab instance
 	zap.L().Debug("Creating group in GitLab Instance", zap.String(ROLE, ROLE_DESTINATION), zap.String(ROLE_DESTINATION, copyOptions.DestinationPath))
 
-	destinationGroup, _, err := g.Gitlab.Groups.CreateGroup(groupCreationArgs)
+	destinationGroup, _, err := g.Gitlab.Groups.CreateGroup(groupCreationArgs, gitlab.WithContext(ctx))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create group %s: %w", copyOptions.DestinationPath, err)
 	}
@@ -130,7 +139,7 @@ func (g *GitlabInstance) CreateGroupFromSource(sourceGroup *gitlab.Group, copyOp
 
 	// Claim ownership of the created group
 	if copyOptions.ClaimOwnership != nil && *copyOptions.ClaimOwnership {
-		ownershipErr := g.ClaimOwnershipToGroup(destinationGroup)
+		ownershipErr := g.ClaimOwnershipToGroup(ctx, destinationGroup)
 		if ownershipErr != nil {
 			zap.L().Warn("Failed to claim ownership of group", zap.String("group", destinationGroup.FullPath), zap.Error(ownershipErr))
 		}
@@ -147,14 +156,14 @@ func (g *GitlabInstance) CreateGroupFromSource(sourceGroup *gitlab.Group, copyOp
 // It also updates the mirror mapping with the corresponding group creation options.
 //
 // The function is run in a goroutine for each group, and a wait group is used to wait for all goroutines to finish.
-func (g *GitlabInstance) FetchAndProcessGroups(groupFilters *map[string]struct{}, mirrorMapping *utils.MirrorMapping) []error {
+func (g *GitlabInstance) FetchAndProcessGroups(ctx context.Context, groupFilters *map[string]struct{}, mirrorMapping *utils.MirrorMapping) []error {
 	zap.L().Debug("Fetching and processing groups from GitLab instance", zap.String(ROLE, g.Role), zap.Int("groups", len(*groupFilters)))
 
 	if !g.IsBig() {
-		return []error{g.FetchAndProcessGroupsSmallInstance(groupFilters, mirrorMapping)}
+		return []error{g.FetchAndProcessGroupsSmallInstance(ctx, groupFilters, mirrorMapping)}
 	}
 
-	return g.FetchAndProcessGroupsLargeInstance(groupFilters, mirrorMapping)
+	return g.FetchAndProcessGroupsLargeInstance(ctx, groupFilters, mirrorMapping)
 }
 
 // StoreGroup stores the group in the Gitlab instance groups cache
@@ -170,32 +179,32 @@ func (g *GitlabInstance) StoreGroup(group *gitlab.Group, parentGroupPath string,
 	g.AddGroup(group)
 
 	if g.Role == ROLE_SOURCE {
-		zap.L().Debug("Storing group in mirror mapping", zap.String("group", group.FullPath), zap.String("parentGroup", parentGroupPath))
-		// Retrieve the corresponding group creation options from the mirror mapping
-		groupCreationOptions, ok := mirrorMapping.GetGroup(parentGroupPath)
+		// If the group is already in the mirror mapping (explicitly listed or already stored),
+		// its options must not be overridden by the ones inherited from its parent group.
+		if _, ok := mirrorMapping.GetGroup(group.FullPath); ok {
+			return
+		}
+
+		// Retrieve the options of the closest group configured in the mirror mapping
+		closestGroupPath, groupCreationOptions, ok := mirrorMapping.ClosestGroup(group.FullPath, parentGroupPath)
 		if !ok {
 			zap.L().Error("Group not found in mirror mapping", zap.String("group", parentGroupPath))
 
 			return
 		}
 
-		// Calculate the relative path between the group and the parent group
-		relativePath, err := filepath.Rel(parentGroupPath, group.FullPath)
+		zap.L().Debug("Storing group in mirror mapping", zap.String("group", group.FullPath), zap.String("parentGroup", closestGroupPath))
+
+		// Derive the group options (destination path relative to the closest group)
+		inheritedOptions, err := groupCreationOptions.Inherit(closestGroupPath, group.FullPath)
 		if err != nil {
-			zap.L().Error("Failed to calculate relative path for group", zap.String("group", group.FullPath), zap.String("parentGroup", parentGroupPath), zap.Error(err))
+			zap.L().Error("Failed to calculate relative path for group", zap.String("group", group.FullPath), zap.String("parentGroup", closestGroupPath), zap.Error(err))
 
 			return
 		}
 
 		// Add the group to the mirror mapping
-		mirrorMapping.AddGroup(group.FullPath, &utils.MirroringOptions{
-			DestinationPath:     filepath.Join(groupCreationOptions.DestinationPath, relativePath),
-			CI_CD_Catalog:       groupCreationOptions.CI_CD_Catalog,
-			MirrorIssues:        groupCreationOptions.MirrorIssues,
-			MirrorTriggerBuilds: groupCreationOptions.MirrorTriggerBuilds,
-			Visibility:          groupCreationOptions.Visibility,
-			MirrorReleases:      groupCreationOptions.MirrorReleases,
-		})
+		mirrorMapping.AddGroup(group.FullPath, inheritedOptions)
 	}
 }
 
@@ -203,23 +212,156 @@ func (g *GitlabInstance) StoreGroup(group *gitlab.Group, parentGroupPath string,
 //                         SMALL INSTANCE FUNCTIONS                         //
 // ===========================================================================
 
-// FetchAndProcessGroupsSmallInstance retrieves all groups that match the filters from the GitLab instance and stores them in the instance cache.
+// FetchAndProcessGroupsSmallInstance retrieves the mapped groups and their descendants from the GitLab instance and stores them in the instance cache.
+// Only the mapped namespaces are queried, and each page of groups is processed as soon as it is received.
 // It also updates the mirror mapping with the corresponding group creation options.
-func (g *GitlabInstance) FetchAndProcessGroupsSmallInstance(groupFilters *map[string]struct{}, mirrorMapping *utils.MirrorMapping) error {
-	allGroups, err := g.FetchAllGroupsSmallInstance()
-	if err != nil {
-		return err
+func (g *GitlabInstance) FetchAndProcessGroupsSmallInstance(ctx context.Context, groupFilters *map[string]struct{}, mirrorMapping *utils.MirrorMapping) error {
+	roots := namespaceRoots(*groupFilters)
+
+	var waitGroup sync.WaitGroup
+
+	errCh := make(chan error, len(roots))
+
+	for _, root := range roots {
+		err := g.Scheduler.spawn(ctx, poolAPI, &waitGroup, func() {
+			err := g.fetchAndProcessGroupTree(ctx, root, groupFilters, mirrorMapping)
+			if err != nil {
+				errCh <- err
+			}
+		})
+		if err != nil {
+			// The interruption is reported once the mirroring stops
+			break
+		}
 	}
 
-	g.ProcessGroupsSmallInstance(allGroups, groupFilters, mirrorMapping)
+	waitGroup.Wait()
+	close(errCh)
 
 	zap.L().Debug("Found matching groups in the GitLab instance", zap.String(ROLE, g.Role), zap.Int("groups", g.GroupsLen()))
 
+	return errors.Join(helpers.MergeErrors(errCh)...)
+}
+
+// fetchAndProcessGroupTree retrieves a mapped group and its descendant groups, and stores those matching the filters.
+// A missing group is not an error, since the destination groups are created by the mirroring.
+func (g *GitlabInstance) fetchAndProcessGroupTree(ctx context.Context, groupPath string, groupFilters *map[string]struct{}, mirrorMapping *utils.MirrorMapping) error {
+	group, _, err := g.Reader().Groups.GetGroup(groupPath, &gitlab.GetGroupOptions{WithProjects: new(false)}, gitlab.WithContext(ctx))
+	if errors.Is(err, gitlab.ErrNotFound) {
+		zap.L().Debug("Mapped group not found in the GitLab instance", zap.String(ROLE, g.Role), zap.String("group", groupPath))
+
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to retrieve group %s: %w", groupPath, err)
+	}
+
+	g.ProcessGroupsSmallInstance([]*gitlab.Group{group}, groupFilters, mirrorMapping)
+
+	return g.listGroupTree(ctx, group, func(groups []*gitlab.Group) {
+		g.ProcessGroupsSmallInstance(groups, groupFilters, mirrorMapping)
+	})
+}
+
+// listGroupTree hands the descendant groups of a mapped group to handlePage, and records the tree in the inventory cache.
+// The tree listed by the previous run is revalidated instead of being listed again, when it can be.
+func (g *GitlabInstance) listGroupTree(ctx context.Context, group *gitlab.Group, handlePage func([]*gitlab.Group)) error {
+	listedAt := time.Now().UTC()
+
+	if groups, ok := g.revalidateGroupTree(ctx, group); ok {
+		handlePage(groups)
+		g.Inventory.RecordGroupTree(g.Role, group.FullPath, listedAt, groups)
+
+		return nil
+	}
+
+	var groups []*gitlab.Group
+
+	err := g.streamDescendantGroups(ctx, group.ID, func(page []*gitlab.Group) {
+		groups = append(groups, page...)
+		handlePage(page)
+	})
+	if err != nil || g.Scheduler.interrupted(ctx, poolAPI) != nil {
+		return err
+	}
+
+	g.Inventory.RecordGroupTree(g.Role, group.FullPath, listedAt, groups)
+
 	return nil
 }
 
+// revalidateGroupTree returns the descendant groups of a group from the tree cached by the previous run.
+// GitLab does not filter the groups on their update time, so the cached groups are kept as long as
+// the descendant groups it counts (X-Total) match them: a renamed group is only picked up once the tree is listed again
+// (after INVENTORY_TREE_MAX_AGE, or with --full).
+func (g *GitlabInstance) revalidateGroupTree(ctx context.Context, group *gitlab.Group) ([]*gitlab.Group, bool) {
+	tree := g.Inventory.GroupTree(g.Role, group.FullPath)
+	if tree == nil {
+		return nil, false
+	}
+
+	_, resp, err := g.Reader().Groups.ListDescendantGroups(group.ID, &gitlab.ListDescendantGroupsOptions{
+		AllAvailable: new(true),
+		ListOptions:  gitlab.ListOptions{PerPage: 1},
+	}, gitlab.WithContext(ctx))
+	if err != nil || !isCounted(resp) || int64(len(tree.Entities)) != resp.TotalItems {
+		return nil, false
+	}
+
+	zap.L().Debug("Revalidated cached group tree", zap.String(ROLE, g.Role), zap.String("group", group.FullPath), zap.Int("groups", len(tree.Entities)))
+
+	return tree.Entities, true
+}
+
+// streamDescendantGroups lists the descendant groups of a group, handing every page to handlePage as soon as it is received.
+// The listing stops once the scheduler stops, the interruption being reported once the mirroring stops.
+func (g *GitlabInstance) streamDescendantGroups(ctx context.Context, gid any, handlePage func([]*gitlab.Group)) error {
+	fetchOpts := &gitlab.ListDescendantGroupsOptions{
+		AllAvailable: new(true),
+		ListOptions: gitlab.ListOptions{
+			PerPage: groupsPerPage,
+			Page:    1,
+		},
+	}
+
+	for {
+		if g.Scheduler.interrupted(ctx, poolAPI) != nil {
+			return nil
+		}
+
+		groups, resp, err := g.Reader().Groups.ListDescendantGroups(gid, fetchOpts, gitlab.WithContext(ctx))
+		if err != nil {
+			return fmt.Errorf("failed to list descendant groups of %v: %w", gid, err)
+		}
+
+		handlePage(groups)
+
+		if resp.NextPage == 0 {
+			return nil
+		}
+
+		fetchOpts.Page = resp.NextPage
+	}
+}
+
+// namespaceRoots returns the minimal set of namespace paths containing every given path,
+// the paths nested in another one being dropped. Sorting puts the parents before their descendants.
+func namespaceRoots(paths map[string]struct{}) []string {
+	sortedPaths := slices.Sorted(maps.Keys(paths))
+	roots := make([]string, 0, len(sortedPaths))
+
+	for _, namespacePath := range sortedPaths {
+		if slices.ContainsFunc(roots, func(root string) bool { return isSubPath(namespacePath, root) }) {
+			continue
+		}
+
+		roots = append(roots, namespacePath)
+	}
+
+	return roots
+}
+
 // FetchAllGroupsSmallInstance retrieves all groups from the small GitLab instance.
-func (g *GitlabInstance) FetchAllGroupsSmallInstance() ([]*gitlab.Group, error) {
+func (g *GitlabInstance) FetchAllGroupsSmallInstance(ctx context.Context) ([]*gitlab.Group, error) {
 	zap.L().Debug("Fetching all groups from GitLab instance", zap.String(ROLE, g.Role))
 
 	fetchOpts := &gitlab.ListGroupsOptions{
@@ -233,7 +375,12 @@ func (g *GitlabInstance) FetchAllGroupsSmallInstance() ([]*gitlab.Group, error)
 	var allGroups []*gitlab.Group
 
 	for {
-		groups, resp, err := g.Gitlab.Groups.ListGroups(fetchOpts)
+		if g.Scheduler.interrupted(ctx, poolAPI) != nil {
+			// The interruption is reported once the mirroring stops
+			return allGroups, nil
+		}
+
+		groups, resp, err := g.Reader().Groups.ListGroups(fetchOpts, gitlab.WithContext(ctx))
 		if err != nil {
 			return allGroups, fmt.Errorf("failed to list groups: %w", err)
 		}
@@ -252,26 +399,16 @@ func (g *GitlabInstance) FetchAllGroupsSmallInstance() ([]*gitlab.Group, error)
 
 // ProcessGroupsSmallInstance processes a list of groups from a small GitLab instance.
 // It iterates over the provided allGroups slice, applies the groupFilters, and stores matching groups.
+// The groups are processed in the scheduled fetch task which received them, since their processing does not call the GitLab API.
 func (g *GitlabInstance) ProcessGroupsSmallInstance(allGroups []*gitlab.Group, groupFilters *map[string]struct{}, mirrorMapping *utils.MirrorMapping) {
 	zap.L().Debug("Processing groups from GitLab instance", zap.String(INSTANCE_SIZE, g.InstanceSize), zap.String(ROLE, g.Role), zap.Int("groups", len(allGroups)))
 
-	// Create a wait group to wait for all goroutines to finish
-	var waitGroup sync.WaitGroup
-
 	for _, group := range allGroups {
-		waitGroup.Add(1)
-
-		go func(group *gitlab.Group) {
-			defer waitGroup.Done()
-
-			groupPath, matches := helpers.MatchPathAgainstFilters(group.FullPath, nil, groupFilters)
-			if matches {
-				g.StoreGroup(group, groupPath, mirrorMapping)
-			}
-		}(group)
+		groupPath, matches := helpers.MatchPathAgainstFilters(group.FullPath, nil, groupFilters)
+		if matches && !g.isExcluded(group.FullPath, groupPath, mirrorMapping) {
+			g.StoreGroup(group, groupPath, mirrorMapping)
+		}
 	}
-
-	waitGroup.Wait()
 }
 
 // ===========================================================================
@@ -280,12 +417,19 @@ func (g *GitlabInstance) ProcessGroupsSmallInstance(allGroups []*gitlab.Group, g
 
 // FetchAndProcessGroupsLargeInstance retrieves all groups that match the filters from the GitLab instance and stores them in the instance cache.
 // It also updates the mirror mapping with the corresponding group creation options.
-// It uses goroutines to fetch groups and their projects concurrently.
-func (g *GitlabInstance) FetchAndProcessGroupsLargeInstance(groupFilters *map[string]struct{}, mirrorMapping *utils.MirrorMapping) []error {
-	errChan := make(chan error)
+// The groups, their projects and their subgroups are walked concurrently, bounded by the API pool of the scheduler.
+// The source groups and their trees are fetched through the GraphQL API when available.
+func (g *GitlabInstance) FetchAndProcessGroupsLargeInstance(ctx context.Context, groupFilters *map[string]struct{}, mirrorMapping *utils.MirrorMapping) []error {
+	if g.useGraphQL(mirrorMapping) {
+		errs, err := g.fetchAndProcessGroupsGraphQL(ctx, groupFilters, mirrorMapping)
+		if err == nil {
+			return errs
+		}
 
-	var recursiveGroupWaitGroup sync.WaitGroup
-	recursiveGroupWaitGroup.Add(len(*groupFilters))
+		g.disableGraphQL(err)
+	}
+
+	errChan := make(chan error)
 
 	var collectorWaitGroup sync.WaitGroup
 
@@ -301,26 +445,27 @@ func (g *GitlabInstance) FetchAndProcessGroupsLargeInstance(groupFilters *map[st
 		}
 	})
 
+	tasks := make([]walkTask, 0, len(*groupFilters))
 	for groupPath := range *groupFilters {
-		go g.FetchAndProcessGroupRecursive(groupPath, groupPath, mirrorMapping, errChan, &recursiveGroupWaitGroup)
+		tasks = append(tasks, func() []walkTask {
+			return g.FetchAndProcessGroupRecursive(ctx, groupPath, groupPath, mirrorMapping, errChan)
+		})
 	}
 
-	// Wait for all goroutines to finish
-	recursiveGroupWaitGroup.Wait()
+	// The interruption is reported once the mirroring stops
+	_ = g.Scheduler.walk(ctx, poolAPI, tasks)
+
 	close(errChan)
 	collectorWaitGroup.Wait()
 
 	return helpers.MergeErrors(errs)
 }
 
-// FetchAndProcessGroupRecursive fetches a group and its projects recursively
-// It uses a wait group to ensure that all goroutines finish before returning
-// It sends the fetched group to the allGroupsChannel and the projects to the allProjectsChannel
+// FetchAndProcessGroupRecursive fetches a group and stores it, returning the tasks fetching its projects and its subgroups
+// (which in turn return the tasks of their own subgroups) to be walked by the scheduler.
 //
 // gid can be either an int, a string or a *gitlab.Group.
-func (g *GitlabInstance) FetchAndProcessGroupRecursive(gid any, fetchOriginPath string, mirrorMapping *utils.MirrorMapping, errChan chan error, recursiveGroupWaitGroup *sync.WaitGroup) {
-	defer recursiveGroupWaitGroup.Done()
-
+func (g *GitlabInstance) FetchAndProcessGroupRecursive(ctx context.Context, gid any, fetchOriginPath string, mirrorMapping *utils.MirrorMapping, errChan chan error) []walkTask {
 	var (
 		group *gitlab.Group
 		err   error
@@ -328,7 +473,7 @@ func (g *GitlabInstance) FetchAndProcessGroupRecursive(gid any, fetchOriginPath
 
 	switch groupIdentifier := gid.(type) {
 	case int, string:
-		group, _, err = g.Gitlab.Groups.GetGroup(gid, &gitlab.GetGroupOptions{WithProjects: new(false)})
+		group, _, err = g.Reader().Groups.GetGroup(gid, &gitlab.GetGroupOptions{WithProjects: new(false)}, gitlab.WithContext(ctx))
 		if err != nil {
 			errChan <- fmt.Errorf("failed to retrieve group %s: %w", gid, err)
 		}
@@ -337,27 +482,36 @@ func (g *GitlabInstance) FetchAndProcessGroupRecursive(gid any, fetchOriginPath
 	default:
 		errChan <- fmt.Errorf("invalid group ID type %T (%v)", gid, gid)
 
-		return
+		return nil
 	}
 
-	if group != nil {
-		g.StoreGroup(group, fetchOriginPath, mirrorMapping)
+	if group == nil {
+		return nil
+	}
 
-		if g.IsSource() || g.IsBig() {
-			recursiveGroupWaitGroup.Add(subgroupWorkerCount)
-			// Fetch the projects of the group
-			go g.FetchAndProcessGroupProjects(group, fetchOriginPath, mirrorMapping, errChan, recursiveGroupWaitGroup)
-			// Fetch the subgroups of the group
-			go g.FetchAndProcessGroupSubgroups(group, fetchOriginPath, mirrorMapping, errChan, recursiveGroupWaitGroup)
-		}
+	g.StoreGroup(group, fetchOriginPath, mirrorMapping)
+
+	if !g.IsSource() && !g.IsBig() {
+		return nil
 	}
-}
 
-// FetchAndProcessGroupSubgroups retrieves all subgroups of a group
-// and processes them to store in the instance cache.
-func (g *GitlabInstance) FetchAndProcessGroupSubgroups(group *gitlab.Group, fetchOriginPath string, mirrorMapping *utils.MirrorMapping, errChan chan error, recursiveGroupWaitGroup *sync.WaitGroup) {
-	defer recursiveGroupWaitGroup.Done()
+	return []walkTask{
+		// Fetch the projects of the group
+		func() []walkTask {
+			g.FetchAndProcessGroupProjects(ctx, group, fetchOriginPath, mirrorMapping, errChan)
+
+			return nil
+		},
+		// Fetch the subgroups of the group
+		func() []walkTask {
+			return g.FetchAndProcessGroupSubgroups(ctx, group, fetchOriginPath, mirrorMapping, errChan)
+		},
+	}
+}
 
+// FetchAndProcessGroupSubgroups retrieves all subgroups of a group and stores them in the instance cache.
+// It returns the tasks walking the stored subgroups.
+func (g *GitlabInstance) FetchAndProcessGroupSubgroups(ctx context.Context, group *gitlab.Group, fetchOriginPath string, mirrorMapping *utils.MirrorMapping, errChan chan error) []walkTask {
 	fetchOpts := &gitlab.ListSubGroupsOptions{
 		AllAvailable: new(true),
 		ListOptions: gitlab.ListOptions{
@@ -366,22 +520,31 @@ func (g *GitlabInstance) FetchAndProcessGroupSubgroups(group *gitlab.Group, fetc
 		},
 	}
 
+	var tasks []walkTask
+
 	for {
-		subgroups, resp, err := g.Gitlab.Groups.ListSubGroups(group.ID, fetchOpts)
+		if g.Scheduler.interrupted(ctx, poolAPI) != nil {
+			// The interruption is reported once the mirroring stops
+			return tasks
+		}
+
+		subgroups, resp, err := g.Reader().Groups.ListSubGroups(group.ID, fetchOpts, gitlab.WithContext(ctx))
 		if err != nil {
 			errChan <- fmt.Errorf("failed to retrieve subgroups for group %s: %w", group.FullPath, err)
 
-			return
+			return tasks
 		}
 
 		for _, subgroup := range subgroups {
-			g.StoreGroup(subgroup, fetchOriginPath, mirrorMapping)
-
-			if g.IsSource() || g.IsBig() {
-				recursiveGroupWaitGroup.Add(1)
-
-				go g.FetchAndProcessGroupRecursive(subgroup, fetchOriginPath, mirrorMapping, errChan, recursiveGroupWaitGroup)
+			// Excluded subgroups are neither stored nor fetched deeper,
+			// nested groups of the mirror mapping are fetched from their own entry
+			if g.isExcluded(subgroup.FullPath, fetchOriginPath, mirrorMapping) || g.isConfiguredGroup(subgroup.FullPath, mirrorMapping) {
+				continue
 			}
+
+			tasks = append(tasks, func() []walkTask {
+				return g.FetchAndProcessGroupRecursive(ctx, subgroup, fetchOriginPath, mirrorMapping, errChan)
+			})
 		}
 
 		if resp.CurrentPage >= resp.TotalPages {
@@ -390,6 +553,8 @@ func (g *GitlabInstance) FetchAndProcessGroupSubgroups(group *gitlab.Group, fetc
 
 		fetchOpts.Page = resp.NextPage
 	}
+
+	return tasks
 }
 
 // ===========================================================================
@@ -398,7 +563,7 @@ func (g *GitlabInstance) FetchAndProcessGroupSubgroups(group *gitlab.Group, fetc
 
 // updateGroupFromSource updates the destination group with settings from the source group.
 // It copies the group avatar and updates the group attributes.
-func (destinationGitlabInstance *GitlabInstance) updateGroupFromSource(sourceGitlabInstance *GitlabInstance, sourceGroup, destinationGroup *gitlab.Group, copyOptions *utils.MirroringOptions) []error {
+func (destinationGitlabInstance *GitlabInstance) updateGroupFromSource(ctx context.Context, sourceGitlabInstance *GitlabInstance, sourceGroup, destinationGroup *gitlab.Group, copyOptions *utils.MirroringOptions) []error {
 	// Immediately capture pointers in local variables to avoid any late overrides
 	srcGroup := sourceGroup
 	dstGroup := destinationGroup
@@ -408,22 +573,27 @@ func (destinationGitlabInstance *GitlabInstance) updateGroupFromSource(sourceGit
 		return []error{errors.New("source or destination group is nil")}
 	}
 
-	waitGroup := sync.WaitGroup{}
-	maxErrors := 2
-	waitGroup.Add(maxErrors)
-	errorChan := make(chan error, maxErrors)
-
-	go func(sg, dg *gitlab.Group, cp *utils.MirroringOptions) {
-		defer waitGroup.Done()
-
-		errorChan <- destinationGitlabInstance.syncGroupAttributes(sg, dg, cp)
-	}(srcGroup, dstGroup, cpOpts)
+	var waitGroup sync.WaitGroup
 
-	go func(sg, dg *gitlab.Group) {
-		defer waitGroup.Done()
+	tasks := []func() error{
+		func() error {
+			return destinationGitlabInstance.syncGroupAttributes(ctx, srcGroup, dstGroup, cpOpts)
+		},
+		func() error {
+			return sourceGitlabInstance.copyGroupAvatar(ctx, destinationGitlabInstance, dstGroup, srcGroup)
+		},
+	}
+	errorChan := make(chan error, len(tasks))
 
-		errorChan <- sourceGitlabInstance.copyGroupAvatar(destinationGitlabInstance, dg, sg)
-	}(srcGroup, dstGroup)
+	for _, task := range tasks {
+		err := destinationGitlabInstance.Scheduler.spawn(ctx, poolAPI, &waitGroup, func() {
+			errorChan <- task()
+		})
+		if err != nil {
+			// The interruption is reported once the mirroring stops
+			break
+		}
+	}
 
 	waitGroup.Wait()
 	close(errorChan)
@@ -436,7 +606,7 @@ func (destinationGitlabInstance *GitlabInstance) updateGroupFromSource(sourceGit
 // and uploads it to the destination group.
 // The avatar is saved with a unique filename based on the current timestamp.
 // The function returns an error if any step fails, including downloading or uploading the avatar.
-func (sourceGitlabInstance *GitlabInstance) copyGroupAvatar(destinationGitlabInstance *GitlabInstance, destinationGroup, sourceGroup *gitlab.Group) error {
+func (sourceGitlabInstance *GitlabInstance) copyGroupAvatar(ctx context.Context, destinationGitlabInstance *GitlabInstance, destinationGroup, sourceGroup *gitlab.Group) error {
 	zap.L().Debug("Checking if group avatar is already set", zap.String("group", destinationGroup.WebURL))
 
 	// Check if the destination group already has an avatar
@@ -449,7 +619,7 @@ func (sourceGitlabInstance *GitlabInstance) copyGroupAvatar(destinationGitlabIns
 	zap.L().Debug("Copying group avatar", zap.String(ROLE_SOURCE, sourceGroup.WebURL), zap.String(ROLE_DESTINATION, destinationGroup.WebURL))
 
 	// Download the source group avatar
-	sourceGroupAvatar, _, err := sourceGitlabInstance.Gitlab.Groups.DownloadAvatar(sourceGroup.ID)
+	sourceGroupAvatar, _, err := sourceGitlabInstance.Reader().Groups.DownloadAvatar(sourceGroup.ID, gitlab.WithContext(ctx))
 	if err != nil {
 		return fmt.Errorf("failed to download avatar for group %s: %w", sourceGroup.WebURL, err)
 	}
@@ -457,7 +627,7 @@ func (sourceGitlabInstance *GitlabInstance) copyGroupAvatar(destinationGitlabIns
 	// Upload the avatar to the destination group
 	filename := fmt.Sprintf("avatar-%d.png", time.Now().Unix())
 
-	_, _, err = destinationGitlabInstance.Gitlab.Groups.UploadAvatar(destinationGroup.ID, sourceGroupAvatar, filename)
+	_, _, err = destinationGitlabInstance.Gitlab.Groups.UploadAvatar(destinationGroup.ID, sourceGroupAvatar, filename, gitlab.WithContext(ctx))
 	if err != nil {
 		return fmt.Errorf("failed to upload avatar for group %s: %w", destinationGroup.WebURL, err)
 	}
@@ -467,14 +637,14 @@ func (sourceGitlabInstance *GitlabInstance) copyGroupAvatar(destinationGitlabIns
 
 // syncGroupAttributes updates the destination group with settings from the source group.
 // It checks if any diverged group data exists and if so, it overwrites it.
-func (destinationGitlabInstance *GitlabInstance) syncGroupAttributes(sourceGroup, destinationGroup *gitlab.Group, copyOptions *utils.MirroringOptions) error {
+func (destinationGitlabInstance *GitlabInstance) syncGroupAttributes(ctx context.Context, sourceGroup, destinationGroup *gitlab.Group, copyOptions *utils.MirroringOptions) error {
 	zap.L().Debug("Checking if group requires attributes resync", zap.String(ROLE_SOURCE, sourceGroup.FullPath), zap.String(ROLE_DESTINATION, destinationGroup.FullPath))
 
 	gitlabEditOptions := &gitlab.UpdateGroupOptions{}
 	missmatched := false
 
-	if sourceGroup.Name != destinationGroup.Name {
-		gitlabEditOptions.Name = &sourceGroup.Name
+	if destinationName := copyOptions.DestinationName(sourceGroup.FullPath, sourceGroup.Name); destinationName != destinationGroup.Name {
+		gitlabEditOptions.Name = &destinationName
 		missmatched = true
 	}
 
@@ -490,7 +660,7 @@ func (destinationGitlabInstance *GitlabInstance) syncGroupAttributes(sourceGroup
 	}
 
 	if missmatched {
-		updatedGroup, _, err := destinationGitlabInstance.Gitlab.Groups.UpdateGroup(destinationGroup.ID, gitlabEditOptions)
+		updatedGroup, _, err := destinationGitlabInstance.Gitlab.Groups.UpdateGroup(destinationGroup.ID, gitlabEditOptions, gitlab.WithContext(ctx))
 		if err != nil {
 			return fmt.Errorf("failed to edit group %s: %w", destinationGroup.FullPath, err)
 		}
@@ -507,13 +677,13 @@ func (destinationGitlabInstance *GitlabInstance) syncGroupAttributes(sourceGroup
 
 // ClaimOwnershipToGroup adds the authenticated user as an owner to the specified group.
 // It uses the GitLab API to add the user as a group member with owner access level.
-func (g *GitlabInstance) ClaimOwnershipToGroup(group *gitlab.Group) error {
+func (g *GitlabInstance) ClaimOwnershipToGroup(ctx context.Context, group *gitlab.Group) error {
 	zap.L().Debug("Claiming ownership of group", zap.String("group", group.FullPath), zap.Int64("userID", g.UserID))
 
 	_, _, err := g.Gitlab.GroupMembers.AddGroupMember(group.ID, &gitlab.AddGroupMemberOptions{
 		UserID:      &g.UserID,
 		AccessLevel: new(gitlab.AccessLevelValue(ownerAccessLevel)),
-	})
+	}, gitlab.WithContext(ctx))
 	if err != nil {
 		return fmt.Errorf("failed to add user as owner to group %s: %w", group.FullPath, err)
 	}
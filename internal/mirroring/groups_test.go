@@ -1,7 +1,9 @@
 package mirroring
 
 import (
+	"encoding/json"
 	"net/http"
+	"reflect"
 	"testing"
 
 	"github.com/boxboxjason/gitlab-sync/internal/utils"
@@ -40,7 +42,7 @@ func TestCreateGroupFromSource(t *testing.T) {
 			// Setup the test server
 			_, gitlabInstance := setupTestServer(t, tt.role, tt.instanceSize)
 			gitlabInstance.AddGroup(TEST_GROUP)
-			createdGroup, err := gitlabInstance.CreateGroupFromSource(TEST_GROUP_2, &utils.MirroringOptions{
+			createdGroup, err := gitlabInstance.CreateGroupFromSource(t.Context(), TEST_GROUP_2, &utils.MirroringOptions{
 				DestinationPath: TEST_GROUP_2.FullPath,
 			})
 			if err != nil {
@@ -107,7 +109,7 @@ func TestCreateGroups(t *testing.T) {
 			})
 
 			// Create groups
-			err := destinationGitlabInstance.CreateGroups(sourceGitlabInstance, mirrorMapping)
+			err := destinationGitlabInstance.CreateGroups(t.Context(), sourceGitlabInstance, mirrorMapping)
 			if err != nil {
 				t.Errorf("Unexpected error when creating groups: %v", err)
 			}
@@ -118,7 +120,7 @@ func TestCreateGroups(t *testing.T) {
 func TestClaimOwnershipToGroup(t *testing.T) {
 	_, gitlabInstance := setupTestServer(t, ROLE_DESTINATION, INSTANCE_SIZE_SMALL)
 
-	err := gitlabInstance.ClaimOwnershipToGroup(TEST_GROUP)
+	err := gitlabInstance.ClaimOwnershipToGroup(t.Context(), TEST_GROUP)
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -173,7 +175,7 @@ func TestCreateGroupFromSourceClaimOwnershipOption(t *testing.T) {
 				w.Write([]byte(`{"id": 1}`))
 			})
 
-			createdGroup, err := gitlabInstance.CreateGroupFromSource(TEST_GROUP_2, &utils.MirroringOptions{
+			createdGroup, err := gitlabInstance.CreateGroupFromSource(t.Context(), TEST_GROUP_2, &utils.MirroringOptions{
 				DestinationPath: "group2",
 				ClaimOwnership:  tc.claimOwnership,
 			})
@@ -189,3 +191,237 @@ func TestCreateGroupFromSourceClaimOwnershipOption(t *testing.T) {
 		})
 	}
 }
+
+func TestFetchAllWithExclusions(t *testing.T) {
+	tests := []struct {
+		name         string
+		instanceSize string
+		exclude      []string
+	}{
+		{
+			name:         "Small instance, excluded subgroup name",
+			instanceSize: INSTANCE_SIZE_SMALL,
+			exclude:      []string{"group2"},
+		},
+		{
+			name:         "Big instance, excluded subgroup name",
+			instanceSize: INSTANCE_SIZE_BIG,
+			exclude:      []string{"group2"},
+		},
+		{
+			name:         "Small instance, excluded full path",
+			instanceSize: INSTANCE_SIZE_SMALL,
+			exclude:      []string{TEST_GROUP_2.FullPath},
+		},
+		{
+			name:         "Big instance, excluded pattern",
+			instanceSize: INSTANCE_SIZE_BIG,
+			exclude:      []string{"group?"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, gitlabInstance := setupTestServer(t, ROLE_SOURCE, tt.instanceSize)
+			mirrorMapping := &utils.MirrorMapping{
+				Projects: map[string]*utils.MirroringOptions{},
+				Groups: map[string]*utils.MirroringOptions{
+					TEST_GROUP.FullPath: {
+						DestinationPath: TEST_GROUP.FullPath,
+						Exclude:         tt.exclude,
+					},
+				},
+			}
+
+			errs := gitlabInstance.FetchAll(t.Context(), map[string]struct{}{}, map[string]struct{}{TEST_GROUP.FullPath: {}}, mirrorMapping)
+			if len(errs) > 0 {
+				t.Fatalf("unexpected errors: %v", errs)
+			}
+
+			if gitlabInstance.GetProject(TEST_PROJECT.PathWithNamespace) == nil {
+				t.Errorf("expected project %s to be stored", TEST_PROJECT.PathWithNamespace)
+			}
+
+			if gitlabInstance.GetGroup(TEST_GROUP_2.FullPath) != nil {
+				t.Errorf("expected excluded group %s not to be stored", TEST_GROUP_2.FullPath)
+			}
+
+			if _, ok := mirrorMapping.GetGroup(TEST_GROUP_2.FullPath); ok {
+				t.Errorf("expected excluded group %s not to be in the mirror mapping", TEST_GROUP_2.FullPath)
+			}
+
+			if _, ok := mirrorMapping.GetProject(TEST_PROJECT_2.PathWithNamespace); ok {
+				t.Errorf("expected project %s of an excluded group not to be in the mirror mapping", TEST_PROJECT_2.PathWithNamespace)
+			}
+		})
+	}
+}
+
+func TestFetchAllWithNestedEntries(t *testing.T) {
+	tests := []struct {
+		name         string
+		instanceSize string
+	}{
+		{
+			name:         "Small instance",
+			instanceSize: INSTANCE_SIZE_SMALL,
+		},
+		{
+			name:         "Big instance",
+			instanceSize: INSTANCE_SIZE_BIG,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, gitlabInstance := setupTestServer(t, ROLE_SOURCE, tt.instanceSize)
+			mirrorMapping := &utils.MirrorMapping{
+				Projects: map[string]*utils.MirroringOptions{},
+				Groups: map[string]*utils.MirroringOptions{
+					TEST_GROUP.FullPath: {
+						DestinationPath: "mirror",
+						ClaimOwnership:  new(true),
+						MirrorIssues:    new(false),
+					},
+					TEST_GROUP_2.FullPath: {
+						DestinationPath: "mirror/group2",
+						ClaimOwnership:  new(true),
+						MirrorIssues:    new(true),
+					},
+				},
+			}
+			groupFilters := map[string]struct{}{TEST_GROUP.FullPath: {}, TEST_GROUP_2.FullPath: {}}
+
+			errs := gitlabInstance.FetchAll(t.Context(), map[string]struct{}{}, groupFilters, mirrorMapping)
+			if len(errs) > 0 {
+				t.Fatalf("unexpected errors: %v", errs)
+			}
+
+			expectedOptions := map[string]*utils.MirroringOptions{
+				TEST_PROJECT.PathWithNamespace:   {DestinationPath: "mirror/project", MirrorIssues: new(false)},
+				TEST_PROJECT_2.PathWithNamespace: {DestinationPath: "mirror/group2/project2", MirrorIssues: new(true)},
+			}
+
+			for projectPath, expected := range expectedOptions {
+				options, ok := mirrorMapping.GetProject(projectPath)
+				if !ok {
+					t.Fatalf("expected project %s in the mirror mapping", projectPath)
+				}
+
+				if options.DestinationPath != expected.DestinationPath {
+					t.Errorf("expected destination path %s for %s, got %s", expected.DestinationPath, projectPath, options.DestinationPath)
+				}
+
+				if *options.MirrorIssues != *expected.MirrorIssues {
+					t.Errorf("expected mirror_issues %t for %s, got %t", *expected.MirrorIssues, projectPath, *options.MirrorIssues)
+				}
+
+				if options.ClaimOwnership == nil || !*options.ClaimOwnership {
+					t.Errorf("expected claim_ownership to be inherited by %s", projectPath)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateGroupFromSourceRenamed(t *testing.T) {
+	t.Parallel()
+
+	mux, gitlabInstance := setupEmptyTestServer(t, ROLE_DESTINATION, INSTANCE_SIZE_SMALL)
+
+	var requestBody map[string]any
+
+	mux.HandleFunc("/api/v4/groups", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set(HEADER_CONTENT_TYPE, HEADER_ACCEPT)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(TEST_GROUP_2_STRING))
+	})
+
+	_, err := gitlabInstance.CreateGroupFromSource(t.Context(), TEST_GROUP_2, &utils.MirroringOptions{
+		DestinationPath: "team-group2",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if requestBody["path"] != "team-group2" {
+		t.Errorf("expected group path team-group2, got %v", requestBody["path"])
+	}
+
+	if requestBody["name"] != TEST_GROUP_2.Name {
+		t.Errorf("expected group name %s, got %v", TEST_GROUP_2.Name, requestBody["name"])
+	}
+}
+
+func TestNamespaceRoots(t *testing.T) {
+	tests := []struct {
+		name     string
+		paths    map[string]struct{}
+		expected []string
+	}{
+		{
+			name:     "No paths",
+			paths:    map[string]struct{}{},
+			expected: []string{},
+		},
+		{
+			name:     "Nested paths",
+			paths:    map[string]struct{}{"org": {}, "org/team": {}, "org/team/sub": {}, "other": {}},
+			expected: []string{"org", "other"},
+		},
+		{
+			name:     "Sibling paths sharing a prefix",
+			paths:    map[string]struct{}{"org": {}, "org-archive": {}, "org-archive/team": {}, "org/team": {}},
+			expected: []string{"org", "org-archive"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if roots := namespaceRoots(tt.paths); !reflect.DeepEqual(roots, tt.expected) {
+				t.Errorf("expected roots %v, got %v", tt.expected, roots)
+			}
+		})
+	}
+}
+
+func TestFetchAndProcessGroupsSmallInstanceMissingGroup(t *testing.T) {
+	t.Parallel()
+
+	_, gitlabInstance := setupTestServer(t, ROLE_DESTINATION, INSTANCE_SIZE_SMALL)
+
+	groupFilters := map[string]struct{}{
+		TEST_GROUP.FullPath:   {},
+		TEST_GROUP_2.FullPath: {},
+		"mirror/new-group":    {},
+	}
+
+	err := gitlabInstance.FetchAndProcessGroupsSmallInstance(t.Context(), &groupFilters, &utils.MirrorMapping{})
+	if err != nil {
+		t.Fatalf("expected missing groups to be skipped, got %v", err)
+	}
+
+	for _, group := range []string{TEST_GROUP.FullPath, TEST_GROUP_2.FullPath} {
+		if gitlabInstance.GetGroup(group) == nil {
+			t.Errorf("expected group %s in instance cache", group)
+		}
+	}
+
+	if gitlabInstance.GroupsLen() != 2 {
+		t.Errorf("expected 2 groups in instance cache, got %d", gitlabInstance.GroupsLen())
+	}
+}
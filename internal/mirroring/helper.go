@@ -38,3 +38,34 @@ func (g *GitlabInstance) reverseGroupMirrorMap(mirrorMapping *utils.MirrorMappin
 
 	return reversedMirrorMap, destinationGroupPaths
 }
+
+// isExcluded checks whether a source group or project is excluded by the exclude list
+// of the mirrored group it was fetched from.
+// Exclude lists only apply to the source GitLab instance.
+func (g *GitlabInstance) isExcluded(path, mappedGroupPath string, mirrorMapping *utils.MirrorMapping) bool {
+	if !g.IsSource() || mappedGroupPath == "" {
+		return false
+	}
+
+	// The exclude list of the closest configured group applies (nested entries inherit it unless they override it)
+	closestGroupPath, groupOptions, ok := mirrorMapping.ClosestGroup(path, mappedGroupPath)
+	if !ok || !groupOptions.IsExcluded(closestGroupPath, path) {
+		return false
+	}
+
+	zap.L().Debug("Skipping excluded path", zap.String("path", path), zap.String("group", closestGroupPath))
+
+	return true
+}
+
+// isConfiguredGroup checks whether a source group has its own entry in the mirror mapping
+// (configured or expanded from a pattern), as opposed to an entry inherited from a mirrored ancestor group.
+func (g *GitlabInstance) isConfiguredGroup(path string, mirrorMapping *utils.MirrorMapping) bool {
+	if !g.IsSource() {
+		return false
+	}
+
+	options, ok := mirrorMapping.GetGroup(path)
+
+	return ok && !options.IsInherited()
+}
@@ -588,6 +588,28 @@ func setupTestProjects(mux *http.ServeMux) {
 		fmt.Fprint(w, "[]")
 	})
 
+	// ========== Setup the list descendant groups endpoint for both groups ==========
+
+	// Descendant groups of the TEST_GROUP
+	mux.HandleFunc(fmt.Sprintf("/api/v4/groups/%d/descendant_groups", TEST_GROUP.ID), func(w http.ResponseWriter, r *http.Request) {
+		writeJSONResponse(w, http.StatusOK, fmt.Sprintf(`[%s]`, TEST_GROUP_2_STRING))
+	})
+
+	// Descendant groups of the TEST_GROUP_2
+	mux.HandleFunc(fmt.Sprintf("/api/v4/groups/%d/descendant_groups", TEST_GROUP_2.ID), func(w http.ResponseWriter, r *http.Request) {
+		writeJSONResponse(w, http.StatusOK, "[]")
+	})
+
+	// ========== Setup the list projects endpoint for each group path, subgroups included ==========
+
+	mux.HandleFunc(fmt.Sprintf("/api/v4/groups/%s/projects", url.QueryEscape(TEST_GROUP.FullPath)), func(w http.ResponseWriter, r *http.Request) {
+		writeJSONResponse(w, http.StatusOK, TEST_PROJECTS_STRING)
+	})
+
+	mux.HandleFunc(fmt.Sprintf("/api/v4/groups/%s/projects", url.QueryEscape(TEST_GROUP_2.FullPath)), func(w http.ResponseWriter, r *http.Request) {
+		writeJSONResponse(w, http.StatusOK, fmt.Sprintf("[%s]", TEST_PROJECT_2_STRING))
+	})
+
 	// ========== Setup the list projects endpoint for each group ==========
 	// Projects of the TEST_GROUP
 	mux.HandleFunc(fmt.Sprintf("/api/v4/groups/%d/projects", TEST_GROUP.ID), func(w http.ResponseWriter, r *http.Request) {
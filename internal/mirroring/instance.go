@@ -1,15 +1,21 @@
 package mirroring
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	"path/filepath"
+	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/boxboxjason/gitlab-sync/internal/utils"
 	"github.com/boxboxjason/gitlab-sync/pkg/helpers"
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/hashicorp/go-cleanhttp"
 	"github.com/hashicorp/go-retryablehttp"
 
 	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
@@ -24,33 +30,86 @@ const (
 )
 
 type GitlabInstance struct {
-	GitAuth             transport.AuthMethod
-	Gitlab              *gitlab.Client
-	Projects            map[string]*gitlab.Project
-	Groups              map[string]*gitlab.Group
-	Role                string
-	InstanceSize        string
-	UserID              int64
-	muProjects          sync.RWMutex
-	muGroups            sync.RWMutex
-	PullMirrorAvailable bool
+	GitAuth      transport.AuthMethod
+	GitTransport *helpers.TransportConfig
+	Gitlab       *gitlab.Client
+	Capabilities *Capabilities
+	Scheduler    *Scheduler
+	RateLimiter  *RateLimiter
+	Inventory    *InventoryCache
+	Watermarks   *SyncState
+	GitCache     *helpers.GitCache
+	Projects     map[string]*gitlab.Project
+	Groups       map[string]*gitlab.Group
+	Role         string
+	InstanceSize string
+	UserID       int64
+	Username     string
+	// ProjectTimeout bounds the sync of each project (no limit when zero)
+	ProjectTimeout time.Duration
+	muProjects     sync.RWMutex
+	muGroups       sync.RWMutex
+	IsAdmin        bool
+	// tokens spreads the read-only API calls over the tokens of the instance (nil with a single token)
+	tokens *tokenPool
+	// graphQLUnavailable is set once a GraphQL request failed, the instance is then fetched through the REST API only
+	graphQLUnavailable atomic.Bool
 }
 
 type GitlabInstanceOpts struct {
-	GitlabURL    string
+	// Context bounds every API request of the instance (no limit when nil)
+	Context   context.Context
+	Transport *helpers.TransportOptions
+	GitlabURL string
+	// GitlabToken holds one token per line, the first one being pinned while the other ones only serve the read-only calls
 	GitlabToken  string
 	Role         string
 	InstanceSize string
 	MaxRetries   int
+	// RateLimit is the maximum number of API requests per second (no fixed limit when zero)
+	RateLimit float64
 }
 
 // NewGitlabInstance creates a new GitlabInstance with the provided parameters
 // and initializes the GitLab client with a custom HTTP client.
+// The TLS and proxy settings of the transport options apply to both the API client and the git operations.
 func NewGitlabInstance(initArgs *GitlabInstanceOpts) (*GitlabInstance, error) {
-	// Initialize the GitLab client with the custom HTTP client
-	gitlabClient, err := gitlab.NewClient(initArgs.GitlabToken, gitlab.WithBaseURL(initArgs.GitlabURL), gitlab.WithCustomRetryMax(initArgs.MaxRetries), gitlab.WithCustomBackoff(retryablehttp.DefaultBackoff))
+	transportConfig, err := helpers.LoadTransportConfig(initArgs.Transport)
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize GitLab client: %w", err)
+		return nil, fmt.Errorf("invalid TLS / proxy settings for %s: %w", initArgs.GitlabURL, err)
+	}
+
+	clientOptions := []gitlab.ClientOptionFunc{gitlab.WithBaseURL(initArgs.GitlabURL), gitlab.WithCustomRetryMax(initArgs.MaxRetries), gitlab.WithCustomBackoff(retryablehttp.DefaultBackoff)}
+
+	httpClient, err := transportConfig.HTTPClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build HTTP client for %s: %w", initArgs.GitlabURL, err)
+	}
+
+	var baseTransport http.RoundTripper = cleanhttp.DefaultPooledTransport()
+	if httpClient != nil {
+		baseTransport = httpClient.Transport
+	}
+
+	if initArgs.Context != nil {
+		clientOptions = append(clientOptions, gitlab.WithRequestOptions(gitlab.WithContext(initArgs.Context)))
+	}
+
+	// The first token is pinned for the writes and the git operations, the other ones only serve the read-only calls
+	tokens := helpers.SplitTokens(initArgs.GitlabToken)
+	pinnedToken := ""
+
+	if len(tokens) > 0 {
+		pinnedToken = tokens[0]
+	}
+
+	// The rate limiter delays the requests, and adapts to the rate limit headers of every response
+	rateLimiter := NewRateLimiter(initArgs.Role, initArgs.RateLimit)
+	pinnedTransport := rateLimiter.transport(baseTransport)
+
+	gitlabClient, err := newGitlabClient(pinnedToken, pinnedTransport, rateLimiter, clientOptions)
+	if err != nil {
+		return nil, err
 	}
 
 	gitlabInstance := &GitlabInstance{
@@ -59,10 +118,23 @@ func NewGitlabInstance(initArgs *GitlabInstanceOpts) (*GitlabInstance, error) {
 		Groups:       make(map[string]*gitlab.Group),
 		Role:         initArgs.Role,
 		InstanceSize: initArgs.InstanceSize,
-		GitAuth:      helpers.BuildHTTPAuth("", initArgs.GitlabToken),
+		GitAuth:      helpers.BuildHTTPAuth("", pinnedToken),
+		GitTransport: transportConfig,
+		RateLimiter:  rateLimiter,
+	}
+
+	if len(tokens) > 1 {
+		pinned := &poolMember{client: gitlabClient, limiter: rateLimiter, position: 1}
+
+		gitlabInstance.tokens, err = buildTokenPool(initArgs.Role, tokens, pinned, pinnedTransport, baseTransport, clientOptions)
+		if err != nil {
+			return nil, err
+		}
+
+		zap.L().Info("Read-only API calls spread over the token pool", zap.String(ROLE, initArgs.Role), zap.Int("tokens", len(tokens)))
 	}
 
-	if initArgs.GitlabToken != "" {
+	if pinnedToken != "" {
 		// Get the current user ID
 		user, _, err := gitlabClient.Users.CurrentUser()
 		if err != nil {
@@ -70,11 +142,25 @@ func NewGitlabInstance(initArgs *GitlabInstanceOpts) (*GitlabInstance, error) {
 		}
 
 		gitlabInstance.UserID = user.ID
+		gitlabInstance.Username = user.Username
+		gitlabInstance.IsAdmin = user.IsAdmin
 	}
 
 	return gitlabInstance, nil
 }
 
+// newGitlabClient initializes a GitLab client authenticated with the token, sending its requests through the transport.
+func newGitlabClient(token string, transport http.RoundTripper, limiter *RateLimiter, clientOptions []gitlab.ClientOptionFunc) (*gitlab.Client, error) {
+	options := append(slices.Clone(clientOptions), gitlab.WithHTTPClient(&http.Client{Transport: transport}), gitlab.WithCustomLimiter(limiter))
+
+	gitlabClient, err := gitlab.NewClient(token, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize GitLab client: %w", err)
+	}
+
+	return gitlabClient, nil
+}
+
 // AddProject adds a project to the GitLabInstance
 // with the given projectPath and project object.
 // It uses a mutex to ensure thread-safe access to the Projects map.
@@ -146,28 +232,21 @@ func (g *GitlabInstance) IsSource() bool {
 	return g.Role == ROLE_SOURCE
 }
 
-// IsVersionGreaterThanThreshold checks if the GitLab instance version is below the defined threshold.
-// It retrieves the metadata from the GitLab instance and compares the version
-// with the INSTANCE_SEMVER_THRESHOLD.
-func (g *GitlabInstance) IsVersionGreaterThanThreshold() (bool, error) {
+// GetVersion retrieves the version of the GitLab instance from its metadata.
+func (g *GitlabInstance) GetVersion() (*semver.Version, error) {
 	metadata, _, err := g.Gitlab.Metadata.GetMetadata()
 	if err != nil {
-		return false, fmt.Errorf("failed to get GitLab version: %w", err)
+		return nil, fmt.Errorf("failed to get GitLab version: %w", err)
 	}
 
 	zap.L().Debug("GitLab Instance version", zap.String(ROLE, g.Role), zap.String("version", metadata.Version))
 
 	currentVer, err := semver.NewVersion(metadata.Version)
 	if err != nil {
-		return false, fmt.Errorf("failed to parse GitLab version: %w", err)
-	}
-
-	thresholdVer, err := semver.NewVersion(INSTANCE_SEMVER_THRESHOLD)
-	if err != nil {
-		return false, fmt.Errorf("failed to parse version threshold: %w", err)
+		return nil, fmt.Errorf("failed to parse GitLab version: %w", err)
 	}
 
-	return currentVer.GreaterThanEqual(thresholdVer), nil
+	return currentVer, nil
 }
 
 // IsLicensePremium checks if the GitLab instance has a premium license.
@@ -189,7 +268,7 @@ func (g *GitlabInstance) IsLicensePremium() (bool, error) {
 
 // FetchAll retrieves all projects and groups from the GitLab instance
 // that match the filters and stores them in the instance cache.
-func (g *GitlabInstance) FetchAll(projectFilters, groupFilters map[string]struct{}, mirrorMapping *utils.MirrorMapping) []error {
+func (g *GitlabInstance) FetchAll(ctx context.Context, projectFilters, groupFilters map[string]struct{}, mirrorMapping *utils.MirrorMapping) []error {
 	zap.L().Info("Fetching all projects and groups from GitLab instance", zap.String(ROLE, g.Role), zap.String(INSTANCE_SIZE, g.InstanceSize), zap.Int("projects", len(projectFilters)), zap.Int("groups", len(groupFilters)))
 
 	waitGroup := sync.WaitGroup{}
@@ -200,14 +279,14 @@ func (g *GitlabInstance) FetchAll(projectFilters, groupFilters map[string]struct
 	go func() {
 		defer waitGroup.Done()
 
-		if err := g.FetchAndProcessGroups(&groupFilters, mirrorMapping); err != nil {
+		if err := g.FetchAndProcessGroups(ctx, &groupFilters, mirrorMapping); err != nil {
 			errCh <- err
 		}
 	}()
 	go func() {
 		defer waitGroup.Done()
 
-		if err := g.FetchAndProcessProjects(&projectFilters, &groupFilters, mirrorMapping); err != nil {
+		if err := g.FetchAndProcessProjects(ctx, &projectFilters, &groupFilters, mirrorMapping); err != nil {
 			errCh <- err
 		}
 	}()
@@ -219,23 +298,25 @@ func (g *GitlabInstance) FetchAll(projectFilters, groupFilters map[string]struct
 }
 
 // GetParentNamespaceID retrieves the parent namespace ID for a given project or group path.
-// It checks if the parent path is already in the instance groups cache.
+// It checks if the parent path is already in the instance groups cache,
+// and looks the namespace up otherwise (a parent namespace left out of the mirror mapping for instance).
 //
-// If not, it returns an error indicating that the parent group was not found.
-func (g *GitlabInstance) GetParentNamespaceID(projectOrGroupPath string) (int64, error) {
-	parentGroupID := int64(-1)
+// If the namespace does not exist either, it returns an error indicating that the parent group was not found.
+func (g *GitlabInstance) GetParentNamespaceID(ctx context.Context, projectOrGroupPath string) (int64, error) {
 	parentPath := filepath.Dir(projectOrGroupPath)
+	if parentPath == "." || parentPath == "/" {
+		return -1, nil
+	}
 
-	var err error = nil
+	// Check if parent path is already in the instance groups cache
+	if parentGroup := g.GetGroup(parentPath); parentGroup != nil {
+		return parentGroup.ID, nil
+	}
 
-	if parentPath != "." && parentPath != "/" {
-		// Check if parent path is already in the instance groups cache
-		if parentGroup := g.GetGroup(parentPath); parentGroup != nil {
-			parentGroupID = parentGroup.ID
-		} else {
-			err = fmt.Errorf("parent group not found for path: %s", parentPath)
-		}
+	namespace, _, err := g.Gitlab.Namespaces.GetNamespace(parentPath, gitlab.WithContext(ctx))
+	if err != nil {
+		return -1, fmt.Errorf("parent group not found for path %s: %w", parentPath, err)
 	}
 
-	return parentGroupID, err
+	return namespace.ID, nil
 }
@@ -6,6 +6,8 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/boxboxjason/gitlab-sync/pkg/helpers"
+
 	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
 )
 
@@ -58,6 +60,57 @@ func TestNewGitlabInstance(t *testing.T) {
 	}
 }
 
+func TestNewGitlabInstanceTransport(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HEADER_CONTENT_TYPE, HEADER_ACCEPT)
+		fmt.Fprint(w, `{"id": 1, "username": "testuser"}`)
+	}))
+	t.Cleanup(server.Close)
+
+	tests := []struct {
+		name          string
+		transport     *helpers.TransportOptions
+		expectedError bool
+	}{
+		{
+			name:          "Default transport rejects the untrusted certificate",
+			transport:     nil,
+			expectedError: true,
+		},
+		{
+			name:      "Insecure skip verify",
+			transport: &helpers.TransportOptions{InsecureSkipVerify: true},
+		},
+		{
+			name:          "Invalid transport settings",
+			transport:     &helpers.TransportOptions{ClientCertFile: "client.pem"},
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			instance, err := NewGitlabInstance(&GitlabInstanceOpts{
+				GitlabURL:    server.URL,
+				GitlabToken:  "test-token",
+				Role:         ROLE_SOURCE,
+				MaxRetries:   0,
+				InstanceSize: INSTANCE_SIZE_SMALL,
+				Transport:    tt.transport,
+			})
+			if (err != nil) != tt.expectedError {
+				t.Fatalf(EXPECTED_ERROR_MESSAGE, tt.expectedError, err)
+			}
+
+			if !tt.expectedError && instance.GitTransport == nil {
+				t.Error("expected the git transport settings to be set")
+			}
+		})
+	}
+}
+
 func TestAddProject(t *testing.T) {
 	instance := &GitlabInstance{
 		Projects: make(map[string]*gitlab.Project),
@@ -158,7 +211,7 @@ func TestGetParentNamespaceID(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
 			// Call the function with the test case parameters
-			gotID, err := gitlabInstance.GetParentNamespaceID(test.path)
+			gotID, err := gitlabInstance.GetParentNamespaceID(t.Context(), test.path)
 
 			// Check if the result matches the expected value
 			if gotID != test.expectedID {
@@ -173,50 +226,46 @@ func TestGetParentNamespaceID(t *testing.T) {
 	}
 }
 
-func TestIsVersionGreaterThanThreshold(t *testing.T) {
+func TestGetVersion(t *testing.T) {
 	tests := []struct {
 		name             string
 		version          string
 		expectedError    bool
-		expectedResponse bool
+		expectedResponse string
 		noApiResponse    bool
 	}{
 		{
-			name:             "Valid version under threshold",
+			name:             "Valid version",
 			version:          "15.0.0",
 			expectedError:    false,
-			expectedResponse: false,
+			expectedResponse: "15.0.0",
 		},
 		{
-			name:             "Valid version above threshold",
+			name:             "Valid version with suffix",
 			version:          "17.9.3-ce.0",
 			expectedError:    false,
-			expectedResponse: true,
+			expectedResponse: "17.9.3-ce.0",
 		},
 		{
-			name:             "Invalid version format with 1 dot",
-			version:          "invalid.version",
-			expectedError:    true,
-			expectedResponse: false,
+			name:          "Invalid version format with 1 dot",
+			version:       "invalid.version",
+			expectedError: true,
 		},
 		{
-			name:             "Invalid version format with 2 dots",
-			version:          "invalid.version.1",
-			expectedError:    true,
-			expectedResponse: false,
+			name:          "Invalid version format with 2 dots",
+			version:       "invalid.version.1",
+			expectedError: true,
 		},
 		{
-			name:             "Invalid empty version",
-			version:          "",
-			expectedError:    true,
-			expectedResponse: false,
+			name:          "Invalid empty version",
+			version:       "",
+			expectedError: true,
 		},
 		{
-			name:             "No API response",
-			version:          "",
-			expectedError:    true,
-			expectedResponse: false,
-			noApiResponse:    true,
+			name:          "No API response",
+			version:       "",
+			expectedError: true,
+			noApiResponse: true,
 		},
 	}
 
@@ -237,12 +286,12 @@ func TestIsVersionGreaterThanThreshold(t *testing.T) {
 				})
 			}
 
-			thresholdOk, err := gitlabInstance.IsVersionGreaterThanThreshold()
+			version, err := gitlabInstance.GetVersion()
 			if (err != nil) != test.expectedError {
 				t.Fatalf(EXPECTED_ERROR_MESSAGE, test.expectedError, err)
 			}
-			if thresholdOk != test.expectedResponse {
-				t.Errorf("expected thresholdOk: %v, got: %v", test.expectedResponse, thresholdOk)
+			if version != nil && version.Original() != test.expectedResponse {
+				t.Errorf("expected version: %s, got: %s", test.expectedResponse, version.Original())
 			}
 		})
 	}
@@ -280,10 +329,9 @@ func TestIsLicensePremium(t *testing.T) {
 			expectedResponse: false,
 		},
 		{
-			name:             "Error API response",
-			license:          "",
-			expectedError:    true,
-			expectedResponse: false,
+			name:          "Error API response",
+			license:       "",
+			expectedError: true,
 		},
 	}
 	// Iterate over the test cases
@@ -0,0 +1,270 @@
+package mirroring
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/boxboxjason/gitlab-sync/internal/utils"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+	"go.uber.org/zap"
+)
+
+const (
+	// INVENTORY_VERSION is the version of the inventory cache format, caches of other versions are discarded.
+	INVENTORY_VERSION = 2
+	// INVENTORY_FILE_NAME is the name of the inventory cache file in the state directory.
+	INVENTORY_FILE_NAME = "inventory.json"
+	// INVENTORY_TREE_MAX_AGE is the age above which a cached namespace tree is listed again instead of being revalidated.
+	INVENTORY_TREE_MAX_AGE = 24 * time.Hour
+	// inventoryClockSkew widens the revalidation window, covering the clock difference between the GitLab instances and the host.
+	inventoryClockSkew = 5 * time.Minute
+)
+
+// InventoryProject is a project pair recorded after its last successful sync
+// - synced_at: the time of the sync
+// - options: the fingerprint of the mirroring options the project was synced with
+// - source / destination: the source and destination projects as fetched before the sync.
+type InventoryProject struct {
+	SyncedAt    time.Time       `json:"synced_at"`
+	Source      *gitlab.Project `json:"source"`
+	Destination *gitlab.Project `json:"destination"`
+	Options     string          `json:"options"`
+}
+
+// InventoryTree is a mapped namespace tree as listed by a run
+// - listed_at: the time the listing started
+// - entities: the projects, or the descendant groups, of the tree as fetched.
+type InventoryTree[T any] struct {
+	ListedAt time.Time `json:"listed_at"`
+	Entities []*T      `json:"entities"`
+}
+
+// inventoryFile is the content of the inventory cache file, after its header (format version INVENTORY_VERSION)
+// - projects: the synced project pairs, by source path
+// - project_trees / group_trees: the listed namespace trees, by instance role and namespace path.
+type inventoryFile struct {
+	Projects     map[string]*InventoryProject              `json:"projects"`
+	ProjectTrees map[string]*InventoryTree[gitlab.Project] `json:"project_trees"`
+	GroupTrees   map[string]*InventoryTree[gitlab.Group]   `json:"group_trees"`
+	stateHeader
+}
+
+// InventoryCache persists the source and destination projects and groups between runs.
+// The listed namespace trees are revalidated with the requests filtered on the entities changed since their listing,
+// and the updates of the projects which did not change since their last successful sync are skipped.
+// The entries of the previous run which are neither confirmed unchanged nor synced again are dropped when the cache is saved.
+// A nil cache disables the caching.
+type InventoryCache struct {
+	previous inventoryFile
+	current  inventoryFile
+	path     string
+	mutex    sync.Mutex
+}
+
+// newInventoryFile returns an empty inventory for the given GitLab instances.
+func newInventoryFile(sourceURL, destinationURL string) inventoryFile {
+	return inventoryFile{
+		Projects:     make(map[string]*InventoryProject),
+		ProjectTrees: make(map[string]*InventoryTree[gitlab.Project]),
+		GroupTrees:   make(map[string]*InventoryTree[gitlab.Group]),
+		stateHeader:  stateHeader{SourceURL: sourceURL, DestinationURL: destinationURL, Version: INVENTORY_VERSION},
+	}
+}
+
+// NewInventoryCache returns an empty inventory cache, saved in the state directory.
+func NewInventoryCache(stateDir, sourceURL, destinationURL string) *InventoryCache {
+	return &InventoryCache{
+		previous: newInventoryFile(sourceURL, destinationURL),
+		current:  newInventoryFile(sourceURL, destinationURL),
+		path:     filepath.Join(stateDir, INVENTORY_FILE_NAME),
+	}
+}
+
+// LoadInventoryCache loads the inventory cache of the state directory.
+// A missing cache, or one recorded with another format version or for other GitLab instances, starts empty.
+// The returned cache is usable (empty) when the existing file cannot be read.
+func LoadInventoryCache(stateDir, sourceURL, destinationURL string) (*InventoryCache, error) {
+	cache := NewInventoryCache(stateDir, sourceURL, destinationURL)
+
+	var previous inventoryFile
+
+	loaded, err := loadStateFile(cache.path, cache.previous.stateHeader, &previous)
+	if !loaded {
+		return cache, err
+	}
+
+	if previous.Projects != nil {
+		cache.previous.Projects = previous.Projects
+	}
+
+	if previous.ProjectTrees != nil {
+		cache.previous.ProjectTrees = previous.ProjectTrees
+	}
+
+	if previous.GroupTrees != nil {
+		cache.previous.GroupTrees = previous.GroupTrees
+	}
+
+	zap.L().Info("Loaded inventory cache", zap.String("path", cache.path), zap.Int("projects", len(cache.previous.Projects)), zap.Int("projectTrees", len(cache.previous.ProjectTrees)), zap.Int("groupTrees", len(cache.previous.GroupTrees)))
+
+	return cache, nil
+}
+
+// ProjectUnchanged checks if a project pair is unchanged since its last successful sync:
+// same mirroring options, same destination project, and same source attributes and activity timestamps.
+// An unchanged project is kept in the cache.
+func (c *InventoryCache) ProjectUnchanged(sourcePath string, sourceProject, destinationProject *gitlab.Project, copyOptions *utils.MirroringOptions) bool {
+	if c == nil || sourceProject == nil || destinationProject == nil {
+		return false
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, ok := c.previous.Projects[sourcePath]
+	if !ok || entry.Source == nil || entry.Destination == nil || entry.Options != optionsFingerprint(copyOptions) {
+		return false
+	}
+
+	if entry.Destination.ID != destinationProject.ID || !sameSourceProject(entry.Source, sourceProject) {
+		return false
+	}
+
+	c.current.Projects[sourcePath] = entry
+
+	return true
+}
+
+// RecordProject records a successfully synced project pair.
+func (c *InventoryCache) RecordProject(sourcePath string, sourceProject, destinationProject *gitlab.Project, copyOptions *utils.MirroringOptions) {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.current.Projects[sourcePath] = &InventoryProject{
+		SyncedAt:    time.Now().UTC(),
+		Source:      sourceProject,
+		Destination: destinationProject,
+		Options:     optionsFingerprint(copyOptions),
+	}
+}
+
+// ProjectTree returns the projects of a namespace tree listed by the previous run, nil when the tree was not listed
+// or was listed more than INVENTORY_TREE_MAX_AGE ago.
+func (c *InventoryCache) ProjectTree(role, namespacePath string) *InventoryTree[gitlab.Project] {
+	if c == nil {
+		return nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return freshTree(c.previous.ProjectTrees[treeKey(role, namespacePath)])
+}
+
+// RecordProjectTree records the projects of a namespace tree, listed (or revalidated) from listedAt.
+func (c *InventoryCache) RecordProjectTree(role, namespacePath string, listedAt time.Time, projects []*gitlab.Project) {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.current.ProjectTrees[treeKey(role, namespacePath)] = &InventoryTree[gitlab.Project]{ListedAt: listedAt, Entities: projects}
+}
+
+// GroupTree returns the descendant groups of a namespace tree listed by the previous run, nil when the tree was not listed
+// or was listed more than INVENTORY_TREE_MAX_AGE ago.
+func (c *InventoryCache) GroupTree(role, namespacePath string) *InventoryTree[gitlab.Group] {
+	if c == nil {
+		return nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return freshTree(c.previous.GroupTrees[treeKey(role, namespacePath)])
+}
+
+// RecordGroupTree records the descendant groups of a namespace tree, listed (or revalidated) from listedAt.
+func (c *InventoryCache) RecordGroupTree(role, namespacePath string, listedAt time.Time, groups []*gitlab.Group) {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.current.GroupTrees[treeKey(role, namespacePath)] = &InventoryTree[gitlab.Group]{ListedAt: listedAt, Entities: groups}
+}
+
+// Save writes the inventory of the current run to the state directory.
+func (c *InventoryCache) Save() error {
+	if c == nil {
+		return nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return writeStateFile(c.path, &c.current)
+}
+
+// treeKey returns the key of a namespace tree of the instance with the given role.
+func treeKey(role, namespacePath string) string {
+	return role + ":" + namespacePath
+}
+
+// freshTree returns the tree unless it is missing or older than INVENTORY_TREE_MAX_AGE.
+func freshTree[T any](tree *InventoryTree[T]) *InventoryTree[T] {
+	if tree == nil || time.Since(tree.ListedAt) > INVENTORY_TREE_MAX_AGE {
+		return nil
+	}
+
+	return tree
+}
+
+// optionsFingerprint returns a digest of the mirroring options, changing whenever one of the options changes.
+func optionsFingerprint(copyOptions *utils.MirroringOptions) string {
+	content, err := json.Marshal(copyOptions)
+	if err != nil {
+		return ""
+	}
+
+	digest := sha256.Sum256(content)
+
+	return hex.EncodeToString(digest[:])
+}
+
+// sameSourceProject checks if the synced attributes and the activity timestamps of a source project are unchanged.
+func sameSourceProject(cached, fetched *gitlab.Project) bool {
+	return cached.ID == fetched.ID &&
+		sameTime(cached.LastActivityAt, fetched.LastActivityAt) &&
+		sameTime(cached.UpdatedAt, fetched.UpdatedAt) &&
+		cached.Name == fetched.Name &&
+		cached.Description == fetched.Description &&
+		cached.DefaultBranch == fetched.DefaultBranch &&
+		cached.Visibility == fetched.Visibility &&
+		cached.AvatarURL == fetched.AvatarURL &&
+		cached.Archived == fetched.Archived &&
+		slices.Equal(cached.Topics, fetched.Topics)
+}
+
+// sameTime checks if two optional timestamps are both unset or equal.
+func sameTime(first, second *time.Time) bool {
+	if first == nil || second == nil {
+		return first == second
+	}
+
+	return first.Equal(*second)
+}
@@ -0,0 +1,403 @@
+package mirroring
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/boxboxjason/gitlab-sync/internal/utils"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+const (
+	TEST_INVENTORY_SOURCE_URL      = "https://source.example.com"
+	TEST_INVENTORY_DESTINATION_URL = "https://destination.example.com"
+)
+
+func TestLoadInventoryCache(t *testing.T) {
+	tests := []struct {
+		name            string
+		content         string
+		expectedError   bool
+		expectedEntries int
+	}{
+		{
+			name:            "Missing cache",
+			expectedEntries: 0,
+		},
+		{
+			name:            "Valid cache",
+			content:         `{"version": 2, "source_url": "` + TEST_INVENTORY_SOURCE_URL + `", "destination_url": "` + TEST_INVENTORY_DESTINATION_URL + `", "projects": {"org/api": {"options": "abc"}}}`,
+			expectedEntries: 1,
+		},
+		{
+			name:            "Other format version",
+			content:         `{"version": 1, "source_url": "` + TEST_INVENTORY_SOURCE_URL + `", "destination_url": "` + TEST_INVENTORY_DESTINATION_URL + `", "projects": {"org/api": {}}}`,
+			expectedEntries: 0,
+		},
+		{
+			name:            "Other instances",
+			content:         `{"version": 2, "source_url": "https://other.example.com", "destination_url": "` + TEST_INVENTORY_DESTINATION_URL + `", "projects": {"org/api": {}}}`,
+			expectedEntries: 0,
+		},
+		{
+			name:            "Corrupted cache",
+			content:         `{"version": `,
+			expectedError:   true,
+			expectedEntries: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			stateDir := t.TempDir()
+			if tt.content != "" {
+				if err := os.WriteFile(filepath.Join(stateDir, INVENTORY_FILE_NAME), []byte(tt.content), 0o600); err != nil {
+					t.Fatalf("failed to write inventory cache: %v", err)
+				}
+			}
+
+			cache, err := LoadInventoryCache(stateDir, TEST_INVENTORY_SOURCE_URL, TEST_INVENTORY_DESTINATION_URL)
+			if (err != nil) != tt.expectedError {
+				t.Fatalf(EXPECTED_ERROR_MESSAGE, tt.expectedError, err)
+			}
+
+			if cache == nil {
+				t.Fatal("expected a usable cache")
+			}
+
+			if entries := len(cache.previous.Projects); entries != tt.expectedEntries {
+				t.Errorf("expected %d cached projects, got %d", tt.expectedEntries, entries)
+			}
+		})
+	}
+}
+
+func TestInventoryCacheProjectUnchanged(t *testing.T) {
+	lastActivity := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	sourceProject := &gitlab.Project{ID: 1, Name: "api", PathWithNamespace: "org/api", Topics: []string{"go"}, LastActivityAt: &lastActivity}
+	destinationProject := &gitlab.Project{ID: 10, PathWithNamespace: "mirror/api"}
+	copyOptions := &utils.MirroringOptions{DestinationPath: "mirror/api"}
+
+	newActivity := lastActivity.Add(time.Hour)
+
+	tests := []struct {
+		name               string
+		sourceProject      *gitlab.Project
+		destinationProject *gitlab.Project
+		copyOptions        *utils.MirroringOptions
+		expected           bool
+	}{
+		{
+			name:               "Unchanged project",
+			sourceProject:      &gitlab.Project{ID: 1, Name: "api", PathWithNamespace: "org/api", Topics: []string{"go"}, LastActivityAt: new(lastActivity)},
+			destinationProject: destinationProject,
+			copyOptions:        &utils.MirroringOptions{DestinationPath: "mirror/api"},
+			expected:           true,
+		},
+		{
+			name:               "New source activity",
+			sourceProject:      &gitlab.Project{ID: 1, Name: "api", PathWithNamespace: "org/api", Topics: []string{"go"}, LastActivityAt: &newActivity},
+			destinationProject: destinationProject,
+			copyOptions:        copyOptions,
+		},
+		{
+			name:               "Changed source topics",
+			sourceProject:      &gitlab.Project{ID: 1, Name: "api", PathWithNamespace: "org/api", Topics: []string{"go", "cli"}, LastActivityAt: &lastActivity},
+			destinationProject: destinationProject,
+			copyOptions:        copyOptions,
+		},
+		{
+			name:               "Recreated destination project",
+			sourceProject:      sourceProject,
+			destinationProject: &gitlab.Project{ID: 11, PathWithNamespace: "mirror/api"},
+			copyOptions:        copyOptions,
+		},
+		{
+			name:               "Changed mirroring options",
+			sourceProject:      sourceProject,
+			destinationProject: destinationProject,
+			copyOptions:        &utils.MirroringOptions{DestinationPath: "mirror/api", MirrorIssues: new(true)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			stateDir := t.TempDir()
+
+			recorder, err := LoadInventoryCache(stateDir, TEST_INVENTORY_SOURCE_URL, TEST_INVENTORY_DESTINATION_URL)
+			if err != nil {
+				t.Fatalf("failed to load inventory cache: %v", err)
+			}
+
+			recorder.RecordProject("org/api", sourceProject, destinationProject, copyOptions)
+
+			if err := recorder.Save(); err != nil {
+				t.Fatalf("failed to save inventory cache: %v", err)
+			}
+
+			cache, err := LoadInventoryCache(stateDir, TEST_INVENTORY_SOURCE_URL, TEST_INVENTORY_DESTINATION_URL)
+			if err != nil {
+				t.Fatalf("failed to reload inventory cache: %v", err)
+			}
+
+			if unchanged := cache.ProjectUnchanged("org/api", tt.sourceProject, tt.destinationProject, tt.copyOptions); unchanged != tt.expected {
+				t.Errorf("expected unchanged %v, got %v", tt.expected, unchanged)
+			}
+
+			// Only the confirmed entries are carried over to the next run
+			if _, kept := cache.current.Projects["org/api"]; kept != tt.expected {
+				t.Errorf("expected the entry to be kept %v, got %v", tt.expected, kept)
+			}
+		})
+	}
+}
+
+func TestRevalidateProjectTree(t *testing.T) {
+	cachedProjects := []*gitlab.Project{
+		{ID: 1, PathWithNamespace: "org/api", Description: "old"},
+		{ID: 2, PathWithNamespace: "org/infra/web"},
+	}
+
+	tests := []struct {
+		name             string
+		listedAt         time.Time
+		cached           bool
+		countHeaders     map[string]string
+		activeProjects   string
+		updatedProjects  string
+		expectedOK       bool
+		expectedProjects int
+		expectedAPI      string
+	}{
+		{
+			name:         "Tree not cached",
+			listedAt:     time.Now(),
+			countHeaders: map[string]string{"X-Total": "2"},
+		},
+		{
+			name:             "Unchanged tree",
+			listedAt:         time.Now(),
+			cached:           true,
+			countHeaders:     map[string]string{"X-Total": "2"},
+			expectedOK:       true,
+			expectedProjects: 2,
+			expectedAPI:      "old",
+		},
+		{
+			name:             "Project with activity",
+			listedAt:         time.Now(),
+			cached:           true,
+			countHeaders:     map[string]string{"X-Total": "2"},
+			activeProjects:   `[{"id": 1, "path_with_namespace": "org/api", "description": "new"}]`,
+			expectedOK:       true,
+			expectedProjects: 2,
+			expectedAPI:      "new",
+		},
+		{
+			name:             "Updated projects in and out of the tree",
+			listedAt:         time.Now(),
+			cached:           true,
+			countHeaders:     map[string]string{"X-Total": "2"},
+			updatedProjects:  `[{"id": 1, "path_with_namespace": "org/api", "description": "renamed"}, {"id": 3, "path_with_namespace": "other/cli"}]`,
+			expectedOK:       true,
+			expectedProjects: 2,
+			expectedAPI:      "renamed",
+		},
+		{
+			name:             "New project",
+			listedAt:         time.Now(),
+			cached:           true,
+			countHeaders:     map[string]string{"X-Total": "3"},
+			activeProjects:   `[{"id": 3, "path_with_namespace": "org/cli"}]`,
+			expectedOK:       true,
+			expectedProjects: 3,
+			expectedAPI:      "old",
+		},
+		{
+			name:             "Archived project",
+			listedAt:         time.Now(),
+			cached:           true,
+			countHeaders:     map[string]string{"X-Total": "1"},
+			updatedProjects:  `[{"id": 2, "path_with_namespace": "org/infra/web", "archived": true}]`,
+			expectedOK:       true,
+			expectedProjects: 1,
+			expectedAPI:      "old",
+		},
+		{
+			name:         "Deleted project",
+			listedAt:     time.Now(),
+			cached:       true,
+			countHeaders: map[string]string{"X-Total": "1"},
+		},
+		{
+			name:         "Uncounted tree",
+			listedAt:     time.Now(),
+			cached:       true,
+			countHeaders: map[string]string{"X-Next-Page": "2"},
+		},
+		{
+			name:         "Expired tree",
+			listedAt:     time.Now().Add(-INVENTORY_TREE_MAX_AGE - time.Hour),
+			cached:       true,
+			countHeaders: map[string]string{"X-Total": "2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mux, gitlabInstance := setupEmptyTestServer(t, ROLE_SOURCE, INSTANCE_SIZE_SMALL)
+
+			gitlabInstance.Inventory = NewInventoryCache(t.TempDir(), TEST_INVENTORY_SOURCE_URL, TEST_INVENTORY_DESTINATION_URL)
+			if tt.cached {
+				gitlabInstance.Inventory.previous.ProjectTrees[treeKey(ROLE_SOURCE, "org")] = &InventoryTree[gitlab.Project]{ListedAt: tt.listedAt, Entities: cachedProjects}
+			}
+
+			mux.HandleFunc("/api/v4/groups/org/projects", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set(HEADER_CONTENT_TYPE, HEADER_ACCEPT)
+
+				if r.URL.Query().Get("last_activity_after") == "" {
+					for key, value := range tt.countHeaders {
+						w.Header().Set(key, value)
+					}
+
+					fmt.Fprint(w, `[]`)
+
+					return
+				}
+
+				fmt.Fprint(w, orEmptyList(tt.activeProjects))
+			})
+			mux.HandleFunc("/api/v4/projects", func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Query().Get("updated_after") == "" {
+					w.WriteHeader(http.StatusBadRequest)
+
+					return
+				}
+
+				w.Header().Set(HEADER_CONTENT_TYPE, HEADER_ACCEPT)
+				fmt.Fprint(w, orEmptyList(tt.updatedProjects))
+			})
+
+			projects, ok := gitlabInstance.revalidateProjectTree(t.Context(), "org")
+			if ok != tt.expectedOK {
+				t.Fatalf("expected revalidation %v, got %v", tt.expectedOK, ok)
+			}
+
+			if len(projects) != tt.expectedProjects {
+				t.Errorf("expected %d projects, got %d", tt.expectedProjects, len(projects))
+			}
+
+			for _, project := range projects {
+				if project.ID == 1 && project.Description != tt.expectedAPI {
+					t.Errorf("expected project description %q, got %q", tt.expectedAPI, project.Description)
+				}
+			}
+		})
+	}
+}
+
+func TestRevalidateGroupTree(t *testing.T) {
+	cachedGroups := []*gitlab.Group{{ID: 2, FullPath: "org/infra"}, {ID: 3, FullPath: "org/infra/tools"}}
+
+	tests := []struct {
+		name           string
+		cached         bool
+		countHeaders   map[string]string
+		expectedOK     bool
+		expectedGroups int
+	}{
+		{
+			name:         "Tree not cached",
+			countHeaders: map[string]string{"X-Total": "2"},
+		},
+		{
+			name:           "Unchanged tree",
+			cached:         true,
+			countHeaders:   map[string]string{"X-Total": "2"},
+			expectedOK:     true,
+			expectedGroups: 2,
+		},
+		{
+			name:         "Added group",
+			cached:       true,
+			countHeaders: map[string]string{"X-Total": "3"},
+		},
+		{
+			name:         "Uncounted tree",
+			cached:       true,
+			countHeaders: map[string]string{"X-Next-Page": "2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mux, gitlabInstance := setupEmptyTestServer(t, ROLE_DESTINATION, INSTANCE_SIZE_SMALL)
+
+			gitlabInstance.Inventory = NewInventoryCache(t.TempDir(), TEST_INVENTORY_SOURCE_URL, TEST_INVENTORY_DESTINATION_URL)
+			if tt.cached {
+				gitlabInstance.Inventory.previous.GroupTrees[treeKey(ROLE_DESTINATION, "org")] = &InventoryTree[gitlab.Group]{ListedAt: time.Now(), Entities: cachedGroups}
+			}
+
+			mux.HandleFunc("/api/v4/groups/1/descendant_groups", func(w http.ResponseWriter, r *http.Request) {
+				for key, value := range tt.countHeaders {
+					w.Header().Set(key, value)
+				}
+
+				w.Header().Set(HEADER_CONTENT_TYPE, HEADER_ACCEPT)
+				fmt.Fprint(w, `[]`)
+			})
+
+			groups, ok := gitlabInstance.revalidateGroupTree(t.Context(), &gitlab.Group{ID: 1, FullPath: "org"})
+			if ok != tt.expectedOK {
+				t.Fatalf("expected revalidation %v, got %v", tt.expectedOK, ok)
+			}
+
+			if len(groups) != tt.expectedGroups {
+				t.Errorf("expected %d groups, got %d", tt.expectedGroups, len(groups))
+			}
+		})
+	}
+}
+
+// orEmptyList returns the JSON list, or an empty list when unset.
+func orEmptyList(list string) string {
+	if list == "" {
+		return `[]`
+	}
+
+	return list
+}
+
+func TestNilInventoryCache(t *testing.T) {
+	t.Parallel()
+
+	var cache *InventoryCache
+
+	if cache.ProjectUnchanged("org/api", &gitlab.Project{}, &gitlab.Project{}, &utils.MirroringOptions{}) {
+		t.Error("expected a disabled cache to report every project as changed")
+	}
+
+	cache.RecordProject("org/api", &gitlab.Project{}, &gitlab.Project{}, &utils.MirroringOptions{})
+	cache.RecordProjectTree(ROLE_SOURCE, "org", time.Now(), []*gitlab.Project{{}})
+	cache.RecordGroupTree(ROLE_SOURCE, "org", time.Now(), []*gitlab.Group{{}})
+
+	if cache.ProjectTree(ROLE_SOURCE, "org") != nil || cache.GroupTree(ROLE_SOURCE, "org") != nil {
+		t.Error("expected a disabled cache to hold no tree")
+	}
+
+	if err := cache.Save(); err != nil {
+		t.Errorf("expected a disabled cache to save nothing, got %v", err)
+	}
+}
@@ -1,7 +1,9 @@
 package mirroring
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 
 	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
 	"go.uber.org/zap"
@@ -21,7 +23,7 @@ const (
 // ================
 
 // FetchProjectIssues retrieves all issues for a project and processes them.
-func (g *GitlabInstance) FetchProjectIssues(project *gitlab.Project) ([]*gitlab.Issue, error) {
+func (g *GitlabInstance) FetchProjectIssues(ctx context.Context, project *gitlab.Project) ([]*gitlab.Issue, error) {
 	zap.L().Debug("Fetching issues for project", zap.String("project", project.PathWithNamespace))
 
 	fetchOpts := &gitlab.ListProjectIssuesOptions{
@@ -34,7 +36,7 @@ func (g *GitlabInstance) FetchProjectIssues(project *gitlab.Project) ([]*gitlab.
 	issues := make([]*gitlab.Issue, 0)
 
 	for {
-		fetchedIssues, resp, err := g.Gitlab.Issues.ListProjectIssues(project.ID, fetchOpts)
+		fetchedIssues, resp, err := g.Reader().Issues.ListProjectIssues(project.ID, fetchOpts, gitlab.WithContext(ctx))
 		if err != nil {
 			return nil, fmt.Errorf("failed to list issues for project %s: %w", project.PathWithNamespace, err)
 		}
@@ -52,9 +54,9 @@ func (g *GitlabInstance) FetchProjectIssues(project *gitlab.Project) ([]*gitlab.
 }
 
 // FetchProjectIssuesTitles retrieves all issue titles for a project and returns them as a map.
-func (g *GitlabInstance) FetchProjectIssuesTitles(project *gitlab.Project) (map[string]struct{}, error) {
+func (g *GitlabInstance) FetchProjectIssuesTitles(ctx context.Context, project *gitlab.Project) (map[string]struct{}, error) {
 	// Fetch existing issues from the destination project
-	issues, err := g.FetchProjectIssues(project)
+	issues, err := g.FetchProjectIssues(ctx, project)
 	if err != nil {
 		return nil, err
 	}
@@ -76,36 +78,49 @@ func (g *GitlabInstance) FetchProjectIssuesTitles(project *gitlab.Project) (map[
 // ================
 
 // MirrorIssue creates an issue in the destination project.
-func (g *GitlabInstance) MirrorIssue(project *gitlab.Project, issue *gitlab.Issue) error {
+// The source creation date is kept when the destination instance supports it, the issue being created
+// with the current date when the destination rejects it (the user is neither an administrator nor an owner).
+func (g *GitlabInstance) MirrorIssue(ctx context.Context, project *gitlab.Project, issue *gitlab.Issue) error {
 	zap.L().Debug("Creating issue in destination project", zap.String("issue", issue.Title), zap.String(ROLE_DESTINATION, project.HTTPURLToRepo))
 
-	// Create the issue in the destination project
-	_, _, err := g.Gitlab.Issues.CreateIssue(project.ID, &gitlab.CreateIssueOptions{
+	createOptions := &gitlab.CreateIssueOptions{
 		Title:        &issue.Title,
 		Description:  &issue.Description,
 		Labels:       (*gitlab.LabelOptions)(&issue.Labels),
-		CreatedAt:    issue.CreatedAt,
 		Confidential: &issue.Confidential,
 		DueDate:      issue.DueDate,
 		Weight:       &issue.Weight,
 		IssueType:    issue.IssueType,
-	})
+	}
+
+	if g.Supports(CAPABILITY_ISSUE_CREATED_AT) {
+		createOptions.CreatedAt = issue.CreatedAt
+	}
+
+	// Create the issue in the destination project
+	_, resp, err := g.Gitlab.Issues.CreateIssue(project.ID, createOptions, gitlab.WithContext(ctx))
+	if err != nil && createOptions.CreatedAt != nil && resp != nil && resp.StatusCode == http.StatusForbidden {
+		zap.L().Debug("Issue creation date rejected by the destination project, creating it with the current date", zap.String("issue", issue.Title), zap.String(ROLE_DESTINATION, project.HTTPURLToRepo))
+
+		createOptions.CreatedAt = nil
+		_, _, err = g.Gitlab.Issues.CreateIssue(project.ID, createOptions, gitlab.WithContext(ctx))
+	}
 
 	if err == nil && issue.State == string(gitlab.ClosedEventType) {
 		// If the issue is closed, close it in the destination project
-		err = g.CloseIssue(project, issue)
+		err = g.CloseIssue(ctx, project, issue)
 	}
 
 	return err
 }
 
 // CloseIssue closes an issue in the destination project.
-func (g *GitlabInstance) CloseIssue(project *gitlab.Project, issue *gitlab.Issue) error {
+func (g *GitlabInstance) CloseIssue(ctx context.Context, project *gitlab.Project, issue *gitlab.Issue) error {
 	zap.L().Debug("Closing issue in destination project", zap.String("issue", issue.Title), zap.String(ROLE_DESTINATION, project.HTTPURLToRepo))
 
 	_, _, err := g.Gitlab.Issues.UpdateIssue(project.ID, issue.IID, &gitlab.UpdateIssueOptions{
 		StateEvent: new(closeStateEvent),
-	})
+	}, gitlab.WithContext(ctx))
 	if err != nil {
 		return fmt.Errorf("failed to close issue %d in project %s: %w", issue.IID, project.PathWithNamespace, err)
 	}
@@ -119,8 +134,10 @@ func (g *GitlabInstance) CloseIssue(project *gitlab.Project, issue *gitlab.Issue
 
 // MirrorIssues mirrors issues from the source project to the destination project.
 // It fetches existing issues from the destination project and creates new issues for those that do not.
-func (destinationGitlab *GitlabInstance) MirrorIssues(sourceGitlab *GitlabInstance, sourceProject, destinationProject *gitlab.Project) []error {
+func (destinationGitlab *GitlabInstance) MirrorIssues(ctx context.Context, sourceGitlab *GitlabInstance, sourceProject, destinationProject *gitlab.Project) []error {
 	return mirrorProjectEntities(
+		ctx,
+		destinationGitlab.Scheduler,
 		"issue",
 		sourceProject,
 		destinationProject,
@@ -129,8 +146,6 @@ func (destinationGitlab *GitlabInstance) MirrorIssues(sourceGitlab *GitlabInstan
 		func(issue *gitlab.Issue) string {
 			return issue.Title
 		},
-		func(project *gitlab.Project, issue *gitlab.Issue) error {
-			return destinationGitlab.MirrorIssue(project, issue)
-		},
+		destinationGitlab.MirrorIssue,
 	)
 }
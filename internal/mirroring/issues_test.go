@@ -1,13 +1,21 @@
 package mirroring
 
 import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
 )
 
 func TestFetchProjectIssues(t *testing.T) {
 	_, gitlabInstance := setupTestServer(t, ROLE_DESTINATION, INSTANCE_SIZE_SMALL)
 	t.Run("Fetch Project Issues", func(t *testing.T) {
-		issues, err := gitlabInstance.FetchProjectIssues(TEST_PROJECT)
+		issues, err := gitlabInstance.FetchProjectIssues(t.Context(), TEST_PROJECT)
 		if err != nil {
 			t.Errorf("Unexpected error when fetching project issues: %v", err)
 		}
@@ -20,7 +28,7 @@ func TestFetchProjectIssues(t *testing.T) {
 func TestFetchProjectIssuesTitles(t *testing.T) {
 	_, gitlabInstance := setupTestServer(t, ROLE_DESTINATION, INSTANCE_SIZE_SMALL)
 	t.Run("Fetch Project Issues Titles", func(t *testing.T) {
-		issueTitles, err := gitlabInstance.FetchProjectIssuesTitles(TEST_PROJECT)
+		issueTitles, err := gitlabInstance.FetchProjectIssuesTitles(t.Context(), TEST_PROJECT)
 		if err != nil {
 			t.Errorf("Unexpected error when fetching project issues titles: %v", err)
 		}
@@ -33,17 +41,54 @@ func TestFetchProjectIssuesTitles(t *testing.T) {
 func TestMirrorIssue(t *testing.T) {
 	_, gitlabInstance := setupTestServer(t, ROLE_SOURCE, INSTANCE_SIZE_SMALL)
 	t.Run("Mirror Issue", func(t *testing.T) {
-		err := gitlabInstance.MirrorIssue(TEST_PROJECT, TEST_ISSUE)
+		err := gitlabInstance.MirrorIssue(t.Context(), TEST_PROJECT, TEST_ISSUE)
 		if err != nil {
 			t.Errorf("Unexpected error when mirroring issue: %v", err)
 		}
 	})
 }
 
+func TestMirrorIssueCreatedAtFallback(t *testing.T) {
+	t.Parallel()
+
+	mux, gitlabInstance := setupEmptyTestServer(t, ROLE_DESTINATION, INSTANCE_SIZE_SMALL)
+	gitlabInstance.Capabilities = NewCapabilities(semver.MustParse("19.4.0"), true, true)
+
+	var attempts, rejected atomic.Int32
+
+	// The destination user is neither an administrator nor an owner, the creation date is rejected
+	mux.HandleFunc(fmt.Sprintf("POST /api/v4/projects/%d/issues", TEST_PROJECT.ID), func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), "created_at") {
+			rejected.Add(1)
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"message": "403 Forbidden"}`)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"iid": 1}`)
+	})
+
+	issue := *TEST_ISSUE
+	issue.CreatedAt = new(time.Date(2019, 1, 3, 1, 55, 18, 0, time.UTC))
+
+	if err := gitlabInstance.MirrorIssue(t.Context(), TEST_PROJECT, &issue); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if attempts.Load() != 2 || rejected.Load() != 1 {
+		t.Errorf("expected the issue to be created again without its creation date, got %d attempts (%d rejected)", attempts.Load(), rejected.Load())
+	}
+}
+
 func TestCloseIssue(t *testing.T) {
 	_, gitlabInstance := setupTestServer(t, ROLE_DESTINATION, INSTANCE_SIZE_SMALL)
 	t.Run("Close Issue", func(t *testing.T) {
-		err := gitlabInstance.CloseIssue(TEST_PROJECT, TEST_ISSUE)
+		err := gitlabInstance.CloseIssue(t.Context(), TEST_PROJECT, TEST_ISSUE)
 		if err != nil {
 			t.Errorf("Unexpected error when closing issue: %v", err)
 		}
@@ -54,7 +99,7 @@ func TestMirrorIssues(t *testing.T) {
 	_, sourceGitlabInstance := setupTestServer(t, ROLE_SOURCE, INSTANCE_SIZE_SMALL)
 	_, destinationGitlabInstance := setupTestServer(t, ROLE_DESTINATION, INSTANCE_SIZE_SMALL)
 	t.Run("Mirror Issues", func(t *testing.T) {
-		errors := destinationGitlabInstance.MirrorIssues(sourceGitlabInstance, TEST_PROJECT, TEST_PROJECT_2)
+		errors := destinationGitlabInstance.MirrorIssues(t.Context(), sourceGitlabInstance, TEST_PROJECT, TEST_PROJECT_2)
 		if len(errors) > 0 {
 			t.Errorf("Unexpected errors when mirroring issues: %v", errors)
 		}
@@ -1,9 +1,13 @@
 package mirroring
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync"
 
 	"github.com/boxboxjason/gitlab-sync/internal/utils"
@@ -14,64 +18,147 @@ import (
 
 const (
 	initialFetchWorkers        = 2
-	initialFetchErrorBufferLen = 4
+	initialFetchErrorBufferLen = 7
 	processFilterWorkers       = 2
 )
 
-func createMirroringInstances(gitlabMirrorArgs *utils.ParserArgs) (*GitlabInstance, *GitlabInstance, error) {
-	sourceGitlabSize := INSTANCE_SIZE_SMALL
-	if gitlabMirrorArgs.SourceGitlabIsBig {
-		sourceGitlabSize = INSTANCE_SIZE_BIG
-	}
+// mirroringInstanceOpts builds the source and destination GitLab instances options from the command line arguments.
+func mirroringInstanceOpts(gitlabMirrorArgs *utils.ParserArgs) (*GitlabInstanceOpts, *GitlabInstanceOpts) {
+	sourceGitlabSize := instanceSizeSetting(gitlabMirrorArgs.SourceGitlabIsBig, gitlabMirrorArgs.SourceGitlabSize)
+	destinationGitlabSize := instanceSizeSetting(gitlabMirrorArgs.DestinationGitlabIsBig, gitlabMirrorArgs.DestinationGitlabSize)
 
-	sourceGitlabInstance, err := NewGitlabInstance(&GitlabInstanceOpts{
+	sourceOpts := &GitlabInstanceOpts{
 		GitlabURL:    gitlabMirrorArgs.SourceGitlabURL,
 		GitlabToken:  gitlabMirrorArgs.SourceGitlabToken,
+		Transport:    &gitlabMirrorArgs.SourceTransport,
 		Role:         ROLE_SOURCE,
 		MaxRetries:   gitlabMirrorArgs.Retry,
 		InstanceSize: sourceGitlabSize,
-	})
-	if err != nil {
-		return nil, nil, err
+		RateLimit:    gitlabMirrorArgs.SourceRateLimit,
 	}
 
-	destinationGitlabSize := INSTANCE_SIZE_SMALL
-	if gitlabMirrorArgs.DestinationGitlabIsBig {
-		destinationGitlabSize = INSTANCE_SIZE_BIG
-	}
-
-	destinationGitlabInstance, err := NewGitlabInstance(&GitlabInstanceOpts{
+	destinationOpts := &GitlabInstanceOpts{
 		GitlabURL:    gitlabMirrorArgs.DestinationGitlabURL,
 		GitlabToken:  gitlabMirrorArgs.DestinationGitlabToken,
+		Transport:    &gitlabMirrorArgs.DestinationTransport,
 		Role:         ROLE_DESTINATION,
 		MaxRetries:   gitlabMirrorArgs.Retry,
 		InstanceSize: destinationGitlabSize,
-	})
+		RateLimit:    gitlabMirrorArgs.DestinationRateLimit,
+	}
+
+	return sourceOpts, destinationOpts
+}
+
+// createMirroringInstances creates the source and destination GitLab instances of a mirroring run.
+// Their API requests are bound to the context, and they share the scheduler which stops once stop is closed.
+func createMirroringInstances(ctx context.Context, stop <-chan struct{}, gitlabMirrorArgs *utils.ParserArgs) (*GitlabInstance, *GitlabInstance, error) {
+	sourceOpts, destinationOpts := mirroringInstanceOpts(gitlabMirrorArgs)
+	sourceOpts.Context = ctx
+	destinationOpts.Context = ctx
+
+	sourceGitlabInstance, err := NewGitlabInstance(sourceOpts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	destinationGitlabInstance, err := NewGitlabInstance(destinationOpts)
 	if err != nil {
 		return nil, nil, err
 	}
 
+	// Both instances share the scheduler, bounding the total in-flight work
+	scheduler := NewScheduler(stop, gitlabMirrorArgs.APIConcurrency, gitlabMirrorArgs.GitConcurrency, gitlabMirrorArgs.EntityConcurrency)
+	sourceGitlabInstance.Scheduler = scheduler
+	destinationGitlabInstance.Scheduler = scheduler
+	destinationGitlabInstance.ProjectTimeout = gitlabMirrorArgs.ProjectTimeout
+
 	return sourceGitlabInstance, destinationGitlabInstance, nil
 }
 
-func setPullMirrorAvailability(destinationGitlabInstance *GitlabInstance, gitlabMirrorArgs *utils.ParserArgs) error {
-	pullMirrorAvailable, err := destinationGitlabInstance.IsPullMirrorAvailable(gitlabMirrorArgs.ForcePremium, gitlabMirrorArgs.ForceNonPremium)
-	switch {
-	case err != nil:
-		return err
-	case pullMirrorAvailable:
+// loadDestinationCapabilities resolves the destination instance capabilities once, before the sync steps consult them.
+// Failing version or license lookups do not stop the mirroring, they only disable the features depending on them.
+func loadDestinationCapabilities(ctx context.Context, destinationGitlabInstance *GitlabInstance, gitlabMirrorArgs *utils.ParserArgs) {
+	err := destinationGitlabInstance.LoadCapabilities(ctx, gitlabMirrorArgs.ForcePremium, gitlabMirrorArgs.ForceNonPremium, gitlabMirrorArgs.MirrorMapping)
+	if err != nil {
+		zap.L().Warn("Failed to check the destination GitLab instance capabilities, the features depending on them are disabled", zap.String(ROLE, destinationGitlabInstance.Role), zap.Error(err))
+	}
+
+	if destinationGitlabInstance.Supports(CAPABILITY_PULL_MIRROR) {
 		zap.L().Info("GitLab instance is compatible with the pull mirroring process", zap.String(ROLE, destinationGitlabInstance.Role), zap.String(INSTANCE_SIZE, destinationGitlabInstance.InstanceSize))
-	default:
-		zap.L().Warn("Destination GitLab instance is not compatible with the pull mirroring process (requires a >= 17.6 ; >= Premium destination GitLab instance)", zap.String(ROLE, destinationGitlabInstance.Role), zap.String(INSTANCE_SIZE, destinationGitlabInstance.InstanceSize))
-		zap.L().Warn("Will use local pull / push mirroring instead (takes a lot longer)", zap.String(ROLE, destinationGitlabInstance.Role), zap.String(INSTANCE_SIZE, destinationGitlabInstance.InstanceSize))
+	}
+}
+
+// loadInventoryCache loads the inventory cache of the state directory, caching is disabled without state directory.
+// With --full, the previous inventory is ignored and rewritten at the end of the run.
+// An unreadable cache is not fatal, every project is then synced and the cache is rewritten at the end of the run.
+func loadInventoryCache(gitlabMirrorArgs *utils.ParserArgs) *InventoryCache {
+	if gitlabMirrorArgs.StateDir == "" {
+		return nil
 	}
 
-	destinationGitlabInstance.PullMirrorAvailable = pullMirrorAvailable
+	if gitlabMirrorArgs.Full {
+		return NewInventoryCache(gitlabMirrorArgs.StateDir, gitlabMirrorArgs.SourceGitlabURL, gitlabMirrorArgs.DestinationGitlabURL)
+	}
 
-	return nil
+	cache, err := LoadInventoryCache(gitlabMirrorArgs.StateDir, gitlabMirrorArgs.SourceGitlabURL, gitlabMirrorArgs.DestinationGitlabURL)
+	if err != nil {
+		zap.L().Warn("Failed to load the inventory cache, every project will be synced", zap.Error(err))
+	}
+
+	return cache
+}
+
+// loadSyncState loads the project watermarks of the state directory, used by the --since last-success mode only.
+// With --full, the previous watermarks are ignored and recorded again at the end of the run.
+// An unreadable state is not fatal, every project is then synced and the state is rewritten at the end of the run.
+func loadSyncState(gitlabMirrorArgs *utils.ParserArgs) *SyncState {
+	if gitlabMirrorArgs.StateDir == "" || gitlabMirrorArgs.Since != SINCE_LAST_SUCCESS {
+		return nil
+	}
+
+	if gitlabMirrorArgs.Full {
+		return NewSyncState(gitlabMirrorArgs.StateDir, gitlabMirrorArgs.SourceGitlabURL, gitlabMirrorArgs.DestinationGitlabURL)
+	}
+
+	state, err := LoadSyncState(gitlabMirrorArgs.StateDir, gitlabMirrorArgs.SourceGitlabURL, gitlabMirrorArgs.DestinationGitlabURL)
+	if err != nil {
+		zap.L().Warn("Failed to load the sync state, every project will be synced", zap.Error(err))
+	}
+
+	return state
+}
+
+// loadGitCache opens the git cache directory, the repositories are mirrored through temporary clones without it.
+// An unusable directory is not fatal, the repositories are then mirrored through temporary clones as well.
+func loadGitCache(gitlabMirrorArgs *utils.ParserArgs) *helpers.GitCache {
+	if gitlabMirrorArgs.GitCacheDir == "" {
+		return nil
+	}
+
+	cache, err := helpers.NewGitCache(gitlabMirrorArgs.GitCacheDir, gitlabMirrorArgs.GitCacheMaxSize)
+	if err != nil {
+		zap.L().Warn("Failed to open the git cache, the repositories will be mirrored through temporary clones", zap.Error(err))
+	}
+
+	return cache
+}
+
+// saveStateFiles writes the inventory cache and the project watermarks of the run to the state directory.
+func (destinationGitlabInstance *GitlabInstance) saveStateFiles() []error {
+	var errs []error
+
+	for _, err := range []error{destinationGitlabInstance.Inventory.Save(), destinationGitlabInstance.Watermarks.Save()} {
+		if err != nil {
+			errs = append(errs, helpers.NewNonBlocking(err))
+		}
+	}
+
+	return errs
 }
 
 func fetchInitialData(
+	ctx context.Context,
 	sourceGitlabInstance *GitlabInstance,
 	destinationGitlabInstance *GitlabInstance,
 	gitlabMirrorArgs *utils.ParserArgs,
@@ -87,12 +174,12 @@ func fetchInitialData(
 	go func() {
 		defer waitGroup.Done()
 
-		errChannel <- sourceGitlabInstance.FetchAll(sourceProjectFilters, sourceGroupFilters, gitlabMirrorArgs.MirrorMapping)
+		errChannel <- sourceGitlabInstance.FetchAll(ctx, sourceProjectFilters, sourceGroupFilters, gitlabMirrorArgs.MirrorMapping)
 	}()
 	go func() {
 		defer waitGroup.Done()
 
-		errChannel <- destinationGitlabInstance.FetchAll(destinationProjectFilters, destinationGroupFilters, gitlabMirrorArgs.MirrorMapping)
+		errChannel <- destinationGitlabInstance.FetchAll(ctx, destinationProjectFilters, destinationGroupFilters, gitlabMirrorArgs.MirrorMapping)
 	}()
 
 	waitGroup.Wait()
@@ -103,22 +190,34 @@ func fetchInitialData(
 // It creates two GitLab instances (source and destination) and fetches the groups and projects from both instances.
 // It then processes the filters for groups and projects, and finally creates the groups and projects in the destination GitLab instance.
 // If the dry run flag is set, it will only print the groups and projects that would be created or updated.
-func MirrorGitlabs(gitlabMirrorArgs *utils.ParserArgs) []error {
+//
+// Cancelling the context aborts the run, including the in-flight API requests and git operations.
+// Closing stop only stops scheduling new work: the in-flight project syncs are drained, and the state files are saved.
+// An interrupted run returns a blocking ErrInterrupted error.
+func MirrorGitlabs(ctx context.Context, stop <-chan struct{}, gitlabMirrorArgs *utils.ParserArgs) []error {
 	zap.L().Info("Starting GitLab mirroring process", zap.String(ROLE_SOURCE, gitlabMirrorArgs.SourceGitlabURL), zap.String(ROLE_DESTINATION, gitlabMirrorArgs.DestinationGitlabURL))
 
-	sourceGitlabInstance, destinationGitlabInstance, err := createMirroringInstances(gitlabMirrorArgs)
+	sourceGitlabInstance, destinationGitlabInstance, err := createMirroringInstances(ctx, stop, gitlabMirrorArgs)
 	if err != nil {
 		return []error{helpers.NewBlocking(err)}
 	}
 
-	err = setPullMirrorAvailability(destinationGitlabInstance, gitlabMirrorArgs)
-	if err != nil {
-		return []error{helpers.NewBlocking(err)}
-	}
+	defer logRateLimitSummary(sourceGitlabInstance, destinationGitlabInstance)
+
+	loadDestinationCapabilities(ctx, destinationGitlabInstance, gitlabMirrorArgs)
+	destinationGitlabInstance.Inventory = loadInventoryCache(gitlabMirrorArgs)
+	// Both instances record their namespace trees in the same cache
+	sourceGitlabInstance.Inventory = destinationGitlabInstance.Inventory
+	destinationGitlabInstance.Watermarks = loadSyncState(gitlabMirrorArgs)
+	destinationGitlabInstance.GitCache = loadGitCache(gitlabMirrorArgs)
 
 	sourceProjectFilters, sourceGroupFilters, destinationProjectFilters, destinationGroupFilters := processFilters(gitlabMirrorArgs.MirrorMapping)
+	sourceGitlabInstance.ResolveInstanceSize(ctx, sourceProjectFilters, sourceGroupFilters, gitlabMirrorArgs.MirrorMapping)
+	destinationGitlabInstance.ResolveInstanceSize(ctx, destinationProjectFilters, destinationGroupFilters, gitlabMirrorArgs.MirrorMapping)
+
 	errCh := make(chan []error, initialFetchErrorBufferLen)
 	fetchInitialData(
+		ctx,
 		sourceGitlabInstance,
 		destinationGitlabInstance,
 		gitlabMirrorArgs,
@@ -129,19 +228,55 @@ func MirrorGitlabs(gitlabMirrorArgs *utils.ParserArgs) []error {
 		errCh,
 	)
 
+	// Expand the glob / regex mapping keys now that the source inventory is known
+	if destinationGitlabInstance.Scheduler.interrupted(ctx, poolAPI) == nil {
+		errCh <- expandMappingPatterns(ctx, sourceGitlabInstance, destinationGitlabInstance, gitlabMirrorArgs.MirrorMapping)
+	}
+
+	// An interrupted fetch leaves incomplete inventories, nothing is mirrored from them
+	if err := destinationGitlabInstance.Scheduler.interrupted(ctx, poolAPI); err != nil {
+		errCh <- []error{helpers.NewBlocking(err)}
+		close(errCh)
+
+		return helpers.MergeErrors(errCh)
+	}
+
+	// The rewrite rules may have mapped several fetched entities to the same destination path
+	if conflicts := gitlabMirrorArgs.MirrorMapping.DestinationConflicts(); len(conflicts) > 0 {
+		for _, conflict := range conflicts {
+			zap.L().Error("Conflicting destination path in the computed mirror mapping", zap.Error(conflict))
+		}
+
+		errCh <- []error{helpers.NewBlocking(errors.Join(conflicts...))}
+		close(errCh)
+
+		return helpers.MergeErrors(errCh)
+	}
+
 	zap.L().Debug("Fully Computed Mirror Mapping", zap.Any("MirrorMapping", gitlabMirrorArgs.MirrorMapping))
 
 	// In case of dry run, simply print the groups and projects that would be created or updated
 	if gitlabMirrorArgs.DryRun {
-		destinationGitlabInstance.DryRun(sourceGitlabInstance, gitlabMirrorArgs.MirrorMapping)
+		destinationGitlabInstance.DryRun(ctx, sourceGitlabInstance, gitlabMirrorArgs.MirrorMapping)
 
 		return nil
 	}
 
 	// Create groups and projects in the destination GitLab instance (Groups must be created before projects)
-	errCh <- destinationGitlabInstance.CreateGroups(sourceGitlabInstance, gitlabMirrorArgs.MirrorMapping)
+	errCh <- destinationGitlabInstance.CreateGroups(ctx, sourceGitlabInstance, gitlabMirrorArgs.MirrorMapping)
+
+	errCh <- destinationGitlabInstance.CreateProjects(ctx, sourceGitlabInstance, gitlabMirrorArgs.MirrorMapping)
+
+	// The state of the projects synced before an interruption is kept
+	errCh <- destinationGitlabInstance.saveStateFiles()
+
+	if err := destinationGitlabInstance.GitCache.Evict(); err != nil {
+		zap.L().Warn("Failed to evict the least recently used git cache entries", zap.Error(err))
+	}
 
-	errCh <- destinationGitlabInstance.CreateProjects(sourceGitlabInstance, gitlabMirrorArgs.MirrorMapping)
+	if err := destinationGitlabInstance.Scheduler.interrupted(ctx, poolAPI); err != nil {
+		errCh <- []error{helpers.NewBlocking(err)}
+	}
 
 	close(errCh)
 
@@ -202,8 +337,20 @@ func processFilters(filters *utils.MirrorMapping) (map[string]struct{}, map[stri
 }
 
 // DryRun prints the groups and projects that would be created or updated in dry run mode.
-func (destinationGitlabInstance *GitlabInstance) DryRun(sourceGitlabInstance *GitlabInstance, mirrorMapping *utils.MirrorMapping) []error {
+func (destinationGitlabInstance *GitlabInstance) DryRun(ctx context.Context, sourceGitlabInstance *GitlabInstance, mirrorMapping *utils.MirrorMapping) []error {
 	zap.L().Info("Dry run mode enabled, will not create groups or projects")
+
+	if expansions := mirrorMapping.Expansions(); len(expansions) > 0 {
+		zap.L().Info("Mirror mapping patterns expanded to:")
+
+		for _, expansion := range expansions {
+			_, err := fmt.Fprintf(os.Stdout, "  - %s %s (pattern) matched %s -> %s (destination gitlab)\n", expansion.Kind, expansion.Pattern, expansion.SourcePath, expansion.DestinationPath)
+			if err != nil {
+				return []error{helpers.NewNonBlocking(fmt.Errorf("failed to print pattern dry-run output: %w", err))}
+			}
+		}
+	}
+
 	zap.L().Info("Groups that will be created (or updated if they already exist):")
 
 	for sourceGroupPath, copyOptions := range mirrorMapping.GroupsSnapshot() {
@@ -225,7 +372,7 @@ func (destinationGitlabInstance *GitlabInstance) DryRun(sourceGitlabInstance *Gi
 			}
 
 			if helpers.Deref(copyOptions.MirrorReleases, false) {
-				err := destinationGitlabInstance.DryRunReleases(sourceGitlabInstance, sourceProject, copyOptions)
+				err := destinationGitlabInstance.DryRunReleases(ctx, sourceGitlabInstance, sourceProject, copyOptions)
 				if err != nil {
 					zap.L().Error("Failed to dry run releases", zap.Error(err))
 
@@ -235,30 +382,18 @@ func (destinationGitlabInstance *GitlabInstance) DryRun(sourceGitlabInstance *Gi
 		}
 	}
 
-	zap.L().Info("Dry run completed")
-
-	return nil
-}
+	if skippedProjects := mirrorMapping.SkippedProjects(); len(skippedProjects) > 0 {
+		zap.L().Info("Projects skipped by their group filters:")
 
-// ===========================================================================
-//                       INSTANCE HEALTH MANAGEMENT                         //
-// ===========================================================================
-
-// IsPullMirrorAvailable checks the destination GitLab instance for version and license compatibility.
-func (g *GitlabInstance) IsPullMirrorAvailable(forcePremium, forceNonPremium bool) (bool, error) {
-	zap.L().Info("Checking destination GitLab instance")
-
-	thresholdOk, err := g.IsVersionGreaterThanThreshold()
-	if err != nil {
-		return false, fmt.Errorf("destination GitLab instance version check failed: %w", err)
-	}
-
-	isPremium, err := g.IsLicensePremium()
-	if err != nil {
-		if !forcePremium && !forceNonPremium {
-			return false, fmt.Errorf("failed to check if destination GitLab instance is premium: %w", err)
+		for _, sourceProjectPath := range slices.Sorted(maps.Keys(skippedProjects)) {
+			_, err := fmt.Fprintf(os.Stdout, "  - %s (source gitlab) skipped: %s\n", sourceProjectPath, skippedProjects[sourceProjectPath])
+			if err != nil {
+				return []error{helpers.NewNonBlocking(fmt.Errorf("failed to print skipped project dry-run output: %w", err))}
+			}
 		}
 	}
 
-	return !forceNonPremium && (thresholdOk && (isPremium || forcePremium)), nil
+	zap.L().Info("Dry run completed")
+
+	return nil
 }
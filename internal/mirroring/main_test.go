@@ -1,7 +1,6 @@
 package mirroring
 
 import (
-	"net/http"
 	"reflect"
 	"testing"
 
@@ -216,101 +215,7 @@ func TestDryRun(t *testing.T) {
 			sourceGitlabInstance.AddGroup(TEST_GROUP_2)
 			sourceGitlabInstance.AddGroup(TEST_GROUP_2)
 
-			destinationGitlabInstance.DryRun(sourceGitlabInstance, gitlabMirrorArgs)
-		})
-	}
-}
-
-func TestIsPullMirrorAvailable(t *testing.T) {
-	const supportedVersion = "18.0.0"
-	const unsupportedVersion = "17.0.0"
-	tests := []struct {
-		name           string
-		licensePlan    string
-		version        string
-		expectedError  bool
-		expectedResult bool
-		forcePremium   bool
-	}{
-		{
-			name:           "Premium license, good version",
-			licensePlan:    PREMIUM_PLAN,
-			version:        supportedVersion,
-			expectedResult: true,
-		},
-		{
-			name:           "Ultimate license, good version",
-			licensePlan:    ULTIMATE_PLAN,
-			version:        supportedVersion,
-			expectedResult: true,
-		},
-		{
-			name:           "Free license, good version",
-			licensePlan:    "free",
-			version:        supportedVersion,
-			expectedResult: false,
-		},
-		{
-			name:           "Free license, good version, force premium",
-			licensePlan:    "free",
-			version:        supportedVersion,
-			expectedResult: true,
-			forcePremium:   true,
-		},
-		{
-			name:           "Premium license, bad version",
-			licensePlan:    PREMIUM_PLAN,
-			version:        unsupportedVersion,
-			expectedResult: false,
-		},
-		{
-			name:           "Ultimate license, bad version",
-			licensePlan:    ULTIMATE_PLAN,
-			version:        unsupportedVersion,
-			expectedResult: false,
-		},
-		{
-			name:           "Bad license, good version",
-			licensePlan:    "bad_license",
-			version:        supportedVersion,
-			expectedResult: false,
-		},
-		{
-			name:           "Bad license, bad version",
-			licensePlan:    "bad_license",
-			version:        unsupportedVersion,
-			expectedResult: false,
-		},
-		{
-			name:           "Error API response",
-			licensePlan:    "",
-			version:        "",
-			expectedError:  true,
-			expectedResult: false,
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			t.Parallel()
-			mux, gitlabInstance := setupEmptyTestServer(t, ROLE_DESTINATION, INSTANCE_SIZE_SMALL)
-			if !tt.expectedError {
-				mux.HandleFunc("/api/v4/license", func(w http.ResponseWriter, r *http.Request) {
-					w.WriteHeader(200)
-					w.Write([]byte(`{"plan": "` + tt.licensePlan + `", "expired": false}`))
-				})
-				mux.HandleFunc("/api/v4/metadata", func(w http.ResponseWriter, r *http.Request) {
-					w.WriteHeader(200)
-					w.Write([]byte(`{"version": "` + tt.version + `"}`))
-				})
-			}
-
-			pullMirrorAvailable, err := gitlabInstance.IsPullMirrorAvailable(tt.forcePremium, false)
-			if (err != nil) != tt.expectedError {
-				t.Fatalf("CheckDestinationInstance() error = %v, expectedError %v", err, tt.expectedError)
-			}
-			if pullMirrorAvailable != tt.expectedResult {
-				t.Errorf("CheckDestinationInstance() = %v, expectedResult %v", pullMirrorAvailable, tt.expectedResult)
-			}
+			destinationGitlabInstance.DryRun(t.Context(), sourceGitlabInstance, gitlabMirrorArgs)
 		})
 	}
 }
@@ -326,7 +231,7 @@ func TestMirrorGitlabsErrorWrapping(t *testing.T) {
 		Retry:                  1,
 	}
 
-	errors := MirrorGitlabs(args)
+	errors := MirrorGitlabs(t.Context(), nil, args)
 	if len(errors) == 0 {
 		t.Fatal("Expected errors, got none")
 	}
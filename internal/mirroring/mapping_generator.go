@@ -0,0 +1,133 @@
+package mirroring
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/boxboxjason/gitlab-sync/internal/utils"
+	"github.com/boxboxjason/gitlab-sync/pkg/helpers"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+	"go.uber.org/zap"
+)
+
+// ===========================================================================
+//                       MIRROR MAPPING GENERATION                          //
+// ===========================================================================
+
+// GenerateMirrorMapping discovers the tree of a source group and generates the mirror mapping copying it under destinationRoot.
+// The tree is traversed like a mirrored group (see FetchAndProcessGroupRecursive), and every discovered entity is written
+// as an explicit entry with default options, keeping the visibility of the source entity.
+// The collapse mode (COLLAPSE_NONE, COLLAPSE_GROUPS, COLLAPSE_ROOT) controls which entities get their own entry,
+// the others being inherited from their closest group entry when the mapping is used.
+func (g *GitlabInstance) GenerateMirrorMapping(ctx context.Context, fromGroup, destinationRoot, collapse string) (*utils.MirrorMapping, []error) {
+	fromGroup = strings.Trim(strings.TrimSpace(fromGroup), "/")
+	destinationRoot = strings.Trim(strings.TrimSpace(destinationRoot), "/")
+
+	if fromGroup == "" {
+		return nil, []error{errors.New("invalid (empty) source group")}
+	}
+
+	if destinationRoot == "" {
+		destinationRoot = fromGroup
+	}
+
+	if !slices.Contains([]string{COLLAPSE_NONE, COLLAPSE_GROUPS, COLLAPSE_ROOT}, collapse) {
+		return nil, []error{fmt.Errorf("invalid collapse mode %s, expected one of %s, %s, %s", collapse, COLLAPSE_NONE, COLLAPSE_GROUPS, COLLAPSE_ROOT)}
+	}
+
+	zap.L().Info("Discovering the source group tree", zap.String(ROLE, g.Role), zap.String("group", fromGroup))
+
+	// Traverse the source group tree, the discovered entities are stored with their inherited options
+	discoveryMapping := &utils.MirrorMapping{
+		Projects: make(map[string]*utils.MirroringOptions),
+		Groups: map[string]*utils.MirroringOptions{
+			fromGroup: {DestinationPath: destinationRoot},
+		},
+	}
+
+	errChan := make(chan error)
+
+	var collectorWaitGroup sync.WaitGroup
+
+	errs := make([]error, 0)
+
+	collectorWaitGroup.Go(func() {
+		for err := range errChan {
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+	})
+
+	walkErr := g.Scheduler.walk(ctx, poolAPI, []walkTask{func() []walkTask {
+		return g.FetchAndProcessGroupRecursive(ctx, fromGroup, fromGroup, discoveryMapping, errChan)
+	}})
+
+	close(errChan)
+	collectorWaitGroup.Wait()
+
+	if walkErr != nil {
+		errs = append(errs, walkErr)
+	}
+
+	if len(errs) > 0 {
+		return nil, helpers.MergeErrors(errs)
+	}
+
+	if g.GetGroup(fromGroup) == nil {
+		return nil, []error{fmt.Errorf("source group %s not found", fromGroup)}
+	}
+
+	// Write the discovered entities as explicit entries
+	mirrorMapping := &utils.MirrorMapping{
+		Projects: make(map[string]*utils.MirroringOptions),
+		Groups:   make(map[string]*utils.MirroringOptions),
+	}
+
+	for groupPath, options := range discoveryMapping.GroupsSnapshot() {
+		group := g.GetGroup(groupPath)
+		if group == nil || (collapse == COLLAPSE_ROOT && groupPath != fromGroup) {
+			continue
+		}
+
+		mirrorMapping.Groups[groupPath] = defaultMirroringOptions(options.DestinationPath, group.Visibility)
+	}
+
+	if collapse == COLLAPSE_NONE {
+		for projectPath, options := range discoveryMapping.ProjectsSnapshot() {
+			project := g.GetProject(projectPath)
+			if project == nil {
+				continue
+			}
+
+			mirrorMapping.Projects[projectPath] = defaultMirroringOptions(options.DestinationPath, project.Visibility)
+		}
+	}
+
+	zap.L().Info("Generated the mirror mapping", zap.String("group", fromGroup), zap.String("collapse", collapse), zap.Int("projects", len(mirrorMapping.Projects)), zap.Int("groups", len(mirrorMapping.Groups)))
+
+	return mirrorMapping, nil
+}
+
+// defaultMirroringOptions returns the mirroring options of a generated mapping entry:
+// every option is explicitly set to its default value, except the visibility which is copied from the source entity.
+func defaultMirroringOptions(destinationPath string, visibility gitlab.VisibilityValue) *utils.MirroringOptions {
+	if visibility == "" {
+		visibility = gitlab.PublicVisibility
+	}
+
+	return &utils.MirroringOptions{
+		DestinationPath:     destinationPath,
+		Visibility:          new(string(visibility)),
+		CI_CD_Catalog:       new(false),
+		MirrorIssues:        new(false),
+		MirrorTriggerBuilds: new(false),
+		MirrorReleases:      new(false),
+		ClaimOwnership:      new(false),
+	}
+}
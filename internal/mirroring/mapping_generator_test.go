@@ -0,0 +1,115 @@
+package mirroring
+
+import (
+	"testing"
+)
+
+func TestGenerateMirrorMapping(t *testing.T) {
+	tests := []struct {
+		name             string
+		destinationRoot  string
+		collapse         string
+		expectedGroups   map[string]string
+		expectedProjects map[string]string
+		expectedError    bool
+	}{
+		{
+			name:            "Every project and group",
+			destinationRoot: "mirror/org",
+			collapse:        COLLAPSE_NONE,
+			expectedGroups: map[string]string{
+				TEST_GROUP.FullPath:   "mirror/org",
+				TEST_GROUP_2.FullPath: "mirror/org/group2",
+			},
+			expectedProjects: map[string]string{
+				TEST_PROJECT.PathWithNamespace:   "mirror/org/project",
+				TEST_PROJECT_2.PathWithNamespace: "mirror/org/group2/project2",
+			},
+		},
+		{
+			name:            "Collapsed to groups",
+			destinationRoot: "mirror/org",
+			collapse:        COLLAPSE_GROUPS,
+			expectedGroups: map[string]string{
+				TEST_GROUP.FullPath:   "mirror/org",
+				TEST_GROUP_2.FullPath: "mirror/org/group2",
+			},
+			expectedProjects: map[string]string{},
+		},
+		{
+			name:     "Collapsed to the root group, destination root defaulting to the source group path",
+			collapse: COLLAPSE_ROOT,
+			expectedGroups: map[string]string{
+				TEST_GROUP.FullPath: TEST_GROUP.FullPath,
+			},
+			expectedProjects: map[string]string{},
+		},
+		{
+			name:          "Invalid collapse mode",
+			collapse:      "projects",
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, gitlabInstance := setupTestServer(t, ROLE_SOURCE, INSTANCE_SIZE_BIG)
+
+			mapping, errs := gitlabInstance.GenerateMirrorMapping(t.Context(), TEST_GROUP.FullPath, tt.destinationRoot, tt.collapse)
+			if (len(errs) > 0) != tt.expectedError {
+				t.Fatalf("expected error: %v, got: %v", tt.expectedError, errs)
+			}
+
+			if tt.expectedError {
+				return
+			}
+
+			if len(mapping.Groups) != len(tt.expectedGroups) || len(mapping.Projects) != len(tt.expectedProjects) {
+				t.Fatalf("expected %d groups and %d projects, got %d and %d", len(tt.expectedGroups), len(tt.expectedProjects), len(mapping.Groups), len(mapping.Projects))
+			}
+
+			for groupPath, destinationPath := range tt.expectedGroups {
+				options, ok := mapping.Groups[groupPath]
+				if !ok {
+					t.Fatalf("expected group %s in the generated mapping", groupPath)
+				}
+
+				if options.DestinationPath != destinationPath {
+					t.Errorf("expected destination path %s for group %s, got %s", destinationPath, groupPath, options.DestinationPath)
+				}
+
+				if options.Visibility == nil || *options.Visibility != "public" || options.MirrorIssues == nil || *options.MirrorIssues {
+					t.Errorf("expected default options for group %s, got %+v", groupPath, options)
+				}
+			}
+
+			for projectPath, destinationPath := range tt.expectedProjects {
+				options, ok := mapping.Projects[projectPath]
+				if !ok {
+					t.Fatalf("expected project %s in the generated mapping", projectPath)
+				}
+
+				if options.DestinationPath != destinationPath {
+					t.Errorf("expected destination path %s for project %s, got %s", destinationPath, projectPath, options.DestinationPath)
+				}
+
+				if options.IsInherited() {
+					t.Errorf("expected project %s to be an explicit entry", projectPath)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateMirrorMappingGroupNotFound(t *testing.T) {
+	t.Parallel()
+
+	_, gitlabInstance := setupTestServer(t, ROLE_SOURCE, INSTANCE_SIZE_BIG)
+
+	_, errs := gitlabInstance.GenerateMirrorMapping(t.Context(), "unknown/group", "mirror", COLLAPSE_NONE)
+	if len(errs) == 0 {
+		t.Fatal("expected an error for an unknown source group")
+	}
+}
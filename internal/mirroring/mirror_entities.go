@@ -1,6 +1,7 @@
 package mirroring
 
 import (
+	"context"
 	"fmt"
 	"sync"
 
@@ -10,23 +11,27 @@ import (
 	"go.uber.org/zap"
 )
 
+// mirrorProjectEntities creates the source project entities missing from the destination project.
+// The entities are created in parallel, bounded by the entity pool of the scheduler, until the context is done.
 func mirrorProjectEntities[T any](
+	ctx context.Context,
+	scheduler *Scheduler,
 	entityName string,
 	sourceProject *gitlab.Project,
 	destinationProject *gitlab.Project,
-	fetchExisting func(*gitlab.Project) (map[string]struct{}, error),
-	fetchSource func(*gitlab.Project) ([]T, error),
+	fetchExisting func(context.Context, *gitlab.Project) (map[string]struct{}, error),
+	fetchSource func(context.Context, *gitlab.Project) ([]T, error),
 	getKey func(T) string,
-	createEntity func(*gitlab.Project, T) error,
+	createEntity func(context.Context, *gitlab.Project, T) error,
 ) []error {
 	zap.L().Info("Starting "+entityName+" mirroring", zap.String(ROLE_SOURCE, sourceProject.HTTPURLToRepo), zap.String(ROLE_DESTINATION, destinationProject.HTTPURLToRepo))
 
-	existingKeys, err := fetchExisting(destinationProject)
+	existingKeys, err := fetchExisting(ctx, destinationProject)
 	if err != nil {
 		return []error{fmt.Errorf("failed to fetch existing %s for destination project %s: %w", entityName, destinationProject.HTTPURLToRepo, err)}
 	}
 
-	sourceEntities, err := fetchSource(sourceProject)
+	sourceEntities, err := fetchSource(ctx, sourceProject)
 	if err != nil {
 		return []error{fmt.Errorf("failed to fetch %s for source project %s: %w", entityName, sourceProject.HTTPURLToRepo, err)}
 	}
@@ -44,16 +49,17 @@ func mirrorProjectEntities[T any](
 			continue
 		}
 
-		waitGroup.Add(1)
-
-		go func(entity T, key string) {
-			defer waitGroup.Done()
-
-			createErr := createEntity(destinationProject, entity)
+		err := scheduler.spawn(ctx, poolEntity, &waitGroup, func() {
+			createErr := createEntity(ctx, destinationProject, sourceEntity)
 			if createErr != nil {
-				errorChannel <- fmt.Errorf("failed to create %s %s in project %s: %w", entityName, key, destinationProject.HTTPURLToRepo, createErr)
+				errorChannel <- fmt.Errorf("failed to create %s %s in project %s: %w", entityName, entityKey, destinationProject.HTTPURLToRepo, createErr)
 			}
-		}(sourceEntity, entityKey)
+		})
+		if err != nil {
+			errorChannel <- fmt.Errorf("stopped %s mirroring of project %s: %w", entityName, destinationProject.HTTPURLToRepo, err)
+
+			break
+		}
 	}
 
 	waitGroup.Wait()
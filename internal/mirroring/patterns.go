@@ -0,0 +1,265 @@
+package mirroring
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/boxboxjason/gitlab-sync/internal/utils"
+	"github.com/boxboxjason/gitlab-sync/pkg/helpers"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+	"go.uber.org/zap"
+)
+
+// ===========================================================================
+//                       MAPPING PATTERNS EXPANSION                         //
+// ===========================================================================
+
+// expandMappingPatterns expands the glob / regex keys of the mirror mapping against the source GitLab inventory.
+// Every matching source group or project becomes a concrete mirror mapping entry, the descendants of expanded groups
+// are stored like the ones of literal groups, and the destination instance cache is completed with the new destination paths.
+//
+// It must run after the initial fetch, and before the groups and projects are created.
+func expandMappingPatterns(ctx context.Context, sourceGitlab *GitlabInstance, destinationGitlab *GitlabInstance, mirrorMapping *utils.MirrorMapping) []error {
+	patterns := slices.Concat(mirrorMapping.Patterns(utils.GROUP), mirrorMapping.Patterns(utils.PROJECT))
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	zap.L().Info("Expanding mirror mapping patterns", zap.String(ROLE, ROLE_SOURCE), zap.Int("patterns", len(patterns)))
+
+	// Snapshot the mapping before the expansion, to only fetch the new destination paths afterwards
+	knownGroups := mirrorMapping.GroupsSnapshot()
+	knownProjects := mirrorMapping.ProjectsSnapshot()
+
+	groups, projects, errs := sourceGitlab.fetchPatternCandidates(ctx, patterns)
+
+	groupsByPath := make(map[string]*gitlab.Group, len(groups))
+	for _, group := range groups {
+		groupsByPath[group.FullPath] = group
+	}
+
+	projectsByPath := make(map[string]*gitlab.Project, len(projects))
+	for _, project := range projects {
+		projectsByPath[project.PathWithNamespace] = project
+	}
+
+	groupExpansions, groupErrs := mirrorMapping.ExpandPatterns(utils.GROUP, slices.Collect(maps.Keys(groupsByPath)))
+	projectExpansions, projectErrs := mirrorMapping.ExpandPatterns(utils.PROJECT, slices.Collect(maps.Keys(projectsByPath)))
+	errs = slices.Concat(errs, groupErrs, projectErrs)
+
+	expandedGroups := make(map[string]struct{}, len(groupExpansions))
+	for _, expansion := range groupExpansions {
+		expandedGroups[expansion.SourcePath] = struct{}{}
+		sourceGitlab.AddGroup(groupsByPath[expansion.SourcePath])
+	}
+
+	for _, expansion := range projectExpansions {
+		sourceGitlab.AddProject(projectsByPath[expansion.SourcePath])
+	}
+
+	// Store the descendants of the expanded groups, unless a closer literal group already covers them
+	for groupPath, group := range groupsByPath {
+		if _, ok := mirrorMapping.GetGroup(groupPath); ok {
+			continue
+		}
+
+		if parentGroupPath, ok := expandedAncestor(groupPath, expandedGroups, knownGroups); ok && !sourceGitlab.isExcluded(groupPath, parentGroupPath, mirrorMapping) {
+			sourceGitlab.StoreGroup(group, parentGroupPath, mirrorMapping)
+		}
+	}
+
+	for projectPath, project := range projectsByPath {
+		if _, ok := knownProjects[projectPath]; ok {
+			continue
+		}
+
+		if parentGroupPath, ok := expandedAncestor(projectPath, expandedGroups, knownGroups); ok && !sourceGitlab.isExcluded(projectPath, parentGroupPath, mirrorMapping) {
+			sourceGitlab.storeProject(project, parentGroupPath, mirrorMapping)
+		}
+	}
+
+	zap.L().Info("Expanded mirror mapping patterns", zap.Int("groups", len(groupExpansions)), zap.Int("projects", len(projectExpansions)))
+
+	// Fetch the destination groups and projects matching the new destination paths
+	destinationProjectFilters, destinationGroupFilters := newDestinationFilters(mirrorMapping, knownProjects, knownGroups)
+	if len(destinationProjectFilters) > 0 || len(destinationGroupFilters) > 0 {
+		errs = append(errs, destinationGitlab.FetchAll(ctx, destinationProjectFilters, destinationGroupFilters, mirrorMapping)...)
+	}
+
+	return helpers.MergeErrors(errs)
+}
+
+// fetchPatternCandidates retrieves the source groups and projects that may match the given patterns.
+// Only the groups under the patterns roots are listed, unless a pattern can match anything on the instance.
+// The listings run concurrently, bounded by the API pool of the scheduler.
+func (g *GitlabInstance) fetchPatternCandidates(ctx context.Context, patterns []*utils.MappingPattern) ([]*gitlab.Group, []*gitlab.Project, []error) {
+	var (
+		waitGroup sync.WaitGroup
+		mutex     sync.Mutex
+		groups    []*gitlab.Group
+		projects  []*gitlab.Project
+	)
+
+	// collect stores the result of a listing
+	collect := func(listedGroups []*gitlab.Group, listedProjects []*gitlab.Project) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		groups = append(groups, listedGroups...)
+		projects = append(projects, listedProjects...)
+	}
+
+	var fetches []func() error
+
+	roots := patternRoots(patterns)
+	if slices.Contains(roots, "") {
+		zap.L().Debug("Mirror mapping patterns are not rooted, listing the whole GitLab instance", zap.String(ROLE, g.Role))
+
+		fetches = []func() error{
+			func() error {
+				allGroups, err := g.FetchAllGroupsSmallInstance(ctx)
+				collect(allGroups, nil)
+
+				return err
+			},
+			func() error {
+				allProjects, err := g.FetchAllProjectsSmallInstance(ctx)
+				collect(nil, allProjects)
+
+				return err
+			},
+		}
+	} else {
+		for _, root := range roots {
+			fetches = append(fetches, func() error {
+				rootGroups, rootProjects, err := g.fetchGroupTree(ctx, root)
+				collect(rootGroups, rootProjects)
+
+				return err
+			})
+		}
+	}
+
+	errCh := make(chan error, len(fetches))
+
+	for _, fetch := range fetches {
+		err := g.Scheduler.spawn(ctx, poolAPI, &waitGroup, func() {
+			if err := fetch(); err != nil {
+				errCh <- err
+			}
+		})
+		if err != nil {
+			// The interruption is reported once the mirroring stops
+			break
+		}
+	}
+
+	waitGroup.Wait()
+	close(errCh)
+
+	return groups, projects, helpers.MergeErrors(errCh)
+}
+
+// fetchGroupTree retrieves a group, all of its descendant groups and all of the projects they contain.
+func (g *GitlabInstance) fetchGroupTree(ctx context.Context, groupPath string) ([]*gitlab.Group, []*gitlab.Project, error) {
+	zap.L().Debug("Fetching group tree from GitLab instance", zap.String(ROLE, g.Role), zap.String("group", groupPath))
+
+	group, _, err := g.Reader().Groups.GetGroup(groupPath, &gitlab.GetGroupOptions{WithProjects: new(false)}, gitlab.WithContext(ctx))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to retrieve pattern root group %s: %w", groupPath, err)
+	}
+
+	groups := []*gitlab.Group{group}
+
+	err = g.streamDescendantGroups(ctx, group.ID, func(descendants []*gitlab.Group) {
+		groups = append(groups, descendants...)
+	})
+	if err != nil {
+		return groups, nil, err
+	}
+
+	var projects []*gitlab.Project
+
+	err = g.streamGroupProjects(ctx, group.ID, func(groupProjects []*gitlab.Project) {
+		projects = append(projects, groupProjects...)
+	})
+
+	return groups, projects, err
+}
+
+// patternRoots returns the minimal set of group paths containing every path matched by the patterns.
+// Roots nested in another root are dropped, an empty root means the whole instance must be listed.
+func patternRoots(patterns []*utils.MappingPattern) []string {
+	roots := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		roots = append(roots, pattern.Root())
+	}
+
+	slices.Sort(roots)
+	roots = slices.Compact(roots)
+
+	minimalRoots := make([]string, 0, len(roots))
+	for _, root := range roots {
+		if slices.ContainsFunc(minimalRoots, func(parentRoot string) bool { return isSubPath(root, parentRoot) }) {
+			continue
+		}
+
+		minimalRoots = append(minimalRoots, root)
+	}
+
+	return minimalRoots
+}
+
+// isSubPath checks whether a path is the given parent path or one of its descendants.
+func isSubPath(path, parentPath string) bool {
+	return parentPath == "" || path == parentPath || (len(path) > len(parentPath) && path[:len(parentPath)+1] == parentPath+"/")
+}
+
+// expandedAncestor returns the closest ancestor of a path when it is a group expanded from a pattern.
+// The lookup stops at the first ancestor explicitly listed in the mirror mapping.
+func expandedAncestor(path string, expandedGroups map[string]struct{}, knownGroups map[string]*utils.MirroringOptions) (string, bool) {
+	for parentPath := filepath.Dir(path); parentPath != "." && parentPath != "/"; parentPath = filepath.Dir(parentPath) {
+		if _, ok := expandedGroups[parentPath]; ok {
+			return parentPath, true
+		}
+
+		if _, ok := knownGroups[parentPath]; ok {
+			return "", false
+		}
+	}
+
+	return "", false
+}
+
+// newDestinationFilters returns the destination project and group paths added to the mirror mapping
+// since the given snapshots were taken.
+func newDestinationFilters(mirrorMapping *utils.MirrorMapping, knownProjects, knownGroups map[string]*utils.MirroringOptions) (map[string]struct{}, map[string]struct{}) {
+	destinationProjectFilters := make(map[string]struct{})
+	destinationGroupFilters := make(map[string]struct{})
+
+	for group, copyOptions := range mirrorMapping.GroupsSnapshot() {
+		if _, ok := knownGroups[group]; !ok {
+			destinationGroupFilters[copyOptions.DestinationPath] = struct{}{}
+		}
+	}
+
+	for project, copyOptions := range mirrorMapping.ProjectsSnapshot() {
+		if _, ok := knownProjects[project]; ok {
+			continue
+		}
+
+		destinationProjectFilters[copyOptions.DestinationPath] = struct{}{}
+
+		destinationGroupPath := filepath.Dir(copyOptions.DestinationPath)
+		if destinationGroupPath != "" && destinationGroupPath != "." && destinationGroupPath != "/" {
+			destinationGroupFilters[destinationGroupPath] = struct{}{}
+		}
+	}
+
+	return destinationProjectFilters, destinationGroupFilters
+}
@@ -0,0 +1,184 @@
+package mirroring
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/boxboxjason/gitlab-sync/internal/utils"
+)
+
+func TestPatternRoots(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		expected []string
+	}{
+		{
+			name:     "Nested roots are dropped",
+			patterns: []string{"platform/*/charts", "platform/team/*", "platform-x/*", "infra/**"},
+			expected: []string{"infra", "platform", "platform-x"},
+		},
+		{
+			name:     "Unrooted pattern",
+			patterns: []string{"platform/*", "*-mirror"},
+			expected: []string{""},
+		},
+		{
+			name:     "Regex root",
+			patterns: []string{"re:^infra/aws/(.+)-terraform$"},
+			expected: []string{"infra/aws"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mapping := &utils.MirrorMapping{}
+			for _, pattern := range tt.patterns {
+				if err := mapping.AddPattern(utils.GROUP, pattern, &utils.MirroringOptions{DestinationPath: "mirror/{name}"}); err != nil {
+					t.Fatalf("failed to add pattern %s: %v", pattern, err)
+				}
+			}
+
+			if roots := patternRoots(mapping.Patterns(utils.GROUP)); !reflect.DeepEqual(roots, tt.expected) {
+				t.Errorf("expected roots %v, got %v", tt.expected, roots)
+			}
+		})
+	}
+}
+
+func TestExpandedAncestor(t *testing.T) {
+	expandedGroups := map[string]struct{}{
+		"platform/team-a": {},
+	}
+	knownGroups := map[string]*utils.MirroringOptions{
+		"platform/team-a/legacy": {DestinationPath: "legacy"},
+	}
+
+	tests := []struct {
+		path           string
+		expectedParent string
+		expectedOk     bool
+	}{
+		{"platform/team-a/charts", "platform/team-a", true},
+		{"platform/team-a/sub/charts", "platform/team-a", true},
+		{"platform/team-a/legacy/charts", "", false},
+		{"platform/team-b/charts", "", false},
+		{"platform/team-a", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			t.Parallel()
+
+			parent, ok := expandedAncestor(tt.path, expandedGroups, knownGroups)
+			if parent != tt.expectedParent || ok != tt.expectedOk {
+				t.Errorf("expected (%q, %t), got (%q, %t)", tt.expectedParent, tt.expectedOk, parent, ok)
+			}
+		})
+	}
+}
+
+func TestExpandMappingPatterns(t *testing.T) {
+	tests := []struct {
+		name             string
+		groupPatterns    map[string]string
+		projectPatterns  map[string]string
+		expectedGroups   map[string]string
+		expectedProjects map[string]string
+	}{
+		{
+			name: "Unrooted group pattern",
+			groupPatterns: map[string]string{
+				"re:.*/group2": "mirror/{name}",
+			},
+			expectedGroups: map[string]string{
+				TEST_GROUP_2.FullPath: "mirror/group2",
+			},
+			expectedProjects: map[string]string{
+				TEST_PROJECT_2.PathWithNamespace: "mirror/group2/project2",
+			},
+		},
+		{
+			name: "Rooted project pattern",
+			projectPatterns: map[string]string{
+				"test/group/*": "mirror/$1",
+			},
+			expectedGroups: map[string]string{},
+			expectedProjects: map[string]string{
+				TEST_PROJECT.PathWithNamespace: "mirror/project",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, sourceGitlabInstance := setupTestServer(t, ROLE_SOURCE, INSTANCE_SIZE_SMALL)
+			_, destinationGitlabInstance := setupTestServer(t, ROLE_DESTINATION, INSTANCE_SIZE_SMALL)
+
+			mapping := &utils.MirrorMapping{
+				Projects: make(map[string]*utils.MirroringOptions),
+				Groups:   make(map[string]*utils.MirroringOptions),
+			}
+
+			for pattern, destinationPath := range tt.groupPatterns {
+				if err := mapping.AddPattern(utils.GROUP, pattern, &utils.MirroringOptions{DestinationPath: destinationPath}); err != nil {
+					t.Fatalf("failed to add group pattern %s: %v", pattern, err)
+				}
+			}
+
+			for pattern, destinationPath := range tt.projectPatterns {
+				if err := mapping.AddPattern(utils.PROJECT, pattern, &utils.MirroringOptions{DestinationPath: destinationPath}); err != nil {
+					t.Fatalf("failed to add project pattern %s: %v", pattern, err)
+				}
+			}
+
+			if errs := expandMappingPatterns(t.Context(), sourceGitlabInstance, destinationGitlabInstance, mapping); len(errs) > 0 {
+				t.Fatalf("unexpected errors: %v", errs)
+			}
+
+			groups := make(map[string]string)
+			for group, options := range mapping.GroupsSnapshot() {
+				groups[group] = options.DestinationPath
+
+				if sourceGitlabInstance.GetGroup(group) == nil {
+					t.Errorf("expected group %s to be stored in the source instance cache", group)
+				}
+			}
+
+			projects := make(map[string]string)
+			for project, options := range mapping.ProjectsSnapshot() {
+				projects[project] = options.DestinationPath
+
+				if sourceGitlabInstance.GetProject(project) == nil {
+					t.Errorf("expected project %s to be stored in the source instance cache", project)
+				}
+			}
+
+			if !reflect.DeepEqual(groups, tt.expectedGroups) {
+				t.Errorf("expected groups %v, got %v", tt.expectedGroups, groups)
+			}
+
+			if !reflect.DeepEqual(projects, tt.expectedProjects) {
+				t.Errorf("expected projects %v, got %v", tt.expectedProjects, projects)
+			}
+		})
+	}
+}
+
+func TestExpandMappingPatternsWithoutPatterns(t *testing.T) {
+	t.Parallel()
+
+	mapping := &utils.MirrorMapping{
+		Projects: make(map[string]*utils.MirroringOptions),
+		Groups:   make(map[string]*utils.MirroringOptions),
+	}
+
+	// No request must be sent when the mapping holds no pattern
+	if errs := expandMappingPatterns(t.Context(), &GitlabInstance{}, &GitlabInstance{}, mapping); errs != nil {
+		t.Errorf("expected no errors, got %v", errs)
+	}
+}
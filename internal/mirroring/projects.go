@@ -1,9 +1,12 @@
 package mirroring
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"maps"
 	"path/filepath"
+	"slices"
 	"sync"
 	"time"
 
@@ -16,6 +19,7 @@ import (
 
 const (
 	projectsPerPage         = 100
+	branchesPerPage         = 100
 	updateProjectBaseTasks  = 3
 	updateProjectErrorLimit = 5
 	projectOwnerAccessLevel = 50
@@ -29,14 +33,14 @@ const (
 // It also updates the mirror mapping with the corresponding group creation options.
 //
 // The function is run in a goroutine for each project, and a wait group is used to wait for all goroutines to finish.
-func (g *GitlabInstance) FetchAndProcessProjects(projectFilters, groupFilters *map[string]struct{}, mirrorMapping *utils.MirrorMapping) []error {
+func (g *GitlabInstance) FetchAndProcessProjects(ctx context.Context, projectFilters, groupFilters *map[string]struct{}, mirrorMapping *utils.MirrorMapping) []error {
 	zap.L().Debug("Fetching and processing projects from GitLab instance", zap.String(ROLE, g.Role), zap.String(INSTANCE_SIZE, g.InstanceSize), zap.Int("projects", len(*projectFilters)), zap.Int("groups", len(*groupFilters)))
 
 	if !g.IsBig() {
-		return g.FetchAndProcessProjectsSmallInstance(projectFilters, groupFilters, mirrorMapping)
+		return g.FetchAndProcessProjectsSmallInstance(ctx, projectFilters, groupFilters, mirrorMapping)
 	}
 
-	return g.FetchAndProcessProjectsBigInstance(projectFilters, mirrorMapping)
+	return g.FetchAndProcessProjectsBigInstance(ctx, projectFilters, mirrorMapping)
 }
 
 // storeProject stores the project in the Gitlab instance projects cache
@@ -52,33 +56,34 @@ func (g *GitlabInstance) storeProject(project *gitlab.Project, parentGroupPath s
 			return
 		}
 
-		zap.L().Debug("Storing project in mirror mapping", zap.String("project", project.HTTPURLToRepo), zap.String("group", parentGroupPath))
-		// Retrieve the corresponding group creation options from the mirror mapping
-		groupCreationOptions, ok := mirrorMapping.GetGroup(parentGroupPath)
+		// Retrieve the options of the closest group configured in the mirror mapping
+		closestGroupPath, groupCreationOptions, ok := mirrorMapping.ClosestGroup(project.PathWithNamespace, parentGroupPath)
 		if !ok {
 			zap.L().Error("Group not found in mirror mapping", zap.String("group", parentGroupPath))
 
 			return
 		}
 
-		// Calculate the relative path between the project and the group
-		relativePath, err := filepath.Rel(parentGroupPath, project.PathWithNamespace)
+		zap.L().Debug("Storing project in mirror mapping", zap.String("project", project.HTTPURLToRepo), zap.String("group", closestGroupPath))
+
+		// Apply the project filters of the group
+		if keep, reason := groupCreationOptions.Filters.Match(project, time.Now()); !keep {
+			zap.L().Debug("Skipping project filtered out by its group filters", zap.String("project", project.PathWithNamespace), zap.String("group", closestGroupPath), zap.String("reason", reason))
+			mirrorMapping.SkipProject(project.PathWithNamespace, reason)
+
+			return
+		}
+
+		// Derive the project options (destination path relative to the closest group)
+		inheritedOptions, err := groupCreationOptions.Inherit(closestGroupPath, project.PathWithNamespace)
 		if err != nil {
-			zap.L().Error("Failed to calculate relative path for project", zap.String("project", project.HTTPURLToRepo), zap.String("group", parentGroupPath), zap.Error(err))
+			zap.L().Error("Failed to calculate relative path for project", zap.String("project", project.HTTPURLToRepo), zap.String("group", closestGroupPath), zap.Error(err))
 
 			return
 		}
 
 		// Add the project to the mirror mapping with the corresponding group creation options
-		mirrorMapping.AddProject(project.PathWithNamespace, &utils.MirroringOptions{
-			DestinationPath:     filepath.Join(groupCreationOptions.DestinationPath, relativePath),
-			CI_CD_Catalog:       groupCreationOptions.CI_CD_Catalog,
-			MirrorIssues:        groupCreationOptions.MirrorIssues,
-			MirrorTriggerBuilds: groupCreationOptions.MirrorTriggerBuilds,
-			Visibility:          groupCreationOptions.Visibility,
-			MirrorReleases:      groupCreationOptions.MirrorReleases,
-			ClaimOwnership:      groupCreationOptions.ClaimOwnership,
-		})
+		mirrorMapping.AddProject(project.PathWithNamespace, inheritedOptions)
 	}
 }
 
@@ -86,26 +91,238 @@ func (g *GitlabInstance) storeProject(project *gitlab.Project, parentGroupPath s
 //                         SMALL INSTANCE FUNCTIONS                         //
 // ===========================================================================
 
-// FetchAndProcessProjectsSmallInstance retrieves all projects from the small GitLab instance
-// and processes them to store in the instance cache.
-func (g *GitlabInstance) FetchAndProcessProjectsSmallInstance(projectFilters, groupFilters *map[string]struct{}, mirrorMapping *utils.MirrorMapping) []error {
-	allProjects, err := g.FetchAllProjectsSmallInstance()
-	if err != nil {
-		if len(allProjects) == 0 {
-			return []error{err}
-		} else {
-			zap.L().Warn("Failed to fetch all projects from GitLab instance", zap.String(ROLE, g.Role), zap.Error(err))
+// FetchAndProcessProjectsSmallInstance retrieves the projects of the mapped namespaces from the GitLab instance
+// and stores those matching the filters in the instance cache.
+// The projects of the mapped groups (subgroups included) are listed group by group, the other mapped projects
+// are retrieved individually. Each page of projects is processed as soon as it is received.
+func (g *GitlabInstance) FetchAndProcessProjectsSmallInstance(ctx context.Context, projectFilters, groupFilters *map[string]struct{}, mirrorMapping *utils.MirrorMapping) []error {
+	roots := namespaceRoots(*groupFilters)
+
+	var (
+		waitGroup  sync.WaitGroup
+		mutex      sync.Mutex
+		foundRoots []string
+	)
+
+	errCh := make(chan error, len(roots)+len(*projectFilters))
+
+	for _, root := range roots {
+		err := g.Scheduler.spawn(ctx, poolAPI, &waitGroup, func() {
+			err := g.listProjectTree(ctx, root, func(projects []*gitlab.Project) {
+				g.processProjectsSmallInstance(projects, projectFilters, groupFilters, mirrorMapping)
+			})
+
+			switch {
+			case errors.Is(err, gitlab.ErrNotFound):
+				zap.L().Debug("Mapped group not found in the GitLab instance", zap.String(ROLE, g.Role), zap.String("group", root))
+			case err != nil:
+				errCh <- err
+			default:
+				mutex.Lock()
+				foundRoots = append(foundRoots, root)
+				mutex.Unlock()
+			}
+		})
+		if err != nil {
+			// The interruption is reported once the mirroring stops
+			break
+		}
+	}
+
+	waitGroup.Wait()
+
+	// The mapped projects outside of the listed groups are retrieved individually
+	for projectPath := range *projectFilters {
+		if slices.ContainsFunc(foundRoots, func(root string) bool { return isSubPath(projectPath, root) }) {
+			continue
+		}
+
+		err := g.Scheduler.spawn(ctx, poolAPI, &waitGroup, func() {
+			project, _, err := g.Reader().Projects.GetProject(projectPath, &gitlab.GetProjectOptions{}, gitlab.WithContext(ctx))
+
+			switch {
+			case errors.Is(err, gitlab.ErrNotFound):
+				zap.L().Debug("Mapped project not found in the GitLab instance", zap.String(ROLE, g.Role), zap.String("project", projectPath))
+			case err != nil:
+				errCh <- fmt.Errorf("failed to retrieve project %s: %w", projectPath, err)
+			default:
+				g.processProjectsSmallInstance([]*gitlab.Project{project}, projectFilters, groupFilters, mirrorMapping)
+			}
+		})
+		if err != nil {
+			break
 		}
 	}
 
-	g.processProjectsSmallInstance(allProjects, projectFilters, groupFilters, mirrorMapping)
+	waitGroup.Wait()
+	close(errCh)
+
 	zap.L().Debug("Found matching projects in the GitLab instance", zap.String(ROLE, g.Role), zap.Int("projects", g.ProjectsLen()))
 
+	return helpers.MergeErrors(errCh)
+}
+
+// listProjectTree hands the projects of a mapped group tree to handlePage, and records the tree in the inventory cache.
+// The tree listed by the previous run is revalidated instead of being listed again, when it can be.
+func (g *GitlabInstance) listProjectTree(ctx context.Context, root string, handlePage func([]*gitlab.Project)) error {
+	listedAt := time.Now().UTC()
+
+	if projects, ok := g.revalidateProjectTree(ctx, root); ok {
+		handlePage(projects)
+		g.Inventory.RecordProjectTree(g.Role, root, listedAt, projects)
+
+		return nil
+	}
+
+	var projects []*gitlab.Project
+
+	err := g.streamGroupProjects(ctx, root, func(page []*gitlab.Project) {
+		projects = append(projects, page...)
+		handlePage(page)
+	})
+	if err != nil || g.Scheduler.interrupted(ctx, poolAPI) != nil {
+		return err
+	}
+
+	g.Inventory.RecordProjectTree(g.Role, root, listedAt, projects)
+
 	return nil
 }
 
+// revalidateProjectTree returns the projects of a group tree from the tree cached by the previous run,
+// only fetching the projects with activity (last_activity_after) or updated (updated_after) since its listing.
+// The tree must be listed again (false) when it is not cached, or when the projects it counts (X-Total) do not match
+// the revalidated ones (deleted, archived or transferred projects).
+func (g *GitlabInstance) revalidateProjectTree(ctx context.Context, root string) ([]*gitlab.Project, bool) {
+	tree := g.Inventory.ProjectTree(g.Role, root)
+	if tree == nil {
+		return nil, false
+	}
+
+	_, resp, err := g.Reader().Groups.ListGroupProjects(root, &gitlab.ListGroupProjectsOptions{
+		Archived:         new(false),
+		IncludeSubGroups: new(true),
+		WithShared:       new(false),
+		ListOptions:      gitlab.ListOptions{PerPage: 1},
+	}, gitlab.WithContext(ctx))
+	if err != nil || !isCounted(resp) {
+		return nil, false
+	}
+
+	projects := make(map[int64]*gitlab.Project, len(tree.Entities))
+	for _, project := range tree.Entities {
+		projects[project.ID] = project
+	}
+
+	since := tree.ListedAt.Add(-inventoryClockSkew)
+	revalidate := func(page []*gitlab.Project) {
+		for _, project := range page {
+			projects[project.ID] = project
+		}
+	}
+
+	err = g.streamGroupProjectsSince(ctx, root, &since, revalidate)
+	if err != nil {
+		return nil, false
+	}
+
+	err = g.streamUpdatedProjects(ctx, since, revalidate)
+	if err != nil || g.Scheduler.interrupted(ctx, poolAPI) != nil {
+		return nil, false
+	}
+
+	// The updated projects are listed on the whole instance, the ones out of the tree or archived are dropped
+	for id, project := range projects {
+		if project.Archived || !isSubPath(project.PathWithNamespace, root) {
+			delete(projects, id)
+		}
+	}
+
+	if int64(len(projects)) != resp.TotalItems {
+		zap.L().Debug("Cached group tree changed, listing it again", zap.String(ROLE, g.Role), zap.String("group", root), zap.Int("cached", len(projects)), zap.Int64("counted", resp.TotalItems))
+
+		return nil, false
+	}
+
+	zap.L().Debug("Revalidated cached group tree", zap.String(ROLE, g.Role), zap.String("group", root), zap.Int("projects", len(projects)))
+
+	return slices.Collect(maps.Values(projects)), true
+}
+
+// streamUpdatedProjects lists the projects of the instance updated since the given time, handing every page to handlePage as soon as it is received.
+// The listing stops once the scheduler stops, the interruption being reported once the mirroring stops.
+func (g *GitlabInstance) streamUpdatedProjects(ctx context.Context, since time.Time, handlePage func([]*gitlab.Project)) error {
+	fetchOpts := &gitlab.ListProjectsOptions{
+		UpdatedAfter: &since,
+		ListOptions: gitlab.ListOptions{
+			PerPage: projectsPerPage,
+			Page:    1,
+		},
+	}
+
+	for {
+		if g.Scheduler.interrupted(ctx, poolAPI) != nil {
+			return nil
+		}
+
+		projects, resp, err := g.Reader().Projects.ListProjects(fetchOpts, gitlab.WithContext(ctx))
+		if err != nil {
+			return fmt.Errorf("failed to list projects updated since %s: %w", since, err)
+		}
+
+		handlePage(projects)
+
+		if resp.NextPage == 0 {
+			return nil
+		}
+
+		fetchOpts.Page = resp.NextPage
+	}
+}
+
+// streamGroupProjects lists the projects of a group and of its subgroups, handing every page to handlePage as soon as it is received.
+// The projects shared with the group from other namespaces are left out.
+// The listing stops once the scheduler stops, the interruption being reported once the mirroring stops.
+func (g *GitlabInstance) streamGroupProjects(ctx context.Context, gid any, handlePage func([]*gitlab.Project)) error {
+	return g.streamGroupProjectsSince(ctx, gid, nil, handlePage)
+}
+
+// streamGroupProjectsSince lists the projects of a group and of its subgroups like streamGroupProjects,
+// only keeping those with activity since the given time when it is set.
+func (g *GitlabInstance) streamGroupProjectsSince(ctx context.Context, gid any, lastActivityAfter *time.Time, handlePage func([]*gitlab.Project)) error {
+	fetchOpts := &gitlab.ListGroupProjectsOptions{
+		Archived:          new(false),
+		IncludeSubGroups:  new(true),
+		WithShared:        new(false),
+		LastActivityAfter: lastActivityAfter,
+		ListOptions: gitlab.ListOptions{
+			PerPage: projectsPerPage,
+			Page:    1,
+		},
+	}
+
+	for {
+		if g.Scheduler.interrupted(ctx, poolAPI) != nil {
+			return nil
+		}
+
+		projects, resp, err := g.Reader().Groups.ListGroupProjects(gid, fetchOpts, gitlab.WithContext(ctx))
+		if err != nil {
+			return fmt.Errorf("failed to list projects of group %v: %w", gid, err)
+		}
+
+		handlePage(projects)
+
+		if resp.NextPage == 0 {
+			return nil
+		}
+
+		fetchOpts.Page = resp.NextPage
+	}
+}
+
 // FetchAllProjectsSmallInstance retrieves all projects from the small GitLab instance.
-func (g *GitlabInstance) FetchAllProjectsSmallInstance() ([]*gitlab.Project, error) {
+func (g *GitlabInstance) FetchAllProjectsSmallInstance(ctx context.Context) ([]*gitlab.Project, error) {
 	zap.L().Debug("Fetching all projects from GitLab instance", zap.String(ROLE, g.Role))
 
 	fetchOpts := &gitlab.ListProjectsOptions{
@@ -121,7 +338,12 @@ func (g *GitlabInstance) FetchAllProjectsSmallInstance() ([]*gitlab.Project, err
 	var allProjects []*gitlab.Project
 
 	for {
-		projects, resp, err := g.Gitlab.Projects.ListProjects(fetchOpts)
+		if g.Scheduler.interrupted(ctx, poolAPI) != nil {
+			// The interruption is reported once the mirroring stops
+			return allProjects, nil
+		}
+
+		projects, resp, err := g.Reader().Projects.ListProjects(fetchOpts, gitlab.WithContext(ctx))
 		if err != nil {
 			return allProjects, fmt.Errorf("failed to list projects: %w", err)
 		}
@@ -141,28 +363,17 @@ func (g *GitlabInstance) FetchAllProjectsSmallInstance() ([]*gitlab.Project, err
 // processProjectsSmallInstance processes the projects from the small GitLab instance
 // and stores those which match the filters in the instance cache.
 //
-// The function is run in a goroutine for each project.
-// It returns an error if any of the goroutines fail.
+// The projects are processed in the scheduled fetch task which received them,
+// since their processing does not call the GitLab API.
 func (g *GitlabInstance) processProjectsSmallInstance(allProjects []*gitlab.Project, projectFilters, groupFilters *map[string]struct{}, mirrorMapping *utils.MirrorMapping) {
 	zap.L().Debug("Processing projects from GitLab instance", zap.String(INSTANCE_SIZE, g.InstanceSize), zap.String(ROLE, g.Role), zap.Int("projects", len(allProjects)))
 
-	// Create a wait group to wait for all goroutines to finish
-	var waitGroup sync.WaitGroup
-
 	for _, project := range allProjects {
-		waitGroup.Add(1)
-
-		go func(project *gitlab.Project) {
-			defer waitGroup.Done()
-
-			group, matches := helpers.MatchPathAgainstFilters(project.PathWithNamespace, projectFilters, groupFilters)
-			if matches {
-				g.storeProject(project, group, mirrorMapping)
-			}
-		}(project)
+		group, matches := helpers.MatchPathAgainstFilters(project.PathWithNamespace, projectFilters, groupFilters)
+		if matches && !g.isExcluded(project.PathWithNamespace, group, mirrorMapping) {
+			g.storeProject(project, group, mirrorMapping)
+		}
 	}
-
-	waitGroup.Wait()
 }
 
 // ===========================================================================
@@ -171,22 +382,29 @@ func (g *GitlabInstance) processProjectsSmallInstance(allProjects []*gitlab.Proj
 
 // FetchAndProcessProjectsBigInstance retrieves all projects individually from the large GitLab instance
 // and processes them to store in the instance cache.
+// The source projects are resolved by batches through the GraphQL API when available.
 //
-// It uses goroutines to fetch each project in parallel and a wait group to wait for all goroutines to finish.
-// It returns an error if any of the goroutines fail.
-func (g *GitlabInstance) FetchAndProcessProjectsBigInstance(projectFilters *map[string]struct{}, mirrorMapping *utils.MirrorMapping) []error {
+// The projects are fetched in parallel, bounded by the API pool of the scheduler.
+// It returns an error if any of the fetches fail.
+func (g *GitlabInstance) FetchAndProcessProjectsBigInstance(ctx context.Context, projectFilters *map[string]struct{}, mirrorMapping *utils.MirrorMapping) []error {
+	if g.useGraphQL(mirrorMapping) {
+		errs, err := g.fetchAndProcessProjectsGraphQL(ctx, projectFilters, mirrorMapping)
+		if err == nil {
+			return errs
+		}
+
+		g.disableGraphQL(err)
+	}
+
 	// Fetch each project in parallel
 	var waitGroup sync.WaitGroup
 
 	projectsChan := make(chan *gitlab.Project, len(*projectFilters))
 	errCh := make(chan error, len(*projectFilters))
-	waitGroup.Add(len(*projectFilters))
-
-	for project := range *projectFilters {
-		go func(projectPath string) {
-			defer waitGroup.Done()
 
-			projectDetails, _, err := g.Gitlab.Projects.GetProject(projectPath, &gitlab.GetProjectOptions{})
+	for projectPath := range *projectFilters {
+		err := g.Scheduler.spawn(ctx, poolAPI, &waitGroup, func() {
+			projectDetails, _, err := g.Reader().Projects.GetProject(projectPath, &gitlab.GetProjectOptions{}, gitlab.WithContext(ctx))
 			if err != nil {
 				errCh <- fmt.Errorf("failed to retrieve project %s: %w", projectPath, err)
 
@@ -194,7 +412,11 @@ func (g *GitlabInstance) FetchAndProcessProjectsBigInstance(projectFilters *map[
 			}
 
 			projectsChan <- projectDetails
-		}(project)
+		})
+		if err != nil {
+			// The interruption is reported once the mirroring stops
+			break
+		}
 	}
 
 	waitGroup.Wait()
@@ -209,9 +431,7 @@ func (g *GitlabInstance) FetchAndProcessProjectsBigInstance(projectFilters *map[
 }
 
 // FetchAndProcessGroupProjects retrieves all projects from the group and processes them to store in the instance cache.
-func (g *GitlabInstance) FetchAndProcessGroupProjects(group *gitlab.Group, fetchOriginPath string, mirrorMapping *utils.MirrorMapping, errChan chan error, wg *sync.WaitGroup) {
-	defer wg.Done()
-
+func (g *GitlabInstance) FetchAndProcessGroupProjects(ctx context.Context, group *gitlab.Group, fetchOriginPath string, mirrorMapping *utils.MirrorMapping, errChan chan error) {
 	if group != nil {
 		// Retrieve all projects in the group
 		opt := &gitlab.ListGroupProjectsOptions{
@@ -223,13 +443,22 @@ func (g *GitlabInstance) FetchAndProcessGroupProjects(group *gitlab.Group, fetch
 		}
 
 		for {
-			projects, resp, err := g.Gitlab.Groups.ListGroupProjects(group.ID, opt)
+			if g.Scheduler.interrupted(ctx, poolAPI) != nil {
+				// The interruption is reported once the mirroring stops
+				return
+			}
+
+			projects, resp, err := g.Reader().Groups.ListGroupProjects(group.ID, opt, gitlab.WithContext(ctx))
 			if err != nil {
 				errChan <- fmt.Errorf("failed to retrieve projects for group %s: %w", group.Name, err)
+
+				return
 			}
 
 			for _, project := range projects {
-				g.storeProject(project, fetchOriginPath, mirrorMapping)
+				if !g.isExcluded(project.PathWithNamespace, fetchOriginPath, mirrorMapping) {
+					g.storeProject(project, fetchOriginPath, mirrorMapping)
+				}
 			}
 
 			if resp.CurrentPage >= resp.TotalPages {
@@ -247,7 +476,9 @@ func (g *GitlabInstance) FetchAndProcessGroupProjects(group *gitlab.Group, fetch
 
 // CreateProjects creates GitLab projects in the destination GitLab instance based on the mirror mapping.
 // It retrieves the source project path for each destination project and creates the project in the destination instance.
-func (destinationGitlab *GitlabInstance) CreateProjects(sourceGitlab *GitlabInstance, mirrorMapping *utils.MirrorMapping) []error {
+// The projects are created in parallel, bounded by the API pool of the scheduler, each within the project timeout.
+// Once the scheduler stops, the remaining projects are left untouched while the in-flight ones are drained.
+func (destinationGitlab *GitlabInstance) CreateProjects(ctx context.Context, sourceGitlab *GitlabInstance, mirrorMapping *utils.MirrorMapping) []error {
 	zap.L().Info("Creating projects in GitLab Instance", zap.String(ROLE, ROLE_DESTINATION))
 
 	// Create a wait group to wait for all goroutines to finish
@@ -267,16 +498,20 @@ func (destinationGitlab *GitlabInstance) CreateProjects(sourceGitlab *GitlabInst
 			continue
 		}
 
-		creationWaitGroup.Add(1)
-		// Create a goroutine to handle the project creation
-		go func(sourcePath string, destinationCopyOptions *utils.MirroringOptions) {
-			defer creationWaitGroup.Done()
+		// Handle the project creation once the scheduler has a free slot
+		err := destinationGitlab.Scheduler.spawn(ctx, poolAPI, &creationWaitGroup, func() {
+			projectCtx, cancel := destinationGitlab.projectContext(ctx)
+			defer cancel()
 
-			_, err := destinationGitlab.CreateProject(sourcePath, destinationCopyOptions, sourceGitlab)
+			_, err := destinationGitlab.CreateProject(projectCtx, sourceProjectPath, destinationProjectOptions, sourceGitlab)
 			if err != nil {
-				errorChan <- fmt.Errorf("failed to create project %s in destination GitLab instance: %v", destinationCopyOptions.DestinationPath, err)
+				errorChan <- fmt.Errorf("failed to create project %s in destination GitLab instance: %v", destinationProjectOptions.DestinationPath, err)
 			}
-		}(sourceProjectPath, destinationProjectOptions)
+		})
+		if err != nil {
+			// The interruption is reported once the mirroring stops
+			break
+		}
 	}
 
 	// Wait for all goroutines to finish & close the error channel
@@ -286,15 +521,27 @@ func (destinationGitlab *GitlabInstance) CreateProjects(sourceGitlab *GitlabInst
 	return helpers.MergeErrors(errorChan)
 }
 
+// projectContext returns the context of a project sync, bounded by the project timeout.
+func (destinationGitlab *GitlabInstance) projectContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if destinationGitlab.ProjectTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, destinationGitlab.ProjectTimeout)
+}
+
 // CreateProject creates a GitLab project in the destination GitLab instance based on the source project and mirror mapping.
 // It checks if the project already exists in the destination instance and creates it if not.
 // The function also handles the copying of project avatars from the source to the destination instance.
-func (destinationGitlab *GitlabInstance) CreateProject(sourceProjectPath string, projectCreationOptions *utils.MirroringOptions, sourceGitlab *GitlabInstance) (*gitlab.Project, []error) {
+func (destinationGitlab *GitlabInstance) CreateProject(ctx context.Context, sourceProjectPath string, projectCreationOptions *utils.MirroringOptions, sourceGitlab *GitlabInstance) (*gitlab.Project, []error) {
 	destinationProjectPath := projectCreationOptions.DestinationPath
 	// Check if the project already exists
 	destinationProject := destinationGitlab.GetProject(destinationProjectPath)
 
-	var err error
+	var (
+		err     error
+		created bool
+	)
 
 	sourceProject := sourceGitlab.GetProject(sourceProjectPath)
 	if sourceProject == nil {
@@ -304,10 +551,12 @@ func (destinationGitlab *GitlabInstance) CreateProject(sourceProjectPath string,
 	// Check if the project already exists in the destination GitLab instance
 	// If it does not exist, create it
 	if destinationProject == nil {
-		destinationProject, err = destinationGitlab.CreateProjectFromSource(sourceProject, projectCreationOptions)
+		destinationProject, err = destinationGitlab.CreateProjectFromSource(ctx, sourceProject, projectCreationOptions)
 		if err != nil || destinationProject == nil {
 			return nil, []error{fmt.Errorf("failed to create project %s in destination GitLab instance: %w", destinationProjectPath, err)}
 		}
+
+		created = true
 	}
 
 	// Reassert ownership on every run, not just when the project is first created.
@@ -315,14 +564,29 @@ func (destinationGitlab *GitlabInstance) CreateProject(sourceProjectPath string,
 	// reassign the mirror to itself, so re-claiming ownership here is what allows changing
 	// the user running the script on an already-mirrored project.
 	if helpers.Deref(projectCreationOptions.ClaimOwnership, false) {
-		ownershipErr := destinationGitlab.ClaimOwnershipToProject(destinationProject)
+		ownershipErr := destinationGitlab.ClaimOwnershipToProject(ctx, destinationProject)
 		if ownershipErr != nil {
 			zap.L().Warn("Failed to claim ownership of project", zap.String("project", destinationProject.PathWithNamespace), zap.Error(ownershipErr))
 		}
 	}
 
+	// Projects unchanged (or without source activity in --since last-success mode) since their last successful sync are not updated again,
+	// while a project created by this call is always updated
+	if !created {
+		unchanged := destinationGitlab.Inventory.ProjectUnchanged(sourceProjectPath, sourceProject, destinationProject, projectCreationOptions)
+		if destinationGitlab.Watermarks.Covers(sourceProjectPath, sourceProject, destinationProject, projectCreationOptions) || unchanged {
+			zap.L().Debug("Project unchanged since its last sync, skipping update", zap.String(ROLE_SOURCE, sourceProjectPath), zap.String(ROLE_DESTINATION, destinationProjectPath))
+
+			return destinationProject, nil
+		}
+	}
+
 	// If the project already exists, update it with the source project details
-	mergedError := destinationGitlab.UpdateProjectFromSource(sourceGitlab, sourceProject, destinationProject, projectCreationOptions)
+	mergedError := destinationGitlab.UpdateProjectFromSource(ctx, sourceGitlab, sourceProject, destinationProject, projectCreationOptions)
+	if len(mergedError) == 0 {
+		destinationGitlab.Inventory.RecordProject(sourceProjectPath, sourceProject, destinationProject, projectCreationOptions)
+		destinationGitlab.Watermarks.Record(sourceProjectPath, sourceProject, destinationProject, projectCreationOptions)
+	}
 
 	zap.L().Info("Completed project mirroring", zap.String(ROLE_SOURCE, sourceProjectPath), zap.String(ROLE_DESTINATION, destinationProjectPath))
 
@@ -331,13 +595,14 @@ func (destinationGitlab *GitlabInstance) CreateProject(sourceProjectPath string,
 
 // CreateProjectFromSource creates a GitLab project in the destination GitLab instance based on the source project.
 // It sets the project name, path, default branch, description, and visibility based on the source project.
+// The project path is the last segment of the destination path, and its name is rewritten by the mapping name rules.
 // The function also handles the setting of the namespace ID for the project.
 // It returns the created project or an error if the creation fails.
-func (g *GitlabInstance) CreateProjectFromSource(sourceProject *gitlab.Project, copyOptions *utils.MirroringOptions) (*gitlab.Project, error) {
+func (g *GitlabInstance) CreateProjectFromSource(ctx context.Context, sourceProject *gitlab.Project, copyOptions *utils.MirroringOptions) (*gitlab.Project, error) {
 	// Define the API call logic
 	projectCreationArgs := &gitlab.CreateProjectOptions{
-		Name:                &sourceProject.Name,
-		Path:                &sourceProject.Path,
+		Name:                new(copyOptions.DestinationName(sourceProject.PathWithNamespace, sourceProject.Name)),
+		Path:                new(filepath.Base(copyOptions.DestinationPath)),
 		DefaultBranch:       &sourceProject.DefaultBranch,
 		Description:         &sourceProject.Description,
 		MirrorTriggerBuilds: copyOptions.MirrorTriggerBuilds,
@@ -349,7 +614,7 @@ func (g *GitlabInstance) CreateProjectFromSource(sourceProject *gitlab.Project,
 
 	// Get the parent namespace ID for the project
 	// This is used to set the namespace ID for the project
-	parentNamespaceId, err := g.GetParentNamespaceID(copyOptions.DestinationPath)
+	parentNamespaceId, err := g.GetParentNamespaceID(ctx, copyOptions.DestinationPath)
 	if err != nil {
 		return nil, err
 	}
@@ -361,7 +626,7 @@ func (g *GitlabInstance) CreateProjectFromSource(sourceProject *gitlab.Project,
 	// Create the project in the destination GitLab instance
 	zap.L().Debug("Creating project in GitLab Instance", zap.String(ROLE, ROLE_DESTINATION), zap.String(ROLE_DESTINATION, copyOptions.DestinationPath))
 
-	destinationProject, _, err := g.Gitlab.Projects.CreateProject(projectCreationArgs)
+	destinationProject, _, err := g.Gitlab.Projects.CreateProject(projectCreationArgs, gitlab.WithContext(ctx))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create project %s: %w", copyOptions.DestinationPath, err)
 	}
@@ -374,7 +639,9 @@ func (g *GitlabInstance) CreateProjectFromSource(sourceProject *gitlab.Project,
 
 // enqueueOptionalProjectTasks enqueues optional tasks related to project creation, such as adding the project to the CI/CD catalog and mirroring issues.
 // It uses goroutines to perform these tasks concurrently and a wait group to wait for their completion.
+// The CI/CD catalog is skipped when the destination instance does not support it.
 func enqueueOptionalProjectTasks(
+	ctx context.Context,
 	destinationGitlabInstance *GitlabInstance,
 	sourceGitlabInstance *GitlabInstance,
 	sourceProject *gitlab.Project,
@@ -383,13 +650,13 @@ func enqueueOptionalProjectTasks(
 	errorChannel chan error,
 	waitGroup *sync.WaitGroup,
 ) {
-	if helpers.Deref(copyOptions.CI_CD_Catalog, false) {
+	if helpers.Deref(copyOptions.CI_CD_Catalog, false) && destinationGitlabInstance.Supports(CAPABILITY_CICD_CATALOG) {
 		waitGroup.Add(1)
 
 		go func(project *gitlab.Project) {
 			defer waitGroup.Done()
 
-			errorChannel <- destinationGitlabInstance.AddProjectToCICDCatalog(project)
+			errorChannel <- destinationGitlabInstance.AddProjectToCICDCatalog(ctx, project)
 		}(destinationProject)
 	}
 
@@ -399,7 +666,7 @@ func enqueueOptionalProjectTasks(
 		go func(sourceProj, destinationProj *gitlab.Project) {
 			defer waitGroup.Done()
 
-			allErrors := destinationGitlabInstance.MirrorIssues(sourceGitlabInstance, sourceProj, destinationProj)
+			allErrors := destinationGitlabInstance.MirrorIssues(ctx, sourceGitlabInstance, sourceProj, destinationProj)
 			nonNilErrors := make([]error, 0, len(allErrors))
 
 			for _, currentErr := range allErrors {
@@ -430,7 +697,7 @@ func enqueueOptionalProjectTasks(
 // It enables the project mirror pull, copies the project avatar, and optionally adds the project to the CI/CD catalog.
 // It also mirrors releases if the option is set.
 // The function uses goroutines to perform these tasks concurrently and waits for all of them to finish.
-func (destinationGitlabInstance *GitlabInstance) UpdateProjectFromSource(sourceGitlabInstance *GitlabInstance, sourceProject, destinationProject *gitlab.Project, copyOptions *utils.MirroringOptions) []error {
+func (destinationGitlabInstance *GitlabInstance) UpdateProjectFromSource(ctx context.Context, sourceGitlabInstance *GitlabInstance, sourceProject, destinationProject *gitlab.Project, copyOptions *utils.MirroringOptions) []error {
 	// Immediately capture pointers in local variables to avoid any late overrides
 	srcProj := sourceProject
 
@@ -447,22 +714,22 @@ func (destinationGitlabInstance *GitlabInstance) UpdateProjectFromSource(sourceG
 	go func(sourceProj, destinationProj *gitlab.Project) {
 		defer waitGroup.Done()
 
-		errorChannel <- destinationGitlabInstance.SyncProjectAttributes(sourceProj, destinationProj, copyOptions)
+		errorChannel <- destinationGitlabInstance.SyncProjectAttributes(ctx, sourceProj, destinationProj, copyOptions)
 	}(srcProj, dstProj)
 
 	go func(sourceProj, destinationProj *gitlab.Project) {
 		defer waitGroup.Done()
 
-		errorChannel <- destinationGitlabInstance.MirrorProjectGit(sourceGitlabInstance, sourceProj, destinationProj, copyOptions)
+		errorChannel <- destinationGitlabInstance.MirrorProjectGit(ctx, sourceGitlabInstance, sourceProj, destinationProj, copyOptions)
 	}(srcProj, dstProj)
 
 	go func(sourceProj, destinationProj *gitlab.Project) {
 		defer waitGroup.Done()
 
-		errorChannel <- sourceGitlabInstance.CopyProjectAvatar(destinationGitlabInstance, destinationProj, sourceProj)
+		errorChannel <- sourceGitlabInstance.CopyProjectAvatar(ctx, destinationGitlabInstance, destinationProj, sourceProj)
 	}(srcProj, dstProj)
 
-	enqueueOptionalProjectTasks(destinationGitlabInstance, sourceGitlabInstance, srcProj, dstProj, copyOptions, errorChannel, &waitGroup)
+	enqueueOptionalProjectTasks(ctx, destinationGitlabInstance, sourceGitlabInstance, srcProj, dstProj, copyOptions, errorChannel, &waitGroup)
 
 	// Wait for git duplication to finish
 	waitGroup.Wait()
@@ -475,7 +742,7 @@ func (destinationGitlabInstance *GitlabInstance) UpdateProjectFromSource(sourceG
 		go func(sourceProj, destinationProj *gitlab.Project) {
 			defer waitGroup.Done()
 
-			allErrors = destinationGitlabInstance.MirrorReleases(sourceGitlabInstance, sourceProj, destinationProj)
+			allErrors = destinationGitlabInstance.MirrorReleases(ctx, sourceGitlabInstance, sourceProj, destinationProj)
 		}(srcProj, dstProj)
 	}
 
@@ -493,11 +760,11 @@ func (destinationGitlabInstance *GitlabInstance) UpdateProjectFromSource(sourceG
 
 // SyncProjectAttributes updates the destination project with settings from the source project.
 // It checks if any diverged project data exists and if so, it overwrites it.
-func syncStandardProjectAttributes(sourceProject, destinationProject *gitlab.Project, gitlabEditOptions *gitlab.EditProjectOptions) bool {
+func syncStandardProjectAttributes(sourceProject, destinationProject *gitlab.Project, destinationName string, gitlabEditOptions *gitlab.EditProjectOptions) bool {
 	mismatch := false
 
-	if sourceProject.Name != destinationProject.Name {
-		gitlabEditOptions.Name = &sourceProject.Name
+	if destinationName != destinationProject.Name {
+		gitlabEditOptions.Name = &destinationName
 		mismatch = true
 	}
 
@@ -541,12 +808,12 @@ func syncMirrorProjectAttributes(destinationProject *gitlab.Project, copyOptions
 	return mismatch
 }
 
-func (destinationGitlabInstance *GitlabInstance) SyncProjectAttributes(sourceProject, destinationProject *gitlab.Project, copyOptions *utils.MirroringOptions) error {
+func (destinationGitlabInstance *GitlabInstance) SyncProjectAttributes(ctx context.Context, sourceProject, destinationProject *gitlab.Project, copyOptions *utils.MirroringOptions) error {
 	zap.L().Debug("Checking if project requires attributes resync", zap.String(ROLE_SOURCE, sourceProject.HTTPURLToRepo), zap.String(ROLE_DESTINATION, destinationProject.HTTPURLToRepo))
 
 	gitlabEditOptions := &gitlab.EditProjectOptions{}
 
-	missmatched := syncStandardProjectAttributes(sourceProject, destinationProject, gitlabEditOptions)
+	missmatched := syncStandardProjectAttributes(sourceProject, destinationProject, copyOptions.DestinationName(sourceProject.PathWithNamespace, sourceProject.Name), gitlabEditOptions)
 	if syncMirrorProjectAttributes(destinationProject, copyOptions, gitlabEditOptions) {
 		missmatched = true
 	}
@@ -558,7 +825,7 @@ func (destinationGitlabInstance *GitlabInstance) SyncProjectAttributes(sourcePro
 	}
 
 	if missmatched {
-		_, _, err := destinationGitlabInstance.Gitlab.Projects.EditProject(destinationProject.ID, gitlabEditOptions)
+		_, _, err := destinationGitlabInstance.Gitlab.Projects.EditProject(destinationProject.ID, gitlabEditOptions, gitlab.WithContext(ctx))
 		if err != nil {
 			return fmt.Errorf("failed to edit project %s: %w", destinationProject.HTTPURLToRepo, err)
 		}
@@ -571,12 +838,28 @@ func (destinationGitlabInstance *GitlabInstance) SyncProjectAttributes(sourcePro
 	return nil
 }
 
-func (destinationGitlabInstance *GitlabInstance) MirrorProjectGit(sourceGitlabInstance *GitlabInstance, sourceProject, destinationProject *gitlab.Project, mirrorOptions *utils.MirroringOptions) error {
-	if destinationGitlabInstance.PullMirrorAvailable {
-		return destinationGitlabInstance.EnableProjectMirrorPull(sourceProject, destinationProject, mirrorOptions)
+// MirrorProjectGit mirrors the repository of a project, through the pull mirror API when the destination instance supports it,
+// or by pulling and pushing the repository from this machine otherwise.
+func (destinationGitlabInstance *GitlabInstance) MirrorProjectGit(ctx context.Context, sourceGitlabInstance *GitlabInstance, sourceProject, destinationProject *gitlab.Project, mirrorOptions *utils.MirroringOptions) error {
+	if destinationGitlabInstance.Supports(CAPABILITY_PULL_MIRROR) {
+		return destinationGitlabInstance.EnableProjectMirrorPull(ctx, sourceProject, destinationProject, mirrorOptions)
+	}
+
+	refs, err := sourceGitlabInstance.refFilter(ctx, sourceProject, mirrorOptions.Git)
+	if err != nil {
+		return err
 	}
 
-	err := helpers.MirrorRepo(sourceProject.HTTPURLToRepo, destinationProject.HTTPURLToRepo, sourceGitlabInstance.GitAuth, destinationGitlabInstance.GitAuth)
+	// The local mirrorings are bounded by the git pool of the scheduler, limiting the parallel bare clones
+	// With a git cache, only the source updates are fetched and the changed refs pushed
+	err = destinationGitlabInstance.Scheduler.run(ctx, poolGit, func() error {
+		return destinationGitlabInstance.GitCache.MirrorRepo(
+			ctx,
+			sourceProject.ID,
+			&helpers.GitRemote{URL: sourceProject.HTTPURLToRepo, Auth: sourceGitlabInstance.GitAuth, Transport: sourceGitlabInstance.GitTransport},
+			&helpers.GitRemote{URL: destinationProject.HTTPURLToRepo, Auth: destinationGitlabInstance.GitAuth, Transport: destinationGitlabInstance.GitTransport, Refs: refs},
+		)
+	})
 	if err != nil {
 		return fmt.Errorf("failed to mirror repository from %s to %s: %w", sourceProject.PathWithNamespace, destinationProject.PathWithNamespace, err)
 	}
@@ -584,6 +867,60 @@ func (destinationGitlabInstance *GitlabInstance) MirrorProjectGit(sourceGitlabIn
 	return nil
 }
 
+// refFilter returns the filter of the refs of a source project pushed by the local git mirroring.
+// The protected branches of the project are only listed when the git options restrict the mirroring to them.
+func (sourceGitlabInstance *GitlabInstance) refFilter(ctx context.Context, sourceProject *gitlab.Project, gitOptions *utils.GitOptions) (*helpers.RefFilter, error) {
+	var protectedBranches []string
+
+	if gitOptions.OnlyProtectedBranches(false) {
+		branches, err := sourceGitlabInstance.fetchProtectedBranches(ctx, sourceProject)
+		if err != nil {
+			return nil, err
+		}
+
+		protectedBranches = branches
+	}
+
+	refs, err := gitOptions.RefFilter(protectedBranches)
+	if err != nil {
+		return nil, fmt.Errorf("invalid git options for project %s: %w", sourceProject.PathWithNamespace, err)
+	}
+
+	return refs, nil
+}
+
+// fetchProtectedBranches returns the names of the protected branches of a project.
+// The branches are listed with their protection status, which resolves the wildcard protection rules.
+func (g *GitlabInstance) fetchProtectedBranches(ctx context.Context, project *gitlab.Project) ([]string, error) {
+	var protectedBranches []string
+
+	fetchOpts := &gitlab.ListBranchesOptions{
+		ListOptions: gitlab.ListOptions{
+			PerPage: branchesPerPage,
+			Page:    1,
+		},
+	}
+
+	for {
+		branches, resp, err := g.Reader().Branches.ListBranches(project.ID, fetchOpts, gitlab.WithContext(ctx))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the branches of project %s: %w", project.PathWithNamespace, err)
+		}
+
+		for _, branch := range branches {
+			if branch.Protected {
+				protectedBranches = append(protectedBranches, branch.Name)
+			}
+		}
+
+		if resp.NextPage == 0 {
+			return protectedBranches, nil
+		}
+
+		fetchOpts.Page = resp.NextPage
+	}
+}
+
 // EnableProjectMirrorPull (re)configures the pull mirror for a project in the destination GitLab instance.
 // It sets the source project URL, enables mirroring, and configures other options like triggering builds and overwriting diverged branches.
 //
@@ -592,18 +929,23 @@ func (destinationGitlabInstance *GitlabInstance) MirrorProjectGit(sourceGitlabIn
 // makes this call, so calling it every time is what allows changing the user running the script.
 // That reassignment only takes effect if the calling user has Maintainer+ access on the
 // destination project, which is why ClaimOwnershipToProject also needs to run on every run.
-func (g *GitlabInstance) EnableProjectMirrorPull(sourceProject, destinationProject *gitlab.Project, mirrorOptions *utils.MirroringOptions) error {
+func (g *GitlabInstance) EnableProjectMirrorPull(ctx context.Context, sourceProject, destinationProject *gitlab.Project, mirrorOptions *utils.MirroringOptions) error {
 	zap.L().Debug("Reapplying project mirror pull", zap.String("sourceProject", sourceProject.HTTPURLToRepo), zap.String("destinationProject", destinationProject.HTTPURLToRepo))
 
 	desiredMirrorTriggerBuilds := helpers.Deref(mirrorOptions.MirrorTriggerBuilds, false)
 
+	// Pull mirrors either mirror the protected branches (the default) or the branches matching the regex, an empty regex clearing it
+	branchRegex := mirrorOptions.Git.MirrorBranchRegex()
+	onlyProtectedBranches := branchRegex == "" && mirrorOptions.Git.OnlyProtectedBranches(true)
+
 	_, _, err := g.Gitlab.Projects.ConfigureProjectPullMirror(destinationProject.ID, &gitlab.ConfigureProjectPullMirrorOptions{
 		URL:                              &sourceProject.HTTPURLToRepo,
-		OnlyMirrorProtectedBranches:      new(true),
+		OnlyMirrorProtectedBranches:      new(onlyProtectedBranches),
+		MirrorBranchRegex:                new(branchRegex),
 		Enabled:                          new(true),
 		MirrorOverwritesDivergedBranches: new(true),
 		MirrorTriggerBuilds:              new(desiredMirrorTriggerBuilds),
-	})
+	}, gitlab.WithContext(ctx))
 	if err != nil {
 		return fmt.Errorf("failed to configure pull mirror for project %s: %w", destinationProject.PathWithNamespace, err)
 	}
@@ -616,7 +958,7 @@ func (g *GitlabInstance) EnableProjectMirrorPull(sourceProject, destinationProje
 // and uploads it to the destination project.
 // The avatar is saved with a unique filename based on the current timestamp.
 // The function returns an error if any step fails, including downloading or uploading the avatar.
-func (sourceGitlabInstance *GitlabInstance) CopyProjectAvatar(destinationGitlabInstance *GitlabInstance, destinationProject, sourceProject *gitlab.Project) error {
+func (sourceGitlabInstance *GitlabInstance) CopyProjectAvatar(ctx context.Context, destinationGitlabInstance *GitlabInstance, destinationProject, sourceProject *gitlab.Project) error {
 	zap.L().Debug("Checking if project avatar is already set", zap.String("project", destinationProject.HTTPURLToRepo))
 
 	// Check if the destination project already has an avatar
@@ -629,13 +971,13 @@ func (sourceGitlabInstance *GitlabInstance) CopyProjectAvatar(destinationGitlabI
 	zap.L().Debug("Copying project avatar", zap.String(ROLE_SOURCE, sourceProject.HTTPURLToRepo), zap.String(ROLE_DESTINATION, destinationProject.HTTPURLToRepo))
 
 	// Download the source project avatar
-	sourceProjectAvatar, _, err := sourceGitlabInstance.Gitlab.Projects.DownloadAvatar(sourceProject.ID)
+	sourceProjectAvatar, _, err := sourceGitlabInstance.Reader().Projects.DownloadAvatar(sourceProject.ID, gitlab.WithContext(ctx))
 	if err != nil {
 		return fmt.Errorf("failed to download avatar for project %s: %w", sourceProject.HTTPURLToRepo, err)
 	}
 
 	// Upload the avatar to the destination project
-	_, _, err = destinationGitlabInstance.Gitlab.Projects.UploadAvatar(destinationProject.ID, sourceProjectAvatar, fmt.Sprintf("avatar-%d.png", time.Now().Unix()))
+	_, _, err = destinationGitlabInstance.Gitlab.Projects.UploadAvatar(destinationProject.ID, sourceProjectAvatar, fmt.Sprintf("avatar-%d.png", time.Now().Unix()), gitlab.WithContext(ctx))
 	if err != nil {
 		return fmt.Errorf("failed to upload avatar for project %s: %w", destinationProject.HTTPURLToRepo, err)
 	}
@@ -646,7 +988,7 @@ func (sourceGitlabInstance *GitlabInstance) CopyProjectAvatar(destinationGitlabI
 // AddProjectToCICDCatalog enables the CI/CD catalog resource for the project in the destination GitLab instance.
 // It skips the API call if the project is already registered as a CI/CD catalog resource.
 // Requires GitLab 19.3+ on the destination instance, since it relies on the "cicd_catalog_enabled" project API field introduced in that version.
-func (g *GitlabInstance) AddProjectToCICDCatalog(project *gitlab.Project) error {
+func (g *GitlabInstance) AddProjectToCICDCatalog(ctx context.Context, project *gitlab.Project) error {
 	if project.CICDCatalogEnabled {
 		zap.L().Debug("Project is already part of the CI/CD catalog, skipping", zap.String("project", project.HTTPURLToRepo))
 
@@ -655,7 +997,7 @@ func (g *GitlabInstance) AddProjectToCICDCatalog(project *gitlab.Project) error
 
 	zap.L().Debug("Adding project to CI/CD catalog", zap.String("project", project.HTTPURLToRepo))
 
-	_, _, err := g.Gitlab.Projects.EditProject(project.ID, &gitlab.EditProjectOptions{CICDCatalogEnabled: new(true)})
+	_, _, err := g.Gitlab.Projects.EditProject(project.ID, &gitlab.EditProjectOptions{CICDCatalogEnabled: new(true)}, gitlab.WithContext(ctx))
 	if err != nil {
 		return fmt.Errorf("failed to add project %s to CI/CD catalog: %w", project.PathWithNamespace, err)
 	}
@@ -671,19 +1013,19 @@ func (g *GitlabInstance) AddProjectToCICDCatalog(project *gitlab.Project) error
 // would otherwise never be promoted to Owner. It tries EditProjectMember first to upgrade an
 // existing direct membership, falling back to AddProjectMember when the user isn't a direct
 // member yet.
-func (g *GitlabInstance) ClaimOwnershipToProject(project *gitlab.Project) error {
+func (g *GitlabInstance) ClaimOwnershipToProject(ctx context.Context, project *gitlab.Project) error {
 	zap.L().Debug("Claiming ownership of project", zap.String("project", project.PathWithNamespace), zap.Int64("userID", g.UserID))
 
 	ownerAccessLevel := new(gitlab.AccessLevelValue(projectOwnerAccessLevel))
 
 	_, _, editErr := g.Gitlab.ProjectMembers.EditProjectMember(project.ID, g.UserID, &gitlab.EditProjectMemberOptions{
 		AccessLevel: ownerAccessLevel,
-	})
+	}, gitlab.WithContext(ctx))
 	if editErr != nil {
 		_, _, addErr := g.Gitlab.ProjectMembers.AddProjectMember(project.ID, &gitlab.AddProjectMemberOptions{
 			UserID:      &g.UserID,
 			AccessLevel: ownerAccessLevel,
-		})
+		}, gitlab.WithContext(ctx))
 		if addErr != nil {
 			return fmt.Errorf("failed to add or promote user as owner of project %s: %w", project.PathWithNamespace, errors.Join(editErr, addErr))
 		}
@@ -1,12 +1,17 @@
 package mirroring
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/boxboxjason/gitlab-sync/internal/utils"
 
+	"github.com/Masterminds/semver/v3"
 	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
 )
 
@@ -67,7 +72,7 @@ func TestFetchAll(t *testing.T) {
 			_, gitlabInstance := setupTestServer(t, test.role, test.instanceSize)
 
 			// Call the function with the test case parameters
-			err := gitlabInstance.FetchAll(projectFilters, groupFilters, gitlabMirrorArgs)
+			err := gitlabInstance.FetchAll(t.Context(), projectFilters, groupFilters, gitlabMirrorArgs)
 
 			// Check if an error was expected
 			if (err != nil) != test.expectedError {
@@ -173,7 +178,7 @@ func TestFetchAndProcessProjectsBigInstance(t *testing.T) {
 
 			_, gitlabInstance := setupTestServer(t, test.role, INSTANCE_SIZE_BIG)
 
-			err := gitlabInstance.FetchAndProcessProjectsBigInstance(&test.projectFilters, gitlabMirrorArgs)
+			err := gitlabInstance.FetchAndProcessProjectsBigInstance(t.Context(), &test.projectFilters, gitlabMirrorArgs)
 			if (err != nil) != test.expectError {
 				t.Fatalf("Expected error: %v, got: %v", test.expectError, err)
 			}
@@ -278,7 +283,7 @@ func TestFetchAndProcessProjectsSmallInstance(t *testing.T) {
 			t.Parallel()
 			_, gitlabInstance := setupTestServer(t, tt.role, INSTANCE_SIZE_SMALL)
 
-			errs := gitlabInstance.FetchAndProcessProjectsSmallInstance(&tt.projectFilters, &tt.groupFilters, tt.mirrorMapping)
+			errs := gitlabInstance.FetchAndProcessProjectsSmallInstance(t.Context(), &tt.projectFilters, &tt.groupFilters, tt.mirrorMapping)
 			if len(errs) > 0 {
 				t.Fatalf("unexpected errors: %v", errs)
 			}
@@ -302,6 +307,53 @@ func TestFetchAndProcessProjectsSmallInstance(t *testing.T) {
 	}
 }
 
+// TestFetchAndProcessProjectsSmallInstanceMappedNamespaces verifies that only the mapped namespaces are queried:
+// the projects of the mapped groups are listed, and the mapped projects outside of them (or of missing groups) are retrieved individually.
+func TestFetchAndProcessProjectsSmallInstanceMappedNamespaces(t *testing.T) {
+	t.Parallel()
+
+	mux, gitlabInstance := setupEmptyTestServer(t, ROLE_DESTINATION, INSTANCE_SIZE_SMALL)
+
+	mux.HandleFunc("/api/v4/projects", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("expected the instance projects not to be listed, got %s %s", r.Method, r.URL)
+		writeJSONResponse(w, http.StatusOK, "[]")
+	})
+	mux.HandleFunc(fmt.Sprintf("/api/v4/groups/%s/projects", url.QueryEscape(TEST_GROUP_2.FullPath)), func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("include_subgroups") != "true" {
+			t.Errorf("expected the subgroups projects to be included, got %s", r.URL.RawQuery)
+		}
+
+		writeJSONResponse(w, http.StatusOK, fmt.Sprintf("[%s]", TEST_PROJECT_2_STRING))
+	})
+	mux.HandleFunc(fmt.Sprintf("/api/v4/projects/%s", url.QueryEscape(TEST_PROJECT.PathWithNamespace)), func(w http.ResponseWriter, r *http.Request) {
+		writeJSONResponse(w, http.StatusOK, TEST_PROJECT_STRING)
+	})
+	projectFilters := map[string]struct{}{
+		TEST_PROJECT.PathWithNamespace:   {},
+		TEST_PROJECT_2.PathWithNamespace: {},
+		"users/alice/missing":            {},
+	}
+	groupFilters := map[string]struct{}{
+		TEST_GROUP_2.FullPath: {},
+		"users/alice":         {},
+	}
+
+	errs := gitlabInstance.FetchAndProcessProjectsSmallInstance(t.Context(), &projectFilters, &groupFilters, &utils.MirrorMapping{})
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	for _, project := range []string{TEST_PROJECT.PathWithNamespace, TEST_PROJECT_2.PathWithNamespace} {
+		if gitlabInstance.GetProject(project) == nil {
+			t.Errorf("expected project %s in instance cache", project)
+		}
+	}
+
+	if gitlabInstance.ProjectsLen() != 2 {
+		t.Errorf("expected 2 projects in instance cache, got %d", gitlabInstance.ProjectsLen())
+	}
+}
+
 func TestStoreProject(t *testing.T) {
 	tests := []struct {
 		name                    string
@@ -391,6 +443,57 @@ func TestStoreProject(t *testing.T) {
 	}
 }
 
+func TestStoreProjectFilters(t *testing.T) {
+	tests := []struct {
+		name           string
+		filters        *utils.ProjectFilters
+		expectedStored bool
+	}{
+		{
+			name:           "No filters",
+			expectedStored: true,
+		},
+		{
+			name:           "Matching filters",
+			filters:        &utils.ProjectFilters{Fork: new(false)},
+			expectedStored: true,
+		},
+		{
+			name:           "Missing required topic",
+			filters:        &utils.ProjectFilters{Topics: []string{"mirror"}},
+			expectedStored: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, gitlabInstance := setupTestServer(t, ROLE_SOURCE, INSTANCE_SIZE_BIG)
+			mirrorMapping := &utils.MirrorMapping{
+				Projects: map[string]*utils.MirroringOptions{},
+				Groups: map[string]*utils.MirroringOptions{
+					TEST_GROUP.FullPath: {DestinationPath: "mirror-group", Filters: tt.filters},
+				},
+			}
+
+			gitlabInstance.storeProject(TEST_PROJECT, TEST_GROUP.FullPath, mirrorMapping)
+
+			if _, ok := mirrorMapping.GetProject(TEST_PROJECT.PathWithNamespace); ok != tt.expectedStored {
+				t.Errorf("expected project in mirror mapping: %t, got %t", tt.expectedStored, ok)
+			}
+
+			reason, skipped := mirrorMapping.SkippedProjects()[TEST_PROJECT.PathWithNamespace]
+			if skipped == tt.expectedStored {
+				t.Errorf("expected project skipped: %t, got %t", !tt.expectedStored, skipped)
+			}
+
+			if skipped && reason == "" {
+				t.Error("expected a reason for the skipped project")
+			}
+		})
+	}
+}
+
 func TestCreateProjectFromSource(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -423,7 +526,7 @@ func TestCreateProjectFromSource(t *testing.T) {
 			// Setup the test server
 			_, gitlabInstance := setupTestServer(t, tt.role, tt.instanceSize)
 			gitlabInstance.AddGroup(TEST_GROUP)
-			createdProject, err := gitlabInstance.CreateProjectFromSource(TEST_PROJECT, &utils.MirroringOptions{
+			createdProject, err := gitlabInstance.CreateProjectFromSource(t.Context(), TEST_PROJECT, &utils.MirroringOptions{
 				DestinationPath:     TEST_PROJECT.PathWithNamespace,
 				MirrorIssues:        new(true),
 				MirrorReleases:      new(true),
@@ -448,7 +551,7 @@ func TestCreateProjectFromSourceWithMinimalOptions(t *testing.T) {
 	_, gitlabInstance := setupTestServer(t, ROLE_DESTINATION, INSTANCE_SIZE_SMALL)
 	gitlabInstance.AddGroup(TEST_GROUP)
 
-	createdProject, err := gitlabInstance.CreateProjectFromSource(TEST_PROJECT, &utils.MirroringOptions{
+	createdProject, err := gitlabInstance.CreateProjectFromSource(t.Context(), TEST_PROJECT, &utils.MirroringOptions{
 		DestinationPath: TEST_PROJECT.PathWithNamespace,
 	})
 	if err != nil {
@@ -466,7 +569,7 @@ func TestCopyProjectAvatar(t *testing.T) {
 	_, sourceGitlabInstance := setupTestServer(t, ROLE_SOURCE, INSTANCE_SIZE_SMALL)
 	_, destinationGitlabInstance := setupTestServer(t, ROLE_DESTINATION, INSTANCE_SIZE_SMALL)
 	t.Run("Copy Project Avatar", func(t *testing.T) {
-		err := sourceGitlabInstance.CopyProjectAvatar(destinationGitlabInstance, TEST_PROJECT, TEST_PROJECT_2)
+		err := sourceGitlabInstance.CopyProjectAvatar(t.Context(), destinationGitlabInstance, TEST_PROJECT, TEST_PROJECT_2)
 		if err != nil {
 			t.Errorf("Unexpected error when copying project avatar: %v", err)
 		}
@@ -480,7 +583,7 @@ func TestCreateProjects(t *testing.T) {
 		sourceGitlabInstance.AddProject(TEST_PROJECT)
 		_, destinationGitlabInstance := setupTestServer(t, ROLE_DESTINATION, INSTANCE_SIZE_SMALL)
 		destinationGitlabInstance.AddGroup(TEST_GROUP)
-		destinationGitlabInstance.PullMirrorAvailable = true
+		destinationGitlabInstance.Capabilities = NewCapabilities(semver.MustParse("19.4.0"), true, true)
 		mirrorMapping := &utils.MirrorMapping{
 			Projects: map[string]*utils.MirroringOptions{
 				TEST_PROJECT.PathWithNamespace: {
@@ -493,7 +596,7 @@ func TestCreateProjects(t *testing.T) {
 				},
 			},
 		}
-		err := destinationGitlabInstance.CreateProjects(sourceGitlabInstance, mirrorMapping)
+		err := destinationGitlabInstance.CreateProjects(t.Context(), sourceGitlabInstance, mirrorMapping)
 		if len(err) > 0 {
 			t.Errorf("Unexpected error when creating projects: %v", err)
 		}
@@ -506,7 +609,7 @@ func TestCreateProjects(t *testing.T) {
 func TestAddProjectToCICDCatalog(t *testing.T) {
 	_, gitlabInstance := setupTestServer(t, ROLE_DESTINATION, INSTANCE_SIZE_SMALL)
 	t.Run("Add Project to CI/CD Catalog", func(t *testing.T) {
-		err := gitlabInstance.AddProjectToCICDCatalog(TEST_PROJECT)
+		err := gitlabInstance.AddProjectToCICDCatalog(t.Context(), TEST_PROJECT)
 		if err != nil {
 			t.Errorf("Unexpected error when adding project to CI/CD catalog: %v", err)
 		}
@@ -522,7 +625,7 @@ func TestAddProjectToCICDCatalog(t *testing.T) {
 			CICDCatalogEnabled: true,
 		}
 
-		err := gitlabInstance.AddProjectToCICDCatalog(alreadyEnabledProject)
+		err := gitlabInstance.AddProjectToCICDCatalog(t.Context(), alreadyEnabledProject)
 		if err != nil {
 			t.Errorf("Unexpected error when project is already in the CI/CD catalog: %v", err)
 		}
@@ -532,7 +635,7 @@ func TestAddProjectToCICDCatalog(t *testing.T) {
 func TestClaimOwnershipToProject(t *testing.T) {
 	_, gitlabInstance := setupTestServer(t, ROLE_DESTINATION, INSTANCE_SIZE_SMALL)
 
-	err := gitlabInstance.ClaimOwnershipToProject(TEST_PROJECT)
+	err := gitlabInstance.ClaimOwnershipToProject(t.Context(), TEST_PROJECT)
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -574,7 +677,7 @@ func TestCreateProjectClaimOwnershipOption(t *testing.T) {
 			sourceGitlabInstance.AddProject(TEST_PROJECT_2)
 
 			mux, destinationGitlabInstance := setupEmptyTestServer(t, ROLE_DESTINATION, INSTANCE_SIZE_SMALL)
-			destinationGitlabInstance.PullMirrorAvailable = true
+			destinationGitlabInstance.Capabilities = NewCapabilities(semver.MustParse("19.4.0"), true, true)
 			destinationGitlabInstance.AddGroup(TEST_GROUP_2)
 
 			memberClaims := 0
@@ -616,7 +719,7 @@ func TestCreateProjectClaimOwnershipOption(t *testing.T) {
 				destinationGitlabInstance.AddProject(TEST_PROJECT_2)
 			}
 
-			createdProject, errs := destinationGitlabInstance.CreateProject(TEST_PROJECT_2.PathWithNamespace, &utils.MirroringOptions{
+			createdProject, errs := destinationGitlabInstance.CreateProject(t.Context(), TEST_PROJECT_2.PathWithNamespace, &utils.MirroringOptions{
 				DestinationPath: TEST_PROJECT_2.PathWithNamespace,
 				ClaimOwnership:  tc.claimOwnership,
 			}, sourceGitlabInstance)
@@ -632,3 +735,186 @@ func TestCreateProjectClaimOwnershipOption(t *testing.T) {
 		})
 	}
 }
+
+func TestCreateProjectWatermarks(t *testing.T) {
+	lastActivity := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name                string
+		preExisting         bool
+		watermarkProjectID  int64
+		expectedMirrorPulls int
+	}{
+		{
+			name:                "Covered existing destination project is skipped",
+			preExisting:         true,
+			watermarkProjectID:  TEST_PROJECT_2.ID,
+			expectedMirrorPulls: 0,
+		},
+		{
+			name:                "Missing destination project is created and updated despite a valid watermark",
+			watermarkProjectID:  TEST_PROJECT_2.ID,
+			expectedMirrorPulls: 1,
+		},
+		{
+			name:                "Recreated destination project is updated",
+			preExisting:         true,
+			watermarkProjectID:  TEST_PROJECT_2.ID + 100,
+			expectedMirrorPulls: 1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			sourceProject := *TEST_PROJECT_2
+			sourceProject.LastActivityAt = &lastActivity
+
+			_, sourceGitlabInstance := setupTestServer(t, ROLE_SOURCE, INSTANCE_SIZE_SMALL)
+			sourceGitlabInstance.AddProject(&sourceProject)
+
+			mux, destinationGitlabInstance := setupEmptyTestServer(t, ROLE_DESTINATION, INSTANCE_SIZE_SMALL)
+			destinationGitlabInstance.Capabilities = NewCapabilities(semver.MustParse("19.4.0"), true, true)
+			destinationGitlabInstance.AddGroup(TEST_GROUP_2)
+
+			copyOptions := &utils.MirroringOptions{DestinationPath: TEST_PROJECT_2.PathWithNamespace}
+
+			// The watermark of the previous run still covers the source project activity
+			destinationGitlabInstance.Watermarks = NewSyncState(t.TempDir(), TEST_INVENTORY_SOURCE_URL, TEST_INVENTORY_DESTINATION_URL)
+			destinationGitlabInstance.Watermarks.previous.Projects[sourceProject.PathWithNamespace] = &ProjectWatermark{
+				LastActivityAt: lastActivity,
+				Options:        optionsFingerprint(copyOptions),
+				DestinationID:  tc.watermarkProjectID,
+			}
+
+			mirrorPulls := 0
+
+			mux.HandleFunc("/api/v4/projects", func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost {
+					writeMethodNotAllowed(w)
+					return
+				}
+				writeJSONResponse(w, http.StatusCreated, TEST_PROJECT_2_STRING)
+			})
+			mux.HandleFunc(fmt.Sprintf("/api/v4/projects/%d", TEST_PROJECT_2.ID), func(w http.ResponseWriter, r *http.Request) {
+				switch r.Method {
+				case http.MethodGet, http.MethodPut:
+					writeJSONResponse(w, http.StatusOK, TEST_PROJECT_2_STRING)
+				default:
+					writeMethodNotAllowed(w)
+				}
+			})
+			mux.HandleFunc(fmt.Sprintf("/api/v4/projects/%d/mirror/pull", TEST_PROJECT_2.ID), func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPut {
+					writeMethodNotAllowed(w)
+					return
+				}
+				mirrorPulls++
+				writeJSONResponse(w, http.StatusOK, "{}")
+			})
+
+			if tc.preExisting {
+				destinationGitlabInstance.AddProject(TEST_PROJECT_2)
+			}
+
+			_, errs := destinationGitlabInstance.CreateProject(t.Context(), sourceProject.PathWithNamespace, copyOptions, sourceGitlabInstance)
+			if len(errs) > 0 {
+				t.Fatalf("unexpected error: %v", errs)
+			}
+
+			if mirrorPulls != tc.expectedMirrorPulls {
+				t.Errorf("expected %d mirror pull updates, got %d", tc.expectedMirrorPulls, mirrorPulls)
+			}
+		})
+	}
+}
+
+func TestFetchProtectedBranches(t *testing.T) {
+	t.Parallel()
+
+	mux, gitlabInstance := setupTestServer(t, ROLE_SOURCE, INSTANCE_SIZE_SMALL)
+
+	mux.HandleFunc(fmt.Sprintf("/api/v4/projects/%d/repository/branches", TEST_PROJECT.ID), func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			writeJSONResponse(w, http.StatusOK, `[{"name": "release/1.x", "protected": true}]`)
+
+			return
+		}
+
+		w.Header().Set("X-Next-Page", "2")
+		writeJSONResponse(w, http.StatusOK, `[{"name": "main", "protected": true, "default": true}, {"name": "feature", "protected": false}]`)
+	})
+
+	branches, err := gitlabInstance.fetchProtectedBranches(t.Context(), TEST_PROJECT)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if expected := []string{"main", "release/1.x"}; !slices.Equal(branches, expected) {
+		t.Errorf("expected protected branches %v, got %v", expected, branches)
+	}
+
+	// The branches are only listed when the mirroring is restricted to the protected ones
+	refs, err := gitlabInstance.refFilter(t.Context(), TEST_PROJECT, &utils.GitOptions{ProtectedBranchesOnly: new(true)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if refs.Match("refs/heads/feature") || !refs.Match("refs/heads/release/1.x") {
+		t.Errorf("expected only the protected branches to be pushed, got %+v", refs.Branches)
+	}
+}
+
+func TestEnableProjectMirrorPullBranches(t *testing.T) {
+	tests := []struct {
+		name                  string
+		gitOptions            *utils.GitOptions
+		expectedOnlyProtected bool
+		expectedBranchRegex   string
+	}{
+		{
+			name:                  "Protected branches by default",
+			expectedOnlyProtected: true,
+		},
+		{
+			name:                "Branch regex",
+			gitOptions:          &utils.GitOptions{BranchRegex: `^(main|release/.*)$`},
+			expectedBranchRegex: `^(main|release/.*)$`,
+		},
+		{
+			name:       "Every branch",
+			gitOptions: &utils.GitOptions{ProtectedBranchesOnly: new(false)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mux, gitlabInstance := setupTestServer(t, ROLE_DESTINATION, INSTANCE_SIZE_SMALL)
+
+			var payload struct {
+				OnlyMirrorProtectedBranches bool   `json:"only_mirror_protected_branches"`
+				MirrorBranchRegex           string `json:"mirror_branch_regex"`
+			}
+
+			destinationProject := &gitlab.Project{ID: 99, PathWithNamespace: "test/mirror"}
+
+			mux.HandleFunc(fmt.Sprintf("/api/v4/projects/%d/mirror/pull", destinationProject.ID), func(w http.ResponseWriter, r *http.Request) {
+				if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+					t.Errorf("failed to decode the pull mirror settings: %v", err)
+				}
+
+				writeJSONResponse(w, http.StatusOK, "{}")
+			})
+
+			err := gitlabInstance.EnableProjectMirrorPull(t.Context(), TEST_PROJECT, destinationProject, &utils.MirroringOptions{Git: tt.gitOptions})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if payload.OnlyMirrorProtectedBranches != tt.expectedOnlyProtected || payload.MirrorBranchRegex != tt.expectedBranchRegex {
+				t.Errorf("expected only_mirror_protected_branches %t and mirror_branch_regex %q, got %+v", tt.expectedOnlyProtected, tt.expectedBranchRegex, payload)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package mirroring
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// headerRateLimitRemaining is the number of requests left in the current GitLab rate limit window.
+	headerRateLimitRemaining = "RateLimit-Remaining"
+	// headerRateLimitReset is the Unix time at which the current GitLab rate limit window resets.
+	headerRateLimitReset = "RateLimit-Reset"
+	// headerRetryAfter is the delay (in seconds, or an HTTP date) before retrying a throttled request.
+	headerRetryAfter = "Retry-After"
+	// rateLimitReserve is the number of requests kept for the requests already in flight when the window is almost spent.
+	rateLimitReserve = 10
+	// rateLimitDefaultPause is the pause after a throttled response without any header telling when to retry.
+	rateLimitDefaultPause = time.Second
+	// rateLimitMaxPause bounds the pauses read from the headers, GitLab rate limit windows last one minute.
+	rateLimitMaxPause = time.Minute
+)
+
+// RateLimiter is the client side rate limiter of the API requests sent to a GitLab instance.
+// It combines a token bucket of a fixed number of requests per second (not bounded when zero) with pauses
+// read from the rate limit headers of the GitLab responses: the requests wait for the window reset once it
+// is almost spent, and for the Retry-After delay once a request is throttled (429 Too Many Requests).
+// A nil rate limiter never waits.
+type RateLimiter struct {
+	bucket   *rate.Limiter
+	counters *rateLimitCounters
+	role     string
+	// pausedUntil is the time until which no request is sent
+	pausedUntil time.Time
+	muPause     sync.Mutex
+}
+
+// rateLimitCounters are the statistics of the rate limiters of an instance.
+type rateLimitCounters struct {
+	waits     atomic.Int64
+	waited    atomic.Int64
+	throttled atomic.Int64
+}
+
+// RateLimitStats summarizes the waits of a rate limiter.
+// - waits: the number of requests which waited before being sent
+// - waited: the total duration the requests waited
+// - throttled: the number of responses throttled by the GitLab instance (429 Too Many Requests)
+type RateLimitStats struct {
+	Waits     int64
+	Waited    time.Duration
+	Throttled int64
+}
+
+// NewRateLimiter returns the rate limiter of the instance with the given role,
+// sending at most requestsPerSecond requests per second (no fixed limit when zero).
+func NewRateLimiter(role string, requestsPerSecond float64) *RateLimiter {
+	limiter := &RateLimiter{role: role, counters: &rateLimitCounters{}}
+	if requestsPerSecond > 0 {
+		limiter.bucket = rate.NewLimiter(rate.Limit(requestsPerSecond), int(math.Ceil(requestsPerSecond)))
+	}
+
+	return limiter
+}
+
+// sharing returns the rate limiter of another token of the same instance: it shares the token bucket
+// and the statistics of l, but only pauses on the rate limit headers of its own responses.
+func (l *RateLimiter) sharing() *RateLimiter {
+	return &RateLimiter{bucket: l.bucket, counters: l.counters, role: l.role}
+}
+
+// Wait blocks until a request can be sent to the GitLab instance, or until the context is done.
+// It implements the gitlab.RateLimiter interface.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	var reservation *rate.Reservation
+
+	delay := l.pause(time.Now())
+	if l.bucket != nil {
+		reservation = l.bucket.Reserve()
+		delay = max(delay, reservation.Delay())
+	}
+
+	if delay <= 0 {
+		return nil
+	}
+
+	l.counters.waits.Add(1)
+	l.counters.waited.Add(int64(delay))
+	zap.L().Debug("Waiting for the GitLab API rate limit", zap.String(ROLE, l.role), zap.Duration("delay", delay))
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		if reservation != nil {
+			reservation.Cancel()
+		}
+
+		return ctx.Err()
+	}
+}
+
+// Stats returns the summary of the waits of the rate limiter, along with the ones of the rate limiters sharing it.
+func (l *RateLimiter) Stats() RateLimitStats {
+	if l == nil {
+		return RateLimitStats{}
+	}
+
+	return RateLimitStats{
+		Waits:     l.counters.waits.Load(),
+		Waited:    time.Duration(l.counters.waited.Load()),
+		Throttled: l.counters.throttled.Load(),
+	}
+}
+
+// pause returns the remaining duration of the pause read from the rate limit headers.
+func (l *RateLimiter) pause(now time.Time) time.Duration {
+	l.muPause.Lock()
+	defer l.muPause.Unlock()
+
+	return l.pausedUntil.Sub(now)
+}
+
+// pauseUntil delays the next requests until the given time, bounded by the maximum pause.
+// A shorter pause than the current one is ignored.
+func (l *RateLimiter) pauseUntil(now, until time.Time) {
+	if limit := now.Add(rateLimitMaxPause); until.After(limit) {
+		until = limit
+	}
+
+	l.muPause.Lock()
+	defer l.muPause.Unlock()
+
+	if until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// observe adapts the rate limiter to the rate limit headers of a GitLab response received at the given time.
+func (l *RateLimiter) observe(statusCode int, header http.Header, now time.Time) {
+	if statusCode == http.StatusTooManyRequests {
+		l.counters.throttled.Add(1)
+
+		until, ok := retryAfter(header, now)
+		if !ok {
+			until, ok = rateLimitReset(header)
+		}
+
+		if !ok || !until.After(now) {
+			until = now.Add(rateLimitDefaultPause)
+		}
+
+		zap.L().Debug("GitLab API request throttled, pausing the requests", zap.String(ROLE, l.role), zap.Duration("pause", until.Sub(now)))
+		l.pauseUntil(now, until)
+
+		return
+	}
+
+	remaining, err := strconv.Atoi(header.Get(headerRateLimitRemaining))
+	if err != nil || remaining > rateLimitReserve {
+		return
+	}
+
+	if reset, ok := rateLimitReset(header); ok && reset.After(now) {
+		zap.L().Debug("GitLab API rate limit almost reached, pausing the requests until its reset", zap.String(ROLE, l.role), zap.Int("remaining", remaining), zap.Duration("pause", reset.Sub(now)))
+		l.pauseUntil(now, reset)
+	}
+}
+
+// retryAfter parses the Retry-After header, either a number of seconds or an HTTP date.
+func retryAfter(header http.Header, now time.Time) (time.Time, bool) {
+	value := header.Get(headerRetryAfter)
+	if value == "" {
+		return time.Time{}, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return now.Add(time.Duration(seconds) * time.Second), true
+	}
+
+	date, err := http.ParseTime(value)
+
+	return date, err == nil
+}
+
+// rateLimitReset parses the RateLimit-Reset header, a Unix time.
+func rateLimitReset(header http.Header) (time.Time, bool) {
+	reset, err := strconv.ParseInt(header.Get(headerRateLimitReset), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return time.Unix(reset, 0), true
+}
+
+// rateLimitTransport observes the rate limit headers of the responses received through its round tripper.
+type rateLimitTransport struct {
+	limiter *RateLimiter
+	next    http.RoundTripper
+}
+
+// transport returns a round tripper sending the requests through next (the default transport when nil),
+// adapting the rate limiter to the headers of every response, retries included.
+func (l *RateLimiter) transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &rateLimitTransport{limiter: l, next: next}
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err == nil {
+		t.limiter.observe(resp.StatusCode, resp.Header, time.Now())
+	}
+
+	return resp, err
+}
+
+// logRateLimitSummary logs how the API requests of the instances were slowed down by their rate limiters.
+func logRateLimitSummary(instances ...*GitlabInstance) {
+	for _, instance := range instances {
+		stats := instance.RateLimiter.Stats()
+		zap.L().Info("GitLab API rate limiting summary", zap.String(ROLE, instance.Role), zap.Int64("waits", stats.Waits), zap.Duration("waited", stats.Waited), zap.Int64("throttled", stats.Throttled))
+	}
+}
@@ -0,0 +1,229 @@
+package mirroring
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const (
+	TEST_RATE_LIMIT_PAUSE = 50 * time.Millisecond
+)
+
+func TestRateLimiterObserve(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+
+	tests := []struct {
+		name              string
+		statusCode        int
+		headers           map[string]string
+		expectedPause     time.Duration
+		expectedThrottled int64
+	}{
+		{
+			name:              "Throttled with Retry-After seconds",
+			statusCode:        http.StatusTooManyRequests,
+			headers:           map[string]string{headerRetryAfter: "5"},
+			expectedPause:     5 * time.Second,
+			expectedThrottled: 1,
+		},
+		{
+			name:              "Throttled with Retry-After date",
+			statusCode:        http.StatusTooManyRequests,
+			headers:           map[string]string{headerRetryAfter: now.Add(10 * time.Second).UTC().Format(http.TimeFormat)},
+			expectedPause:     10 * time.Second,
+			expectedThrottled: 1,
+		},
+		{
+			name:              "Throttled with RateLimit-Reset only",
+			statusCode:        http.StatusTooManyRequests,
+			headers:           map[string]string{headerRateLimitReset: strconv.FormatInt(now.Add(20*time.Second).Unix(), 10)},
+			expectedPause:     20 * time.Second,
+			expectedThrottled: 1,
+		},
+		{
+			name:              "Throttled without headers",
+			statusCode:        http.StatusTooManyRequests,
+			headers:           map[string]string{},
+			expectedPause:     rateLimitDefaultPause,
+			expectedThrottled: 1,
+		},
+		{
+			name:              "Throttled with a pause longer than the window",
+			statusCode:        http.StatusTooManyRequests,
+			headers:           map[string]string{headerRetryAfter: "3600"},
+			expectedPause:     rateLimitMaxPause,
+			expectedThrottled: 1,
+		},
+		{
+			name:       "Window almost spent",
+			statusCode: http.StatusOK,
+			headers: map[string]string{
+				headerRateLimitRemaining: "3",
+				headerRateLimitReset:     strconv.FormatInt(now.Add(30*time.Second).Unix(), 10),
+			},
+			expectedPause: 30 * time.Second,
+		},
+		{
+			name:       "Window with remaining requests",
+			statusCode: http.StatusOK,
+			headers: map[string]string{
+				headerRateLimitRemaining: "500",
+				headerRateLimitReset:     strconv.FormatInt(now.Add(30*time.Second).Unix(), 10),
+			},
+		},
+		{
+			name:       "Window already reset",
+			statusCode: http.StatusOK,
+			headers: map[string]string{
+				headerRateLimitRemaining: "0",
+				headerRateLimitReset:     strconv.FormatInt(now.Add(-time.Second).Unix(), 10),
+			},
+		},
+		{
+			name:       "No rate limit headers",
+			statusCode: http.StatusOK,
+			headers:    map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			header := http.Header{}
+			for key, value := range tt.headers {
+				header.Set(key, value)
+			}
+
+			limiter := NewRateLimiter(ROLE_SOURCE, 0)
+			limiter.observe(tt.statusCode, header, now)
+
+			if pause := max(limiter.pause(now), 0); pause != tt.expectedPause {
+				t.Errorf("expected a pause of %s, got %s", tt.expectedPause, pause)
+			}
+
+			if throttled := limiter.Stats().Throttled; throttled != tt.expectedThrottled {
+				t.Errorf("expected %d throttled responses, got %d", tt.expectedThrottled, throttled)
+			}
+		})
+	}
+}
+
+func TestRateLimiterPauseKeepsTheLongest(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	limiter := NewRateLimiter(ROLE_DESTINATION, 0)
+
+	limiter.pauseUntil(now, now.Add(10*time.Second))
+	limiter.pauseUntil(now, now.Add(time.Second))
+
+	if pause := limiter.pause(now); pause != 10*time.Second {
+		t.Errorf("expected the longest pause to be kept, got %s", pause)
+	}
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Token bucket", func(t *testing.T) {
+		t.Parallel()
+
+		// The burst of 20 requests is immediate, the next ones are delayed by 50ms each
+		limiter := NewRateLimiter(ROLE_SOURCE, 20)
+		for range 23 {
+			if err := limiter.Wait(t.Context()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+
+		stats := limiter.Stats()
+		if stats.Waits < 1 || stats.Waits > 3 {
+			t.Errorf("expected the requests beyond the burst to wait, got %d waits", stats.Waits)
+		}
+
+		if stats.Waited <= 0 {
+			t.Errorf("expected a positive wait duration, got %s", stats.Waited)
+		}
+	})
+
+	t.Run("Header pause", func(t *testing.T) {
+		t.Parallel()
+
+		limiter := NewRateLimiter(ROLE_SOURCE, 0)
+		start := time.Now()
+		limiter.pauseUntil(start, start.Add(TEST_RATE_LIMIT_PAUSE))
+
+		if err := limiter.Wait(t.Context()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if elapsed := time.Since(start); elapsed < TEST_RATE_LIMIT_PAUSE {
+			t.Errorf("expected to wait for %s, waited %s", TEST_RATE_LIMIT_PAUSE, elapsed)
+		}
+
+		if waits := limiter.Stats().Waits; waits != 1 {
+			t.Errorf("expected 1 wait, got %d", waits)
+		}
+	})
+
+	t.Run("Cancelled context", func(t *testing.T) {
+		t.Parallel()
+
+		limiter := NewRateLimiter(ROLE_SOURCE, 0)
+		now := time.Now()
+		limiter.pauseUntil(now, now.Add(time.Minute))
+
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+
+		if err := limiter.Wait(ctx); err == nil {
+			t.Error("expected the cancelled context to stop the wait")
+		}
+	})
+
+	t.Run("Nil rate limiter", func(t *testing.T) {
+		t.Parallel()
+
+		var limiter *RateLimiter
+		if err := limiter.Wait(t.Context()); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if stats := limiter.Stats(); stats != (RateLimitStats{}) {
+			t.Errorf("expected empty stats, got %+v", stats)
+		}
+	})
+}
+
+func TestNewGitlabInstanceRateLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HEADER_CONTENT_TYPE, HEADER_ACCEPT)
+		w.Header().Set(headerRateLimitRemaining, "0")
+		w.Header().Set(headerRateLimitReset, strconv.FormatInt(time.Now().Add(30*time.Second).Unix(), 10))
+		fmt.Fprint(w, `{"id": 1, "username": "testuser"}`)
+	}))
+	t.Cleanup(server.Close)
+
+	instance, err := NewGitlabInstance(&GitlabInstanceOpts{
+		GitlabURL:    server.URL,
+		GitlabToken:  "test-token",
+		Role:         ROLE_SOURCE,
+		MaxRetries:   0,
+		InstanceSize: INSTANCE_SIZE_SMALL,
+		RateLimit:    5,
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// The spent window of the current user response pauses the next requests
+	if pause := instance.RateLimiter.pause(time.Now()); pause <= 0 {
+		t.Errorf("expected the rate limit headers to pause the requests, got %s", pause)
+	}
+}
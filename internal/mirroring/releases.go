@@ -1,6 +1,7 @@
 package mirroring
 
 import (
+	"context"
 	"fmt"
 	"os"
 
@@ -21,7 +22,7 @@ const releasesPerPage = 100
 // ================
 
 // FetchProjectReleases retrieves all releases for a project and returns them.
-func (g *GitlabInstance) FetchProjectReleases(project *gitlab.Project) ([]*gitlab.Release, error) {
+func (g *GitlabInstance) FetchProjectReleases(ctx context.Context, project *gitlab.Project) ([]*gitlab.Release, error) {
 	zap.L().Debug("Fetching releases for project", zap.String("project", project.PathWithNamespace))
 
 	fetchOpts := &gitlab.ListReleasesOptions{
@@ -34,7 +35,7 @@ func (g *GitlabInstance) FetchProjectReleases(project *gitlab.Project) ([]*gitla
 	releases := make([]*gitlab.Release, 0)
 
 	for {
-		fetchedReleases, resp, err := g.Gitlab.Releases.ListReleases(project.ID, fetchOpts)
+		fetchedReleases, resp, err := g.Reader().Releases.ListReleases(project.ID, fetchOpts, gitlab.WithContext(ctx))
 		if err != nil {
 			return nil, fmt.Errorf("failed to list releases for project %s: %w", project.PathWithNamespace, err)
 		}
@@ -52,9 +53,9 @@ func (g *GitlabInstance) FetchProjectReleases(project *gitlab.Project) ([]*gitla
 }
 
 // FetchProjectReleasesTags retrieves all release tags for a project and returns them as a map.
-func (g *GitlabInstance) FetchProjectReleasesTags(project *gitlab.Project) (map[string]struct{}, error) {
+func (g *GitlabInstance) FetchProjectReleasesTags(ctx context.Context, project *gitlab.Project) (map[string]struct{}, error) {
 	// Fetch existing releases from the destination project
-	releases, err := g.FetchProjectReleases(project)
+	releases, err := g.FetchProjectReleases(ctx, project)
 	if err != nil {
 		return nil, err
 	}
@@ -73,9 +74,9 @@ func (g *GitlabInstance) FetchProjectReleasesTags(project *gitlab.Project) (map[
 
 // DryRunReleases prints the releases that would be created in dry run mode.
 // It fetches the releases from the source project and prints them.
-func (destinationGitlabInstance *GitlabInstance) DryRunReleases(sourceGitlabInstance *GitlabInstance, sourceProject *gitlab.Project, copyOptions *utils.MirroringOptions) error {
+func (destinationGitlabInstance *GitlabInstance) DryRunReleases(ctx context.Context, sourceGitlabInstance *GitlabInstance, sourceProject *gitlab.Project, copyOptions *utils.MirroringOptions) error {
 	// Fetch releases from the source project
-	sourceReleases, err := sourceGitlabInstance.FetchProjectReleasesTags(sourceProject)
+	sourceReleases, err := sourceGitlabInstance.FetchProjectReleasesTags(ctx, sourceProject)
 	if err != nil {
 		return fmt.Errorf("failed to fetch releases for source project %s: %w", sourceProject.HTTPURLToRepo, err)
 	}
@@ -95,7 +96,7 @@ func (destinationGitlabInstance *GitlabInstance) DryRunReleases(sourceGitlabInst
 // ================
 
 // MirrorRelease creates a release in the destination project.
-func (g *GitlabInstance) MirrorRelease(project *gitlab.Project, release *gitlab.Release) error {
+func (g *GitlabInstance) MirrorRelease(ctx context.Context, project *gitlab.Project, release *gitlab.Release) error {
 	zap.L().Debug("Creating release in destination project", zap.String("release", release.TagName), zap.String(ROLE_DESTINATION, project.HTTPURLToRepo))
 
 	// Create the release in the destination project
@@ -104,7 +105,7 @@ func (g *GitlabInstance) MirrorRelease(project *gitlab.Project, release *gitlab.
 		TagName:     &release.TagName,
 		Description: &release.Description,
 		ReleasedAt:  release.ReleasedAt,
-	})
+	}, gitlab.WithContext(ctx))
 	if err != nil {
 		return fmt.Errorf("failed to create release %s in project %s: %w", release.TagName, project.PathWithNamespace, err)
 	}
@@ -118,9 +119,11 @@ func (g *GitlabInstance) MirrorRelease(project *gitlab.Project, release *gitlab.
 
 // MirrorReleases mirrors releases from the source project to the destination project.
 // It fetches existing releases from the destination project and creates new releases for those that do not exist.
-// The function handles the API calls concurrently using goroutines.
-func (destinationGitlab *GitlabInstance) MirrorReleases(sourceGitlab *GitlabInstance, sourceProject, destinationProject *gitlab.Project) []error {
+// The releases are created concurrently, bounded by the entity pool of the scheduler.
+func (destinationGitlab *GitlabInstance) MirrorReleases(ctx context.Context, sourceGitlab *GitlabInstance, sourceProject, destinationProject *gitlab.Project) []error {
 	return mirrorProjectEntities(
+		ctx,
+		destinationGitlab.Scheduler,
 		"release",
 		sourceProject,
 		destinationProject,
@@ -129,8 +132,6 @@ func (destinationGitlab *GitlabInstance) MirrorReleases(sourceGitlab *GitlabInst
 		func(release *gitlab.Release) string {
 			return release.TagName
 		},
-		func(project *gitlab.Project, release *gitlab.Release) error {
-			return destinationGitlab.MirrorRelease(project, release)
-		},
+		destinationGitlab.MirrorRelease,
 	)
 }
@@ -10,7 +10,7 @@ func TestMirrorReleases(t *testing.T) {
 	_, sourceGitlabInstance := setupTestServer(t, ROLE_SOURCE, INSTANCE_SIZE_SMALL)
 	_, destinationGitlabInstance := setupTestServer(t, ROLE_DESTINATION, INSTANCE_SIZE_SMALL)
 	t.Run("Mirror Releases", func(t *testing.T) {
-		err := destinationGitlabInstance.MirrorReleases(sourceGitlabInstance, TEST_PROJECT, TEST_PROJECT_2)
+		err := destinationGitlabInstance.MirrorReleases(t.Context(), sourceGitlabInstance, TEST_PROJECT, TEST_PROJECT_2)
 		if err != nil {
 			t.Errorf("Unexpected error when mirroring releases: %v", err)
 		}
@@ -20,7 +20,7 @@ func TestMirrorReleases(t *testing.T) {
 func TestFetchProjectReleases(t *testing.T) {
 	_, gitlabInstance := setupTestServer(t, ROLE_DESTINATION, INSTANCE_SIZE_SMALL)
 	t.Run("Fetch Project Releases", func(t *testing.T) {
-		releases, err := gitlabInstance.FetchProjectReleases(TEST_PROJECT)
+		releases, err := gitlabInstance.FetchProjectReleases(t.Context(), TEST_PROJECT)
 		if err != nil {
 			t.Errorf("Unexpected error when fetching project releases: %v", err)
 		}
@@ -33,7 +33,7 @@ func TestFetchProjectReleases(t *testing.T) {
 func TestFetchProjectReleasesTags(t *testing.T) {
 	_, gitlabInstance := setupTestServer(t, ROLE_DESTINATION, INSTANCE_SIZE_SMALL)
 	t.Run("Fetch Project Releases Tags", func(t *testing.T) {
-		releasesTags, err := gitlabInstance.FetchProjectReleasesTags(TEST_PROJECT)
+		releasesTags, err := gitlabInstance.FetchProjectReleasesTags(t.Context(), TEST_PROJECT)
 		if err != nil {
 			t.Errorf("Unexpected error when fetching project releases tags: %v", err)
 		}
@@ -51,7 +51,7 @@ func TestMirrorRelease(t *testing.T) {
 			TagName:     "v1.0.0",
 			Description: "This is a test release",
 		}
-		err := gitlabInstance.MirrorRelease(TEST_PROJECT, release)
+		err := gitlabInstance.MirrorRelease(t.Context(), TEST_PROJECT, release)
 		if err != nil {
 			t.Errorf("Unexpected error when mirroring release: %v", err)
 		}
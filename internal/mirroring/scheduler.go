@@ -0,0 +1,230 @@
+package mirroring
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+const (
+	// DEFAULT_API_CONCURRENCY is the default number of concurrent GitLab API tasks.
+	DEFAULT_API_CONCURRENCY = 10
+	// DEFAULT_GIT_CONCURRENCY is the default number of concurrent local git mirroring operations.
+	DEFAULT_GIT_CONCURRENCY = 4
+	// DEFAULT_ENTITY_CONCURRENCY is the default number of concurrent issue and release creations.
+	DEFAULT_ENTITY_CONCURRENCY = 10
+)
+
+// ErrInterrupted reports work left undone because the mirroring was interrupted or timed out.
+var ErrInterrupted = errors.New("mirroring interrupted")
+
+// schedulerPool identifies a pool of the scheduler.
+type schedulerPool int
+
+const (
+	// poolAPI bounds the GitLab API fetch tasks and the project syncs.
+	poolAPI schedulerPool = iota
+	// poolGit bounds the local git mirroring operations (bare clones and pushes).
+	poolGit
+	// poolEntity bounds the issue and release creations.
+	poolEntity
+)
+
+// Scheduler bounds the concurrent work of the mirroring phases with a pool of slots per kind of work.
+// It is shared by the source and destination GitLab instances, so the total in-flight work is predictable.
+// A task must not wait for tasks of its own pool, since they could never get a slot once the pool is exhausted.
+//
+// Once the stop channel is closed, no new API task (fetch or project sync) is scheduled, while the git and entity
+// tasks of the in-flight project syncs still run so they are drained. No task at all is scheduled once the context
+// of the caller is done.
+// A nil scheduler runs every task without limit.
+type Scheduler struct {
+	api    chan struct{}
+	git    chan struct{}
+	entity chan struct{}
+	stop   <-chan struct{}
+}
+
+// NewScheduler returns a scheduler running at most the given number of tasks of each pool at once,
+// which stops scheduling new API tasks once stop is closed (never when nil).
+// A pool with a non positive concurrency is not bounded.
+func NewScheduler(stop <-chan struct{}, apiConcurrency, gitConcurrency, entityConcurrency int) *Scheduler {
+	return &Scheduler{
+		api:    newPoolSlots(apiConcurrency),
+		git:    newPoolSlots(gitConcurrency),
+		entity: newPoolSlots(entityConcurrency),
+		stop:   stop,
+	}
+}
+
+// newPoolSlots returns the slots of a pool running at most concurrency tasks at once, nil when it is not bounded.
+func newPoolSlots(concurrency int) chan struct{} {
+	if concurrency < 1 {
+		return nil
+	}
+
+	return make(chan struct{}, concurrency)
+}
+
+// ValidateConcurrency checks that a concurrency setting allows at least one task.
+func ValidateConcurrency(name string, concurrency int) error {
+	if concurrency < 1 {
+		return fmt.Errorf("invalid %s %d, must be strictly greater than 0", name, concurrency)
+	}
+
+	return nil
+}
+
+// slots returns the slots of a pool, nil when the scheduler does not bound it.
+func (s *Scheduler) slots(pool schedulerPool) chan struct{} {
+	if s == nil {
+		return nil
+	}
+
+	switch pool {
+	case poolAPI:
+		return s.api
+	case poolGit:
+		return s.git
+	case poolEntity:
+		return s.entity
+	default:
+		return nil
+	}
+}
+
+// stopped returns the channel closed once the pool stops scheduling new tasks, nil when it never stops.
+func (s *Scheduler) stopped(pool schedulerPool) <-chan struct{} {
+	if s == nil || pool != poolAPI {
+		return nil
+	}
+
+	return s.stop
+}
+
+// interrupted returns an ErrInterrupted error when no new task of the pool can be scheduled anymore.
+func (s *Scheduler) interrupted(ctx context.Context, pool schedulerPool) error {
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %w", ErrInterrupted, ctx.Err())
+	case <-s.stopped(pool):
+		return ErrInterrupted
+	default:
+		return nil
+	}
+}
+
+// acquire waits for a free slot of the pool, the returned function releasing it.
+// It fails when the pool stops scheduling new tasks before a slot is free.
+func (s *Scheduler) acquire(ctx context.Context, pool schedulerPool) (func(), error) {
+	if err := s.interrupted(ctx, pool); err != nil {
+		return nil, err
+	}
+
+	slots := s.slots(pool)
+	if slots == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%w: %w", ErrInterrupted, ctx.Err())
+	case <-s.stopped(pool):
+		return nil, ErrInterrupted
+	}
+}
+
+// spawn runs the task in a new goroutine of the wait group once a slot of the pool is free.
+// The caller is blocked until then, which also bounds the number of goroutines waiting for a slot.
+// It returns an ErrInterrupted error, without running the task, when the pool stops scheduling new tasks.
+func (s *Scheduler) spawn(ctx context.Context, pool schedulerPool, waitGroup *sync.WaitGroup, task func()) error {
+	release, err := s.acquire(ctx, pool)
+	if err != nil {
+		return err
+	}
+
+	waitGroup.Go(func() {
+		defer release()
+
+		task()
+	})
+
+	return nil
+}
+
+// run runs the task in the calling goroutine once a slot of the pool is free, and returns its error.
+// It returns an ErrInterrupted error, without running the task, when the pool stops scheduling new tasks.
+func (s *Scheduler) run(ctx context.Context, pool schedulerPool, task func() error) error {
+	release, err := s.acquire(ctx, pool)
+	if err != nil {
+		return err
+	}
+
+	defer release()
+
+	return task()
+}
+
+// walkTask is a task of a tree walk, returning the tasks it discovered (the subgroups of a fetched group for instance).
+type walkTask func() []walkTask
+
+// walk runs the tasks, and the tasks they discover, in new goroutines once a slot of the pool is free.
+// The discovered tasks are scheduled by the calling goroutine, so that a task never waits for a slot of its own pool.
+// It returns once every scheduled task is done, with an ErrInterrupted error when the pool stopped scheduling
+// new tasks before the walk was complete.
+func (s *Scheduler) walk(ctx context.Context, pool schedulerPool, tasks []walkTask) error {
+	var (
+		waitGroup sync.WaitGroup
+		mutex     sync.Mutex
+		running   int
+	)
+
+	pending := slices.Clone(tasks)
+	// done is signaled whenever a task ends, the pending tasks it discovered being checked again
+	done := make(chan struct{}, 1)
+
+	for {
+		mutex.Lock()
+
+		if len(pending) == 0 {
+			idle := running == 0
+			mutex.Unlock()
+
+			if idle {
+				return nil
+			}
+
+			<-done
+
+			continue
+		}
+
+		task := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		running++
+		mutex.Unlock()
+
+		err := s.spawn(ctx, pool, &waitGroup, func() {
+			discovered := task()
+
+			mutex.Lock()
+			pending = append(pending, discovered...)
+			running--
+			mutex.Unlock()
+
+			select {
+			case done <- struct{}{}:
+			default:
+			}
+		})
+		if err != nil {
+			waitGroup.Wait()
+
+			return err
+		}
+	}
+}
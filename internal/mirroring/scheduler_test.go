@@ -0,0 +1,315 @@
+package mirroring
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	TEST_SCHEDULER_TASKS      = 20
+	TEST_SCHEDULER_TASK_DELAY = 5 * time.Millisecond
+)
+
+// maxInFlight runs the test tasks with the given scheduler function, waits for them and returns the maximum number of tasks running at once.
+func maxInFlight(schedule func(task func()), wait func()) int64 {
+	var inFlight, highest atomic.Int64
+
+	for range TEST_SCHEDULER_TASKS {
+		schedule(func() {
+			current := inFlight.Add(1)
+			defer inFlight.Add(-1)
+
+			for {
+				previous := highest.Load()
+				if current <= previous || highest.CompareAndSwap(previous, current) {
+					break
+				}
+			}
+
+			time.Sleep(TEST_SCHEDULER_TASK_DELAY)
+		})
+	}
+
+	wait()
+
+	return highest.Load()
+}
+
+func TestSchedulerSpawn(t *testing.T) {
+	tests := []struct {
+		name      string
+		scheduler *Scheduler
+		pool      schedulerPool
+		expected  int64
+	}{
+		{
+			name:      "API pool",
+			scheduler: NewScheduler(nil, 3, 1, 1),
+			pool:      poolAPI,
+			expected:  3,
+		},
+		{
+			name:      "Git pool",
+			scheduler: NewScheduler(nil, 5, 2, 5),
+			pool:      poolGit,
+			expected:  2,
+		},
+		{
+			name:      "Entity pool",
+			scheduler: NewScheduler(nil, 1, 1, 4),
+			pool:      poolEntity,
+			expected:  4,
+		},
+		{
+			name:      "Unbounded pool",
+			scheduler: NewScheduler(nil, 0, 0, 0),
+			pool:      poolAPI,
+			expected:  TEST_SCHEDULER_TASKS,
+		},
+		{
+			name:     "Nil scheduler",
+			pool:     poolAPI,
+			expected: TEST_SCHEDULER_TASKS,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var waitGroup sync.WaitGroup
+
+			highest := maxInFlight(func(task func()) {
+				tt.scheduler.spawn(t.Context(), tt.pool, &waitGroup, task)
+			}, waitGroup.Wait)
+
+			if highest > tt.expected {
+				t.Errorf("expected at most %d tasks at once, got %d", tt.expected, highest)
+			}
+		})
+	}
+}
+
+func TestSchedulerRun(t *testing.T) {
+	t.Parallel()
+
+	scheduler := NewScheduler(nil, 1, 2, 1)
+
+	var waitGroup sync.WaitGroup
+
+	// The run calls of unbounded goroutines share the slots of the git pool
+	highest := maxInFlight(func(task func()) {
+		waitGroup.Go(func() {
+			_ = scheduler.run(t.Context(), poolGit, func() error {
+				task()
+
+				return nil
+			})
+		})
+	}, waitGroup.Wait)
+
+	if highest > 2 {
+		t.Errorf("expected at most 2 tasks at once, got %d", highest)
+	}
+}
+
+func TestSchedulerInterrupted(t *testing.T) {
+	stop := make(chan struct{})
+	close(stop)
+
+	cancelledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name          string
+		scheduler     *Scheduler
+		ctx           context.Context
+		pool          schedulerPool
+		expectedError bool
+	}{
+		{
+			name:      "Running scheduler",
+			scheduler: NewScheduler(make(chan struct{}), 1, 1, 1),
+			ctx:       context.Background(),
+			pool:      poolAPI,
+		},
+		{
+			name:          "Stopped API pool",
+			scheduler:     NewScheduler(stop, 1, 1, 1),
+			ctx:           context.Background(),
+			pool:          poolAPI,
+			expectedError: true,
+		},
+		{
+			name:      "Stopped scheduler drains the git pool",
+			scheduler: NewScheduler(stop, 1, 1, 1),
+			ctx:       context.Background(),
+			pool:      poolGit,
+		},
+		{
+			name:      "Stopped scheduler drains the entity pool",
+			scheduler: NewScheduler(stop, 1, 1, 1),
+			ctx:       context.Background(),
+			pool:      poolEntity,
+		},
+		{
+			name:          "Cancelled context",
+			scheduler:     NewScheduler(nil, 1, 1, 1),
+			ctx:           cancelledCtx,
+			pool:          poolGit,
+			expectedError: true,
+		},
+		{
+			name:          "Nil scheduler with a cancelled context",
+			ctx:           cancelledCtx,
+			pool:          poolAPI,
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var waitGroup sync.WaitGroup
+
+			ran := false
+
+			err := tt.scheduler.spawn(tt.ctx, tt.pool, &waitGroup, func() {
+				ran = true
+			})
+			waitGroup.Wait()
+
+			if (err != nil) != tt.expectedError {
+				t.Fatalf(EXPECTED_ERROR_MESSAGE, tt.expectedError, err)
+			}
+
+			if err != nil && !errors.Is(err, ErrInterrupted) {
+				t.Errorf("expected an ErrInterrupted error, got %v", err)
+			}
+
+			if ran == tt.expectedError {
+				t.Errorf("expected the task to run: %v, got %v", !tt.expectedError, ran)
+			}
+		})
+	}
+}
+
+func TestSchedulerStopWhileWaiting(t *testing.T) {
+	t.Parallel()
+
+	stop := make(chan struct{})
+	scheduler := NewScheduler(stop, 1, 1, 1)
+
+	release, err := scheduler.acquire(t.Context(), poolAPI)
+	if err != nil {
+		t.Fatalf("failed to acquire the only API slot: %v", err)
+	}
+	defer release()
+
+	// The waiting task is refused once the scheduler stops instead of waiting for the busy slot
+	close(stop)
+
+	if err := scheduler.run(t.Context(), poolAPI, func() error { return nil }); !errors.Is(err, ErrInterrupted) {
+		t.Errorf("expected an ErrInterrupted error, got %v", err)
+	}
+}
+
+func TestSchedulerWalk(t *testing.T) {
+	t.Parallel()
+
+	scheduler := NewScheduler(nil, 2, 1, 1)
+
+	var visited, inFlight, highest atomic.Int64
+
+	// Every task of the tree discovers 3 children down to the depth 3, while at most 2 tasks run at once
+	var node func(depth int) walkTask
+	node = func(depth int) walkTask {
+		return func() []walkTask {
+			visited.Add(1)
+
+			current := inFlight.Add(1)
+			defer inFlight.Add(-1)
+
+			if current > highest.Load() {
+				highest.Store(current)
+			}
+
+			time.Sleep(time.Millisecond)
+
+			if depth == 3 {
+				return nil
+			}
+
+			return []walkTask{node(depth + 1), node(depth + 1), node(depth + 1)}
+		}
+	}
+
+	if err := scheduler.walk(t.Context(), poolAPI, []walkTask{node(0)}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if visited.Load() != 40 {
+		t.Errorf("expected the 40 tasks of the tree to run, got %d", visited.Load())
+	}
+
+	if highest.Load() > 2 {
+		t.Errorf("expected at most 2 tasks at once, got %d", highest.Load())
+	}
+}
+
+func TestSchedulerWalkInterrupted(t *testing.T) {
+	t.Parallel()
+
+	stop := make(chan struct{})
+	scheduler := NewScheduler(stop, 1, 1, 1)
+
+	var visited atomic.Int64
+
+	// The first task stops the scheduler, its children are never run
+	root := func() []walkTask {
+		visited.Add(1)
+		close(stop)
+
+		return []walkTask{func() []walkTask {
+			visited.Add(1)
+
+			return nil
+		}}
+	}
+
+	if err := scheduler.walk(t.Context(), poolAPI, []walkTask{root}); !errors.Is(err, ErrInterrupted) {
+		t.Errorf("expected an ErrInterrupted error, got %v", err)
+	}
+
+	if visited.Load() != 1 {
+		t.Errorf("expected only the first task to run, got %d", visited.Load())
+	}
+}
+
+func TestValidateConcurrency(t *testing.T) {
+	tests := []struct {
+		name          string
+		concurrency   int
+		expectedError bool
+	}{
+		{name: "Positive", concurrency: 4},
+		{name: "Zero", concurrency: 0, expectedError: true},
+		{name: "Negative", concurrency: -1, expectedError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := ValidateConcurrency("api-concurrency", tt.concurrency)
+			if (err != nil) != tt.expectedError {
+				t.Errorf(EXPECTED_ERROR_MESSAGE, tt.expectedError, err)
+			}
+		})
+	}
+}
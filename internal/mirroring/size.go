@@ -0,0 +1,138 @@
+package mirroring
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/boxboxjason/gitlab-sync/internal/utils"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+	"go.uber.org/zap"
+)
+
+// groupWalkRequests is the least number of requests the big strategy needs to walk a mapped group.
+const groupWalkRequests = 3
+
+// InstanceSizes lists the accepted values of the --source-size / --destination-size settings.
+var InstanceSizes = []string{INSTANCE_SIZE_AUTO, INSTANCE_SIZE_SMALL, INSTANCE_SIZE_BIG}
+
+// ValidateInstanceSize checks that the instance size setting is auto, small or big.
+func ValidateInstanceSize(size string) error {
+	if size != "" && !slices.Contains(InstanceSizes, size) {
+		return fmt.Errorf("invalid instance size %q, must be one of %v", size, InstanceSizes)
+	}
+
+	return nil
+}
+
+// instanceSizeSetting returns the size of an instance from its --*-big override and its --*-size setting.
+// An unset size keeps the small instance strategy.
+func instanceSizeSetting(isBig bool, size string) string {
+	switch {
+	case isBig:
+		return INSTANCE_SIZE_BIG
+	case size == "":
+		return INSTANCE_SIZE_SMALL
+	default:
+		return size
+	}
+}
+
+// ResolveInstanceSize picks the cheaper fetch strategy of an instance sized auto, before fetching it.
+// The small strategy looks up every mapped group tree, and every mapped project outside of them, one by one,
+// then lists the projects and groups of the trees page by page. The pages are counted from the X-Total pagination
+// headers of the instance, which bound the mapped trees.
+// The big strategy fetches the mapping entries one by one, every mapped group costing at least three requests
+// (the group, its first pages of projects and of subgroups), or by batches through the GraphQL API on the source instance
+// (listing the trees page by page as well).
+// GitLab omits the X-Total headers above 10,000 items, the instance is then considered big.
+// When the estimation fails, the small strategy is kept.
+func (g *GitlabInstance) ResolveInstanceSize(ctx context.Context, projectFilters, groupFilters map[string]struct{}, mirrorMapping *utils.MirrorMapping) {
+	if g.InstanceSize != INSTANCE_SIZE_AUTO {
+		return
+	}
+
+	roots := namespaceRoots(groupFilters)
+
+	lookupRequests := len(roots)
+	for projectPath := range projectFilters {
+		if !slices.ContainsFunc(roots, func(root string) bool { return isSubPath(projectPath, root) }) {
+			lookupRequests++
+		}
+	}
+
+	// Without any mapped group, only the mapped projects are looked up
+	listingRequests, counted := 0, true
+
+	if len(roots) > 0 {
+		var err error
+
+		listingRequests, counted, err = g.estimateListingRequests(ctx)
+		if err != nil {
+			g.InstanceSize = INSTANCE_SIZE_SMALL
+			zap.L().Warn("Failed to estimate the GitLab instance size, using the small instance strategy", zap.String(ROLE, g.Role), zap.Error(err))
+
+			return
+		}
+	}
+
+	smallRequests := lookupRequests + listingRequests
+	bigRequests := len(projectFilters) + groupWalkRequests*len(groupFilters)
+
+	if g.graphQLEligible(mirrorMapping) {
+		bigRequests = batchesCount(len(roots), graphQLBatchSize) + batchesCount(len(projectFilters), graphQLBatchSize) + listingRequests
+	}
+
+	if !counted || bigRequests < smallRequests {
+		g.InstanceSize = INSTANCE_SIZE_BIG
+	} else {
+		g.InstanceSize = INSTANCE_SIZE_SMALL
+	}
+
+	zap.L().Info("Selected the GitLab instance fetch strategy", zap.String(ROLE, g.Role), zap.String(INSTANCE_SIZE, g.InstanceSize), zap.Bool("counted", counted), zap.Int("listingRequests", listingRequests), zap.Int("smallRequests", smallRequests), zap.Int("bigRequests", bigRequests))
+}
+
+// estimateListingRequests returns the number of requests needed to list every project and group of the instance.
+// It returns false when the instance does not count its projects or groups (X-Total headers omitted).
+func (g *GitlabInstance) estimateListingRequests(ctx context.Context) (int, bool, error) {
+	_, projectsResp, err := g.Reader().Projects.ListProjects(&gitlab.ListProjectsOptions{
+		Archived:             new(false),
+		IncludeHidden:        new(false),
+		IncludePendingDelete: new(false),
+		ListOptions:          gitlab.ListOptions{PerPage: 1},
+	}, gitlab.WithContext(ctx))
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to count projects: %w", err)
+	}
+
+	_, groupsResp, err := g.Reader().Groups.ListGroups(&gitlab.ListGroupsOptions{
+		AllAvailable: new(true),
+		ListOptions:  gitlab.ListOptions{PerPage: 1},
+	}, gitlab.WithContext(ctx))
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to count groups: %w", err)
+	}
+
+	if !isCounted(projectsResp) || !isCounted(groupsResp) {
+		return 0, false, nil
+	}
+
+	return pagesCount(projectsResp.TotalItems, projectsPerPage) + pagesCount(groupsResp.TotalItems, groupsPerPage), true, nil
+}
+
+// isCounted checks if a paginated response holds the X-Total header,
+// omitted by GitLab when more items than it is willing to count remain.
+func isCounted(resp *gitlab.Response) bool {
+	return resp.TotalItems > 0 || resp.NextPage == 0
+}
+
+// pagesCount returns the number of pages listing the items, at least one.
+func pagesCount(items int64, perPage int) int {
+	return max(1, int((items+int64(perPage)-1)/int64(perPage)))
+}
+
+// batchesCount returns the number of batches of the given size needed to hold the items.
+func batchesCount(items, batchSize int) int {
+	return (items + batchSize - 1) / batchSize
+}
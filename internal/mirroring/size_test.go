@@ -0,0 +1,200 @@
+package mirroring
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/boxboxjason/gitlab-sync/internal/utils"
+)
+
+func TestValidateInstanceSize(t *testing.T) {
+	tests := []struct {
+		name          string
+		size          string
+		expectedError bool
+	}{
+		{name: "Auto", size: INSTANCE_SIZE_AUTO},
+		{name: "Small", size: INSTANCE_SIZE_SMALL},
+		{name: "Big", size: INSTANCE_SIZE_BIG},
+		{name: "Unset", size: ""},
+		{name: "Invalid", size: "huge", expectedError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := ValidateInstanceSize(tt.size)
+			if (err != nil) != tt.expectedError {
+				t.Errorf(EXPECTED_ERROR_MESSAGE, tt.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestInstanceSizeSetting(t *testing.T) {
+	tests := []struct {
+		name     string
+		isBig    bool
+		size     string
+		expected string
+	}{
+		{name: "Big flag overrides the size", isBig: true, size: INSTANCE_SIZE_SMALL, expected: INSTANCE_SIZE_BIG},
+		{name: "Auto size", size: INSTANCE_SIZE_AUTO, expected: INSTANCE_SIZE_AUTO},
+		{name: "Explicit small size", size: INSTANCE_SIZE_SMALL, expected: INSTANCE_SIZE_SMALL},
+		{name: "Unset size", expected: INSTANCE_SIZE_SMALL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if size := instanceSizeSetting(tt.isBig, tt.size); size != tt.expected {
+				t.Errorf("expected size %s, got %s", tt.expected, size)
+			}
+		})
+	}
+}
+
+func TestResolveInstanceSize(t *testing.T) {
+	manyProjects := make(map[string]struct{})
+	for index := range 120 {
+		manyProjects[fmt.Sprintf("other/project-%d", index)] = struct{}{}
+	}
+
+	fewItems := map[string]string{"X-Total": "150", "X-Next-Page": "2"}
+	manyItems := map[string]string{"X-Total": "5000", "X-Next-Page": "2"}
+
+	tests := []struct {
+		name           string
+		role           string
+		initialSize    string
+		projectFilters map[string]struct{}
+		groupFilters   map[string]struct{}
+		mirrorMapping  *utils.MirrorMapping
+		countHeaders   map[string]string
+		expectedSize   string
+	}{
+		{
+			name:           "Mapped groups and their projects",
+			role:           ROLE_SOURCE,
+			initialSize:    INSTANCE_SIZE_AUTO,
+			projectFilters: map[string]struct{}{"org/api": {}, "org/web": {}},
+			groupFilters:   map[string]struct{}{"org": {}, "org/infra": {}},
+			countHeaders:   fewItems,
+			expectedSize:   INSTANCE_SIZE_SMALL,
+		},
+		{
+			name:           "Mapped groups on a large source instance",
+			role:           ROLE_SOURCE,
+			initialSize:    INSTANCE_SIZE_AUTO,
+			projectFilters: map[string]struct{}{"org/api": {}},
+			groupFilters:   map[string]struct{}{"org": {}, "team": {}},
+			countHeaders:   manyItems,
+			expectedSize:   INSTANCE_SIZE_SMALL,
+		},
+		{
+			name:           "Many mapped projects outside of the mapped groups",
+			role:           ROLE_SOURCE,
+			initialSize:    INSTANCE_SIZE_AUTO,
+			projectFilters: manyProjects,
+			groupFilters:   map[string]struct{}{"org": {}},
+			countHeaders:   fewItems,
+			expectedSize:   INSTANCE_SIZE_BIG,
+		},
+		{
+			name:           "Destination instance with few projects and groups",
+			role:           ROLE_DESTINATION,
+			initialSize:    INSTANCE_SIZE_AUTO,
+			projectFilters: map[string]struct{}{"mirror/org/api": {}},
+			groupFilters:   map[string]struct{}{"mirror/org": {}, "mirror/team": {}},
+			countHeaders:   fewItems,
+			expectedSize:   INSTANCE_SIZE_SMALL,
+		},
+		{
+			name:           "Destination instance with many projects and groups",
+			role:           ROLE_DESTINATION,
+			initialSize:    INSTANCE_SIZE_AUTO,
+			projectFilters: map[string]struct{}{"mirror/org/api": {}},
+			groupFilters:   map[string]struct{}{"mirror/org": {}, "mirror/team": {}},
+			countHeaders:   manyItems,
+			expectedSize:   INSTANCE_SIZE_BIG,
+		},
+		{
+			name:           "Uncounted destination instance",
+			role:           ROLE_DESTINATION,
+			initialSize:    INSTANCE_SIZE_AUTO,
+			projectFilters: map[string]struct{}{"mirror/org/api": {}},
+			groupFilters:   map[string]struct{}{"mirror/org": {}},
+			countHeaders:   map[string]string{"X-Next-Page": "2"},
+			expectedSize:   INSTANCE_SIZE_BIG,
+		},
+		{
+			name:           "Mapped projects only",
+			role:           ROLE_DESTINATION,
+			initialSize:    INSTANCE_SIZE_AUTO,
+			projectFilters: manyProjects,
+			expectedSize:   INSTANCE_SIZE_SMALL,
+		},
+		{
+			name:           "Fork filter without GraphQL",
+			role:           ROLE_SOURCE,
+			initialSize:    INSTANCE_SIZE_AUTO,
+			projectFilters: map[string]struct{}{"org/api": {}},
+			groupFilters:   map[string]struct{}{"org": {}, "team": {}},
+			mirrorMapping: &utils.MirrorMapping{Groups: map[string]*utils.MirroringOptions{
+				"org": {Filters: &utils.ProjectFilters{Fork: new(false)}},
+			}},
+			countHeaders: manyItems,
+			expectedSize: INSTANCE_SIZE_BIG,
+		},
+		{
+			name:           "Failed estimation",
+			role:           ROLE_DESTINATION,
+			initialSize:    INSTANCE_SIZE_AUTO,
+			projectFilters: map[string]struct{}{"mirror/org/api": {}},
+			groupFilters:   map[string]struct{}{"mirror/org": {}},
+			expectedSize:   INSTANCE_SIZE_SMALL,
+		},
+		{
+			name:           "Explicit size",
+			role:           ROLE_SOURCE,
+			initialSize:    INSTANCE_SIZE_BIG,
+			projectFilters: map[string]struct{}{"org/api": {}},
+			expectedSize:   INSTANCE_SIZE_BIG,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mux, gitlabInstance := setupEmptyTestServer(t, tt.role, tt.initialSize)
+
+			if tt.countHeaders != nil {
+				for _, pattern := range []string{"/api/v4/projects", "/api/v4/groups"} {
+					mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+						for key, value := range tt.countHeaders {
+							w.Header().Set(key, value)
+						}
+
+						w.Header().Set(HEADER_CONTENT_TYPE, HEADER_ACCEPT)
+						w.Write([]byte(`[]`))
+					})
+				}
+			}
+
+			mirrorMapping := tt.mirrorMapping
+			if mirrorMapping == nil {
+				mirrorMapping = &utils.MirrorMapping{}
+			}
+
+			gitlabInstance.ResolveInstanceSize(t.Context(), tt.projectFilters, tt.groupFilters, mirrorMapping)
+
+			if gitlabInstance.InstanceSize != tt.expectedSize {
+				t.Errorf("expected size %s, got %s", tt.expectedSize, gitlabInstance.InstanceSize)
+			}
+		})
+	}
+}
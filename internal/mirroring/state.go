@@ -0,0 +1,94 @@
+package mirroring
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"go.uber.org/zap"
+)
+
+const (
+	stateDirPermission  = 0o700
+	stateFilePermission = 0o600
+)
+
+// stateHeader is the common header of the state directory files
+// - version: the version of the file format
+// - source_url / destination_url: the GitLab instances the file was recorded for.
+type stateHeader struct {
+	SourceURL      string `json:"source_url"`
+	DestinationURL string `json:"destination_url"`
+	Version        int    `json:"version"`
+}
+
+// stateFile is a file of the state directory, starting with a stateHeader.
+type stateFile interface {
+	header() *stateHeader
+}
+
+func (h *stateHeader) header() *stateHeader {
+	return h
+}
+
+// loadStateFile reads a file of the state directory into state.
+// It returns false when the file is missing, or was recorded with another format version or for other GitLab instances,
+// the state must then be discarded.
+func loadStateFile(path string, expected stateHeader, state stateFile) (bool, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to read state file %s: %w", path, err)
+	}
+
+	err = json.Unmarshal(content, state)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse state file %s: %w", path, err)
+	}
+
+	header := state.header()
+
+	switch {
+	case header.Version != expected.Version:
+		zap.L().Info("Discarding state file of another format version", zap.String("path", path), zap.Int("version", header.Version), zap.Int("expectedVersion", expected.Version))
+
+		return false, nil
+	case header.SourceURL != expected.SourceURL || header.DestinationURL != expected.DestinationURL:
+		zap.L().Info("Discarding state file recorded for other GitLab instances", zap.String("path", path), zap.String(ROLE_SOURCE, header.SourceURL), zap.String(ROLE_DESTINATION, header.DestinationURL))
+
+		return false, nil
+	default:
+		return true, nil
+	}
+}
+
+// writeStateFile atomically replaces a file of the state directory, creating the directory if needed.
+// An interrupted write keeps the previous file.
+func writeStateFile(path string, state stateFile) error {
+	content, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode state file %s: %w", path, err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), stateDirPermission)
+	if err != nil {
+		return fmt.Errorf("failed to create state directory %s: %w", filepath.Dir(path), err)
+	}
+
+	temporaryPath := path + ".tmp"
+
+	err = os.WriteFile(temporaryPath, content, stateFilePermission)
+	if err != nil {
+		return fmt.Errorf("failed to write state file %s: %w", temporaryPath, err)
+	}
+
+	err = os.Rename(temporaryPath, path)
+	if err != nil {
+		return fmt.Errorf("failed to replace state file %s: %w", path, err)
+	}
+
+	return nil
+}
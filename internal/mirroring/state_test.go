@@ -0,0 +1,94 @@
+package mirroring
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadStateFile(t *testing.T) {
+	expected := stateHeader{SourceURL: TEST_INVENTORY_SOURCE_URL, DestinationURL: TEST_INVENTORY_DESTINATION_URL, Version: 1}
+
+	tests := []struct {
+		name           string
+		content        string
+		expectedLoaded bool
+		expectedError  bool
+	}{
+		{
+			name: "Missing file",
+		},
+		{
+			name:           "Matching header",
+			content:        `{"version": 1, "source_url": "` + TEST_INVENTORY_SOURCE_URL + `", "destination_url": "` + TEST_INVENTORY_DESTINATION_URL + `"}`,
+			expectedLoaded: true,
+		},
+		{
+			name:    "Other format version",
+			content: `{"version": 2, "source_url": "` + TEST_INVENTORY_SOURCE_URL + `", "destination_url": "` + TEST_INVENTORY_DESTINATION_URL + `"}`,
+		},
+		{
+			name:    "Other destination instance",
+			content: `{"version": 1, "source_url": "` + TEST_INVENTORY_SOURCE_URL + `", "destination_url": "https://other.example.com"}`,
+		},
+		{
+			name:          "Invalid JSON",
+			content:       `not json`,
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "state.json")
+			if tt.content != "" {
+				if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+					t.Fatalf("failed to write state file: %v", err)
+				}
+			}
+
+			var state stateHeader
+
+			loaded, err := loadStateFile(path, expected, &state)
+			if (err != nil) != tt.expectedError {
+				t.Fatalf(EXPECTED_ERROR_MESSAGE, tt.expectedError, err)
+			}
+
+			if loaded != tt.expectedLoaded {
+				t.Errorf("expected loaded %v, got %v", tt.expectedLoaded, loaded)
+			}
+		})
+	}
+}
+
+func TestWriteStateFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "nested", "state.json")
+	header := stateHeader{SourceURL: TEST_INVENTORY_SOURCE_URL, DestinationURL: TEST_INVENTORY_DESTINATION_URL, Version: 1}
+
+	if err := writeStateFile(path, &header); err != nil {
+		t.Fatalf("failed to write state file: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("expected the state file to exist: %v", err)
+	}
+
+	if info.Mode().Perm() != stateFilePermission {
+		t.Errorf("expected permissions %o, got %o", stateFilePermission, info.Mode().Perm())
+	}
+
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("expected the temporary file to be renamed, got %v", err)
+	}
+
+	var loadedHeader stateHeader
+
+	if loaded, err := loadStateFile(path, header, &loadedHeader); err != nil || !loaded {
+		t.Errorf("expected the written state file to load, got %v (%v)", loaded, err)
+	}
+}
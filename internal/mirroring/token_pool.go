@@ -0,0 +1,267 @@
+package mirroring
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+	"go.uber.org/zap"
+)
+
+const (
+	// headerPrivateToken is the header authenticating the API requests with a personal, group or project access token.
+	headerPrivateToken = "PRIVATE-TOKEN"
+	// headerWWWAuthenticate describes why GitLab rejected the token of a request.
+	headerWWWAuthenticate = "WWW-Authenticate"
+	// tokenPoolMaxExhaustions is the number of rate limit windows in a row a member spends as soon as it resumes
+	// (its quota being used by other clients) before being removed from the pool.
+	tokenPoolMaxExhaustions = 3
+	// tokenErrorBodyLimit bounds the body read from a forbidden response, looking for a token error.
+	tokenErrorBodyLimit = 4096
+)
+
+// tokenErrors are the errors of the forbidden responses rejecting the token itself rather than the access to a resource.
+var tokenErrors = []string{"insufficient_scope", "invalid_token", "expired"}
+
+// tokenPool round-robins the read-only API calls of a GitLab instance over the clients of its tokens.
+// Its first member holds the pinned token of the instance, used for the writes and never removed.
+// The other members are skipped while their rate limit window is spent. They are removed once their token
+// is rejected (revoked, expired or lacking the API scope) or their rate limit is repeatedly exhausted,
+// their requests being then sent with the pinned token.
+type tokenPool struct {
+	members   []*poolMember
+	role      string
+	next      atomic.Uint64
+	muMembers sync.RWMutex
+}
+
+// poolMember is the client of a token of the pool.
+// - position: the line of the token in the token list, logged instead of the token itself
+// - exhaustions: the number of rate limit windows in a row spent as soon as the member resumed
+type poolMember struct {
+	client      *gitlab.Client
+	limiter     *RateLimiter
+	position    int
+	exhaustions atomic.Int32
+	removed     atomic.Bool
+}
+
+// newTokenPool returns the token pool of the instance with the given role, starting with its pinned member.
+func newTokenPool(role string, pinned *poolMember) *tokenPool {
+	return &tokenPool{role: role, members: []*poolMember{pinned}}
+}
+
+// add adds a member to the pool.
+func (p *tokenPool) add(member *poolMember) {
+	p.muMembers.Lock()
+	defer p.muMembers.Unlock()
+
+	p.members = append(p.members, member)
+
+	zap.L().Debug("Token added to the token pool", zap.String(ROLE, p.role), zap.Int("token", member.position), zap.Int("tokens", len(p.members)))
+}
+
+// remove removes a member from the pool for the given reason, logged once.
+func (p *tokenPool) remove(member *poolMember, reason string) {
+	if !member.removed.CompareAndSwap(false, true) {
+		return
+	}
+
+	p.muMembers.Lock()
+	defer p.muMembers.Unlock()
+
+	p.members = slices.DeleteFunc(p.members, func(candidate *poolMember) bool {
+		return candidate == member
+	})
+
+	zap.L().Warn("Token removed from the token pool", zap.String(ROLE, p.role), zap.Int("token", member.position), zap.String("reason", reason), zap.Int("tokens", len(p.members)))
+}
+
+// pick returns the client of the next member whose rate limit window is not spent.
+// When every window is spent, the client of the member resuming first is returned.
+func (p *tokenPool) pick(now time.Time) *gitlab.Client {
+	p.muMembers.RLock()
+	defer p.muMembers.RUnlock()
+
+	count := uint64(len(p.members))
+	start := p.next.Add(1)
+
+	var (
+		resumingFirst *poolMember
+		shortestPause time.Duration
+	)
+
+	for offset := range count {
+		member := p.members[(start+offset)%count]
+
+		pause := member.limiter.pause(now)
+		if pause <= 0 {
+			return member.client
+		}
+
+		if resumingFirst == nil || pause < shortestPause {
+			resumingFirst, shortestPause = member, pause
+		}
+	}
+
+	return resumingFirst.client
+}
+
+// tokenPoolTransport sends the requests of a pool member, and removes it from the pool once its token is rejected
+// or its rate limit is repeatedly exhausted.
+// The rejected and throttled requests are sent again with the pinned token, along with the requests sent after the removal.
+type tokenPoolTransport struct {
+	pool        *tokenPool
+	member      *poolMember
+	next        http.RoundTripper
+	pinned      http.RoundTripper
+	pinnedToken string
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *tokenPoolTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.member.removed.Load() {
+		if pinnedReq, ok := t.withPinnedToken(req); ok {
+			return t.pinned.RoundTrip(pinnedReq)
+		}
+	}
+
+	pausedBefore := t.member.limiter.pause(time.Now()) > 0
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		t.pool.remove(t.member, "token rejected (revoked or expired)")
+	case resp.StatusCode == http.StatusForbidden && isTokenForbidden(resp):
+		t.pool.remove(t.member, "token forbidden (lacking the API scope or expired)")
+	case t.exhausted(resp, pausedBefore):
+		t.pool.remove(t.member, "rate limit repeatedly exhausted")
+
+		if resp.StatusCode != http.StatusTooManyRequests {
+			return resp, nil
+		}
+	default:
+		return resp, nil
+	}
+
+	pinnedReq, ok := t.withPinnedToken(req)
+	if !ok {
+		return resp, nil
+	}
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	return t.pinned.RoundTrip(pinnedReq)
+}
+
+// exhausted records whether the response spent the rate limit window of the member (throttled or pausing it),
+// when the member was not paused yet. It returns true once tokenPoolMaxExhaustions windows in a row were spent,
+// a response leaving the window open resetting the count.
+func (t *tokenPoolTransport) exhausted(resp *http.Response, pausedBefore bool) bool {
+	if pausedBefore {
+		return false
+	}
+
+	if resp.StatusCode != http.StatusTooManyRequests && t.member.limiter.pause(time.Now()) <= 0 {
+		t.member.exhaustions.Store(0)
+
+		return false
+	}
+
+	return t.member.exhaustions.Add(1) >= tokenPoolMaxExhaustions
+}
+
+// isTokenForbidden checks if a forbidden response rejects the token itself (lacking the API scope or expired),
+// from its WWW-Authenticate header or its body. The body read is restored for the client.
+func isTokenForbidden(resp *http.Response) bool {
+	if containsTokenError(resp.Header.Get(headerWWWAuthenticate)) {
+		return true
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, tokenErrorBodyLimit))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+
+	return err == nil && containsTokenError(string(body))
+}
+
+// containsTokenError checks if the text holds one of the token errors.
+func containsTokenError(text string) bool {
+	return slices.ContainsFunc(tokenErrors, func(tokenError string) bool {
+		return strings.Contains(text, tokenError)
+	})
+}
+
+// withPinnedToken returns a copy of the request authenticated with the pinned token.
+// It fails when the body of the request cannot be sent again.
+func (t *tokenPoolTransport) withPinnedToken(req *http.Request) (*http.Request, bool) {
+	pinnedReq := req.Clone(req.Context())
+
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return nil, false
+		}
+
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, false
+		}
+
+		pinnedReq.Body = body
+	}
+
+	pinnedReq.Header.Set(headerPrivateToken, t.pinnedToken)
+
+	return pinnedReq, true
+}
+
+// buildTokenPool builds the pool of the instance tokens, starting with the pinned member holding the first token.
+// The clients of the other tokens share the rate limiter of the pinned member, but pause on their own rate limit headers.
+func buildTokenPool(role string, tokens []string, pinned *poolMember, pinnedTransport, baseTransport http.RoundTripper, clientOptions []gitlab.ClientOptionFunc) (*tokenPool, error) {
+	pool := newTokenPool(role, pinned)
+
+	for index, token := range tokens[1:] {
+		member := &poolMember{limiter: pinned.limiter.sharing(), position: index + 2}
+		transport := &tokenPoolTransport{
+			pool:        pool,
+			member:      member,
+			next:        member.limiter.transport(baseTransport),
+			pinned:      pinnedTransport,
+			pinnedToken: tokens[0],
+		}
+
+		client, err := newGitlabClient(token, transport, member.limiter, clientOptions)
+		if err != nil {
+			return nil, err
+		}
+
+		member.client = client
+		pool.add(member)
+	}
+
+	return pool, nil
+}
+
+// Reader returns the client of a read-only API call, round-robin over the tokens of the instance.
+// The writes and the calls depending on the identity of the instance (current user, token checks)
+// use the Gitlab client, pinned to the first token.
+func (g *GitlabInstance) Reader() *gitlab.Client {
+	if g.tokens == nil {
+		return g.Gitlab
+	}
+
+	return g.tokens.pick(time.Now())
+}
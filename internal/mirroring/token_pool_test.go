@@ -0,0 +1,251 @@
+package mirroring
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+const (
+	TEST_PINNED_TOKEN  = "pinned-token"
+	TEST_POOLED_TOKEN  = "pooled-token"
+	TEST_REVOKED_TOKEN = "revoked-token"
+	TEST_SCOPED_TOKEN  = "scoped-token"
+	TEST_POOL_CALLS    = 6
+)
+
+// newTokenPoolServer returns a test server recording the tokens of the requests, rejecting the revoked token
+// and forbidding the token lacking the API scope.
+func newTokenPoolServer(t *testing.T) (*httptest.Server, func() map[string]int) {
+	t.Helper()
+
+	var (
+		muTokens sync.Mutex
+		tokens   = make(map[string]int)
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get(headerPrivateToken)
+
+		muTokens.Lock()
+		tokens[token]++
+		muTokens.Unlock()
+
+		if token == TEST_REVOKED_TOKEN {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"message": "401 Unauthorized"}`)
+
+			return
+		}
+
+		if token == TEST_SCOPED_TOKEN {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"error": "insufficient_scope", "error_description": "The request requires higher privileges than provided by the access token."}`)
+
+			return
+		}
+
+		w.Header().Set(HEADER_CONTENT_TYPE, HEADER_ACCEPT)
+		fmt.Fprint(w, `{"id": 1, "username": "testuser", "path_with_namespace": "test/project"}`)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() map[string]int {
+		muTokens.Lock()
+		defer muTokens.Unlock()
+
+		return tokens
+	}
+}
+
+func TestNewGitlabInstanceTokenPool(t *testing.T) {
+	tests := []struct {
+		name               string
+		tokens             string
+		expectedPool       int
+		expectedRequests   map[string]int
+		expectedRejections int
+	}{
+		{
+			name:             "Single token",
+			tokens:           TEST_PINNED_TOKEN,
+			expectedRequests: map[string]int{TEST_PINNED_TOKEN: 1 + TEST_POOL_CALLS},
+		},
+		{
+			name:             "Read-only calls spread over the pool",
+			tokens:           TEST_PINNED_TOKEN + "\n\n" + TEST_POOLED_TOKEN + "\n",
+			expectedPool:     2,
+			expectedRequests: map[string]int{TEST_PINNED_TOKEN: 1 + TEST_POOL_CALLS/2, TEST_POOLED_TOKEN: TEST_POOL_CALLS / 2},
+		},
+		{
+			name:         "Revoked token removed from the pool",
+			tokens:       TEST_PINNED_TOKEN + "\n" + TEST_REVOKED_TOKEN,
+			expectedPool: 1,
+			// The rejected call is sent again with the pinned token
+			expectedRequests: map[string]int{TEST_PINNED_TOKEN: 1 + TEST_POOL_CALLS, TEST_REVOKED_TOKEN: 1},
+		},
+		{
+			name:         "Token lacking the API scope removed from the pool",
+			tokens:       TEST_PINNED_TOKEN + "\n" + TEST_SCOPED_TOKEN + "\n" + TEST_POOLED_TOKEN,
+			expectedPool: 2,
+			// The forbidden call is sent again with the pinned token, the other calls are spread over the remaining tokens
+			expectedRequests: map[string]int{TEST_PINNED_TOKEN: 1 + 1 + TEST_POOL_CALLS/2, TEST_SCOPED_TOKEN: 1, TEST_POOLED_TOKEN: TEST_POOL_CALLS/2 - 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			server, requests := newTokenPoolServer(t)
+
+			instance, err := NewGitlabInstance(&GitlabInstanceOpts{
+				GitlabURL:    server.URL,
+				GitlabToken:  tt.tokens,
+				Role:         ROLE_SOURCE,
+				MaxRetries:   0,
+				InstanceSize: INSTANCE_SIZE_SMALL,
+			})
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			for range TEST_POOL_CALLS {
+				if _, _, err := instance.Reader().Projects.GetProject(TEST_PROJECT.PathWithNamespace, &gitlab.GetProjectOptions{}); err != nil {
+					t.Fatalf("expected the read-only call to succeed, got %v", err)
+				}
+			}
+
+			if tt.expectedPool == 0 && instance.tokens != nil {
+				t.Errorf("expected no token pool, got %d tokens", len(instance.tokens.members))
+			} else if tt.expectedPool > 0 && len(instance.tokens.members) != tt.expectedPool {
+				t.Errorf("expected %d tokens in the pool, got %d", tt.expectedPool, len(instance.tokens.members))
+			}
+
+			for token, expected := range tt.expectedRequests {
+				if got := requests()[token]; got != expected {
+					t.Errorf("expected %d requests with %s, got %d", expected, token, got)
+				}
+			}
+		})
+	}
+}
+
+func TestGitlabInstanceWritesPinned(t *testing.T) {
+	t.Parallel()
+
+	server, requests := newTokenPoolServer(t)
+
+	instance, err := NewGitlabInstance(&GitlabInstanceOpts{
+		GitlabURL:    server.URL,
+		GitlabToken:  TEST_PINNED_TOKEN + "\n" + TEST_POOLED_TOKEN,
+		Role:         ROLE_DESTINATION,
+		MaxRetries:   0,
+		InstanceSize: INSTANCE_SIZE_SMALL,
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	for range TEST_POOL_CALLS {
+		if _, _, err := instance.Gitlab.Projects.EditProject(TEST_PROJECT.ID, &gitlab.EditProjectOptions{}); err != nil {
+			t.Fatalf("expected the write to succeed, got %v", err)
+		}
+	}
+
+	if got := requests()[TEST_POOLED_TOKEN]; got != 0 {
+		t.Errorf("expected the writes to stay pinned to the first token, got %d requests with the pooled token", got)
+	}
+}
+
+func TestTokenPoolExhaustion(t *testing.T) {
+	t.Parallel()
+
+	pinned := &poolMember{limiter: NewRateLimiter(ROLE_SOURCE, 0), position: 1}
+	member := &poolMember{limiter: pinned.limiter.sharing(), position: 2}
+
+	pool := newTokenPool(ROLE_SOURCE, pinned)
+	pool.add(member)
+
+	transport := &tokenPoolTransport{pool: pool, member: member}
+	throttled := &http.Response{StatusCode: http.StatusTooManyRequests}
+	served := &http.Response{StatusCode: http.StatusOK}
+
+	// A response leaving the window open resets the count, and the responses received while paused are not counted
+	steps := []struct {
+		resp         *http.Response
+		pausedBefore bool
+		expected     bool
+	}{
+		{resp: throttled},
+		{resp: throttled},
+		{resp: served},
+		{resp: throttled},
+		{resp: throttled, pausedBefore: true},
+		{resp: throttled},
+		{resp: throttled, expected: true},
+	}
+
+	for step, tt := range steps {
+		if exhausted := transport.exhausted(tt.resp, tt.pausedBefore); exhausted != tt.expected {
+			t.Errorf("step %d: expected exhausted %v, got %v", step, tt.expected, exhausted)
+		}
+	}
+}
+
+func TestTokenPoolPick(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		pauses   []time.Duration
+		expected []int
+	}{
+		{
+			name:     "Round-robin",
+			pauses:   []time.Duration{0, 0, 0},
+			expected: []int{1, 2, 0, 1},
+		},
+		{
+			name:     "Spent windows skipped",
+			pauses:   []time.Duration{0, time.Minute, 0},
+			expected: []int{2, 2, 0, 2},
+		},
+		{
+			name:     "Every window spent",
+			pauses:   []time.Duration{time.Minute, 10 * time.Second, 30 * time.Second},
+			expected: []int{1, 1, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			clients := make([]*gitlab.Client, len(tt.pauses))
+			members := make([]*poolMember, len(tt.pauses))
+
+			for index, pause := range tt.pauses {
+				clients[index] = &gitlab.Client{}
+				members[index] = &poolMember{client: clients[index], limiter: NewRateLimiter(ROLE_SOURCE, 0), position: index + 1}
+				members[index].limiter.pauseUntil(now, now.Add(pause))
+			}
+
+			pool := newTokenPool(ROLE_SOURCE, members[0])
+			for _, member := range members[1:] {
+				pool.add(member)
+			}
+
+			for call, expected := range tt.expected {
+				if client := pool.pick(now); client != clients[expected] {
+					t.Errorf("call %d: expected the client of token %d", call, expected+1)
+				}
+			}
+		})
+	}
+}
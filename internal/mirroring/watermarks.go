@@ -0,0 +1,150 @@
+package mirroring
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/boxboxjason/gitlab-sync/internal/utils"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+	"go.uber.org/zap"
+)
+
+const (
+	// SINCE_LAST_SUCCESS is the --since mode skipping the projects without source activity since their last successful sync.
+	SINCE_LAST_SUCCESS = "last-success"
+	// SYNC_STATE_VERSION is the version of the sync state format, states of other versions are discarded.
+	SYNC_STATE_VERSION = 2
+	// SYNC_STATE_FILE_NAME is the name of the sync state file in the state directory.
+	SYNC_STATE_FILE_NAME = "sync-state.json"
+)
+
+// ProjectWatermark is the watermark of a project recorded after its last successful sync
+// - last_activity_at: the source project last activity when it was synced
+// - destination_id: the ID of the destination project it was synced to
+// - synced_at: the time of the sync
+// - options: the fingerprint of the mirroring options the project was synced with.
+type ProjectWatermark struct {
+	LastActivityAt time.Time `json:"last_activity_at"`
+	SyncedAt       time.Time `json:"synced_at"`
+	Options        string    `json:"options"`
+	DestinationID  int64     `json:"destination_id"`
+}
+
+// syncStateFile is the content of the sync state file, after its header (format version SYNC_STATE_VERSION)
+// - projects: the project watermarks, by source path.
+type syncStateFile struct {
+	Projects map[string]*ProjectWatermark `json:"projects"`
+	stateHeader
+}
+
+// SyncState holds the project watermarks of the --since last-success mode.
+// The watermarks of the previous run which are neither still covering their project nor recorded again
+// are dropped when the state is saved.
+// A nil state disables the incremental sync.
+type SyncState struct {
+	previous syncStateFile
+	current  syncStateFile
+	path     string
+	mutex    sync.Mutex
+}
+
+// ValidateSince checks that the --since mode is unset or last-success.
+func ValidateSince(since string) error {
+	if since != "" && since != SINCE_LAST_SUCCESS {
+		return fmt.Errorf("invalid since mode %q, must be %s", since, SINCE_LAST_SUCCESS)
+	}
+
+	return nil
+}
+
+// newSyncStateFile returns an empty sync state for the given GitLab instances.
+func newSyncStateFile(sourceURL, destinationURL string) syncStateFile {
+	return syncStateFile{
+		Projects:    make(map[string]*ProjectWatermark),
+		stateHeader: stateHeader{SourceURL: sourceURL, DestinationURL: destinationURL, Version: SYNC_STATE_VERSION},
+	}
+}
+
+// NewSyncState returns an empty sync state, saved in the state directory.
+func NewSyncState(stateDir, sourceURL, destinationURL string) *SyncState {
+	return &SyncState{
+		previous: newSyncStateFile(sourceURL, destinationURL),
+		current:  newSyncStateFile(sourceURL, destinationURL),
+		path:     filepath.Join(stateDir, SYNC_STATE_FILE_NAME),
+	}
+}
+
+// LoadSyncState loads the sync state of the state directory.
+// A missing state, or one recorded with another format version or for other GitLab instances, starts empty.
+// The returned state is usable (empty) when the existing file cannot be read.
+func LoadSyncState(stateDir, sourceURL, destinationURL string) (*SyncState, error) {
+	state := NewSyncState(stateDir, sourceURL, destinationURL)
+
+	var previous syncStateFile
+
+	loaded, err := loadStateFile(state.path, state.previous.stateHeader, &previous)
+	if !loaded {
+		return state, err
+	}
+
+	if previous.Projects != nil {
+		state.previous.Projects = previous.Projects
+	}
+
+	zap.L().Info("Loaded sync state", zap.String("path", state.path), zap.Int("projects", len(state.previous.Projects)))
+
+	return state, nil
+}
+
+// Covers checks if the source project had no activity since its last successful sync to the same destination project
+// (not recreated since) with the same mirroring options.
+// A covered project keeps its watermark.
+func (s *SyncState) Covers(sourcePath string, sourceProject, destinationProject *gitlab.Project, copyOptions *utils.MirroringOptions) bool {
+	if s == nil || sourceProject == nil || sourceProject.LastActivityAt == nil || destinationProject == nil {
+		return false
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	watermark, ok := s.previous.Projects[sourcePath]
+	if !ok || watermark.DestinationID != destinationProject.ID || watermark.Options != optionsFingerprint(copyOptions) || sourceProject.LastActivityAt.After(watermark.LastActivityAt) {
+		return false
+	}
+
+	s.current.Projects[sourcePath] = watermark
+
+	return true
+}
+
+// Record records the watermark of a successfully synced project, its source last activity and its destination project.
+func (s *SyncState) Record(sourcePath string, sourceProject, destinationProject *gitlab.Project, copyOptions *utils.MirroringOptions) {
+	if s == nil || sourceProject == nil || sourceProject.LastActivityAt == nil || destinationProject == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.current.Projects[sourcePath] = &ProjectWatermark{
+		LastActivityAt: sourceProject.LastActivityAt.UTC(),
+		SyncedAt:       time.Now().UTC(),
+		Options:        optionsFingerprint(copyOptions),
+		DestinationID:  destinationProject.ID,
+	}
+}
+
+// Save writes the watermarks of the current run to the state directory.
+func (s *SyncState) Save() error {
+	if s == nil {
+		return nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return writeStateFile(s.path, &s.current)
+}
@@ -0,0 +1,131 @@
+package mirroring
+
+import (
+	"testing"
+	"time"
+
+	"github.com/boxboxjason/gitlab-sync/internal/utils"
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+func TestValidateSince(t *testing.T) {
+	tests := []struct {
+		name          string
+		since         string
+		expectedError bool
+	}{
+		{name: "Unset", since: ""},
+		{name: "Last success", since: SINCE_LAST_SUCCESS},
+		{name: "Invalid", since: "yesterday", expectedError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := ValidateSince(tt.since)
+			if (err != nil) != tt.expectedError {
+				t.Errorf(EXPECTED_ERROR_MESSAGE, tt.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestSyncStateCovers(t *testing.T) {
+	syncedActivity := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	copyOptions := &utils.MirroringOptions{DestinationPath: "mirror/api"}
+	destinationProject := &gitlab.Project{ID: 10, PathWithNamespace: "mirror/api"}
+
+	tests := []struct {
+		name               string
+		lastActivityAt     *time.Time
+		destinationProject *gitlab.Project
+		copyOptions        *utils.MirroringOptions
+		expected           bool
+	}{
+		{
+			name:               "No activity since the sync",
+			lastActivityAt:     new(syncedActivity),
+			destinationProject: destinationProject,
+			copyOptions:        copyOptions,
+			expected:           true,
+		},
+		{
+			name:               "Activity since the sync",
+			lastActivityAt:     new(syncedActivity.Add(time.Minute)),
+			destinationProject: destinationProject,
+			copyOptions:        copyOptions,
+		},
+		{
+			name:               "Unknown activity",
+			destinationProject: destinationProject,
+			copyOptions:        copyOptions,
+		},
+		{
+			name:               "Changed mirroring options",
+			lastActivityAt:     new(syncedActivity),
+			destinationProject: destinationProject,
+			copyOptions:        &utils.MirroringOptions{DestinationPath: "mirror/api-v2"},
+		},
+		{
+			name:               "Recreated destination project",
+			lastActivityAt:     new(syncedActivity),
+			destinationProject: &gitlab.Project{ID: 11, PathWithNamespace: "mirror/api"},
+			copyOptions:        copyOptions,
+		},
+		{
+			name:           "Missing destination project",
+			lastActivityAt: new(syncedActivity),
+			copyOptions:    copyOptions,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			stateDir := t.TempDir()
+
+			recorder, err := LoadSyncState(stateDir, TEST_INVENTORY_SOURCE_URL, TEST_INVENTORY_DESTINATION_URL)
+			if err != nil {
+				t.Fatalf("failed to load sync state: %v", err)
+			}
+
+			recorder.Record("org/api", &gitlab.Project{LastActivityAt: &syncedActivity}, destinationProject, copyOptions)
+
+			if err := recorder.Save(); err != nil {
+				t.Fatalf("failed to save sync state: %v", err)
+			}
+
+			state, err := LoadSyncState(stateDir, TEST_INVENTORY_SOURCE_URL, TEST_INVENTORY_DESTINATION_URL)
+			if err != nil {
+				t.Fatalf("failed to reload sync state: %v", err)
+			}
+
+			if covered := state.Covers("org/api", &gitlab.Project{LastActivityAt: tt.lastActivityAt}, tt.destinationProject, tt.copyOptions); covered != tt.expected {
+				t.Errorf("expected covered %v, got %v", tt.expected, covered)
+			}
+
+			// Only the covering watermarks are carried over to the next run
+			if _, kept := state.current.Projects["org/api"]; kept != tt.expected {
+				t.Errorf("expected the watermark to be kept %v, got %v", tt.expected, kept)
+			}
+		})
+	}
+}
+
+func TestNilSyncState(t *testing.T) {
+	t.Parallel()
+
+	var state *SyncState
+
+	if state.Covers("org/api", &gitlab.Project{LastActivityAt: new(time.Now())}, &gitlab.Project{}, &utils.MirroringOptions{}) {
+		t.Error("expected a disabled sync state to cover no project")
+	}
+
+	state.Record("org/api", &gitlab.Project{LastActivityAt: new(time.Now())}, &gitlab.Project{}, &utils.MirroringOptions{})
+
+	if err := state.Save(); err != nil {
+		t.Errorf("expected a disabled sync state to save nothing, got %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/boxboxjason/gitlab-sync/pkg/helpers"
+)
+
+// exclusionRule is a compiled entry of a group exclude list.
+// Entries without any slash (and not written as regular expressions) match the name
+// of any descendant at any depth, the other entries match the path relative to the group
+// declaring the exclude list (its root, empty when declared by a pattern entry).
+type exclusionRule struct {
+	pattern  *helpers.PathPattern
+	root     string
+	nameOnly bool
+}
+
+// compileExclusions compiles the exclude list of a group mapping entry.
+// Entries may be written relative to the group, or as full paths starting with the group path.
+func compileExclusions(groupPath string, entries []string) ([]*exclusionRule, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	rules := make([]*exclusionRule, 0, len(entries))
+
+	for _, entry := range entries {
+		rule, err := compileExclusion(groupPath, entry)
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// compileExclusion compiles a single exclude list entry.
+func compileExclusion(groupPath, entry string) (*exclusionRule, error) {
+	entry = strings.TrimSpace(entry)
+
+	expression, isRegex := strings.CutPrefix(entry, helpers.PATTERN_REGEX_PREFIX)
+	if !isRegex && groupPath != "" {
+		entry = strings.TrimPrefix(entry, groupPath+"/")
+	}
+
+	pattern, err := helpers.CompilePathPattern(entry)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exclude entry %q: %w", entry, err)
+	}
+
+	return &exclusionRule{
+		pattern:  pattern,
+		root:     groupPath,
+		nameOnly: !isRegex && !strings.Contains(expression, "/"),
+	}, nil
+}
+
+// IsExcluded checks whether a descendant of a mirrored group is excluded by the group exclude list.
+// groupPath is the source path of the group entry holding these options.
+// A descendant is also excluded when one of its ancestors (below the group) is excluded.
+// Exclude lists inherited from an ancestor entry never exclude the group itself.
+func (o *MirroringOptions) IsExcluded(groupPath, descendantPath string) bool {
+	if !strings.HasPrefix(descendantPath, groupPath+"/") {
+		return false
+	}
+
+	rules := o.exclusions
+	if rules == nil {
+		// The options were not checked (built programmatically), invalid entries are ignored
+		for _, entry := range o.Exclude {
+			if rule, err := compileExclusion(groupPath, entry); err == nil {
+				rules = append(rules, rule)
+			}
+		}
+	}
+
+	for _, rule := range rules {
+		root := rule.root
+		if root == "" {
+			root = groupPath
+		}
+
+		relativePath, ok := strings.CutPrefix(descendantPath, root+"/")
+		if !ok {
+			continue
+		}
+
+		// Only the segments below the group holding the options are evaluated
+		firstSegment := 0
+		if groupRelativePath, ok := strings.CutPrefix(groupPath, root+"/"); ok {
+			firstSegment = strings.Count(groupRelativePath, "/") + 1
+		}
+
+		segments := strings.Split(relativePath, "/")
+		for index := firstSegment; index < len(segments); index++ {
+			target := strings.Join(segments[:index+1], "/")
+			if rule.nameOnly {
+				target = segments[index]
+			}
+
+			if _, matched := rule.pattern.Match(target); matched {
+				return true
+			}
+		}
+	}
+
+	return false
+}
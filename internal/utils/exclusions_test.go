@@ -0,0 +1,165 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestMirroringOptionsIsExcluded(t *testing.T) {
+	tests := []struct {
+		name           string
+		exclude        []string
+		descendantPath string
+		expected       bool
+	}{
+		{
+			name:           "No exclude list",
+			descendantPath: "team/sandbox",
+			expected:       false,
+		},
+		{
+			name:           "Relative literal path",
+			exclude:        []string{"sandbox"},
+			descendantPath: "team/sandbox",
+			expected:       true,
+		},
+		{
+			name:           "Full literal path",
+			exclude:        []string{"team/sandbox"},
+			descendantPath: "team/sandbox",
+			expected:       true,
+		},
+		{
+			name:           "Descendant of an excluded group",
+			exclude:        []string{"team/sandbox"},
+			descendantPath: "team/sandbox/sub/project",
+			expected:       true,
+		},
+		{
+			name:           "Name pattern at any depth",
+			exclude:        []string{"*-playground"},
+			descendantPath: "team/apps/api-playground",
+			expected:       true,
+		},
+		{
+			name:           "Anchored relative pattern",
+			exclude:        []string{"apps/*-playground"},
+			descendantPath: "team/other/apps/api-playground",
+			expected:       false,
+		},
+		{
+			name:           "Regular expression on the relative path",
+			exclude:        []string{"re:^legacy/.+$"},
+			descendantPath: "team/legacy/project",
+			expected:       true,
+		},
+		{
+			name:           "Not matching",
+			exclude:        []string{"sandbox", "*-playground"},
+			descendantPath: "team/apps/api",
+			expected:       false,
+		},
+		{
+			name:           "Outside of the group",
+			exclude:        []string{"sandbox"},
+			descendantPath: "team-b/sandbox",
+			expected:       false,
+		},
+		{
+			name:           "The group itself",
+			exclude:        []string{"*"},
+			descendantPath: "team",
+			expected:       false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			options := &MirroringOptions{Exclude: tt.exclude}
+			if got := options.IsExcluded("team", tt.descendantPath); got != tt.expected {
+				t.Errorf("expected IsExcluded(%s) to be %t, got %t", tt.descendantPath, tt.expected, got)
+			}
+
+			// The compiled exclude list must behave the same way
+			exclusions, err := compileExclusions("team", tt.exclude)
+			if err != nil {
+				t.Fatalf("failed to compile exclude list: %v", err)
+			}
+
+			options.exclusions = exclusions
+			if got := options.IsExcluded("team", tt.descendantPath); got != tt.expected {
+				t.Errorf("expected compiled IsExcluded(%s) to be %t, got %t", tt.descendantPath, tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestCheckExclusions(t *testing.T) {
+	tests := []struct {
+		name           string
+		mapping        *MirrorMapping
+		expectedErrors int
+	}{
+		{
+			name: "Valid group exclude list",
+			mapping: &MirrorMapping{
+				Groups: map[string]*MirroringOptions{
+					FAKE_VALID_GROUP: {DestinationPath: FAKE_VALID_GROUP, Exclude: []string{"sandbox", "*-playground"}},
+				},
+			},
+		},
+		{
+			name: "Invalid group exclude entry",
+			mapping: &MirrorMapping{
+				Groups: map[string]*MirroringOptions{
+					FAKE_VALID_GROUP: {DestinationPath: FAKE_VALID_GROUP, Exclude: []string{"re:sandbox("}},
+				},
+			},
+			expectedErrors: 1,
+		},
+		{
+			name: "Exclude list in a project entry",
+			mapping: &MirrorMapping{
+				Projects: map[string]*MirroringOptions{
+					FAKE_VALID_PROJECT: {DestinationPath: FAKE_VALID_PROJECT, Exclude: []string{"sandbox"}},
+				},
+			},
+			expectedErrors: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if errs := tt.mapping.check(); len(errs) != tt.expectedErrors {
+				t.Errorf("expected %d errors, got %v", tt.expectedErrors, errs)
+			}
+		})
+	}
+}
+
+func TestOpenMirrorMappingExclusions(t *testing.T) {
+	t.Parallel()
+
+	content := "groups:\n  team:\n    destination_path: mirror/team\n    exclude:\n      - team/sandbox\n      - \"*-playground\"\n"
+
+	mapping, errs := OpenMirrorMapping(createTempMappingFile(t, "mapping.yaml", content))
+	if len(errs) > 0 {
+		t.Fatalf("OpenMirrorMapping() errors = %v", errs)
+	}
+
+	options, ok := mapping.GetGroup("team")
+	if !ok {
+		t.Fatal("expected group team to be in the mapping")
+	}
+
+	if len(options.Exclude) != 2 || len(options.exclusions) != 2 {
+		t.Fatalf("expected 2 compiled exclude entries, got %v", options.Exclude)
+	}
+
+	if !options.IsExcluded("team", "team/sandbox/project") || !options.IsExcluded("team", "team/a/b-playground") {
+		t.Error("expected the exclude list to apply to the descendants of team")
+	}
+}
@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/boxboxjason/gitlab-sync/pkg/helpers"
+)
+
+// defaultIncludeRefs are the refs pushed by the local git mirroring when include_refs is not set,
+// leaving out the GitLab internal refs (refs/merge-requests, refs/pipelines, refs/keep-around, refs/environments).
+var defaultIncludeRefs = []string{"refs/heads/*", "refs/tags/*"}
+
+// GitOptions defines which refs of the source repository are mirrored
+// - include_refs / exclude_refs: the refspec patterns of the refs pushed / left out by the local git mirroring (refs/heads/*, refs/tags/v*)
+// - branch_regex: the regular expression the mirrored branch names must match
+// - tag_regex: the regular expression the mirrored tag names must match (local git mirroring only)
+// - protected_branches_only: whether to only mirror the protected branches of the source project.
+// The pull mirrors only support branch_regex and protected_branches_only, which cannot be combined.
+type GitOptions struct {
+	ProtectedBranchesOnly *bool    `json:"protected_branches_only,omitempty" toml:"protected_branches_only,omitempty"`
+	BranchRegex           string   `json:"branch_regex,omitempty"            toml:"branch_regex,omitempty"`
+	TagRegex              string   `json:"tag_regex,omitempty"               toml:"tag_regex,omitempty"`
+	IncludeRefs           []string `json:"include_refs,omitempty"            toml:"include_refs,omitempty"`
+	ExcludeRefs           []string `json:"exclude_refs,omitempty"            toml:"exclude_refs,omitempty"`
+}
+
+// check checks if the git options are valid.
+func (o *GitOptions) check() error {
+	var errs []error
+
+	if _, err := o.RefFilter(nil); err != nil {
+		errs = append(errs, err)
+	}
+
+	if o.BranchRegex != "" && helpers.Deref(o.ProtectedBranchesOnly, false) {
+		errs = append(errs, errors.New("branch_regex and protected_branches_only cannot be combined"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// OnlyProtectedBranches reports whether only the protected branches are mirrored, defaultValue being used when unset.
+func (o *GitOptions) OnlyProtectedBranches(defaultValue bool) bool {
+	if o == nil {
+		return defaultValue
+	}
+
+	return helpers.Deref(o.ProtectedBranchesOnly, defaultValue)
+}
+
+// MirrorBranchRegex returns the regular expression of the mirrored branch names, empty when every branch is mirrored.
+func (o *GitOptions) MirrorBranchRegex() string {
+	if o == nil {
+		return ""
+	}
+
+	return o.BranchRegex
+}
+
+// RefFilter returns the filter of the refs pushed by the local git mirroring.
+// protectedBranches are the names of the protected source branches, only used with protected_branches_only.
+// A nil options block pushes the branches and tags.
+func (o *GitOptions) RefFilter(protectedBranches []string) (*helpers.RefFilter, error) {
+	if o == nil {
+		return &helpers.RefFilter{Include: defaultIncludeRefs}, nil
+	}
+
+	filter := &helpers.RefFilter{Include: o.IncludeRefs, Exclude: o.ExcludeRefs}
+	if len(filter.Include) == 0 {
+		filter.Include = defaultIncludeRefs
+	}
+
+	var errs []error
+
+	for _, pattern := range slices.Concat(o.IncludeRefs, o.ExcludeRefs) {
+		if err := helpers.CheckRefPattern(pattern); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	var err error
+
+	if o.BranchRegex != "" {
+		if filter.BranchRegex, err = regexp.Compile(o.BranchRegex); err != nil {
+			errs = append(errs, fmt.Errorf("invalid branch_regex: %w", err))
+		}
+	}
+
+	if o.TagRegex != "" {
+		if filter.TagRegex, err = regexp.Compile(o.TagRegex); err != nil {
+			errs = append(errs, fmt.Errorf("invalid tag_regex: %w", err))
+		}
+	}
+
+	if helpers.Deref(o.ProtectedBranchesOnly, false) {
+		filter.Branches = make(map[string]struct{}, len(protectedBranches))
+		for _, branch := range protectedBranches {
+			filter.Branches[branch] = struct{}{}
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return filter, nil
+}
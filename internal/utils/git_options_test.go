@@ -0,0 +1,144 @@
+package utils
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+func TestGitOptionsCheck(t *testing.T) {
+	tests := []struct {
+		name          string
+		options       *GitOptions
+		expectedError bool
+	}{
+		{name: "Empty options", options: &GitOptions{}},
+		{name: "Valid options", options: &GitOptions{BranchRegex: `^(main|release/.*)$`, TagRegex: `^v\d+`, IncludeRefs: []string{"refs/heads/*"}, ExcludeRefs: []string{"refs/heads/tmp/*"}}},
+		{name: "Invalid branch regex", options: &GitOptions{BranchRegex: `(main`}, expectedError: true},
+		{name: "Invalid tag regex", options: &GitOptions{TagRegex: `[v`}, expectedError: true},
+		{name: "Invalid include pattern", options: &GitOptions{IncludeRefs: []string{"heads/*"}}, expectedError: true},
+		{name: "Invalid exclude pattern", options: &GitOptions{ExcludeRefs: []string{"refs/*/x/*"}}, expectedError: true},
+		{name: "Regex with protected branches", options: &GitOptions{BranchRegex: `^main$`, ProtectedBranchesOnly: new(true)}, expectedError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if err := tt.options.check(); (err != nil) != tt.expectedError {
+				t.Errorf("expected error: %v, got: %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestGitOptionsRefFilter(t *testing.T) {
+	tests := []struct {
+		name              string
+		options           *GitOptions
+		protectedBranches []string
+		expected          map[plumbing.ReferenceName]bool
+	}{
+		{
+			name:    "Default refs",
+			options: nil,
+			expected: map[plumbing.ReferenceName]bool{
+				"refs/heads/feature":         true,
+				"refs/tags/v1.0.0":           true,
+				"refs/merge-requests/1/head": false,
+				"refs/pipelines/42":          false,
+				"refs/keep-around/abc":       false,
+			},
+		},
+		{
+			name:    "Custom refs",
+			options: &GitOptions{IncludeRefs: []string{"refs/*"}, ExcludeRefs: []string{"refs/pipelines/*"}},
+			expected: map[plumbing.ReferenceName]bool{
+				"refs/merge-requests/1/head": true,
+				"refs/pipelines/42":          false,
+			},
+		},
+		{
+			name:              "Protected branches only",
+			options:           &GitOptions{ProtectedBranchesOnly: new(true), TagRegex: `^v`},
+			protectedBranches: []string{"main"},
+			expected: map[plumbing.ReferenceName]bool{
+				"refs/heads/main":    true,
+				"refs/heads/feature": false,
+				"refs/tags/v1.0.0":   true,
+				"refs/tags/nightly":  false,
+			},
+		},
+		{
+			name:              "Protected branches ignored when unset",
+			options:           &GitOptions{BranchRegex: `^feat`},
+			protectedBranches: []string{"main"},
+			expected: map[plumbing.ReferenceName]bool{
+				"refs/heads/main":    false,
+				"refs/heads/feature": true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			filter, err := tt.options.RefFilter(tt.protectedBranches)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			for name, expected := range tt.expected {
+				if got := filter.Match(name); got != expected {
+					t.Errorf("Match(%s) = %t; want %t", name, got, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestGitOptionsPullMirrorSettings(t *testing.T) {
+	t.Parallel()
+
+	var unset *GitOptions
+	if !unset.OnlyProtectedBranches(true) || unset.MirrorBranchRegex() != "" {
+		t.Error("expected unset git options to keep the defaults")
+	}
+
+	options := &GitOptions{ProtectedBranchesOnly: new(false), BranchRegex: `^main$`}
+	if options.OnlyProtectedBranches(true) || options.MirrorBranchRegex() != `^main$` {
+		t.Errorf("expected the git options to override the defaults, got %+v", options)
+	}
+}
+
+func TestOpenMirrorMappingGitOptions(t *testing.T) {
+	t.Parallel()
+
+	content := `groups:
+  team:
+    destination_path: mirror/team
+    git:
+      protected_branches_only: true
+      exclude_refs: [refs/heads/tmp/*]
+  team/legacy:
+    destination_path: mirror/team/legacy
+projects:
+  team/api:
+    destination_path: mirror/api
+    git:
+      branch_regex: "(main"
+`
+
+	mapping, errs := OpenMirrorMapping(createTempMappingFile(t, "mapping.yaml", content))
+	if len(errs) != 1 || MappingErrorPath(errs[0]) != `$.projects["team/api"].git` {
+		t.Fatalf("expected exactly 1 error (invalid branch regex), got %v", errs)
+	}
+
+	// The nested group inherits the git options of its ancestor
+	legacy, ok := mapping.GetGroup("team/legacy")
+	if !ok || !legacy.Git.OnlyProtectedBranches(false) || !slices.Equal(legacy.Git.ExcludeRefs, []string{"refs/heads/tmp/*"}) {
+		t.Errorf("expected the nested group to inherit the git options, got %+v", legacy.Git)
+	}
+}
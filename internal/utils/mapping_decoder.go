@@ -0,0 +1,209 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/pelletier/go-toml/v2"
+)
+
+const (
+	// MAPPING_FORMAT_JSON is the identifier of JSON mirror mapping files.
+	MAPPING_FORMAT_JSON = "json"
+	// MAPPING_FORMAT_YAML is the identifier of YAML mirror mapping files.
+	MAPPING_FORMAT_YAML = "yaml"
+	// MAPPING_FORMAT_TOML is the identifier of TOML mirror mapping files.
+	MAPPING_FORMAT_TOML = "toml"
+)
+
+// MappingFormats are the supported formats of the mirror mapping files.
+var MappingFormats = []string{MAPPING_FORMAT_JSON, MAPPING_FORMAT_YAML, MAPPING_FORMAT_TOML}
+
+// tomlKeyValuePattern matches a TOML "key = value" line (bare, dotted or quoted keys).
+var tomlKeyValuePattern = regexp.MustCompile(`^([A-Za-z0-9_\-. ]|"[^"]*"|'[^']*')+=`)
+
+// MappingDecodeError reports a mirror mapping file that could not be decoded,
+// along with the position (1-based line and column) of the faulty token in the original file.
+type MappingDecodeError struct {
+	Err    error
+	Format string
+	Line   int
+	Column int
+}
+
+// Error implements the error interface.
+func (e *MappingDecodeError) Error() string {
+	if e.Line <= 0 {
+		return fmt.Sprintf("invalid %s: %v", e.Format, e.Err)
+	}
+
+	return fmt.Sprintf("invalid %s at line %d, column %d: %v", e.Format, e.Line, e.Column, e.Err)
+}
+
+// Unwrap returns the underlying decoder error.
+func (e *MappingDecodeError) Unwrap() error {
+	return e.Err
+}
+
+// DetectMappingFormat returns the format of a mirror mapping file.
+// The file extension (.json, .yaml / .yml, .toml) wins when it is known,
+// otherwise the format is guessed from the file content.
+func DetectMappingFormat(path string, content []byte) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return MAPPING_FORMAT_JSON
+	case ".yaml", ".yml":
+		return MAPPING_FORMAT_YAML
+	case ".toml":
+		return MAPPING_FORMAT_TOML
+	default:
+		return sniffMappingFormat(content)
+	}
+}
+
+// sniffMappingFormat guesses the format of a mirror mapping from its content.
+// A document starting with "{" is JSON, a document whose first significant line
+// is a TOML table header or a "key = value" pair is TOML, anything else is YAML.
+func sniffMappingFormat(content []byte) string {
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(content, []byte("\xef\xbb\xbf")))
+	if len(trimmed) == 0 || trimmed[0] == '{' {
+		return MAPPING_FORMAT_JSON
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") || tomlKeyValuePattern.MatchString(line) {
+			return MAPPING_FORMAT_TOML
+		}
+
+		break
+	}
+
+	return MAPPING_FORMAT_YAML
+}
+
+// decodeMirrorMapping decodes the content of a mirror mapping file into the given mapping.
+// All formats decode into the same MirrorMapping / MirroringOptions structs.
+// Decoding errors are returned as *MappingDecodeError, positioned in the original content.
+func decodeMirrorMapping(content []byte, format string, mapping *MirrorMapping) error {
+	switch format {
+	case MAPPING_FORMAT_JSON:
+		return decodeJSONMirrorMapping(content, mapping)
+	case MAPPING_FORMAT_YAML:
+		return decodeYAMLMirrorMapping(content, mapping)
+	case MAPPING_FORMAT_TOML:
+		return decodeTOMLMirrorMapping(content, mapping)
+	default:
+		return fmt.Errorf("unsupported mirror mapping format: %s", format)
+	}
+}
+
+// decodeMappingDocument decodes the content of a mirror mapping file into generic maps and slices,
+// to check its fields against the mapping schema.
+func decodeMappingDocument(content []byte, format string) (any, error) {
+	var document any
+
+	var err error
+
+	switch format {
+	case MAPPING_FORMAT_JSON:
+		err = json.Unmarshal(content, &document)
+	case MAPPING_FORMAT_YAML:
+		err = yaml.Unmarshal(content, &document)
+	case MAPPING_FORMAT_TOML:
+		err = toml.Unmarshal(content, &document)
+	default:
+		err = fmt.Errorf("unsupported mirror mapping format: %s", format)
+	}
+
+	return document, err
+}
+
+// decodeJSONMirrorMapping decodes a JSON mirror mapping.
+// The JSON decoder only reports byte offsets, they are converted to line / column positions.
+func decodeJSONMirrorMapping(content []byte, mapping *MirrorMapping) error {
+	err := json.Unmarshal(content, mapping)
+	if err == nil {
+		return nil
+	}
+
+	decodeErr := &MappingDecodeError{Format: MAPPING_FORMAT_JSON, Err: err}
+
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+
+	switch {
+	case errors.As(err, &syntaxErr):
+		decodeErr.Line, decodeErr.Column = positionFromOffset(content, syntaxErr.Offset)
+	case errors.As(err, &typeErr):
+		decodeErr.Line, decodeErr.Column = positionFromOffset(content, typeErr.Offset)
+	}
+
+	return decodeErr
+}
+
+// decodeYAMLMirrorMapping decodes a YAML mirror mapping.
+func decodeYAMLMirrorMapping(content []byte, mapping *MirrorMapping) error {
+	err := yaml.Unmarshal(content, mapping)
+	if err == nil {
+		return nil
+	}
+
+	decodeErr := &MappingDecodeError{Format: MAPPING_FORMAT_YAML, Err: err}
+
+	var yamlErr yaml.Error
+	if errors.As(err, &yamlErr) {
+		decodeErr.Err = errors.New(yamlErr.GetMessage())
+		if token := yamlErr.GetToken(); token != nil && token.Position != nil {
+			decodeErr.Line = token.Position.Line
+			decodeErr.Column = token.Position.Column
+		}
+	}
+
+	return decodeErr
+}
+
+// decodeTOMLMirrorMapping decodes a TOML mirror mapping.
+func decodeTOMLMirrorMapping(content []byte, mapping *MirrorMapping) error {
+	err := toml.Unmarshal(content, mapping)
+	if err == nil {
+		return nil
+	}
+
+	decodeErr := &MappingDecodeError{Format: MAPPING_FORMAT_TOML, Err: err}
+
+	var tomlErr *toml.DecodeError
+	if errors.As(err, &tomlErr) {
+		decodeErr.Err = errors.New(strings.TrimPrefix(tomlErr.Error(), "toml: "))
+		decodeErr.Line, decodeErr.Column = tomlErr.Position()
+	}
+
+	return decodeErr
+}
+
+// positionFromOffset converts a JSON decoder offset into a 1-based line and column.
+// The JSON decoder reports the number of bytes read when the error occurred,
+// so the position returned is the one of the last byte read.
+func positionFromOffset(content []byte, offset int64) (int, int) {
+	index := max(min(offset, int64(len(content)))-1, 0)
+
+	before := content[:index]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+
+	return line, column
+}
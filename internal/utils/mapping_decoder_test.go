@@ -0,0 +1,311 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var (
+	FAKE_VALID_MAPPING_YAML = fmt.Sprintf(`# Mirror mapping
+projects:
+  %s:
+    destination_path: %s
+    ci_cd_catalog: true
+    mirror_issues: true
+    mirror_trigger_builds: false
+    visibility: private
+groups:
+  %s:
+    destination_path: %s
+    ci_cd_catalog: true
+    mirror_issues: true
+    mirror_trigger_builds: false
+    visibility: private
+`, FAKE_VALID_PROJECT, FAKE_VALID_PROJECT, FAKE_VALID_GROUP, FAKE_VALID_GROUP)
+
+	FAKE_VALID_MAPPING_TOML = fmt.Sprintf(`# Mirror mapping
+[projects."%s"]
+destination_path = "%s"
+ci_cd_catalog = true
+mirror_issues = true
+mirror_trigger_builds = false
+visibility = "private"
+
+[groups."%s"]
+destination_path = "%s"
+ci_cd_catalog = true
+mirror_issues = true
+mirror_trigger_builds = false
+visibility = "private"
+`, FAKE_VALID_PROJECT, FAKE_VALID_PROJECT, FAKE_VALID_GROUP, FAKE_VALID_GROUP)
+)
+
+// createTempMappingFile writes a mapping file with the given name in a temporary directory
+func createTempMappingFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write temp mapping file: %v", err)
+	}
+
+	return path
+}
+
+func TestDetectMappingFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		content  string
+		expected string
+	}{
+		{
+			name:     "JSON extension",
+			path:     "mapping.json",
+			content:  FAKE_VALID_MAPPING_YAML,
+			expected: MAPPING_FORMAT_JSON,
+		},
+		{
+			name:     "YAML extension",
+			path:     "mapping.yaml",
+			content:  FAKE_VALID_MAPPING_RAW,
+			expected: MAPPING_FORMAT_YAML,
+		},
+		{
+			name:     "YML extension (upper case)",
+			path:     "mapping.YML",
+			expected: MAPPING_FORMAT_YAML,
+		},
+		{
+			name:     "TOML extension",
+			path:     "mapping.toml",
+			expected: MAPPING_FORMAT_TOML,
+		},
+		{
+			name:     "Unknown extension, JSON content",
+			path:     "mapping",
+			content:  FAKE_VALID_MAPPING_RAW,
+			expected: MAPPING_FORMAT_JSON,
+		},
+		{
+			name:     "Unknown extension, YAML content",
+			path:     "mapping.conf",
+			content:  FAKE_VALID_MAPPING_YAML,
+			expected: MAPPING_FORMAT_YAML,
+		},
+		{
+			name:     "Unknown extension, TOML content",
+			path:     "mapping.conf",
+			content:  FAKE_VALID_MAPPING_TOML,
+			expected: MAPPING_FORMAT_TOML,
+		},
+		{
+			name:     "Unknown extension, TOML key value content",
+			path:     "mapping",
+			content:  "projects.\"a/b\".destination_path = \"a/b\"\n",
+			expected: MAPPING_FORMAT_TOML,
+		},
+		{
+			name:     "Unknown extension, empty content",
+			path:     "mapping",
+			content:  "  \n",
+			expected: MAPPING_FORMAT_JSON,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := DetectMappingFormat(tt.path, []byte(tt.content)); got != tt.expected {
+				t.Errorf("expected format %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestOpenMirrorMappingFormats(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		content  string
+	}{
+		{
+			name:     "JSON",
+			fileName: "mapping.json",
+			content:  FAKE_VALID_MAPPING_RAW,
+		},
+		{
+			name:     "YAML",
+			fileName: "mapping.yaml",
+			content:  FAKE_VALID_MAPPING_YAML,
+		},
+		{
+			name:     "YML",
+			fileName: "mapping.yml",
+			content:  FAKE_VALID_MAPPING_YAML,
+		},
+		{
+			name:     "TOML",
+			fileName: "mapping.toml",
+			content:  FAKE_VALID_MAPPING_TOML,
+		},
+		{
+			name:     "YAML without extension",
+			fileName: "mapping",
+			content:  FAKE_VALID_MAPPING_YAML,
+		},
+		{
+			name:     "TOML without extension",
+			fileName: "mapping",
+			content:  FAKE_VALID_MAPPING_TOML,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mapping, errs := OpenMirrorMapping(createTempMappingFile(t, tt.fileName, tt.content))
+			if len(errs) > 0 {
+				t.Fatalf("OpenMirrorMapping() errors = %v", errs)
+			}
+
+			if !reflect.DeepEqual(mapping.Projects, FAKE_VALID_MAPPPING.Projects) {
+				t.Errorf("expected projects %v, got %v", FAKE_VALID_MAPPPING.Projects, mapping.Projects)
+			}
+
+			if !reflect.DeepEqual(mapping.Groups, FAKE_VALID_MAPPPING.Groups) {
+				t.Errorf("expected groups %v, got %v", FAKE_VALID_MAPPPING.Groups, mapping.Groups)
+			}
+		})
+	}
+}
+
+func TestOpenMirrorMappingDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name           string
+		fileName       string
+		content        string
+		expectedFormat string
+		expectedLine   int
+	}{
+		{
+			name:           "JSON syntax error",
+			fileName:       "mapping.json",
+			content:        "{\n  \"projects\": {\n    \"a/b\": {\"destination_path\": \"a/b\",}\n  }\n}\n",
+			expectedFormat: MAPPING_FORMAT_JSON,
+			expectedLine:   3,
+		},
+		{
+			name:           "JSON type error",
+			fileName:       "mapping.json",
+			content:        "{\n  \"projects\": {\n    \"a/b\": {\n      \"mirror_issues\": \"yes\"\n    }\n  }\n}\n",
+			expectedFormat: MAPPING_FORMAT_JSON,
+			expectedLine:   4,
+		},
+		{
+			name:           "YAML syntax error",
+			fileName:       "mapping.yaml",
+			content:        "projects:\n  a/b:\n    destination_path: \"a/b\n",
+			expectedFormat: MAPPING_FORMAT_YAML,
+			expectedLine:   3,
+		},
+		{
+			name:           "YAML type error",
+			fileName:       "mapping.yaml",
+			content:        "projects:\n  a/b:\n    destination_path: a/b\n    mirror_issues: [true]\n",
+			expectedFormat: MAPPING_FORMAT_YAML,
+			expectedLine:   4,
+		},
+		{
+			name:           "TOML syntax error",
+			fileName:       "mapping.toml",
+			content:        "[projects.\"a/b\"]\ndestination_path = \"a/b\"\nmirror_issues = \n",
+			expectedFormat: MAPPING_FORMAT_TOML,
+			expectedLine:   3,
+		},
+		{
+			name:           "TOML type error",
+			fileName:       "mapping.toml",
+			content:        "[projects.\"a/b\"]\ndestination_path = \"a/b\"\nmirror_issues = \"yes\"\n",
+			expectedFormat: MAPPING_FORMAT_TOML,
+			expectedLine:   3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := createTempMappingFile(t, tt.fileName, tt.content)
+
+			_, errs := OpenMirrorMapping(path)
+			if len(errs) != 1 {
+				t.Fatalf("expected exactly 1 error, got %v", errs)
+			}
+
+			var decodeErr *MappingDecodeError
+			if !errors.As(errs[0], &decodeErr) {
+				t.Fatalf("expected a MappingDecodeError, got %v", errs[0])
+			}
+
+			if decodeErr.Format != tt.expectedFormat {
+				t.Errorf("expected format %s, got %s", tt.expectedFormat, decodeErr.Format)
+			}
+
+			if decodeErr.Line != tt.expectedLine {
+				t.Errorf("expected error at line %d, got %d (%v)", tt.expectedLine, decodeErr.Line, errs[0])
+			}
+
+			if decodeErr.Column <= 0 {
+				t.Errorf("expected a positive column, got %d (%v)", decodeErr.Column, errs[0])
+			}
+
+			if !strings.Contains(errs[0].Error(), path) {
+				t.Errorf("expected error to mention the file path %s, got %v", path, errs[0])
+			}
+		})
+	}
+}
+
+func TestOpenMirrorMappingMissingOptions(t *testing.T) {
+	t.Parallel()
+
+	_, errs := OpenMirrorMapping(createTempMappingFile(t, "mapping.yaml", "groups:\n  group1:\n"))
+	if len(errs) != 1 || errs[0].Error() != "missing mirroring options in group mapping: group1" {
+		t.Errorf("expected a missing mirroring options error, got %v", errs)
+	}
+}
+
+func TestPositionFromOffset(t *testing.T) {
+	content := []byte("ab\ncd\n\nef")
+	tests := []struct {
+		name           string
+		offset         int64
+		expectedLine   int
+		expectedColumn int
+	}{
+		{name: "Start of file", offset: 0, expectedLine: 1, expectedColumn: 1},
+		{name: "First line", offset: 2, expectedLine: 1, expectedColumn: 2},
+		{name: "Second line", offset: 4, expectedLine: 2, expectedColumn: 1},
+		{name: "Empty line", offset: 7, expectedLine: 3, expectedColumn: 1},
+		{name: "Out of bounds", offset: 100, expectedLine: 4, expectedColumn: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			line, column := positionFromOffset(content, tt.offset)
+			if line != tt.expectedLine || column != tt.expectedColumn {
+				t.Errorf("expected %d:%d, got %d:%d", tt.expectedLine, tt.expectedColumn, line, column)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/goccy/go-yaml"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// EncodeMirrorMapping encodes a mirror mapping in the given format (MAPPING_FORMAT_JSON, MAPPING_FORMAT_YAML, MAPPING_FORMAT_TOML).
+// The entries are sorted by key, so a mapping always encodes to the same content.
+func EncodeMirrorMapping(mapping *MirrorMapping, format string) ([]byte, error) {
+	switch format {
+	case MAPPING_FORMAT_JSON:
+		var buffer bytes.Buffer
+
+		encoder := json.NewEncoder(&buffer)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+
+		err := encoder.Encode(mapping)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode mirror mapping as %s: %w", format, err)
+		}
+
+		return buffer.Bytes(), nil
+	case MAPPING_FORMAT_YAML:
+		content, err := yaml.MarshalWithOptions(mapping, yaml.Indent(2))
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode mirror mapping as %s: %w", format, err)
+		}
+
+		return content, nil
+	case MAPPING_FORMAT_TOML:
+		content, err := toml.Marshal(mapping)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode mirror mapping as %s: %w", format, err)
+		}
+
+		return content, nil
+	default:
+		return nil, fmt.Errorf("unsupported mirror mapping format: %s", format)
+	}
+}
@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEncodeMirrorMapping(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		fileName string
+		contains string
+	}{
+		{
+			name:     "JSON",
+			format:   MAPPING_FORMAT_JSON,
+			fileName: "mapping.json",
+			contains: `"destination_path": "mirror/org/api"`,
+		},
+		{
+			name:     "YAML",
+			format:   MAPPING_FORMAT_YAML,
+			fileName: "mapping.yaml",
+			contains: "destination_path: mirror/org/api",
+		},
+		{
+			name:     "TOML",
+			format:   MAPPING_FORMAT_TOML,
+			fileName: "mapping.toml",
+			contains: "destination_path = 'mirror/org/api'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mapping := &MirrorMapping{
+				Projects: map[string]*MirroringOptions{
+					"org/api": {DestinationPath: "mirror/org/api", Visibility: new("private"), MirrorIssues: new(false)},
+				},
+				Groups: map[string]*MirroringOptions{
+					"org":       {DestinationPath: "mirror/org", Visibility: new("internal"), ClaimOwnership: new(true)},
+					"org/infra": {DestinationPath: "mirror/org/infra", Visibility: new("internal"), ClaimOwnership: new(false)},
+				},
+			}
+
+			content, err := EncodeMirrorMapping(mapping, tt.format)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !strings.Contains(string(content), tt.contains) {
+				t.Errorf("expected encoded mapping to contain %q, got:\n%s", tt.contains, content)
+			}
+
+			// The encoding must be stable
+			again, err := EncodeMirrorMapping(mapping, tt.format)
+			if err != nil || !bytes.Equal(content, again) {
+				t.Errorf("expected a stable encoding, got:\n%s\nand:\n%s", content, again)
+			}
+
+			// The encoded mapping must open to the same entries
+			opened, errs := OpenMirrorMapping(createTempMappingFile(t, tt.fileName, string(content)))
+			if len(errs) > 0 {
+				t.Fatalf("unexpected errors opening the encoded mapping: %v\n%s", errs, content)
+			}
+
+			if len(opened.Projects) != 1 || len(opened.Groups) != 2 {
+				t.Fatalf("expected 1 project and 2 groups, got %d and %d", len(opened.Projects), len(opened.Groups))
+			}
+
+			if options := opened.Projects["org/api"]; options.DestinationPath != "mirror/org/api" || *options.Visibility != "private" {
+				t.Errorf("expected project org/api to be decoded, got %+v", options)
+			}
+
+			if options := opened.Groups["org"]; options.ClaimOwnership == nil || !*options.ClaimOwnership {
+				t.Errorf("expected group org to claim ownership, got %+v", options)
+			}
+		})
+	}
+}
+
+func TestEncodeMirrorMappingUnsupportedFormat(t *testing.T) {
+	t.Parallel()
+
+	_, err := EncodeMirrorMapping(&MirrorMapping{}, "xml")
+	if err == nil {
+		t.Fatal("expected an error for an unsupported format")
+	}
+}
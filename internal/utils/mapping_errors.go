@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// MAPPING_ROOT_PATH is the JSON path of the mirror mapping document root.
+const MAPPING_ROOT_PATH = "$"
+
+// MappingError is a mirror mapping validation error, along with the JSON path of the faulty entry or field
+// (for example $.groups["org/security"].visibility). Its message is the one of the wrapped error.
+type MappingError struct {
+	Err  error
+	Path string
+}
+
+// Error implements the error interface.
+func (e *MappingError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error.
+func (e *MappingError) Unwrap() error {
+	return e.Err
+}
+
+// newMappingError wraps an error with the JSON path of the faulty mapping entry or field.
+func newMappingError(path string, err error) *MappingError {
+	return &MappingError{Path: path, Err: err}
+}
+
+// MappingErrorPath returns the JSON path carried by a mirror mapping error, or the document root path.
+func MappingErrorPath(err error) string {
+	var mappingErr *MappingError
+	if errors.As(err, &mappingErr) && mappingErr.Path != "" {
+		return mappingErr.Path
+	}
+
+	return MAPPING_ROOT_PATH
+}
+
+// entryPath returns the JSON path of a project or group mapping entry, or of one of its (nested) fields.
+func entryPath(kind, key string, fields ...string) string {
+	path := fmt.Sprintf("%s.%ss[%s]", MAPPING_ROOT_PATH, kind, strconv.Quote(key))
+	for _, field := range fields {
+		path += "." + field
+	}
+
+	return path
+}
+
+// rewriteRulePath returns the JSON path of a rewrite rule, or of one of its fields.
+func rewriteRulePath(index int, fields ...string) string {
+	path := fmt.Sprintf("%s.rewrite[%d]", MAPPING_ROOT_PATH, index)
+	for _, field := range fields {
+		path += "." + field
+	}
+
+	return path
+}
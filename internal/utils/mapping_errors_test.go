@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestMappingErrorPath(t *testing.T) {
+	baseErr := errors.New("invalid group visibility: secret")
+
+	tests := []struct {
+		name            string
+		err             error
+		expectedPath    string
+		expectedMessage string
+	}{
+		{
+			name:            "Mapping error",
+			err:             newMappingError(entryPath(GROUP, "org/security", "visibility"), baseErr),
+			expectedPath:    `$.groups["org/security"].visibility`,
+			expectedMessage: baseErr.Error(),
+		},
+		{
+			name:            "Wrapped mapping error",
+			err:             fmt.Errorf("wrapped: %w", newMappingError(rewriteRulePath(2, "regex"), baseErr)),
+			expectedPath:    "$.rewrite[2].regex",
+			expectedMessage: "wrapped: " + baseErr.Error(),
+		},
+		{
+			name:            "Plain error",
+			err:             baseErr,
+			expectedPath:    MAPPING_ROOT_PATH,
+			expectedMessage: baseErr.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if path := MappingErrorPath(tt.err); path != tt.expectedPath {
+				t.Errorf("expected path %s, got %s", tt.expectedPath, path)
+			}
+
+			if tt.err.Error() != tt.expectedMessage {
+				t.Errorf("expected message %q, got %q", tt.expectedMessage, tt.err.Error())
+			}
+
+			if !errors.Is(tt.err, baseErr) {
+				t.Error("expected the mapping error to wrap the original error")
+			}
+		})
+	}
+}
+
+func TestCheckErrorPaths(t *testing.T) {
+	t.Parallel()
+
+	mapping := &MirrorMapping{
+		Projects: map[string]*MirroringOptions{
+			"org/api": {DestinationPath: "api", Exclude: []string{"x"}},
+		},
+		Groups: map[string]*MirroringOptions{
+			"org": {DestinationPath: "mirror/org", Visibility: new("secret")},
+		},
+		Rewrite: []*RewriteRule{{Case: "title"}},
+	}
+
+	paths := make(map[string]struct{})
+	for _, err := range mapping.check() {
+		paths[MappingErrorPath(err)] = struct{}{}
+	}
+
+	for _, expectedPath := range []string{
+		`$.projects["org/api"].destination_path`,
+		`$.projects["org/api"].exclude`,
+		`$.groups["org"].visibility`,
+		"$.rewrite[0]",
+	} {
+		if _, ok := paths[expectedPath]; !ok {
+			t.Errorf("expected an error at %s, got %v", expectedPath, paths)
+		}
+	}
+}
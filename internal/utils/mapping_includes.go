@@ -0,0 +1,186 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/boxboxjason/gitlab-sync/pkg/helpers"
+)
+
+// mappingSources tracks the files a mirror mapping is composed of while it is loaded.
+// - projects / groups: the file declaring each mapping entry
+// - loading: the files currently being loaded (to detect include cycles)
+// - loaded: the files already loaded (a file included several times is only merged once).
+type mappingSources struct {
+	projects map[string]string
+	groups   map[string]string
+	loading  map[string]struct{}
+	loaded   map[string]struct{}
+}
+
+// loadMirrorMappingFile decodes a mirror mapping file and merges it, along with the files it includes, into the mapping.
+// The destination paths and include entries are interpolated with the environment variables.
+// Entries declared in several files are reported as errors naming both files.
+func (m *MirrorMapping) loadMirrorMappingFile(path string, sources *mappingSources) []error {
+	absolutePath, err := filepath.Abs(path)
+	if err != nil {
+		return []error{fmt.Errorf("failed to resolve mirror mapping file path %s: %w", path, err)}
+	}
+
+	if _, ok := sources.loading[absolutePath]; ok {
+		return []error{fmt.Errorf("mirror mapping include cycle detected: %s", path)}
+	}
+
+	if _, ok := sources.loaded[absolutePath]; ok {
+		return nil
+	}
+
+	sources.loading[absolutePath] = struct{}{}
+	sources.loaded[absolutePath] = struct{}{}
+
+	defer delete(sources.loading, absolutePath)
+
+	// Read the file
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return []error{fmt.Errorf("failed to open mirror mapping file: %w", err)}
+	}
+
+	// Decode the mapping
+	format := DetectMappingFormat(path, content)
+	fileMapping := &MirrorMapping{}
+
+	err = decodeMirrorMapping(content, format, fileMapping)
+	if err != nil {
+		return []error{fmt.Errorf("failed to decode mirror mapping file %s: %w", path, err)}
+	}
+
+	// Reject the fields unknown to the mapping (typos would otherwise be silently ignored)
+	document, err := decodeMappingDocument(content, format)
+	if err != nil {
+		return []error{fmt.Errorf("failed to decode mirror mapping file %s: %w", path, err)}
+	}
+
+	errs := unknownFieldErrors(document, MappingSchema(), MAPPING_ROOT_PATH, path)
+	errs = append(errs, mergeMappingEntries(PROJECT, path, fileMapping.Projects, m.Projects, sources.projects)...)
+	errs = append(errs, mergeMappingEntries(GROUP, path, fileMapping.Groups, m.Groups, sources.groups)...)
+	m.Rewrite = append(m.Rewrite, fileMapping.Rewrite...)
+
+	// Load the included files
+	for index, include := range fileMapping.Include {
+		includedPaths, includeErr := resolveMappingInclude(filepath.Dir(path), include)
+		if includeErr != nil {
+			errs = append(errs, newMappingError(fmt.Sprintf("%s.include[%d]", MAPPING_ROOT_PATH, index), fmt.Errorf("invalid include in mirror mapping file %s: %w", path, includeErr)))
+
+			continue
+		}
+
+		for _, includedPath := range includedPaths {
+			errs = append(errs, m.loadMirrorMappingFile(includedPath, sources)...)
+		}
+	}
+
+	return errs
+}
+
+// mergeMappingEntries merges the entries decoded from a mirror mapping file into the mapping entries.
+// The destination paths are interpolated with the environment variables.
+func mergeMappingEntries(kind, path string, entries, mappingEntries map[string]*MirroringOptions, entrySources map[string]string) []error {
+	var errs []error
+
+	for _, key := range slices.Sorted(maps.Keys(entries)) {
+		if previousPath, ok := entrySources[key]; ok {
+			errs = append(errs, newMappingError(entryPath(kind, key), fmt.Errorf("duplicate %s mapping %s declared in %s and %s", kind, key, previousPath, path)))
+
+			continue
+		}
+
+		options := entries[key]
+		if options != nil {
+			destinationPath, err := helpers.InterpolateEnv(options.DestinationPath)
+			if err != nil {
+				errs = append(errs, newMappingError(entryPath(kind, key, "destination_path"), fmt.Errorf("invalid destination path of %s mapping %s in %s: %w", kind, key, path, err)))
+			}
+
+			options.DestinationPath = destinationPath
+		}
+
+		entrySources[key] = path
+		mappingEntries[key] = options
+	}
+
+	return errs
+}
+
+// resolveMappingInclude resolves an include entry into the mirror mapping files to load, sorted by path.
+// Entries are file paths, directories (every JSON, YAML and TOML file they contain) or glob patterns of those,
+// relative to the directory of the including file. They are interpolated with the environment variables.
+func resolveMappingInclude(baseDirectory, include string) ([]string, error) {
+	include, err := helpers.InterpolateEnv(strings.TrimSpace(include))
+	if err != nil {
+		return nil, err
+	}
+
+	if include == "" {
+		return nil, errors.New("invalid (empty) include")
+	}
+
+	pattern := include
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(baseDirectory, pattern)
+	}
+
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid include pattern %s: %w", include, err)
+	}
+
+	paths := make([]string, 0, len(matches))
+
+	for _, match := range matches {
+		info, statErr := os.Stat(match)
+		if statErr != nil {
+			return nil, fmt.Errorf("failed to read include %s: %w", match, statErr)
+		}
+
+		if !info.IsDir() {
+			paths = append(paths, match)
+
+			continue
+		}
+
+		directoryEntries, readErr := os.ReadDir(match)
+		if readErr != nil {
+			return nil, fmt.Errorf("failed to read include directory %s: %w", match, readErr)
+		}
+
+		for _, entry := range directoryEntries {
+			if !entry.IsDir() && isMappingFileExtension(entry.Name()) {
+				paths = append(paths, filepath.Join(match, entry.Name()))
+			}
+		}
+	}
+
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("include %s does not match any mirror mapping file", include)
+	}
+
+	slices.Sort(paths)
+
+	return slices.Compact(paths), nil
+}
+
+// isMappingFileExtension checks whether a file name has a mirror mapping file extension.
+func isMappingFileExtension(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json", ".yaml", ".yml", ".toml":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeMappingFiles writes the given files (relative path -> content) in a temporary directory, and returns the directory.
+func writeMappingFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	directory := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(directory, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatalf("failed to create directory for %s: %v", name, err)
+		}
+
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to write mapping file %s: %v", name, err)
+		}
+	}
+
+	return directory
+}
+
+func TestOpenMirrorMappingIncludes(t *testing.T) {
+	t.Setenv("GITLAB_SYNC_TEST_DESTINATION_ROOT", "staging")
+
+	directory := writeMappingFiles(t, map[string]string{
+		"mirror.yaml":          "include:\n  - teams/\n  - shared/*.json\ngroups:\n  platform:\n    destination_path: ${GITLAB_SYNC_TEST_DESTINATION_ROOT}/platform\n",
+		"teams/security.toml":  "[groups.\"org/security\"]\ndestination_path = \"${GITLAB_SYNC_TEST_DESTINATION_ROOT}/security\"\n",
+		"teams/apps.yml":       "projects:\n  org/apps/api:\n    destination_path: ${GITLAB_SYNC_TEST_UNSET_ROOT:-production}/apps/api\n",
+		"teams/README.md":      "not a mapping file",
+		"shared/charts.json":   `{"groups": {"re:^charts/(.+)$": {"destination_path": "${GITLAB_SYNC_TEST_DESTINATION_ROOT}/charts/${1}"}}}`,
+		"shared/security.json": `{"include": ["../teams/security.toml"]}`,
+	})
+
+	mapping, errs := OpenMirrorMapping(filepath.Join(directory, "mirror.yaml"))
+	if len(errs) > 0 {
+		t.Fatalf("OpenMirrorMapping() errors = %v", errs)
+	}
+
+	expectedGroups := map[string]string{
+		"platform":     "staging/platform",
+		"org/security": "staging/security",
+	}
+	for group, expectedDestination := range expectedGroups {
+		options, ok := mapping.GetGroup(group)
+		if !ok {
+			t.Fatalf("expected group %s to be in the mapping", group)
+		}
+
+		if options.DestinationPath != expectedDestination {
+			t.Errorf("expected group %s destination path %s, got %s", group, expectedDestination, options.DestinationPath)
+		}
+	}
+
+	if options, ok := mapping.GetProject("org/apps/api"); !ok || options.DestinationPath != "production/apps/api" {
+		t.Errorf("expected project org/apps/api to be mapped to production/apps/api, got %v", options)
+	}
+
+	if patterns := mapping.Patterns(GROUP); len(patterns) != 1 || patterns[0].Options.DestinationPath != "staging/charts/${1}" {
+		t.Errorf("expected the group pattern destination path to keep its capture reference, got %v", patterns)
+	}
+}
+
+func TestOpenMirrorMappingIncludeErrors(t *testing.T) {
+	tests := []struct {
+		name             string
+		files            map[string]string
+		expectedMessages []string
+	}{
+		{
+			name: "Duplicate keys across files",
+			files: map[string]string{
+				"mirror.yaml":     "include: [teams/*.yaml]\ngroups:\n  org:\n    destination_path: mirror/org\n",
+				"teams/team.yaml": "groups:\n  org:\n    destination_path: other/org\n",
+			},
+			expectedMessages: []string{"duplicate group mapping org declared in", "mirror.yaml and", filepath.Join("teams", "team.yaml")},
+		},
+		{
+			name: "Include matching no file",
+			files: map[string]string{
+				"mirror.yaml": "include: [teams/*.yaml]\ngroups:\n  org:\n    destination_path: mirror/org\n",
+			},
+			expectedMessages: []string{"does not match any mirror mapping file"},
+		},
+		{
+			name: "Include cycle",
+			files: map[string]string{
+				"mirror.yaml": "include: [other.yaml]\ngroups:\n  org:\n    destination_path: mirror/org\n",
+				"other.yaml":  "include: [mirror.yaml]\n",
+			},
+			expectedMessages: []string{"include cycle detected"},
+		},
+		{
+			name: "Undefined environment variable",
+			files: map[string]string{
+				"mirror.yaml": "groups:\n  org:\n    destination_path: ${GITLAB_SYNC_TEST_UNSET_ROOT}/org\n",
+			},
+			expectedMessages: []string{"GITLAB_SYNC_TEST_UNSET_ROOT", "group mapping org"},
+		},
+		{
+			name: "Invalid included file",
+			files: map[string]string{
+				"mirror.yaml": "include: [broken.json]\n",
+				"broken.json": `{"groups": `,
+			},
+			expectedMessages: []string{"failed to decode mirror mapping file", "broken.json"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			directory := writeMappingFiles(t, tt.files)
+
+			mapping, errs := OpenMirrorMapping(filepath.Join(directory, "mirror.yaml"))
+			if len(errs) != 1 || mapping != nil {
+				t.Fatalf("expected a single error and no mapping, got %v", errs)
+			}
+
+			for _, message := range tt.expectedMessages {
+				if !strings.Contains(errs[0].Error(), message) {
+					t.Errorf("expected error to contain %q, got %v", message, errs[0])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+
+	"github.com/boxboxjason/gitlab-sync/pkg/helpers"
+)
+
+// IsInherited reports whether the options were derived from a mirrored ancestor group
+// while fetching the source instance, rather than configured in the mapping.
+func (o *MirroringOptions) IsInherited() bool {
+	return o.inherited
+}
+
+// Inherit derives the mirroring options of a descendant (subgroup or project) of the group holding these options.
+// Every option is copied as-is, the destination path keeps the descendant path relative to the group,
+// each of its segments being rewritten by the path rewrite rules of the mapping.
+func (o *MirroringOptions) Inherit(groupPath, descendantPath string) (*MirroringOptions, error) {
+	relativePath, err := filepath.Rel(groupPath, descendantPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate relative path between %s and %s: %w", groupPath, descendantPath, err)
+	}
+
+	relativePath, err = rewriteRelativePath(o.rewrites, groupPath, relativePath)
+	if err != nil {
+		return nil, err
+	}
+
+	inherited := *o
+	inherited.DestinationPath = filepath.Join(o.DestinationPath, relativePath)
+	inherited.inherited = true
+
+	return &inherited, nil
+}
+
+// mergeOver merges the options of a nested entry over the (already merged) options of its closest ancestor group entry.
+// The merge is done field by field, nil pointer fields being unset, and an empty destination path is derived from the ancestor.
+// An exclude list left unset inherits the ancestor compiled exclude list.
+func (o *MirroringOptions) mergeOver(ancestor *MirroringOptions, ancestorPath, path string) error {
+	o.CI_CD_Catalog = inheritValue(o.CI_CD_Catalog, ancestor.CI_CD_Catalog)
+	o.MirrorIssues = inheritValue(o.MirrorIssues, ancestor.MirrorIssues)
+	o.MirrorTriggerBuilds = inheritValue(o.MirrorTriggerBuilds, ancestor.MirrorTriggerBuilds)
+	o.Visibility = inheritValue(o.Visibility, ancestor.Visibility)
+	o.MirrorReleases = inheritValue(o.MirrorReleases, ancestor.MirrorReleases)
+	o.ClaimOwnership = inheritValue(o.ClaimOwnership, ancestor.ClaimOwnership)
+	o.Filters = inheritValue(o.Filters, ancestor.Filters)
+	o.Git = inheritValue(o.Git, ancestor.Git)
+
+	if o.Exclude == nil {
+		o.exclusions = ancestor.exclusions
+	}
+
+	if o.DestinationPath == "" {
+		derived, err := ancestor.Inherit(ancestorPath, path)
+		if err != nil {
+			return err
+		}
+
+		o.DestinationPath = derived.DestinationPath
+	}
+
+	return nil
+}
+
+// inheritValue returns the value when it is set, the inherited value otherwise.
+func inheritValue[T any](value, inheritedValue *T) *T {
+	if value != nil {
+		return value
+	}
+
+	return inheritedValue
+}
+
+// ClosestGroup returns the closest group entry configured in the mapping (not inherited) above the given path.
+// The lookup stops at rootGroupPath (the mirrored group the path was fetched from), which is returned by default.
+func (m *MirrorMapping) ClosestGroup(path, rootGroupPath string) (string, *MirroringOptions, bool) {
+	for parentPath := filepath.Dir(path); parentPath != "." && parentPath != "/" && parentPath != rootGroupPath; parentPath = filepath.Dir(parentPath) {
+		if options, ok := m.GetGroup(parentPath); ok && !options.IsInherited() {
+			return parentPath, options, true
+		}
+	}
+
+	options, ok := m.GetGroup(rootGroupPath)
+
+	return rootGroupPath, options, ok
+}
+
+// resolveNestedEntries compiles the group exclude lists and merges the nested mapping entries
+// (entries listed below another group entry) over their closest ancestor group entry.
+// Parents are resolved before their children, so options cascade down the tree.
+func (m *MirrorMapping) resolveNestedEntries(errChan chan error) {
+	for _, group := range slices.Sorted(maps.Keys(m.Groups)) {
+		options := m.Groups[group]
+		if options == nil {
+			continue
+		}
+
+		// Compile the exclude list (full path entries are only supported for literal groups)
+		exclusionsRoot := group
+		if helpers.IsPathPattern(group) {
+			exclusionsRoot = ""
+		}
+
+		exclusions, err := compileExclusions(exclusionsRoot, options.Exclude)
+		if err != nil {
+			errChan <- newMappingError(entryPath(GROUP, group, "exclude"), fmt.Errorf("invalid exclude list in group mapping %s: %w", group, err))
+		}
+
+		options.exclusions = exclusions
+
+		if helpers.IsPathPattern(group) {
+			continue
+		}
+
+		m.mergeNestedEntry(GROUP, group, options, errChan)
+	}
+
+	for project, options := range m.Projects {
+		if options != nil && !helpers.IsPathPattern(project) {
+			m.mergeNestedEntry(PROJECT, project, options, errChan)
+		}
+	}
+}
+
+// mergeNestedEntry merges a mapping entry over its closest ancestor group entry, if any.
+func (m *MirrorMapping) mergeNestedEntry(kind, path string, options *MirroringOptions, errChan chan error) {
+	for ancestorPath := filepath.Dir(path); ancestorPath != "." && ancestorPath != "/"; ancestorPath = filepath.Dir(ancestorPath) {
+		ancestor := m.Groups[ancestorPath]
+		if ancestor == nil {
+			continue
+		}
+
+		err := options.mergeOver(ancestor, ancestorPath, path)
+		if err != nil {
+			errChan <- newMappingError(entryPath(kind, path), fmt.Errorf("failed to merge %s mapping %s over group mapping %s: %w", kind, path, ancestorPath, err))
+		}
+
+		return
+	}
+}
@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/boxboxjason/gitlab-sync/pkg/helpers"
+)
+
+func TestMirroringOptionsInherit(t *testing.T) {
+	t.Parallel()
+
+	options := &MirroringOptions{
+		DestinationPath: "mirror/org",
+		ClaimOwnership:  new(true),
+		MirrorIssues:    new(false),
+		Visibility:      new("internal"),
+	}
+
+	inherited, err := options.Inherit("org", "org/team/project")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if inherited.DestinationPath != "mirror/org/team/project" {
+		t.Errorf("expected destination path mirror/org/team/project, got %s", inherited.DestinationPath)
+	}
+
+	if !helpers.Deref(inherited.ClaimOwnership, false) {
+		t.Error("expected claim_ownership to be inherited")
+	}
+
+	if helpers.Deref(inherited.MirrorIssues, true) || helpers.Deref(inherited.Visibility, "") != "internal" {
+		t.Error("expected mirror_issues and visibility to be inherited")
+	}
+
+	if !inherited.IsInherited() || options.IsInherited() {
+		t.Error("expected only the derived options to be marked as inherited")
+	}
+}
+
+func TestCheckMergesNestedEntries(t *testing.T) {
+	t.Parallel()
+
+	mapping := &MirrorMapping{
+		Groups: map[string]*MirroringOptions{
+			"org": {
+				DestinationPath: "mirror/org",
+				MirrorIssues:    new(false),
+				MirrorReleases:  new(true),
+				ClaimOwnership:  new(true),
+				Visibility:      new("internal"),
+				Exclude:         []string{"*-playground"},
+			},
+			"org/security": {
+				MirrorIssues: new(true),
+				Visibility:   new("private"),
+			},
+			"org/security/audit": {
+				DestinationPath: "audit/audit",
+				MirrorReleases:  new(false),
+				Exclude:         []string{},
+			},
+		},
+		Projects: map[string]*MirroringOptions{
+			"org/security/scanner": {
+				ClaimOwnership: new(false),
+			},
+		},
+	}
+
+	if errs := mapping.check(); len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	security := mapping.Groups["org/security"]
+	if security.DestinationPath != "mirror/org/security" {
+		t.Errorf("expected derived destination path mirror/org/security, got %s", security.DestinationPath)
+	}
+
+	if !helpers.Deref(security.MirrorIssues, false) || helpers.Deref(security.Visibility, "") != "private" {
+		t.Error("expected org/security overrides to be kept")
+	}
+
+	if !helpers.Deref(security.MirrorReleases, false) || !helpers.Deref(security.ClaimOwnership, false) {
+		t.Error("expected unset org/security options to be inherited from org")
+	}
+
+	if !security.IsExcluded("org/security", "org/security/api-playground") {
+		t.Error("expected the org exclude list to be inherited by org/security")
+	}
+
+	audit := mapping.Groups["org/security/audit"]
+	if audit.DestinationPath != "audit/audit" || helpers.Deref(audit.MirrorReleases, true) {
+		t.Error("expected org/security/audit overrides to be kept")
+	}
+
+	if !helpers.Deref(audit.MirrorIssues, false) || helpers.Deref(audit.Visibility, "") != "private" {
+		t.Error("expected org/security/audit to inherit the merged org/security options")
+	}
+
+	if audit.IsExcluded("org/security/audit", "org/security/audit/api-playground") {
+		t.Error("expected an empty exclude list to override the inherited one")
+	}
+
+	scanner := mapping.Projects["org/security/scanner"]
+	if scanner.DestinationPath != "mirror/org/security/scanner" || helpers.Deref(scanner.ClaimOwnership, true) || !helpers.Deref(scanner.MirrorIssues, false) {
+		t.Errorf("expected nested project entry to merge over org/security, got %+v", scanner)
+	}
+}
+
+func TestMirrorMappingClosestGroup(t *testing.T) {
+	mapping := &MirrorMapping{
+		Groups: map[string]*MirroringOptions{
+			"org":          {DestinationPath: "mirror/org"},
+			"org/security": {DestinationPath: "mirror/security"},
+			"org/security/team": {
+				DestinationPath: "mirror/security/team",
+				inherited:       true,
+			},
+		},
+	}
+
+	tests := []struct {
+		path          string
+		rootGroupPath string
+		expectedPath  string
+		expectedOk    bool
+	}{
+		{"org/security/team/project", "org", "org/security", true},
+		{"org/apps/project", "org", "org", true},
+		{"org/security/project", "org/security", "org/security", true},
+		{"other/project", "other", "other", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			t.Parallel()
+
+			closestPath, _, ok := mapping.ClosestGroup(tt.path, tt.rootGroupPath)
+			if closestPath != tt.expectedPath || ok != tt.expectedOk {
+				t.Errorf("expected (%s, %t), got (%s, %t)", tt.expectedPath, tt.expectedOk, closestPath, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/boxboxjason/gitlab-sync/pkg/helpers"
+)
+
+// MappingPattern is a mirror mapping entry whose key is a glob or regex pattern ("re:" prefixed)
+// instead of a literal path. Its destination path is a template that may reference the pattern
+// capture groups ($1, ${1}) or the {name}, {parent} and {path} placeholders.
+// Patterns are expanded into concrete mapping entries once the source inventory is known.
+type MappingPattern struct {
+	Options *MirroringOptions
+	pattern *helpers.PathPattern
+	Key     string
+	Kind    string
+}
+
+// PatternExpansion records a concrete mapping entry created from a pattern.
+type PatternExpansion struct {
+	Pattern         string
+	Kind            string
+	SourcePath      string
+	DestinationPath string
+}
+
+// Root returns the literal group path that contains every source path matched by the pattern.
+// It is empty when the pattern can match anything on the source instance.
+func (p *MappingPattern) Root() string {
+	return p.pattern.Root()
+}
+
+// Expand matches a source path against the pattern.
+// It returns the expanded destination path and whether the source path matched.
+func (p *MappingPattern) Expand(sourcePath string) (string, bool, error) {
+	captures, ok := p.pattern.Match(sourcePath)
+	if !ok {
+		return "", false, nil
+	}
+
+	destinationPath, err := helpers.ExpandPathTemplate(p.Options.DestinationPath, sourcePath, captures)
+	if err != nil {
+		return "", true, fmt.Errorf("failed to expand %s pattern %s for %s: %w", p.Kind, p.Key, sourcePath, err)
+	}
+
+	return destinationPath, true, nil
+}
+
+// AddPattern compiles a pattern key and registers it in the mapping.
+// The destination path template is validated against the pattern capture groups.
+func (m *MirrorMapping) AddPattern(kind, key string, options *MirroringOptions) error {
+	pattern, err := helpers.CompilePathPattern(key)
+	if err != nil {
+		return fmt.Errorf("invalid %s pattern %s: %w", kind, key, err)
+	}
+
+	if options.DestinationPath == "" {
+		return errors.New("invalid (empty) string in " + kind + " mapping")
+	}
+
+	if strings.HasPrefix(options.DestinationPath, "/") || strings.HasSuffix(options.DestinationPath, "/") {
+		return errors.New("invalid destination path (must not start or end with /): " + options.DestinationPath)
+	}
+
+	err = pattern.CheckTemplate(options.DestinationPath)
+	if err != nil {
+		return fmt.Errorf("invalid %s pattern %s: %w", kind, key, err)
+	}
+
+	m.muPatterns.Lock()
+	defer m.muPatterns.Unlock()
+
+	m.patterns = append(m.patterns, &MappingPattern{
+		Options: options,
+		pattern: pattern,
+		Key:     key,
+		Kind:    kind,
+	})
+
+	slices.SortFunc(m.patterns, func(a, b *MappingPattern) int {
+		return strings.Compare(a.Kind+":"+a.Key, b.Kind+":"+b.Key)
+	})
+
+	return nil
+}
+
+// Patterns returns the patterns of the given kind (PROJECT or GROUP), sorted by key.
+func (m *MirrorMapping) Patterns(kind string) []*MappingPattern {
+	m.muPatterns.RLock()
+	defer m.muPatterns.RUnlock()
+
+	patterns := make([]*MappingPattern, 0, len(m.patterns))
+	for _, pattern := range m.patterns {
+		if pattern.Kind == kind {
+			patterns = append(patterns, pattern)
+		}
+	}
+
+	return patterns
+}
+
+// Expansions returns the concrete mapping entries created from patterns so far.
+func (m *MirrorMapping) Expansions() []*PatternExpansion {
+	m.muPatterns.RLock()
+	defer m.muPatterns.RUnlock()
+
+	return slices.Clone(m.expansions)
+}
+
+// ExpandPatterns expands the patterns of the given kind (PROJECT or GROUP) against the candidate source paths.
+// Every matching candidate becomes a concrete mapping entry, with the pattern options and the expanded destination path.
+// Literal mapping entries always take precedence over patterns. A candidate matching several patterns,
+// or expanding to a destination path already in use, is reported as an error and skipped.
+// It returns the created expansions, sorted by source path.
+func (m *MirrorMapping) ExpandPatterns(kind string, candidates []string) ([]*PatternExpansion, []error) {
+	patterns := m.Patterns(kind)
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+
+	sortedCandidates := slices.Clone(candidates)
+	slices.Sort(sortedCandidates)
+	sortedCandidates = slices.Compact(sortedCandidates)
+
+	errs := make([]error, 0)
+	expansions := make([]*PatternExpansion, 0)
+	usedDestinations := m.destinationPaths(kind)
+
+	for _, sourcePath := range sortedCandidates {
+		if m.hasEntry(kind, sourcePath) {
+			continue
+		}
+
+		expansion, options, err := expandCandidate(patterns, sourcePath)
+		if err != nil {
+			errs = append(errs, err)
+
+			continue
+		}
+
+		if expansion == nil {
+			continue
+		}
+
+		if _, used := usedDestinations[expansion.DestinationPath]; used {
+			errs = append(errs, fmt.Errorf("duplicate destination path found in %s mapping: %s (expanded from %s by pattern %s)", kind, expansion.DestinationPath, sourcePath, expansion.Pattern))
+
+			continue
+		}
+
+		errChan := make(chan error, 4)
+		checkCopyPaths(sourcePath, expansion.DestinationPath, kind, errChan)
+		close(errChan)
+
+		if checkErrs := helpers.MergeErrors(errChan); len(checkErrs) > 0 {
+			errs = append(errs, fmt.Errorf("invalid expansion of %s pattern %s: %w", kind, expansion.Pattern, errors.Join(checkErrs...)))
+
+			continue
+		}
+
+		if kind == PROJECT {
+			m.AddProject(sourcePath, options)
+		} else {
+			m.AddGroup(sourcePath, options)
+		}
+
+		usedDestinations[expansion.DestinationPath] = struct{}{}
+		expansions = append(expansions, expansion)
+	}
+
+	m.muPatterns.Lock()
+	m.expansions = append(m.expansions, expansions...)
+	m.muPatterns.Unlock()
+
+	return expansions, errs
+}
+
+// expandCandidate matches a source path against all patterns.
+// It returns the expansion and the concrete mirroring options, or nil if no pattern matched.
+func expandCandidate(patterns []*MappingPattern, sourcePath string) (*PatternExpansion, *MirroringOptions, error) {
+	var (
+		matched         *MappingPattern
+		destinationPath string
+	)
+
+	for _, pattern := range patterns {
+		expanded, ok, err := pattern.Expand(sourcePath)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if !ok {
+			continue
+		}
+
+		if matched != nil {
+			return nil, nil, fmt.Errorf("%s %s matches several patterns: %s, %s", pattern.Kind, sourcePath, matched.Key, pattern.Key)
+		}
+
+		matched = pattern
+		destinationPath = expanded
+	}
+
+	if matched == nil {
+		return nil, nil, nil
+	}
+
+	options := *matched.Options
+	options.DestinationPath = destinationPath
+
+	return &PatternExpansion{
+		Pattern:         matched.Key,
+		Kind:            matched.Kind,
+		SourcePath:      sourcePath,
+		DestinationPath: destinationPath,
+	}, &options, nil
+}
+
+// hasEntry checks whether the mapping already holds a concrete entry for the source path.
+func (m *MirrorMapping) hasEntry(kind, sourcePath string) bool {
+	var ok bool
+	if kind == PROJECT {
+		_, ok = m.GetProject(sourcePath)
+	} else {
+		_, ok = m.GetGroup(sourcePath)
+	}
+
+	return ok
+}
+
+// destinationPaths returns the destination paths already used by the entries of the given kind.
+func (m *MirrorMapping) destinationPaths(kind string) map[string]struct{} {
+	entries := m.GroupsSnapshot()
+	if kind == PROJECT {
+		entries = m.ProjectsSnapshot()
+	}
+
+	destinations := make(map[string]struct{}, len(entries))
+	for _, options := range entries {
+		destinations[options.DestinationPath] = struct{}{}
+	}
+
+	return destinations
+}
+
+// extractPattern moves a pattern entry out of the literal entries of the mapping.
+func (m *MirrorMapping) extractPattern(kind, key string, options *MirroringOptions, errChan chan error) {
+	if kind == PROJECT {
+		delete(m.Projects, key)
+	} else {
+		delete(m.Groups, key)
+	}
+
+	err := m.AddPattern(kind, key, options)
+	if err != nil {
+		errChan <- newMappingError(entryPath(kind, key), err)
+	}
+}
@@ -0,0 +1,301 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAddPattern(t *testing.T) {
+	tests := []struct {
+		name            string
+		key             string
+		destinationPath string
+		expectedError   bool
+	}{
+		{
+			name:            "Valid glob pattern",
+			key:             "platform/*/charts",
+			destinationPath: "mirror/$1/charts",
+		},
+		{
+			name:            "Valid regex pattern",
+			key:             "re:^infra/(.+)-terraform$",
+			destinationPath: "terraform/{name}",
+		},
+		{
+			name:            "Invalid pattern",
+			key:             "re:infra/(",
+			destinationPath: "mirror/{name}",
+			expectedError:   true,
+		},
+		{
+			name:            "Empty destination path",
+			key:             "platform/*",
+			destinationPath: "",
+			expectedError:   true,
+		},
+		{
+			name:            "Destination path ending with a slash",
+			key:             "platform/*",
+			destinationPath: "mirror/",
+			expectedError:   true,
+		},
+		{
+			name:            "Unknown capture group",
+			key:             "platform/*",
+			destinationPath: "mirror/$2",
+			expectedError:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mapping := &MirrorMapping{}
+			options := testMirroringOptions()
+			options.DestinationPath = tt.destinationPath
+
+			err := mapping.AddPattern(PROJECT, tt.key, options)
+			if (err != nil) != tt.expectedError {
+				t.Fatalf("expected error: %v, got: %v", tt.expectedError, err)
+			}
+
+			expectedPatterns := 1
+			if tt.expectedError {
+				expectedPatterns = 0
+			}
+
+			if got := len(mapping.Patterns(PROJECT)); got != expectedPatterns {
+				t.Errorf("expected %d project patterns, got %d", expectedPatterns, got)
+			}
+
+			if got := len(mapping.Patterns(GROUP)); got != 0 {
+				t.Errorf("expected no group patterns, got %d", got)
+			}
+		})
+	}
+}
+
+func TestCheckExtractsPatterns(t *testing.T) {
+	t.Parallel()
+
+	mapping := &MirrorMapping{
+		Projects: map[string]*MirroringOptions{
+			FAKE_VALID_PROJECT:  {DestinationPath: FAKE_VALID_PROJECT},
+			"platform/*/charts": {DestinationPath: "mirror/$1/charts", Visibility: new(" private ")},
+		},
+		Groups: map[string]*MirroringOptions{
+			FAKE_VALID_GROUP:    {DestinationPath: FAKE_VALID_GROUP},
+			"re:^infra/(.+)$":   {DestinationPath: "mirror/infra/$1"},
+			"re:^broken/(.+)$":  {DestinationPath: "mirror/$3"},
+			"another-group/*/x": {DestinationPath: "mirror/{unknown}"},
+		},
+	}
+
+	errs := mapping.check()
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %v", errs)
+	}
+
+	if _, ok := mapping.Projects["platform/*/charts"]; ok {
+		t.Error("expected project pattern to be removed from the literal projects")
+	}
+
+	if _, ok := mapping.Groups["re:^infra/(.+)$"]; ok {
+		t.Error("expected group pattern to be removed from the literal groups")
+	}
+
+	if len(mapping.Projects) != 1 || len(mapping.Groups) != 1 {
+		t.Errorf("expected 1 literal project and 1 literal group, got %d and %d", len(mapping.Projects), len(mapping.Groups))
+	}
+
+	projectPatterns := mapping.Patterns(PROJECT)
+	if len(projectPatterns) != 1 || projectPatterns[0].Key != "platform/*/charts" {
+		t.Fatalf("expected project pattern platform/*/charts, got %v", projectPatterns)
+	}
+
+	if visibility := *projectPatterns[0].Options.Visibility; visibility != "private" {
+		t.Errorf("expected pattern visibility to be normalized to private, got %q", visibility)
+	}
+
+	if groupPatterns := mapping.Patterns(GROUP); len(groupPatterns) != 1 || groupPatterns[0].Root() != "infra" {
+		t.Errorf("expected group pattern rooted at infra, got %v", groupPatterns)
+	}
+}
+
+func TestExpandPatterns(t *testing.T) {
+	tests := []struct {
+		name               string
+		kind               string
+		literals           map[string]*MirroringOptions
+		patterns           map[string]string
+		candidates         []string
+		expectedExpansions map[string]string
+		expectedErrors     int
+	}{
+		{
+			name: "Glob pattern with capture groups",
+			kind: PROJECT,
+			patterns: map[string]string{
+				"platform/*/charts": "mirror/$1/charts",
+			},
+			candidates: []string{"platform/team-a/charts", "platform/team-b/charts", "platform/team-a/api", "platform/team-a/charts"},
+			expectedExpansions: map[string]string{
+				"platform/team-a/charts": "mirror/team-a/charts",
+				"platform/team-b/charts": "mirror/team-b/charts",
+			},
+		},
+		{
+			name: "Literal entries take precedence",
+			kind: GROUP,
+			literals: map[string]*MirroringOptions{
+				"infra/aws": {DestinationPath: "custom/aws"},
+			},
+			patterns: map[string]string{
+				"re:^infra/([^/]+)$": "mirror/{name}",
+			},
+			candidates: []string{"infra/aws", "infra/gcp"},
+			expectedExpansions: map[string]string{
+				"infra/gcp": "mirror/gcp",
+			},
+		},
+		{
+			name: "Candidate matching several patterns",
+			kind: PROJECT,
+			patterns: map[string]string{
+				"platform/*":      "a/{name}",
+				"re:^platform/.+": "b/{name}",
+			},
+			candidates:         []string{"platform/api"},
+			expectedExpansions: map[string]string{},
+			expectedErrors:     1,
+		},
+		{
+			name: "Duplicate expanded destination paths",
+			kind: PROJECT,
+			literals: map[string]*MirroringOptions{
+				"legacy/api": {DestinationPath: "mirror/api"},
+			},
+			patterns: map[string]string{
+				"*/*": "mirror/{name}",
+			},
+			candidates: []string{"legacy/api", "team-a/api", "team-a/web", "team-b/web"},
+			expectedExpansions: map[string]string{
+				"team-a/web": "mirror/web",
+			},
+			expectedErrors: 2,
+		},
+		{
+			name: "Renamed expansion",
+			kind: GROUP,
+			patterns: map[string]string{
+				"platform/*": "mirror/$1-copy",
+			},
+			candidates: []string{"platform/api"},
+			expectedExpansions: map[string]string{
+				"platform/api": "mirror/api-copy",
+			},
+		},
+		{
+			name: "Invalid expansion",
+			kind: PROJECT,
+			patterns: map[string]string{
+				"platform/*": "$1",
+			},
+			candidates:         []string{"platform/api"},
+			expectedExpansions: map[string]string{},
+			expectedErrors:     1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mapping := &MirrorMapping{
+				Projects: make(map[string]*MirroringOptions),
+				Groups:   make(map[string]*MirroringOptions),
+			}
+
+			for key, options := range tt.literals {
+				if tt.kind == PROJECT {
+					mapping.AddProject(key, options)
+				} else {
+					mapping.AddGroup(key, options)
+				}
+			}
+
+			for key, destinationPath := range tt.patterns {
+				options := testMirroringOptions()
+				options.DestinationPath = destinationPath
+
+				if err := mapping.AddPattern(tt.kind, key, options); err != nil {
+					t.Fatalf("failed to add pattern %s: %v", key, err)
+				}
+			}
+
+			expansions, errs := mapping.ExpandPatterns(tt.kind, tt.candidates)
+			if len(errs) != tt.expectedErrors {
+				t.Errorf("expected %d errors, got %v", tt.expectedErrors, errs)
+			}
+
+			if len(expansions) != len(tt.expectedExpansions) {
+				t.Fatalf("expected %d expansions, got %d", len(tt.expectedExpansions), len(expansions))
+			}
+
+			for _, expansion := range expansions {
+				if expected := tt.expectedExpansions[expansion.SourcePath]; expansion.DestinationPath != expected {
+					t.Errorf("expected %s to expand to %s, got %s", expansion.SourcePath, expected, expansion.DestinationPath)
+				}
+
+				options, ok := mapping.GetProject(expansion.SourcePath)
+				if tt.kind == GROUP {
+					options, ok = mapping.GetGroup(expansion.SourcePath)
+				}
+
+				if !ok {
+					t.Errorf("expected %s to be added to the mapping", expansion.SourcePath)
+				} else if options.DestinationPath != expansion.DestinationPath {
+					t.Errorf("expected mapping destination %s, got %s", expansion.DestinationPath, options.DestinationPath)
+				}
+			}
+
+			if got := len(mapping.Expansions()); got != len(expansions) {
+				t.Errorf("expected %d recorded expansions, got %d", len(expansions), got)
+			}
+		})
+	}
+}
+
+func TestMappingPatternExpandSharesNoOptions(t *testing.T) {
+	t.Parallel()
+
+	mapping := &MirrorMapping{
+		Projects: make(map[string]*MirroringOptions),
+		Groups:   make(map[string]*MirroringOptions),
+	}
+
+	options := testMirroringOptions()
+	options.DestinationPath = "mirror/{name}"
+
+	if err := mapping.AddPattern(PROJECT, "team/*", options); err != nil {
+		t.Fatalf("failed to add pattern: %v", err)
+	}
+
+	_, errs := mapping.ExpandPatterns(PROJECT, []string{"team/a", "team/b"})
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	first, _ := mapping.GetProject("team/a")
+	second, _ := mapping.GetProject("team/b")
+
+	if first == second || !strings.HasSuffix(first.DestinationPath, "/a") || !strings.HasSuffix(second.DestinationPath, "/b") {
+		t.Errorf("expected distinct options per expansion, got %s and %s", first.DestinationPath, second.DestinationPath)
+	}
+
+	if options.DestinationPath != "mirror/{name}" {
+		t.Errorf("expected pattern options to be left untouched, got %s", options.DestinationPath)
+	}
+}
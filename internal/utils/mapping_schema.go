@@ -0,0 +1,175 @@
+package utils
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MAPPING_SCHEMA_DRAFT is the JSON Schema dialect of the mirror mapping schema.
+	MAPPING_SCHEMA_DRAFT = "https://json-schema.org/draft/2020-12/schema"
+	// MAPPING_SCHEMA_ID is the identifier of the mirror mapping schema.
+	MAPPING_SCHEMA_ID = "https://github.com/boxboxjason/gitlab-sync/mirror-mapping.schema.json"
+)
+
+// visibilityValues are the GitLab visibility levels.
+var visibilityValues = []any{"public", "internal", "private"}
+
+// schemaDescriptions documents the mirror mapping fields, by "<Type>.<field>".
+var schemaDescriptions = map[string]string{
+	"MirrorMapping.$schema":                  "The JSON Schema of the file, used by editors for validation and autocompletion.",
+	"MirrorMapping.projects":                 "The projects to mirror, by source path (or glob / regex pattern).",
+	"MirrorMapping.groups":                   "The groups to mirror, by source path (or glob / regex pattern).",
+	"MirrorMapping.rewrite":                  "The rewrite rules applied to the destination path segments and names of the mirrored entities.",
+	"MirrorMapping.include":                  "The mapping files (paths, directories or glob patterns) merged into the mapping.",
+	"MirroringOptions.ci_cd_catalog":         "Whether to add the project to the CI/CD catalog.",
+	"MirroringOptions.mirror_issues":         "Whether to copy the issues of the source project.",
+	"MirroringOptions.mirror_trigger_builds": "Whether to trigger builds on the destination project when the mirror is updated.",
+	"MirroringOptions.visibility":            "The visibility of the project / group on the destination instance.",
+	"MirroringOptions.mirror_releases":       "Whether to copy the releases of the source project.",
+	"MirroringOptions.claim_ownership":       "Whether to claim the ownership of the destination project / group.",
+	"MirroringOptions.destination_path":      "The path of the project / group on the destination instance (may reference ${VAR} environment variables).",
+	"MirroringOptions.exclude":               "(groups only) The descendant subgroups / projects to leave out, as paths or patterns.",
+	"MirroringOptions.filters":               "(groups only) The attribute based filters the descendant projects must match.",
+	"MirroringOptions.git":                   "The refs of the repository to mirror.",
+	"GitOptions.protected_branches_only":     "Whether to only mirror the protected branches of the source project (pull mirrors default to true, the local git mirroring to false).",
+	"GitOptions.branch_regex":                "The regular expression the mirrored branch names must match (cannot be combined with protected_branches_only).",
+	"GitOptions.tag_regex":                   "(local git mirroring only) The regular expression the mirrored tag names must match.",
+	"GitOptions.include_refs":                "(local git mirroring only) The refspec patterns of the refs to push (default: refs/heads/* and refs/tags/*).",
+	"GitOptions.exclude_refs":                "(local git mirroring only) The refspec patterns of the refs to leave out.",
+	"ProjectFilters.fork":                    "true to only keep forks, false to leave forks out.",
+	"ProjectFilters.empty_repository":        "true to only keep empty repositories, false to leave them out.",
+	"ProjectFilters.last_activity_after":     "The projects must have had activity after this date (2006-01-02 or RFC 3339), or within this duration (30d, 720h).",
+	"ProjectFilters.topics":                  "The projects must carry at least one of these topics.",
+	"ProjectFilters.exclude_topics":          "The projects must not carry any of these topics.",
+	"ProjectFilters.visibility":              "The allowed source project visibilities.",
+	"RewriteRule.match":                      "The source paths the rule applies to, as a literal path or a pattern (every path when empty).",
+	"RewriteRule.target":                     "What the rule rewrites: the destination path segment or the display name.",
+	"RewriteRule.regex":                      "A regular expression substituted in the value.",
+	"RewriteRule.replace":                    "The replacement of the regular expression ($1 / ${name} reference the capture groups).",
+	"RewriteRule.case":                       "A case conversion.",
+	"RewriteRule.prefix":                     "A string added before the value.",
+	"RewriteRule.suffix":                     "A string added after the value.",
+}
+
+// schemaEnums lists the allowed values of the mirror mapping fields, by "<Type>.<field>".
+// The values of array fields constrain their items.
+var schemaEnums = map[string][]any{
+	"MirroringOptions.visibility": visibilityValues,
+	"ProjectFilters.visibility":   visibilityValues,
+	"RewriteRule.target":          {REWRITE_TARGET_PATH, REWRITE_TARGET_NAME},
+	"RewriteRule.case":            {REWRITE_CASE_LOWER, REWRITE_CASE_UPPER},
+}
+
+// MappingSchema returns the JSON Schema of the mirror mapping files, generated from the MirrorMapping struct.
+// Every object rejects the fields it does not declare.
+func MappingSchema() map[string]any {
+	schema := typeSchema(reflect.TypeFor[MirrorMapping]())
+	schema["$schema"] = MAPPING_SCHEMA_DRAFT
+	schema["$id"] = MAPPING_SCHEMA_ID
+	schema["title"] = "gitlab-sync mirror mapping"
+
+	return schema
+}
+
+// typeSchema returns the JSON Schema of a Go type, following its json struct tags.
+func typeSchema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		properties := make(map[string]any)
+
+		for field := range t.Fields() {
+			name, ok := schemaFieldName(field)
+			if !ok {
+				continue
+			}
+
+			fieldSchema := typeSchema(field.Type)
+
+			key := t.Name() + "." + name
+			if description, ok := schemaDescriptions[key]; ok {
+				fieldSchema["description"] = description
+			}
+
+			if enum, ok := schemaEnums[key]; ok {
+				if items, isArray := fieldSchema["items"].(map[string]any); isArray {
+					items["enum"] = enum
+				} else {
+					fieldSchema["enum"] = enum
+				}
+			}
+
+			properties[name] = fieldSchema
+		}
+
+		return map[string]any{"type": "object", "properties": properties, "additionalProperties": false}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	default:
+		return map[string]any{"type": "string"}
+	}
+}
+
+// schemaFieldName returns the name of a struct field in the mirror mapping files (its json tag),
+// or false if the field is not part of the files.
+func schemaFieldName(field reflect.StructField) (string, bool) {
+	if !field.IsExported() {
+		return "", false
+	}
+
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+
+	switch name {
+	case "-":
+		return "", false
+	case "":
+		return field.Name, true
+	default:
+		return name, true
+	}
+}
+
+// unknownFieldErrors walks a generically decoded mirror mapping document against the mapping schema,
+// and returns an error for every field the schema does not declare.
+func unknownFieldErrors(document any, schema map[string]any, path, file string) []error {
+	var errs []error
+
+	switch value := document.(type) {
+	case map[string]any:
+		properties, _ := schema["properties"].(map[string]any)
+		additionalProperties, _ := schema["additionalProperties"].(map[string]any)
+
+		for _, key := range slices.Sorted(maps.Keys(value)) {
+			if propertySchema, ok := properties[key].(map[string]any); ok {
+				errs = append(errs, unknownFieldErrors(value[key], propertySchema, path+"."+key, file)...)
+			} else if additionalProperties != nil {
+				errs = append(errs, unknownFieldErrors(value[key], additionalProperties, path+"["+strconv.Quote(key)+"]", file)...)
+			} else if properties != nil {
+				errs = append(errs, newMappingError(path+"."+key, fmt.Errorf("unknown field %s in %s", key, file)))
+			}
+		}
+	case []any:
+		items, _ := schema["items"].(map[string]any)
+		for index, item := range value {
+			errs = append(errs, unknownFieldErrors(item, items, fmt.Sprintf("%s[%d]", path, index), file)...)
+		}
+	}
+
+	return errs
+}
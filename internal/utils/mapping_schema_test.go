@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMappingSchema(t *testing.T) {
+	t.Parallel()
+
+	schema := MappingSchema()
+	if schema["$schema"] != MAPPING_SCHEMA_DRAFT || schema["additionalProperties"] != false {
+		t.Fatalf("expected a closed draft 2020-12 object schema, got %v", schema)
+	}
+
+	groups := schema["properties"].(map[string]any)["groups"].(map[string]any)
+	options := groups["additionalProperties"].(map[string]any)
+	properties := options["properties"].(map[string]any)
+
+	for _, field := range []string{"destination_path", "ci_cd_catalog", "mirror_issues", "visibility", "exclude", "filters"} {
+		if _, ok := properties[field]; !ok {
+			t.Errorf("expected mirroring options property %s", field)
+		}
+	}
+
+	if _, ok := properties["exclusions"]; ok {
+		t.Error("expected unexported fields to be left out of the schema")
+	}
+
+	if enum := properties["visibility"].(map[string]any)["enum"]; !reflect.DeepEqual(enum, visibilityValues) {
+		t.Errorf("expected visibility enum %v, got %v", visibilityValues, enum)
+	}
+
+	filterVisibility := properties["filters"].(map[string]any)["properties"].(map[string]any)["visibility"].(map[string]any)
+	if enum := filterVisibility["items"].(map[string]any)["enum"]; !reflect.DeepEqual(enum, visibilityValues) {
+		t.Errorf("expected filters visibility items enum %v, got %v", visibilityValues, enum)
+	}
+}
+
+func TestUnknownFieldErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		document      any
+		expectedPaths []string
+	}{
+		{
+			name: "Known fields",
+			document: map[string]any{
+				"groups":  map[string]any{"org": map[string]any{"destination_path": "mirror/org", "filters": map[string]any{"topics": []any{"a"}}}},
+				"rewrite": []any{map[string]any{"prefix": "team-"}},
+			},
+		},
+		{
+			name: "Unknown fields at every level",
+			document: map[string]any{
+				"group":    map[string]any{},
+				"projects": map[string]any{"org/api": map[string]any{"mirror_issue": true}},
+				"groups":   map[string]any{"org": map[string]any{"filters": map[string]any{"topic": "a"}}},
+				"rewrite":  []any{map[string]any{"prefix": "team-"}, map[string]any{"sufix": "-x"}},
+			},
+			expectedPaths: []string{
+				"$.group",
+				`$.groups["org"].filters.topic`,
+				`$.projects["org/api"].mirror_issue`,
+				"$.rewrite[1].sufix",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var paths []string
+			for _, err := range unknownFieldErrors(tt.document, MappingSchema(), MAPPING_ROOT_PATH, "mirror.yaml") {
+				paths = append(paths, MappingErrorPath(err))
+			}
+
+			if !reflect.DeepEqual(paths, tt.expectedPaths) {
+				t.Errorf("expected unknown field paths %v, got %v", tt.expectedPaths, paths)
+			}
+		})
+	}
+}
+
+func TestOpenMirrorMappingUnknownFields(t *testing.T) {
+	t.Parallel()
+
+	content := "groups:\n  org:\n    destination_path: mirror/org\n    mirror_issue: true\n"
+
+	mapping, errs := OpenMirrorMapping(createTempMappingFile(t, "mapping.yaml", content))
+	if mapping != nil || len(errs) != 1 {
+		t.Fatalf("expected a single error and no mapping, got %v", errs)
+	}
+
+	if path := MappingErrorPath(errs[0]); path != `$.groups["org"].mirror_issue` {
+		t.Errorf(`expected error path $.groups["org"].mirror_issue, got %s`, path)
+	}
+}
@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+// activityDateLayout is the layout of the last_activity_after filter when written as a date.
+const activityDateLayout = "2006-01-02"
+
+// ProjectFilters defines the attribute based filters applied to the projects of a mirrored group
+// - topics: the project must carry at least one of these topics
+// - exclude_topics: the project must not carry any of these topics
+// - last_activity_after: the project must have had activity after this date (2006-01-02 or RFC 3339),
+// or within this duration (720h, 30d)
+// - visibility: the allowed source project visibilities
+// - fork: true to only keep forks, false to leave forks out
+// - empty_repository: true to only keep empty repositories, false to leave them out.
+type ProjectFilters struct {
+	Fork              *bool    `json:"fork,omitempty"                toml:"fork,omitempty"`
+	EmptyRepository   *bool    `json:"empty_repository,omitempty"    toml:"empty_repository,omitempty"`
+	LastActivityAfter string   `json:"last_activity_after,omitempty" toml:"last_activity_after,omitempty"`
+	Topics            []string `json:"topics,omitempty"              toml:"topics,omitempty"`
+	ExcludeTopics     []string `json:"exclude_topics,omitempty"      toml:"exclude_topics,omitempty"`
+	Visibility        []string `json:"visibility,omitempty"          toml:"visibility,omitempty"`
+}
+
+// check checks if the project filters are valid.
+func (f *ProjectFilters) check() error {
+	var errs []error
+
+	if _, err := f.activityThreshold(time.Now()); err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, visibility := range f.Visibility {
+		if !checkVisibility(visibility) {
+			errs = append(errs, fmt.Errorf("invalid visibility filter: %s", visibility))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Match evaluates the filters against a source project.
+// It returns whether the project must be mirrored and, if not, the reason why it is skipped.
+// A nil filters block matches every project.
+func (f *ProjectFilters) Match(project *gitlab.Project, now time.Time) (bool, string) {
+	if f == nil || project == nil {
+		return true, ""
+	}
+
+	if len(f.Topics) > 0 && !slices.ContainsFunc(f.Topics, func(topic string) bool { return slices.Contains(project.Topics, topic) }) {
+		return false, "missing required topics: " + strings.Join(f.Topics, ", ")
+	}
+
+	for _, topic := range f.ExcludeTopics {
+		if slices.Contains(project.Topics, topic) {
+			return false, "has excluded topic: " + topic
+		}
+	}
+
+	threshold, err := f.activityThreshold(now)
+	if err == nil && !threshold.IsZero() && (project.LastActivityAt == nil || !project.LastActivityAt.After(threshold)) {
+		return false, "no activity since " + threshold.Format(time.RFC3339)
+	}
+
+	if len(f.Visibility) > 0 && !slices.Contains(f.Visibility, string(project.Visibility)) {
+		return false, fmt.Sprintf("visibility %s is not allowed", project.Visibility)
+	}
+
+	if isFork := project.ForkedFromProject != nil; f.Fork != nil && *f.Fork != isFork {
+		if isFork {
+			return false, "is a fork"
+		}
+
+		return false, "is not a fork"
+	}
+
+	if f.EmptyRepository != nil && *f.EmptyRepository != project.EmptyRepo {
+		if project.EmptyRepo {
+			return false, "has an empty repository"
+		}
+
+		return false, "has a non-empty repository"
+	}
+
+	return true, ""
+}
+
+// activityThreshold returns the date after which projects must have had activity.
+// It returns the zero time when the filter is not set.
+func (f *ProjectFilters) activityThreshold(now time.Time) (time.Time, error) {
+	value := strings.TrimSpace(f.LastActivityAfter)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if date, err := time.Parse(activityDateLayout, value); err == nil {
+		return date, nil
+	}
+
+	if date, err := time.Parse(time.RFC3339, value); err == nil {
+		return date, nil
+	}
+
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		count, err := strconv.Atoi(days)
+		if err == nil && count >= 0 {
+			return now.AddDate(0, 0, -count), nil
+		}
+	}
+
+	if duration, err := time.ParseDuration(value); err == nil && duration >= 0 {
+		return now.Add(-duration), nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid last_activity_after filter (expected a date or a duration): %s", value)
+}
+
+// SkipProject records a source project left out by the project filters of its group, along with the reason.
+func (m *MirrorMapping) SkipProject(project, reason string) {
+	m.muSkipped.Lock()
+	defer m.muSkipped.Unlock()
+
+	if m.skippedProjects == nil {
+		m.skippedProjects = make(map[string]string)
+	}
+
+	m.skippedProjects[project] = reason
+}
+
+// SkippedProjects returns a copy of the source projects left out by the project filters, with the reason for each.
+func (m *MirrorMapping) SkippedProjects() map[string]string {
+	m.muSkipped.RLock()
+	defer m.muSkipped.RUnlock()
+
+	return maps.Clone(m.skippedProjects)
+}
@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go/v2"
+)
+
+func TestProjectFiltersMatch(t *testing.T) {
+	now := time.Date(2025, time.June, 15, 12, 0, 0, 0, time.UTC)
+	recentActivity := now.AddDate(0, 0, -3)
+	oldActivity := now.AddDate(-1, 0, 0)
+
+	project := &gitlab.Project{
+		PathWithNamespace: "team/api",
+		Topics:            []string{"go", "backend"},
+		LastActivityAt:    &recentActivity,
+		Visibility:        gitlab.InternalVisibility,
+	}
+	fork := &gitlab.Project{
+		PathWithNamespace: "team/fork",
+		LastActivityAt:    &oldActivity,
+		ForkedFromProject: &gitlab.ForkParent{ID: 1},
+		EmptyRepo:         true,
+		Visibility:        gitlab.PublicVisibility,
+	}
+
+	tests := []struct {
+		name     string
+		filters  *ProjectFilters
+		project  *gitlab.Project
+		expected bool
+	}{
+		{name: "Nil filters", filters: nil, project: project, expected: true},
+		{name: "Required topic present", filters: &ProjectFilters{Topics: []string{"python", "go"}}, project: project, expected: true},
+		{name: "Required topic missing", filters: &ProjectFilters{Topics: []string{"python"}}, project: project, expected: false},
+		{name: "Excluded topic", filters: &ProjectFilters{ExcludeTopics: []string{"backend"}}, project: project, expected: false},
+		{name: "Recent activity (days)", filters: &ProjectFilters{LastActivityAfter: "30d"}, project: project, expected: true},
+		{name: "Old activity (days)", filters: &ProjectFilters{LastActivityAfter: "30d"}, project: fork, expected: false},
+		{name: "Old activity (duration)", filters: &ProjectFilters{LastActivityAfter: "720h"}, project: fork, expected: false},
+		{name: "Activity after date", filters: &ProjectFilters{LastActivityAfter: "2024-01-01"}, project: fork, expected: true},
+		{name: "Allowed visibility", filters: &ProjectFilters{Visibility: []string{"internal"}}, project: project, expected: true},
+		{name: "Disallowed visibility", filters: &ProjectFilters{Visibility: []string{"internal", "private"}}, project: fork, expected: false},
+		{name: "Forks left out", filters: &ProjectFilters{Fork: new(false)}, project: fork, expected: false},
+		{name: "Only forks", filters: &ProjectFilters{Fork: new(true)}, project: project, expected: false},
+		{name: "Empty repositories left out", filters: &ProjectFilters{EmptyRepository: new(false)}, project: fork, expected: false},
+		{name: "Non-empty repository kept", filters: &ProjectFilters{EmptyRepository: new(false)}, project: project, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			matches, reason := tt.filters.Match(tt.project, now)
+			if matches != tt.expected {
+				t.Errorf("expected match %t, got %t (%s)", tt.expected, matches, reason)
+			}
+
+			if !matches && reason == "" {
+				t.Error("expected a reason for the skipped project")
+			}
+		})
+	}
+}
+
+func TestProjectFiltersCheck(t *testing.T) {
+	tests := []struct {
+		name          string
+		filters       *ProjectFilters
+		expectedError bool
+	}{
+		{name: "Empty filters", filters: &ProjectFilters{}},
+		{name: "Valid filters", filters: &ProjectFilters{LastActivityAfter: "2025-01-01T00:00:00Z", Visibility: []string{"public"}}},
+		{name: "Invalid activity", filters: &ProjectFilters{LastActivityAfter: "last week"}, expectedError: true},
+		{name: "Negative duration", filters: &ProjectFilters{LastActivityAfter: "-3d"}, expectedError: true},
+		{name: "Invalid visibility", filters: &ProjectFilters{Visibility: []string{"secret"}}, expectedError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if err := tt.filters.check(); (err != nil) != tt.expectedError {
+				t.Errorf("expected error: %v, got: %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestOpenMirrorMappingProjectFilters(t *testing.T) {
+	t.Parallel()
+
+	content := `[groups.team]
+destination_path = "mirror/team"
+
+[groups.team.filters]
+topics = ["mirror"]
+last_activity_after = "90d"
+fork = false
+
+[projects."team/api"]
+destination_path = "mirror/api"
+
+[projects."team/api".filters]
+empty_repository = false
+`
+
+	_, errs := OpenMirrorMapping(createTempMappingFile(t, "mapping.toml", content))
+	if len(errs) != 1 {
+		t.Fatalf("expected exactly 1 error (filters in a project entry), got %v", errs)
+	}
+
+	if errs[0].Error() != "project filters are only supported in group mapping: team/api" {
+		t.Errorf("unexpected error: %v", errs[0])
+	}
+}
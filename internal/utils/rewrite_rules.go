@@ -0,0 +1,233 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/boxboxjason/gitlab-sync/pkg/helpers"
+)
+
+const (
+	REWRITE_TARGET_PATH = "path"
+	REWRITE_TARGET_NAME = "name"
+	REWRITE_CASE_LOWER  = "lower"
+	REWRITE_CASE_UPPER  = "upper"
+)
+
+// destinationSegmentRegex matches the valid GitLab project / group path segments.
+var destinationSegmentRegex = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]*$`)
+
+// RewriteRule defines a declarative rewrite of the destination path segments or names of the mirrored entities
+// - match: the source paths the rule applies to, as a literal path or a pattern (every path when empty)
+// - target: what the rule rewrites, "path" (the destination path segment, default) or "name" (the display name)
+// - regex / replace: a regular expression substitution ($1 / ${name} reference the capture groups)
+// - case: a case conversion, "lower" or "upper"
+// - prefix / suffix: strings added around the value.
+// The steps are applied in the above order, and the rules are applied in the order of the list.
+type RewriteRule struct {
+	Match   string `json:"match,omitempty"   toml:"match,omitempty"`
+	Target  string `json:"target,omitempty"  toml:"target,omitempty"`
+	Regex   string `json:"regex,omitempty"   toml:"regex,omitempty"`
+	Replace string `json:"replace,omitempty" toml:"replace,omitempty"`
+	Case    string `json:"case,omitempty"    toml:"case,omitempty"`
+	Prefix  string `json:"prefix,omitempty"  toml:"prefix,omitempty"`
+	Suffix  string `json:"suffix,omitempty"  toml:"suffix,omitempty"`
+	match   *helpers.PathPattern
+	regex   *regexp.Regexp
+}
+
+// compile checks the rewrite rule and compiles its match pattern and regular expression.
+func (r *RewriteRule) compile() error {
+	var errs []error
+
+	if r.Target == "" {
+		r.Target = REWRITE_TARGET_PATH
+	}
+
+	if r.Target != REWRITE_TARGET_PATH && r.Target != REWRITE_TARGET_NAME {
+		errs = append(errs, fmt.Errorf("invalid target (expected %s or %s): %s", REWRITE_TARGET_PATH, REWRITE_TARGET_NAME, r.Target))
+	}
+
+	if r.Case != "" && r.Case != REWRITE_CASE_LOWER && r.Case != REWRITE_CASE_UPPER {
+		errs = append(errs, fmt.Errorf("invalid case (expected %s or %s): %s", REWRITE_CASE_LOWER, REWRITE_CASE_UPPER, r.Case))
+	}
+
+	if r.Regex == "" && r.Replace != "" {
+		errs = append(errs, errors.New("replace is set without any regex"))
+	}
+
+	if r.Regex == "" && r.Case == "" && r.Prefix == "" && r.Suffix == "" {
+		errs = append(errs, errors.New("the rule does not rewrite anything (expected a regex, case, prefix or suffix)"))
+	}
+
+	if r.Match != "" {
+		match, err := helpers.CompilePathPattern(r.Match)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid match %s: %w", r.Match, err))
+		}
+
+		r.match = match
+	}
+
+	if r.Regex != "" {
+		expression, err := regexp.Compile(r.Regex)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid regex %s: %w", r.Regex, err))
+		}
+
+		r.regex = expression
+	}
+
+	return errors.Join(errs...)
+}
+
+// matches checks whether the rule applies to the given source path.
+func (r *RewriteRule) matches(sourcePath string) bool {
+	if r.match == nil {
+		return r.Match == "" || r.Match == sourcePath
+	}
+
+	_, ok := r.match.Match(sourcePath)
+
+	return ok
+}
+
+// apply rewrites a value (path segment or name).
+func (r *RewriteRule) apply(value string) string {
+	if r.regex != nil {
+		value = r.regex.ReplaceAllString(value, r.Replace)
+	}
+
+	switch r.Case {
+	case REWRITE_CASE_LOWER:
+		value = strings.ToLower(value)
+	case REWRITE_CASE_UPPER:
+		value = strings.ToUpper(value)
+	}
+
+	return r.Prefix + value + r.Suffix
+}
+
+// rewriteValue applies, in order, the rules of the given target matching the source path.
+func rewriteValue(rules []*RewriteRule, target, sourcePath, value string) string {
+	for _, rule := range rules {
+		if rule.Target == target && rule.matches(sourcePath) {
+			value = rule.apply(value)
+		}
+	}
+
+	return value
+}
+
+// rewriteRelativePath rewrites every segment of a path relative to a group with the path rules.
+// Each segment is matched against the source path of the entity it names.
+func rewriteRelativePath(rules []*RewriteRule, groupPath, relativePath string) (string, error) {
+	if len(rules) == 0 || relativePath == "." {
+		return relativePath, nil
+	}
+
+	segments := strings.Split(relativePath, "/")
+	sourcePath := groupPath
+
+	for index, segment := range segments {
+		sourcePath += "/" + segment
+
+		rewritten := rewriteValue(rules, REWRITE_TARGET_PATH, sourcePath, segment)
+		if !destinationSegmentRegex.MatchString(rewritten) {
+			return "", fmt.Errorf("invalid destination path segment %q rewritten from %s", rewritten, sourcePath)
+		}
+
+		segments[index] = rewritten
+	}
+
+	return strings.Join(segments, "/"), nil
+}
+
+// DestinationName returns the display name of a mirrored entity on the destination instance,
+// its source name rewritten by the name rules of the mapping.
+func (o *MirroringOptions) DestinationName(sourcePath, sourceName string) string {
+	return rewriteValue(o.rewrites, REWRITE_TARGET_NAME, sourcePath, sourceName)
+}
+
+// checkRewriteRules compiles the rewrite rules of the mapping and attaches the valid ones
+// to every mapping entry, so they are carried over to the entries derived from them.
+func (m *MirrorMapping) checkRewriteRules(errChan chan error) {
+	rules := make([]*RewriteRule, 0, len(m.Rewrite))
+
+	for index, rule := range m.Rewrite {
+		if rule == nil {
+			errChan <- newMappingError(rewriteRulePath(index), fmt.Errorf("missing rewrite rule %d", index))
+
+			continue
+		}
+
+		if err := rule.compile(); err != nil {
+			errChan <- newMappingError(rewriteRulePath(index), fmt.Errorf("invalid rewrite rule %d: %w", index, err))
+
+			continue
+		}
+
+		rules = append(rules, rule)
+	}
+
+	if len(rules) == 0 {
+		return
+	}
+
+	for _, options := range m.Projects {
+		if options != nil {
+			options.rewrites = rules
+		}
+	}
+
+	for _, options := range m.Groups {
+		if options != nil {
+			options.rewrites = rules
+		}
+	}
+}
+
+// DestinationConflicts checks that no destination path is shared by several mapping entries,
+// whether they are projects or groups. Rewrite rules may map different source paths to the same destination.
+// It returns an error for every conflicting destination path.
+func (m *MirrorMapping) DestinationConflicts() []error {
+	// Sources of each destination path, as "<kind> <source path>" labels
+	sources := make(map[string][]string)
+
+	for project, options := range m.ProjectsSnapshot() {
+		if options == nil || options.DestinationPath == "" {
+			continue
+		}
+
+		sources[options.DestinationPath] = append(sources[options.DestinationPath], PROJECT+" "+project)
+	}
+
+	for group, options := range m.GroupsSnapshot() {
+		if options == nil || options.DestinationPath == "" {
+			continue
+		}
+
+		sources[options.DestinationPath] = append(sources[options.DestinationPath], GROUP+" "+group)
+	}
+
+	var errs []error
+
+	for _, destinationPath := range slices.Sorted(maps.Keys(sources)) {
+		entries := sources[destinationPath]
+		if len(entries) < 2 {
+			continue
+		}
+
+		slices.Sort(entries)
+
+		// The error points to the last conflicting entry
+		kind, key, _ := strings.Cut(entries[len(entries)-1], " ")
+		errs = append(errs, newMappingError(entryPath(kind, key, "destination_path"), fmt.Errorf("conflicting destination path %s: mapped from %s", destinationPath, strings.Join(entries, ", "))))
+	}
+
+	return errs
+}
@@ -0,0 +1,286 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRewriteRuleCompile(t *testing.T) {
+	tests := []struct {
+		name          string
+		rule          *RewriteRule
+		expectedError bool
+	}{
+		{
+			name: "Prefix rule",
+			rule: &RewriteRule{Match: "old-team/*", Prefix: "team-"},
+		},
+		{
+			name: "Regex name rule",
+			rule: &RewriteRule{Target: REWRITE_TARGET_NAME, Regex: `^(.+) \(legacy\)$`, Replace: "$1"},
+		},
+		{
+			name:          "Unknown target",
+			rule:          &RewriteRule{Target: "description", Prefix: "x-"},
+			expectedError: true,
+		},
+		{
+			name:          "Unknown case",
+			rule:          &RewriteRule{Case: "title"},
+			expectedError: true,
+		},
+		{
+			name:          "Replace without regex",
+			rule:          &RewriteRule{Replace: "x", Suffix: "-x"},
+			expectedError: true,
+		},
+		{
+			name:          "Empty rule",
+			rule:          &RewriteRule{Match: "old-team/*"},
+			expectedError: true,
+		},
+		{
+			name:          "Invalid regex",
+			rule:          &RewriteRule{Regex: "("},
+			expectedError: true,
+		},
+		{
+			name:          "Invalid match",
+			rule:          &RewriteRule{Match: "re:(", Case: REWRITE_CASE_LOWER},
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if err := tt.rule.compile(); (err != nil) != tt.expectedError {
+				t.Errorf("expected error: %v, got: %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestRewriteRelativePath(t *testing.T) {
+	rules := []*RewriteRule{
+		{Match: "old-team/*", Prefix: "team-"},
+		{Regex: `_`, Replace: "-", Case: REWRITE_CASE_LOWER},
+		{Target: REWRITE_TARGET_NAME, Suffix: " (mirror)"},
+	}
+
+	for _, rule := range rules {
+		if err := rule.compile(); err != nil {
+			t.Fatalf("failed to compile rewrite rule: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name          string
+		relativePath  string
+		expected      string
+		expectedError bool
+	}{
+		{
+			name:         "Direct child",
+			relativePath: "api",
+			expected:     "team-api",
+		},
+		{
+			name:         "Every segment is rewritten",
+			relativePath: "Sub_Group/My_Project",
+			expected:     "team-sub-group/my-project",
+		},
+		{
+			name:         "Group itself",
+			relativePath: ".",
+			expected:     ".",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := rewriteRelativePath(rules, "old-team", tt.relativePath)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+
+	t.Run("Invalid rewritten segment", func(t *testing.T) {
+		t.Parallel()
+
+		invalidRule := &RewriteRule{Regex: ".+", Replace: ""}
+		if err := invalidRule.compile(); err != nil {
+			t.Fatalf("failed to compile rewrite rule: %v", err)
+		}
+
+		if _, err := rewriteRelativePath([]*RewriteRule{invalidRule}, "old-team", "api"); err == nil {
+			t.Error("expected an error for an empty rewritten segment")
+		}
+	})
+}
+
+func TestMirroringOptionsDestinationName(t *testing.T) {
+	t.Parallel()
+
+	options := &MirroringOptions{
+		rewrites: []*RewriteRule{
+			{Target: REWRITE_TARGET_NAME, Match: "old-team/**", Prefix: "Team "},
+			{Prefix: "ignored-"},
+		},
+	}
+
+	for _, rule := range options.rewrites {
+		if err := rule.compile(); err != nil {
+			t.Fatalf("failed to compile rewrite rule: %v", err)
+		}
+	}
+
+	if name := options.DestinationName("old-team/api", "API"); name != "Team API" {
+		t.Errorf("expected Team API, got %s", name)
+	}
+
+	if name := options.DestinationName("other/api", "API"); name != "API" {
+		t.Errorf("expected API, got %s", name)
+	}
+}
+
+func TestCheckRewriteRules(t *testing.T) {
+	t.Parallel()
+
+	mapping := &MirrorMapping{
+		Groups: map[string]*MirroringOptions{
+			"old-team":       {DestinationPath: "platform"},
+			"old-team/infra": {Visibility: new("private")},
+		},
+		Projects: map[string]*MirroringOptions{
+			"old-team/api": {DestinationPath: "platform/team-api"},
+		},
+		Rewrite: []*RewriteRule{
+			{Match: "old-team/**", Prefix: "team-"},
+		},
+	}
+
+	if errs := mapping.check(); len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	if destinationPath := mapping.Groups["old-team/infra"].DestinationPath; destinationPath != "platform/team-infra" {
+		t.Errorf("expected derived destination path platform/team-infra, got %s", destinationPath)
+	}
+
+	inherited, err := mapping.Groups["old-team"].Inherit("old-team", "old-team/web/app")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if inherited.DestinationPath != "platform/team-web/team-app" {
+		t.Errorf("expected inherited destination path platform/team-web/team-app, got %s", inherited.DestinationPath)
+	}
+}
+
+func TestCheckDestinationConflicts(t *testing.T) {
+	tests := []struct {
+		name           string
+		mapping        *MirrorMapping
+		expectedErrors int
+	}{
+		{
+			name: "Renamed project conflicting with a project",
+			mapping: &MirrorMapping{
+				Projects: map[string]*MirroringOptions{
+					"old-team/api": {DestinationPath: "platform/team-api"},
+					"team/api":     {DestinationPath: "platform/team-api"},
+				},
+			},
+			expectedErrors: 1,
+		},
+		{
+			name: "Project conflicting with a group",
+			mapping: &MirrorMapping{
+				Projects: map[string]*MirroringOptions{
+					"old-team/api": {DestinationPath: "platform/api"},
+				},
+				Groups: map[string]*MirroringOptions{
+					"team/api": {DestinationPath: "platform/api"},
+				},
+			},
+			expectedErrors: 1,
+		},
+		{
+			name: "Nested entry rewritten to an explicit destination",
+			mapping: &MirrorMapping{
+				Groups: map[string]*MirroringOptions{
+					"old-team":       {DestinationPath: "platform"},
+					"old-team/infra": {},
+					"infra":          {DestinationPath: "platform/team-infra"},
+				},
+				Rewrite: []*RewriteRule{
+					{Match: "old-team/*", Prefix: "team-"},
+				},
+			},
+			expectedErrors: 1,
+		},
+		{
+			name: "Invalid rewrite rule",
+			mapping: &MirrorMapping{
+				Groups: map[string]*MirroringOptions{
+					"old-team": {DestinationPath: "platform"},
+				},
+				Rewrite: []*RewriteRule{
+					{Case: "title"},
+					nil,
+				},
+			},
+			expectedErrors: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			errs := tt.mapping.check()
+			if len(errs) != tt.expectedErrors {
+				t.Fatalf("expected %d errors, got %v", tt.expectedErrors, errs)
+			}
+
+			for _, err := range errs {
+				if strings.Contains(tt.name, "conflict") && !strings.Contains(err.Error(), "conflicting destination path") {
+					t.Errorf("expected a conflicting destination path error, got %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestOpenMirrorMappingRewriteRules(t *testing.T) {
+	t.Parallel()
+
+	content := "[groups.old-team]\ndestination_path = \"platform\"\n\n[[rewrite]]\nmatch = \"old-team/*\"\nprefix = \"team-\"\n\n[[rewrite]]\ntarget = \"name\"\ncase = \"upper\"\n"
+
+	mapping, errs := OpenMirrorMapping(createTempMappingFile(t, "mapping.toml", content))
+	if len(errs) > 0 {
+		t.Fatalf("OpenMirrorMapping() errors = %v", errs)
+	}
+
+	if len(mapping.Rewrite) != 2 || mapping.Rewrite[0].Target != REWRITE_TARGET_PATH {
+		t.Fatalf("expected 2 rewrite rules defaulting to the path target, got %v", mapping.Rewrite)
+	}
+
+	options, ok := mapping.GetGroup("old-team")
+	if !ok {
+		t.Fatal("expected group old-team to be in the mapping")
+	}
+
+	if name := options.DestinationName("old-team/api", "Api"); name != "API" {
+		t.Errorf("expected API, got %s", name)
+	}
+}
@@ -2,14 +2,13 @@
 package utils
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"maps"
-	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/boxboxjason/gitlab-sync/pkg/helpers"
 
@@ -26,19 +25,49 @@ const (
 // - source_gitlab_token: the token for the source GitLab instance
 // - destination_gitlab_url: the URL of the destination GitLab instance
 // - destination_gitlab_token: the token for the destination GitLab instance
-// - mirror_mapping: the path to the JSON file that contains the mapping
+// - source_gitlab_token_file / destination_gitlab_token_file: the files the tokens are read from ("-" for the standard input)
+// - credential_helper: the git style credential helper command the tokens are retrieved from
+// - source_transport / destination_transport: the TLS and proxy settings of the GitLab instances
+// - source_gitlab_size / destination_gitlab_size: the fetch strategy of the GitLab instances (auto, small or big), overridden by the is_big flags
+// - mirror_mapping: the path to the JSON, YAML or TOML file that contains the mapping
 // - verbose: whether to enable verbose logging
 // - no_prompt: whether to disable prompts
 // - dry_run: whether to perform a dry run
 // - version: whether to show the version
-// - retry: the number of retries for the GitLab API requests.
+// - retry: the number of retries for the GitLab API requests
+// - state_dir: the directory the inventory cache is persisted in between runs (caching disabled when empty)
+// - since: the incremental sync mode (last-success skips the projects without source activity since their last successful sync)
+// - full: whether to ignore the state directory content and sync every project
+// - git_cache_dir: the directory the bare mirrors of the source projects are kept in between runs (temporary clones when empty)
+// - git_cache_max_size: the size above which the least recently used bare mirrors are evicted (unbounded when zero)
+// - api_concurrency / git_concurrency / entity_concurrency: the maximum number of concurrent API tasks, local git mirrorings and issue / release creations
+// - timeout / project_timeout: the maximum duration of the whole mirroring and of each project sync (no limit when zero)
+// - source_rate_limit / destination_rate_limit: the maximum number of API requests per second sent to the GitLab instances (no fixed limit when zero).
 type ParserArgs struct {
 	MirrorMapping          *MirrorMapping
+	SourceTransport        helpers.TransportOptions
+	DestinationTransport   helpers.TransportOptions
 	SourceGitlabURL        string
 	SourceGitlabToken      string
 	DestinationGitlabURL   string
 	DestinationGitlabToken string
+	SourceTokenFile        string
+	DestinationTokenFile   string
+	CredentialHelper       string
+	SourceGitlabSize       string
+	DestinationGitlabSize  string
+	StateDir               string
+	Since                  string
+	GitCacheDir            string
 	Retry                  int
+	APIConcurrency         int
+	GitConcurrency         int
+	EntityConcurrency      int
+	Timeout                time.Duration
+	ProjectTimeout         time.Duration
+	SourceRateLimit        float64
+	DestinationRateLimit   float64
+	GitCacheMaxSize        helpers.ByteSize
 	ForcePremium           bool
 	ForceNonPremium        bool
 	DestinationGitlabIsBig bool
@@ -46,6 +75,7 @@ type ParserArgs struct {
 	NoPrompt               bool
 	DryRun                 bool
 	SourceGitlabIsBig      bool
+	Full                   bool
 }
 
 // MirroringOptions defines how a project or group should be mirrored
@@ -53,26 +83,48 @@ type ParserArgs struct {
 // - destination_url: the URL of the destination GitLab instance
 // - ci_cd_catalog: whether to add the project to the CI/CD catalog. Requires GitLab 19.3+ on the destination instance.
 // - issues: whether to mirror the issues.
+// - exclude: (groups only) the descendant subgroups and projects to leave out, as paths or patterns.
+// - filters: (groups only) the attribute based filters the descendant projects must match.
+// - git: the refs of the repository mirrored (see GitOptions).
 type MirroringOptions struct {
-	CI_CD_Catalog       *bool   `json:"ci_cd_catalog"`
-	MirrorIssues        *bool   `json:"mirror_issues"`
-	MirrorTriggerBuilds *bool   `json:"mirror_trigger_builds"`
-	Visibility          *string `json:"visibility"`
-	MirrorReleases      *bool   `json:"mirror_releases"`
-	ClaimOwnership      *bool   `json:"claim_ownership"`
-	DestinationPath     string  `json:"destination_path"`
+	CI_CD_Catalog       *bool           `json:"ci_cd_catalog"         toml:"ci_cd_catalog"`
+	MirrorIssues        *bool           `json:"mirror_issues"         toml:"mirror_issues"`
+	MirrorTriggerBuilds *bool           `json:"mirror_trigger_builds" toml:"mirror_trigger_builds"`
+	Visibility          *string         `json:"visibility"            toml:"visibility"`
+	MirrorReleases      *bool           `json:"mirror_releases"       toml:"mirror_releases"`
+	ClaimOwnership      *bool           `json:"claim_ownership"       toml:"claim_ownership"`
+	DestinationPath     string          `json:"destination_path"      toml:"destination_path"`
+	Exclude             []string        `json:"exclude,omitempty"     toml:"exclude,omitempty"`
+	Filters             *ProjectFilters `json:"filters,omitempty"     toml:"filters,omitempty"`
+	Git                 *GitOptions     `json:"git,omitempty"         toml:"git,omitempty"`
+	exclusions          []*exclusionRule
+	rewrites            []*RewriteRule
+	inherited           bool
 }
 
 // MirrorMapping defines the mapping of projects and groups
 // to the destination GitLab instance
-// It is used to parse the JSON file that contains the mapping
+// It is used to parse the JSON, YAML or TOML file that contains the mapping
 // - projects: a map of project names to their mirroring options
-// - groups: a map of group names to their mirroring options.
+// - groups: a map of group names to their mirroring options
+// - rewrite: the rewrite rules applied to the destination path segments and names of the mirrored entities
+// - include: the mapping files (paths, directories or glob patterns) merged into the mapping when it is opened
+// - $schema: the JSON Schema of the file (see MappingSchema), only used by editors.
+// Keys may be glob or regex ("re:" prefixed) patterns, they are moved out of the maps
+// when the mapping is checked and expanded against the source inventory (see MappingPattern).
 type MirrorMapping struct {
-	Projects   map[string]*MirroringOptions `json:"projects"`
-	Groups     map[string]*MirroringOptions `json:"groups"`
-	muProjects sync.RWMutex
-	muGroups   sync.RWMutex
+	Projects        map[string]*MirroringOptions `json:"projects"          toml:"projects"`
+	Groups          map[string]*MirroringOptions `json:"groups"            toml:"groups"`
+	Rewrite         []*RewriteRule               `json:"rewrite,omitempty" toml:"rewrite,omitempty"`
+	Include         []string                     `json:"include,omitempty" toml:"include,omitempty"`
+	Schema          string                       `json:"$schema,omitempty" toml:"$schema,omitempty"`
+	patterns        []*MappingPattern
+	expansions      []*PatternExpansion
+	skippedProjects map[string]string
+	muProjects      sync.RWMutex
+	muGroups        sync.RWMutex
+	muPatterns      sync.RWMutex
+	muSkipped       sync.RWMutex
 }
 
 // AddProject adds a project to the mapping
@@ -141,8 +193,12 @@ func (m *MirrorMapping) GroupsSnapshot() map[string]*MirroringOptions {
 	return snapshot
 }
 
-// OpenMirrorMapping opens the JSON file that contains the mapping
+// OpenMirrorMapping opens the file that contains the mapping
 // and parses it into a MirrorMapping struct
+// The file format (JSON, YAML or TOML) is detected from its extension, or from its content
+// when the extension is unknown. Decoding errors report the line and column of the faulty token.
+// The files listed in its include directives are merged into the mapping, and the ${VAR}
+// references of the destination paths are replaced with the environment variables values.
 // It returns the mapping and an error if any.
 func OpenMirrorMapping(path string) (*MirrorMapping, []error) {
 	mapping := &MirrorMapping{
@@ -150,22 +206,16 @@ func OpenMirrorMapping(path string) (*MirrorMapping, []error) {
 		Groups:   make(map[string]*MirroringOptions),
 	}
 
-	// Read the file
-	cleanPath := filepath.Clean(path)
-
-	file, err := os.Open(cleanPath)
-	if err != nil {
-		return nil, []error{fmt.Errorf("failed to open mirror mapping file: %w", err)}
+	sources := &mappingSources{
+		projects: make(map[string]string),
+		groups:   make(map[string]string),
+		loading:  make(map[string]struct{}),
+		loaded:   make(map[string]struct{}),
 	}
 
-	defer file.Close() //nolint:errcheck // We do not need to check if file closing returns an error
-
-	// Decode the JSON
-	decoder := json.NewDecoder(file)
-
-	err = decoder.Decode(mapping)
-	if err != nil {
-		return nil, []error{fmt.Errorf("failed to decode mirror mapping file: %w", err)}
+	// Read and decode the file, along with its includes
+	if errs := mapping.loadMirrorMappingFile(filepath.Clean(path), sources); len(errs) > 0 {
+		return nil, errs
 	}
 
 	return mapping, mapping.check()
@@ -175,18 +225,29 @@ func OpenMirrorMapping(path string) (*MirrorMapping, []error) {
 // It checks if the projects and groups are valid
 // It returns an error if any of the projects or groups are invalid.
 func (m *MirrorMapping) check() []error {
-	errChan := make(chan error, 4*(len(m.Projects)+len(m.Groups))+1)
+	errChan := make(chan error, 8*(len(m.Projects)+len(m.Groups))+len(m.Rewrite)+1)
 	// Check if the mapping is valid
 	if len(m.Projects) == 0 && len(m.Groups) == 0 {
-		errChan <- errors.New("no projects or groups defined in the mapping")
+		errChan <- newMappingError(MAPPING_ROOT_PATH, errors.New("no projects or groups defined in the mapping"))
 	}
 
+	// Compile the rewrite rules (before any destination path is derived)
+	m.checkRewriteRules(errChan)
+
+	// Merge the nested entries over their closest ancestor group entry
+	m.resolveNestedEntries(errChan)
+
 	// Check if the projects are valid
 	m.checkProjects(errChan)
 
 	// Check if the groups are valid
 	m.checkGroups(errChan)
 
+	// Check that every destination path is used by a single entry
+	for _, err := range m.DestinationConflicts() {
+		errChan <- err
+	}
+
 	close(errChan)
 
 	return helpers.MergeErrors(errChan)
@@ -196,24 +257,34 @@ func (m *MirrorMapping) check() []error {
 // It checks if the project names and destination paths are valid
 // It returns an error if any of the projects are invalid.
 func (m *MirrorMapping) checkProjects(errChan chan error) {
-	duplicateDestinationFinder := make(map[string]struct{}, len(m.Projects))
 	for project, options := range m.Projects {
-		// Check if the destination path is already used
-		if _, ok := duplicateDestinationFinder[options.DestinationPath]; ok {
-			errChan <- fmt.Errorf("duplicate destination path found in project mapping: %s", options.DestinationPath)
-		} else {
-			duplicateDestinationFinder[options.DestinationPath] = struct{}{}
+		if options == nil {
+			errChan <- newMappingError(entryPath(PROJECT, project), fmt.Errorf("missing mirroring options in project mapping: %s", project))
+
+			continue
 		}
-		// Check the source / destination paths
-		checkCopyPaths(project, options.DestinationPath, PROJECT, errChan)
 
 		// Check the visibility
-		options.Visibility = new(strings.TrimSpace(helpers.Deref(options.Visibility, string(gitlab.PublicVisibility))))
-		if options.Visibility != nil && !checkVisibility(*options.Visibility) {
-			errChan <- fmt.Errorf("invalid project visibility: %s", *options.Visibility)
+		checkOptionsVisibility(options, PROJECT, project, errChan)
 
-			options.Visibility = new(string(gitlab.PublicVisibility))
+		if len(options.Exclude) > 0 {
+			errChan <- newMappingError(entryPath(PROJECT, project, "exclude"), fmt.Errorf("exclude lists are only supported in group mapping: %s", project))
 		}
+
+		if options.Filters != nil {
+			errChan <- newMappingError(entryPath(PROJECT, project, "filters"), fmt.Errorf("project filters are only supported in group mapping: %s", project))
+		}
+
+		checkGitOptions(options, PROJECT, project, errChan)
+
+		if helpers.IsPathPattern(project) {
+			m.extractPattern(PROJECT, project, options, errChan)
+
+			continue
+		}
+
+		// Check the source / destination paths
+		checkCopyPaths(project, options.DestinationPath, PROJECT, errChan)
 	}
 }
 
@@ -223,50 +294,77 @@ func (m *MirrorMapping) checkProjects(errChan chan error) {
 func checkCopyPaths(sourcePath, destinationPath, pathType string, errChan chan error) {
 	// Ensure the source project path and destination path are not empty
 	if sourcePath == "" || destinationPath == "" {
-		errChan <- errors.New("invalid (empty) string in " + pathType + " mapping")
+		errChan <- newMappingError(entryPath(pathType, sourcePath, "destination_path"), errors.New("invalid (empty) string in "+pathType+" mapping"))
 
 		return
 	}
 
 	// Ensure the source project path and destination path do not start or end with a slash
 	if strings.HasPrefix(sourcePath, "/") || strings.HasSuffix(sourcePath, "/") {
-		errChan <- errors.New("invalid " + pathType + " mapping (must not start or end with /): " + sourcePath)
+		errChan <- newMappingError(entryPath(pathType, sourcePath), errors.New("invalid "+pathType+" mapping (must not start or end with /): "+sourcePath))
 	}
 	// Ensure the destination path does not start or end with a slash
 	if strings.HasPrefix(destinationPath, "/") || strings.HasSuffix(destinationPath, "/") {
-		errChan <- errors.New("invalid destination path (must not start or end with /): " + destinationPath)
+		errChan <- newMappingError(entryPath(pathType, sourcePath, "destination_path"), errors.New("invalid destination path (must not start or end with /): "+destinationPath))
 	}
 
 	if pathType == PROJECT && strings.Count(destinationPath, "/") < 1 {
-		errChan <- errors.New("invalid project destination path (must be in a namespace): " + destinationPath)
-	}
-
-	if filepath.Base(sourcePath) != filepath.Base(destinationPath) {
-		errChan <- fmt.Errorf("source and destination paths must have the same base name (ending): %s != %s", sourcePath, destinationPath)
+		errChan <- newMappingError(entryPath(pathType, sourcePath, "destination_path"), errors.New("invalid project destination path (must be in a namespace): "+destinationPath))
 	}
 }
 
 // checkGroups checks if the groups are valid
 // It checks if the group names and destination paths are valid.
 func (m *MirrorMapping) checkGroups(errChan chan error) {
-	duplicateDestinationFinder := make(map[string]struct{}, len(m.Groups))
 	for group, options := range m.Groups {
-		// Check if the destination path is already used
-		if _, ok := duplicateDestinationFinder[options.DestinationPath]; ok {
-			errChan <- fmt.Errorf("duplicate destination path found in group mapping: %s", options.DestinationPath)
-		} else {
-			duplicateDestinationFinder[options.DestinationPath] = struct{}{}
+		if options == nil {
+			errChan <- newMappingError(entryPath(GROUP, group), fmt.Errorf("missing mirroring options in group mapping: %s", group))
+
+			continue
 		}
-		// Check the source / destination paths
-		checkCopyPaths(group, options.DestinationPath, GROUP, errChan)
 
 		// Check the visibility
-		options.Visibility = new(strings.TrimSpace(helpers.Deref(options.Visibility, string(gitlab.PublicVisibility))))
-		if options.Visibility != nil && !checkVisibility(*options.Visibility) {
-			errChan <- fmt.Errorf("invalid group visibility: %s", *options.Visibility)
+		checkOptionsVisibility(options, GROUP, group, errChan)
+
+		// Check the project filters
+		if options.Filters != nil {
+			if err := options.Filters.check(); err != nil {
+				errChan <- newMappingError(entryPath(GROUP, group, "filters"), fmt.Errorf("invalid project filters in group mapping %s: %w", group, err))
+			}
+		}
 
-			options.Visibility = new(string(gitlab.PublicVisibility))
+		checkGitOptions(options, GROUP, group, errChan)
+
+		if helpers.IsPathPattern(group) {
+			m.extractPattern(GROUP, group, options, errChan)
+
+			continue
 		}
+
+		// Check the source / destination paths
+		checkCopyPaths(group, options.DestinationPath, GROUP, errChan)
+	}
+}
+
+// checkGitOptions checks the git options of the mirroring options, if any.
+func checkGitOptions(options *MirroringOptions, pathType, key string, errChan chan error) {
+	if options.Git == nil {
+		return
+	}
+
+	if err := options.Git.check(); err != nil {
+		errChan <- newMappingError(entryPath(pathType, key, "git"), fmt.Errorf("invalid git options in %s mapping %s: %w", pathType, key, err))
+	}
+}
+
+// checkOptionsVisibility trims the visibility of the mirroring options, defaulting to public.
+// Invalid visibilities are reported and replaced by public.
+func checkOptionsVisibility(options *MirroringOptions, pathType, key string, errChan chan error) {
+	options.Visibility = new(strings.TrimSpace(helpers.Deref(options.Visibility, string(gitlab.PublicVisibility))))
+	if !checkVisibility(*options.Visibility) {
+		errChan <- newMappingError(entryPath(pathType, key, "visibility"), fmt.Errorf("invalid %s visibility: %s", pathType, *options.Visibility))
+
+		options.Visibility = new(string(gitlab.PublicVisibility))
 	}
 }
 
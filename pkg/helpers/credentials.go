@@ -0,0 +1,196 @@
+package helpers
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const (
+	// STDIN_PATH is the token file path reading the token from the standard input.
+	STDIN_PATH = "-"
+	// CREDENTIAL_HELPER_PREFIX is the prefix of the git credential helper executables (git-credential-<name>).
+	CREDENTIAL_HELPER_PREFIX = "git-credential-"
+)
+
+// TokenSource provides the token of a GitLab instance.
+// A source that has no token for the instance returns an empty token and no error.
+type TokenSource interface {
+	Token(gitlabURL string) (string, error)
+}
+
+// ResolveToken returns the first token provided by the sources, in order.
+// An empty token is returned when none of the sources provides one.
+func ResolveToken(gitlabURL string, sources ...TokenSource) (string, error) {
+	for _, source := range sources {
+		if source == nil {
+			continue
+		}
+
+		token, err := source.Token(gitlabURL)
+		if err != nil {
+			return "", err
+		}
+
+		if token != "" {
+			return token, nil
+		}
+	}
+
+	return "", nil
+}
+
+// SplitTokens splits a list of tokens holding one token per line, the blank lines being ignored.
+func SplitTokens(tokens string) []string {
+	var split []string
+
+	for line := range strings.Lines(tokens) {
+		if token := strings.TrimSpace(line); token != "" {
+			split = append(split, token)
+		}
+	}
+
+	return split
+}
+
+// StaticToken is a token given as-is (command line argument, environment variable or configuration profile).
+type StaticToken string
+
+// Token implements the TokenSource interface.
+func (t StaticToken) Token(_ string) (string, error) {
+	return strings.TrimSpace(string(t)), nil
+}
+
+// TokenFile reads the token from a file, or from Stdin when the path is "-".
+// The surrounding whitespaces (trailing newline) are trimmed, an empty file is an error.
+// A file may hold several tokens, one per line, which are split by SplitTokens.
+type TokenFile struct {
+	Stdin io.Reader
+	Path  string
+}
+
+// Token implements the TokenSource interface.
+func (f *TokenFile) Token(_ string) (string, error) {
+	path := strings.TrimSpace(f.Path)
+	if path == "" {
+		return "", nil
+	}
+
+	var (
+		content []byte
+		err     error
+	)
+
+	if path == STDIN_PATH {
+		if f.Stdin == nil {
+			return "", errors.New("failed to read token from the standard input: no standard input")
+		}
+
+		content, err = io.ReadAll(f.Stdin)
+		path = "the standard input"
+	} else {
+		content, err = os.ReadFile(path)
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("failed to read token from %s: %w", path, err)
+	}
+
+	token := strings.TrimSpace(string(content))
+	if token == "" {
+		return "", fmt.Errorf("failed to read token from %s: empty token", path)
+	}
+
+	return token, nil
+}
+
+// CredentialHelper retrieves the token from an external command speaking the git credential helper protocol:
+// the command is called with the "get" argument, the protocol, host and path of the instance URL are written
+// to its standard input as key=value lines, and the token is read from the "password" line of its standard output.
+//
+// The command is either a path or executable name followed by its arguments, the name of a git credential helper
+// (store runs git-credential-store), or a shell snippet when prefixed with "!" (like in git configuration).
+type CredentialHelper struct {
+	Stderr  io.Writer
+	Command string
+}
+
+// Token implements the TokenSource interface.
+func (h *CredentialHelper) Token(gitlabURL string) (string, error) {
+	command := strings.TrimSpace(h.Command)
+	if command == "" {
+		return "", nil
+	}
+
+	parsedURL, err := url.Parse(gitlabURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse GitLab URL %s for the credential helper: %w", gitlabURL, err)
+	}
+
+	var request bytes.Buffer
+
+	fmt.Fprintf(&request, "protocol=%s\nhost=%s\n", parsedURL.Scheme, parsedURL.Host)
+
+	if path := strings.Trim(parsedURL.Path, "/"); path != "" {
+		fmt.Fprintf(&request, "path=%s\n", path)
+	}
+
+	request.WriteString("\n")
+
+	var output bytes.Buffer
+
+	cmd := credentialHelperCommand(command)
+	cmd.Stdin = &request
+	cmd.Stdout = &output
+	cmd.Stderr = h.Stderr
+
+	err = cmd.Run()
+	if err != nil {
+		return "", fmt.Errorf("credential helper %s failed: %w", command, err)
+	}
+
+	return parseCredentialHelperOutput(&output), nil
+}
+
+// credentialHelperCommand builds the command running a credential helper "get" action.
+func credentialHelperCommand(command string) *exec.Cmd {
+	if shellCommand, ok := strings.CutPrefix(command, "!"); ok {
+		return exec.Command("sh", "-c", shellCommand+" get") //nolint:gosec // the credential helper is configured by the user
+	}
+
+	fields := strings.Fields(command)
+	name := fields[0]
+
+	// Bare names refer to git credential helpers, when they are installed
+	if !strings.ContainsRune(name, os.PathSeparator) {
+		if helperPath, err := exec.LookPath(CREDENTIAL_HELPER_PREFIX + name); err == nil {
+			name = helperPath
+		}
+	}
+
+	return exec.Command(name, append(fields[1:], "get")...) //nolint:gosec // the credential helper is configured by the user
+}
+
+// parseCredentialHelperOutput returns the password of a credential helper output,
+// made of key=value lines ended by an empty line or the end of the output.
+func parseCredentialHelperOutput(output io.Reader) string {
+	scanner := bufio.NewScanner(output)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			break
+		}
+
+		if key, value, ok := strings.Cut(line, "="); ok && key == "password" {
+			return strings.TrimSpace(value)
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,206 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// writeCredentialHelper writes an executable credential helper script, recording its arguments and input.
+func writeCredentialHelper(t *testing.T, output string, exitCode int) (string, string) {
+	t.Helper()
+
+	directory := t.TempDir()
+	requestPath := filepath.Join(directory, "request")
+	script := "#!/bin/sh\necho \"$@\" > " + requestPath + "\ncat >> " + requestPath + "\nprintf '" + output + "'\nexit " + strconv.Itoa(exitCode) + "\n"
+
+	helperPath := filepath.Join(directory, "helper")
+	if err := os.WriteFile(helperPath, []byte(script), 0o700); err != nil {
+		t.Fatalf("failed to write credential helper: %v", err)
+	}
+
+	return helperPath, requestPath
+}
+
+func TestTokenFile(t *testing.T) {
+	directory := t.TempDir()
+
+	tokenPath := filepath.Join(directory, "token")
+	if err := os.WriteFile(tokenPath, []byte("file-token\n"), 0o600); err != nil {
+		t.Fatalf("failed to write token file: %v", err)
+	}
+
+	emptyPath := filepath.Join(directory, "empty")
+	if err := os.WriteFile(emptyPath, []byte("\n"), 0o600); err != nil {
+		t.Fatalf("failed to write token file: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		file          *TokenFile
+		expected      string
+		expectedError bool
+	}{
+		{
+			name:     "No file",
+			file:     &TokenFile{},
+			expected: "",
+		},
+		{
+			name:     "Token file",
+			file:     &TokenFile{Path: tokenPath},
+			expected: "file-token",
+		},
+		{
+			name:     "Standard input",
+			file:     &TokenFile{Path: STDIN_PATH, Stdin: strings.NewReader("stdin-token\r\n")},
+			expected: "stdin-token",
+		},
+		{
+			name:          "Empty token file",
+			file:          &TokenFile{Path: emptyPath},
+			expectedError: true,
+		},
+		{
+			name:          "Missing token file",
+			file:          &TokenFile{Path: filepath.Join(directory, "missing")},
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			token, err := tt.file.Token("https://gitlab.example.com")
+			if (err != nil) != tt.expectedError {
+				t.Fatalf(EXPECTED_ERROR_MESSAGE, tt.expectedError, err)
+			}
+
+			if token != tt.expected {
+				t.Errorf("expected token %q, got %q", tt.expected, token)
+			}
+		})
+	}
+}
+
+func TestCredentialHelper(t *testing.T) {
+	t.Parallel()
+
+	helperPath, requestPath := writeCredentialHelper(t, `username=git\npassword=helper-token\n\nignored=value\n`, 0)
+
+	token, err := (&CredentialHelper{Command: helperPath + " --store"}).Token("https://gitlab.example.com/gitlab/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if token != "helper-token" {
+		t.Errorf("expected token helper-token, got %q", token)
+	}
+
+	request, err := os.ReadFile(requestPath)
+	if err != nil {
+		t.Fatalf("failed to read the credential helper request: %v", err)
+	}
+
+	expectedRequest := "--store get\nprotocol=https\nhost=gitlab.example.com\npath=gitlab\n\n"
+	if string(request) != expectedRequest {
+		t.Errorf("expected credential helper request %q, got %q", expectedRequest, string(request))
+	}
+}
+
+func TestCredentialHelperErrors(t *testing.T) {
+	t.Parallel()
+
+	failingHelper, _ := writeCredentialHelper(t, "", 1)
+	emptyHelper, _ := writeCredentialHelper(t, `username=git\n`, 0)
+
+	if _, err := (&CredentialHelper{Command: failingHelper}).Token("https://gitlab.example.com"); err == nil {
+		t.Error("expected an error for a failing credential helper")
+	}
+
+	token, err := (&CredentialHelper{Command: "!" + emptyHelper}).Token("https://gitlab.example.com")
+	if err != nil || token != "" {
+		t.Errorf("expected no token and no error for a helper without password, got %q and %v", token, err)
+	}
+}
+
+func TestResolveToken(t *testing.T) {
+	t.Parallel()
+
+	helperPath, _ := writeCredentialHelper(t, `password=helper-token\n`, 0)
+	helper := &CredentialHelper{Command: helperPath}
+
+	tests := []struct {
+		name     string
+		sources  []TokenSource
+		expected string
+	}{
+		{
+			name:     "Static token first",
+			sources:  []TokenSource{StaticToken(" static-token "), &TokenFile{Path: STDIN_PATH, Stdin: strings.NewReader("stdin-token")}, helper},
+			expected: "static-token",
+		},
+		{
+			name:     "Falls back to the next source",
+			sources:  []TokenSource{StaticToken(""), &TokenFile{}, helper},
+			expected: "helper-token",
+		},
+		{
+			name:     "No token",
+			sources:  []TokenSource{StaticToken(""), nil},
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			token, err := ResolveToken("https://gitlab.example.com", tt.sources...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if token != tt.expected {
+				t.Errorf("expected token %q, got %q", tt.expected, token)
+			}
+		})
+	}
+}
+
+func TestSplitTokens(t *testing.T) {
+	tests := []struct {
+		name     string
+		tokens   string
+		expected []string
+	}{
+		{
+			name:     "Single token",
+			tokens:   "token-1",
+			expected: []string{"token-1"},
+		},
+		{
+			name:     "One token per line",
+			tokens:   "token-1\r\n  token-2\n\n\ttoken-3  \n",
+			expected: []string{"token-1", "token-2", "token-3"},
+		},
+		{
+			name:   "No token",
+			tokens: " \n ",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if tokens := SplitTokens(tt.tokens); !slices.Equal(tokens, tt.expected) {
+				t.Errorf("expected tokens %q, got %q", tt.expected, tokens)
+			}
+		})
+	}
+}
@@ -1,9 +1,32 @@
 package helpers
 
 import (
+	"fmt"
+	"strconv"
 	"strings"
+	"unicode"
 )
 
+// byteSizeUnits are the multipliers of the size units.
+var byteSizeUnits = []struct {
+	suffix     string
+	multiplier int64
+}{
+	{"TiB", 1 << 40},
+	{"GiB", 1 << 30},
+	{"MiB", 1 << 20},
+	{"KiB", 1 << 10},
+	{"TB", 1_000_000_000_000},
+	{"GB", 1_000_000_000},
+	{"MB", 1_000_000},
+	{"KB", 1_000},
+	{"B", 1},
+}
+
+// ByteSize is a size in bytes, written as an integer followed by an optional unit (B, KB, MB, GB, TB, KiB, MiB, GiB or TiB).
+// It implements the pflag.Value interface.
+type ByteSize int64
+
 // ToStrings converts a []error into a []string for easy comparison.
 func ToStrings(errs []error) []string {
 	if errs == nil {
@@ -49,3 +72,68 @@ func Deref[T any](ptr *T, defaultValue T) T {
 
 	return *ptr
 }
+
+// ParseByteSize parses a size in bytes such as 512MiB, 20GB or 1048576 (bytes when no unit is given).
+// The units are case insensitive.
+func ParseByteSize(value string) (ByteSize, error) {
+	trimmed := strings.TrimSpace(value)
+	digits := strings.TrimRightFunc(trimmed, unicode.IsLetter)
+	suffix := strings.TrimSpace(trimmed[len(digits):])
+
+	number, err := strconv.ParseInt(strings.TrimSpace(digits), 10, 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid size %q, expected a positive integer followed by an optional unit (e.g. 20GiB)", value)
+	}
+
+	if suffix == "" {
+		return ByteSize(number), nil
+	}
+
+	for _, unit := range byteSizeUnits {
+		if !strings.EqualFold(suffix, unit.suffix) {
+			continue
+		}
+
+		if number > (1<<63-1)/unit.multiplier {
+			return 0, fmt.Errorf("size %q is too large", value)
+		}
+
+		return ByteSize(number * unit.multiplier), nil
+	}
+
+	return 0, fmt.Errorf("invalid size unit %q in %q", suffix, value)
+}
+
+// String formats the size with the unit giving the shortest exact value, e.g. 20GiB or 3KB.
+func (s ByteSize) String() string {
+	formatted := strconv.FormatInt(int64(s), 10)
+
+	for _, unit := range byteSizeUnits {
+		if s == 0 || int64(s)%unit.multiplier != 0 {
+			continue
+		}
+
+		if candidate := strconv.FormatInt(int64(s)/unit.multiplier, 10) + unit.suffix; len(candidate) <= len(formatted) {
+			formatted = candidate
+		}
+	}
+
+	return formatted
+}
+
+// Set parses the size, implementing the pflag.Value interface.
+func (s *ByteSize) Set(value string) error {
+	size, err := ParseByteSize(value)
+	if err != nil {
+		return err
+	}
+
+	*s = size
+
+	return nil
+}
+
+// Type implements the pflag.Value interface.
+func (s *ByteSize) Type() string {
+	return "size"
+}
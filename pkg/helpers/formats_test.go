@@ -31,3 +31,54 @@ func TestMatchPathAgainstFilters(t *testing.T) {
 const (
 	EXPECTED_ERROR_MESSAGE = "expected error: %v, got: %v"
 )
+
+func TestParseByteSize(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value       string
+		expected    ByteSize
+		expectedStr string
+		expectError bool
+	}{
+		{value: "0", expected: 0, expectedStr: "0"},
+		{value: "1536", expected: 1536, expectedStr: "1536"},
+		{value: "2048", expected: 2048, expectedStr: "2KiB"},
+		{value: "20GiB", expected: 20 << 30, expectedStr: "20GiB"},
+		{value: " 512 mib ", expected: 512 << 20, expectedStr: "512MiB"},
+		{value: "1TB", expected: 1_000_000_000_000, expectedStr: "1TB"},
+		{value: "3kb", expected: 3000, expectedStr: "3KB"},
+		{value: "10B", expected: 10, expectedStr: "10"},
+		{value: "", expectError: true},
+		{value: "GiB", expectError: true},
+		{value: "1.5GiB", expectError: true},
+		{value: "-1GiB", expectError: true},
+		{value: "10PB", expectError: true},
+		{value: "9000000TiB", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Parallel()
+
+			var size ByteSize
+
+			err := size.Set(tt.value)
+			if (err != nil) != tt.expectError {
+				t.Fatalf(EXPECTED_ERROR_MESSAGE, tt.expectError, err)
+			}
+
+			if tt.expectError {
+				return
+			}
+
+			if size != tt.expected {
+				t.Errorf("expected %d bytes, got %d", tt.expected, size)
+			}
+
+			if size.String() != tt.expectedStr {
+				t.Errorf("expected %q, got %q", tt.expectedStr, size.String())
+			}
+		})
+	}
+}
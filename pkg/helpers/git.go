@@ -1,13 +1,14 @@
 package helpers
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/url"
 	"os"
 	"strings"
 
 	"github.com/go-git/go-git/v5"
-	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
@@ -25,9 +26,20 @@ func cleanupTempDir(path string) {
 	}
 }
 
+// GitRemote is a git repository URL along with the authentication and transport settings used to reach it.
+// Nil Auth and Transport fall back to anonymous access and the default transport.
+// The Refs filter of a destination selects the refs pushed to it (every ref when nil).
+type GitRemote struct {
+	Auth      transport.AuthMethod
+	Transport *TransportConfig
+	Refs      *RefFilter
+	URL       string
+}
+
 // MirrorRepo clones the source remote as a bare repo and pushes all refs
 // (branches, tags, and then fixes the bare-repo HEAD) to the destination.
-func MirrorRepo(sourceURL, destinationURL string, pullAuth, pushAuth transport.AuthMethod) error {
+// Cancelling the context aborts the clone or the push, the temporary bare repo being removed in any case.
+func MirrorRepo(ctx context.Context, source, destination *GitRemote) error {
 	tmpDir, err := os.MkdirTemp("", "bare-mirror-*")
 	if err != nil {
 		return fmt.Errorf("failed to create temporary directory: %w", err)
@@ -35,50 +47,76 @@ func MirrorRepo(sourceURL, destinationURL string, pullAuth, pushAuth transport.A
 
 	defer cleanupTempDir(tmpDir)
 
+	srcRepo, err := cloneMirror(ctx, tmpDir, source)
+	if err != nil {
+		return err
+	}
+
+	return pushMirror(ctx, srcRepo, destination)
+}
+
+// cloneMirror clones the source remote as a bare mirror repo in the given directory.
+func cloneMirror(ctx context.Context, path string, source *GitRemote) (*git.Repository, error) {
 	pullOpts := &git.CloneOptions{
-		URL:    sourceURL,
+		URL:    source.URL,
 		Mirror: true,
 	}
-	if pullAuth != nil {
-		pullOpts.Auth = pullAuth
+	if source.Auth != nil {
+		pullOpts.Auth = source.Auth
 	}
 
-	zap.L().Debug("Cloning source repository", zap.String("sourceURL", sourceURL), zap.String("destinationURL", destinationURL))
+	source.Transport.applyToClone(pullOpts)
+
+	zap.L().Debug("Cloning source repository", zap.String("sourceURL", source.URL), zap.String("path", path))
 
-	srcRepo, err := git.PlainClone(tmpDir, true, pullOpts)
+	srcRepo, err := git.PlainCloneContext(ctx, path, true, pullOpts)
 	if err != nil {
-		return fmt.Errorf("failed to clone source repository locally: %w", err)
+		return nil, fmt.Errorf("failed to clone source repository locally: %w", err)
 	}
 
-	zap.L().Debug("Adding destination remote", zap.String("destinationURL", destinationURL))
+	return srcRepo, nil
+}
+
+// pushMirror force pushes the refs of the bare mirror repo matched by the destination filter, and then fixes its HEAD.
+// The refs already up to date on the destination are not sent again.
+func pushMirror(ctx context.Context, srcRepo *git.Repository, destination *GitRemote) error {
+	destinationURL := destination.URL
 
-	_, err = srcRepo.CreateRemote(&config.RemoteConfig{
-		Name: "destination",
-		URLs: []string{destinationURL},
-	})
+	refSpecs, err := destination.Refs.refSpecs(srcRepo)
 	if err != nil {
-		return fmt.Errorf("failed to create remote for destination: %w", err)
+		return err
 	}
 
-	zap.L().Debug("Pushing to destination repository", zap.String("destinationURL", destinationURL))
+	if len(refSpecs) == 0 {
+		zap.L().Debug("No ref left to push by the ref filter, skipping", zap.String("destinationURL", destinationURL))
+
+		return nil
+	}
+
+	zap.L().Debug("Pushing to destination repository", zap.String("destinationURL", destinationURL), zap.Int("refspecs", len(refSpecs)))
 
 	pushOpts := &git.PushOptions{
-		RemoteName: "destination",
-		Force:      true,
-		RefSpecs: []config.RefSpec{
-			// force-update everything (branches, tags, etc)
-			config.RefSpec("+refs/*:refs/*"),
-		},
+		RemoteURL: destinationURL,
+		Force:     true,
+		RefSpecs:  refSpecs,
 	}
-	if pushAuth != nil {
-		pushOpts.Auth = pushAuth
+	if destination.Auth != nil {
+		pushOpts.Auth = destination.Auth
 	}
 
-	err = srcRepo.Push(pushOpts)
-	if err != nil {
+	destination.Transport.applyToPush(pushOpts)
+
+	err = srcRepo.PushContext(ctx, pushOpts)
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
 		return fmt.Errorf("failed to push to destination repository: %w", err)
 	}
 
+	// The HEAD would be left dangling if its branch was not pushed
+	head, err := srcRepo.Reference(plumbing.HEAD, false)
+	if err == nil && !destination.Refs.Match(head.Target()) {
+		return nil
+	}
+
 	err = fixBareRepoHEAD(destinationURL, srcRepo)
 	if err != nil {
 		return fmt.Errorf("failed to set destination HEAD: %w", err)
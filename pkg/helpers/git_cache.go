@@ -0,0 +1,511 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/idxfile"
+	"github.com/go-git/go-git/v5/plumbing/format/objfile"
+	"github.com/go-git/go-git/v5/plumbing/format/packfile"
+	"go.uber.org/zap"
+)
+
+const (
+	// gitCacheLockSuffix is appended to the path of a cache entry to build the path of its lock file.
+	gitCacheLockSuffix = ".lock"
+	// gitCacheTakeoverSuffix is appended to the path of a lock file to build the path of its takeover marker.
+	gitCacheTakeoverSuffix = ".takeover"
+	// gitCacheLockPoll is the interval at which a locked cache entry is checked again.
+	gitCacheLockPoll = time.Second
+	// gitCacheLockRefresh is the interval at which the holder of a lock refreshes its modification time.
+	gitCacheLockRefresh = 30 * time.Second
+	// gitCacheLockStale is the age after which a lock not refreshed is considered left by a crashed run, and taken over.
+	gitCacheLockStale = 2 * time.Minute
+	// gitCacheLocalHost is the directory of the entries of the sources without host (local paths).
+	gitCacheLocalHost = "local"
+)
+
+// GitCache keeps the bare mirrors of the source projects between runs, so that each run only fetches
+// the updates of the source and pushes the refs which changed.
+// The entries are stored per source host and project ID, and protected by lock files so that
+// concurrent runs sharing the directory do not corrupt them.
+// - dir: the directory the bare mirrors are kept in
+// - max_size: the size above which the least recently used entries are evicted (unbounded when zero).
+type GitCache struct {
+	Dir     string
+	MaxSize ByteSize
+}
+
+// gitCacheEntry is a bare mirror of the cache, along with its size and last use time.
+type gitCacheEntry struct {
+	usedAt time.Time
+	path   string
+	size   int64
+}
+
+// NewGitCache returns the git cache of the given directory, creating it when missing.
+func NewGitCache(dir string, maxSize ByteSize) (*GitCache, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create git cache directory %s: %w", dir, err)
+	}
+
+	return &GitCache{Dir: dir, MaxSize: maxSize}, nil
+}
+
+// MirrorRepo mirrors the source remote to the destination through the cache entry of the source project.
+// The entry is fetched from the source (cloned when missing or unusable), and its refs are pushed to the destination,
+// the refs already up to date being skipped. A nil cache falls back to a temporary bare clone (see MirrorRepo).
+// The entry is locked during the whole mirroring, waiting for the other runs holding it until the context is done.
+func (c *GitCache) MirrorRepo(ctx context.Context, projectID int64, source, destination *GitRemote) error {
+	if c == nil {
+		return MirrorRepo(ctx, source, destination)
+	}
+
+	entry := c.entryPath(projectID, source.URL)
+	if err := os.MkdirAll(filepath.Dir(entry), 0o750); err != nil {
+		return fmt.Errorf("failed to create git cache directory: %w", err)
+	}
+
+	unlock, err := lockEntry(ctx, entry)
+	if err != nil {
+		return fmt.Errorf("failed to lock git cache entry %s: %w", entry, err)
+	}
+	defer unlock()
+
+	srcRepo, err := updateEntry(ctx, entry, source)
+	if err != nil {
+		return err
+	}
+
+	// The modification time of the entry directory records its last use, for the eviction
+	now := time.Now()
+	if err := os.Chtimes(entry, now, now); err != nil {
+		zap.L().Warn("Failed to record the use of the git cache entry", zap.String("path", entry), zap.Error(err))
+	}
+
+	return pushMirror(ctx, srcRepo, destination)
+}
+
+// entryPath returns the path of the cache entry of a source project.
+func (c *GitCache) entryPath(projectID int64, sourceURL string) string {
+	host := gitCacheLocalHost
+	if parsed, err := url.Parse(sourceURL); err == nil && parsed.Host != "" {
+		// Ports are separated with an underscore, colons not being allowed in Windows paths
+		host = strings.ReplaceAll(parsed.Host, ":", "_")
+	}
+
+	return filepath.Join(c.Dir, host, strconv.FormatInt(projectID, 10))
+}
+
+// gitCacheCorruptionErrors are the errors reporting damaged objects or refs in a cache entry.
+var gitCacheCorruptionErrors = []error{
+	plumbing.ErrObjectNotFound,
+	plumbing.ErrInvalidType,
+	plumbing.ErrReferenceNotFound,
+	objfile.ErrHeader,
+	idxfile.ErrMalformedIdxFile,
+	packfile.ErrInvalidObject,
+	packfile.ErrZLib,
+}
+
+// isEntryCorrupted returns whether the error reports a damaged cache entry, rather than a failure to reach the source.
+func isEntryCorrupted(err error) bool {
+	return slices.ContainsFunc(gitCacheCorruptionErrors, func(target error) bool {
+		return errors.Is(err, target)
+	})
+}
+
+// updateEntry fetches the updates of the source into the cache entry, or clones it when missing or unusable.
+// An entry failing to be fetched is kept for the next run, unless its objects or refs are damaged: it is then cloned again.
+func updateEntry(ctx context.Context, entry string, source *GitRemote) (*git.Repository, error) {
+	srcRepo, err := git.PlainOpen(entry)
+	if err == nil {
+		err = fetchMirror(ctx, srcRepo, source)
+		if err == nil {
+			return srcRepo, nil
+		}
+
+		if !isEntryCorrupted(err) {
+			return nil, err
+		}
+	}
+
+	if !errors.Is(err, git.ErrRepositoryNotExists) {
+		zap.L().Warn("Unusable git cache entry, cloning it again", zap.String("path", entry), zap.Error(err))
+	}
+
+	if err := os.RemoveAll(entry); err != nil {
+		return nil, fmt.Errorf("failed to remove git cache entry %s: %w", entry, err)
+	}
+
+	srcRepo, err = cloneMirror(ctx, entry, source)
+	if err != nil {
+		cleanupTempDir(entry)
+
+		return nil, err
+	}
+
+	return srcRepo, nil
+}
+
+// fetchMirror fetches all the refs of the source into the bare mirror repo, pruning the refs deleted from the source.
+func fetchMirror(ctx context.Context, srcRepo *git.Repository, source *GitRemote) error {
+	fetchOpts := &git.FetchOptions{
+		RemoteURL: source.URL,
+		Force:     true,
+		Prune:     true,
+		RefSpecs:  []config.RefSpec{mirrorAllRefSpec},
+	}
+	if source.Auth != nil {
+		fetchOpts.Auth = source.Auth
+	}
+
+	source.Transport.applyToFetch(fetchOpts)
+
+	zap.L().Debug("Fetching source repository updates", zap.String("sourceURL", source.URL))
+
+	err := srcRepo.FetchContext(ctx, fetchOpts)
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("failed to fetch source repository updates: %w", err)
+	}
+
+	// The default branch of the source may have changed since the clone
+	if err := refreshMirrorHead(ctx, srcRepo, source); err != nil {
+		return err
+	}
+
+	if _, err := srcRepo.Head(); err != nil {
+		return fmt.Errorf("failed to resolve the HEAD of the git cache entry: %w", err)
+	}
+
+	return nil
+}
+
+// refreshMirrorHead points the HEAD of the bare mirror repo to the branch the HEAD of the source points to.
+// A source without HEAD (an empty repository) leaves it untouched.
+func refreshMirrorHead(ctx context.Context, srcRepo *git.Repository, source *GitRemote) error {
+	remote := git.NewRemote(srcRepo.Storer, &config.RemoteConfig{Name: git.DefaultRemoteName, URLs: []string{source.URL}})
+
+	listOpts := &git.ListOptions{}
+	if source.Auth != nil {
+		listOpts.Auth = source.Auth
+	}
+
+	source.Transport.applyToList(listOpts)
+
+	refs, err := remote.ListContext(ctx, listOpts)
+	if err != nil {
+		return fmt.Errorf("failed to list source repository refs: %w", err)
+	}
+
+	for _, ref := range refs {
+		if ref.Name() != plumbing.HEAD || ref.Type() != plumbing.SymbolicReference {
+			continue
+		}
+
+		if err := srcRepo.Storer.SetReference(ref); err != nil {
+			return fmt.Errorf("failed to set the HEAD of the git cache entry: %w", err)
+		}
+
+		return nil
+	}
+
+	return nil
+}
+
+// lockEntry takes the lock file of a cache entry, waiting for the run holding it until the context is done.
+// It returns the function releasing the lock.
+func lockEntry(ctx context.Context, entry string) (func(), error) {
+	lockPath := entry + gitCacheLockSuffix
+
+	ticker := time.NewTicker(gitCacheLockPoll)
+	defer ticker.Stop()
+
+	for {
+		locked, err := tryLock(lockPath, time.Now())
+		if err != nil {
+			return nil, err
+		}
+
+		if locked {
+			return holdLock(lockPath), nil
+		}
+
+		zap.L().Debug("Git cache entry locked by another run, waiting", zap.String("path", entry))
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// tryLock creates the lock file if it does not exist yet, taking over the stale locks left by crashed runs.
+func tryLock(lockPath string, now time.Time) (bool, error) {
+	locked, err := createLock(lockPath)
+	if locked || err != nil {
+		return locked, err
+	}
+
+	info, err := os.Stat(lockPath)
+	if err != nil || now.Sub(info.ModTime()) <= gitCacheLockStale {
+		// A lock released in the meantime is taken on the next attempt
+		return false, nil
+	}
+
+	return takeOverLock(lockPath, now)
+}
+
+// createLock creates the lock file exclusively, returning false when it already exists.
+func createLock(lockPath string) (bool, error) {
+	lockFile, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if errors.Is(err, fs.ErrExist) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to create lock file: %w", err)
+	}
+
+	_, _ = fmt.Fprintf(lockFile, "%d\n", os.Getpid())
+
+	if err := lockFile.Close(); err != nil {
+		_ = os.Remove(lockPath)
+
+		return false, fmt.Errorf("failed to write lock file: %w", err)
+	}
+
+	return true, nil
+}
+
+// takeOverLock replaces a stale lock by a new one. Only the run creating the takeover marker of the lock (exclusively)
+// replaces it, so that a lock taken again in the meantime by another run is kept, and the lock file is never missing
+// while another run may take it over. A marker left by a run crashed during a takeover is removed once stale.
+func takeOverLock(lockPath string, now time.Time) (bool, error) {
+	markerPath := lockPath + gitCacheTakeoverSuffix
+
+	marker, err := os.OpenFile(markerPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if errors.Is(err, fs.ErrExist) {
+		markerInfo, err := os.Stat(markerPath)
+		if err == nil && now.Sub(markerInfo.ModTime()) > gitCacheLockStale && os.Remove(markerPath) == nil {
+			return takeOverLock(lockPath, now)
+		}
+
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to create lock takeover marker: %w", err)
+	}
+
+	_ = marker.Close()
+
+	defer func() {
+		_ = os.Remove(markerPath)
+	}()
+
+	// The lock may have been released and taken again, or refreshed, since it was found stale
+	info, err := os.Stat(lockPath)
+	if err == nil && now.Sub(info.ModTime()) <= gitCacheLockStale {
+		return false, nil
+	}
+
+	if err == nil {
+		zap.L().Warn("Taking over a stale git cache lock", zap.String("path", lockPath), zap.Time("refreshed_at", info.ModTime()))
+
+		if err := os.Remove(lockPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return false, fmt.Errorf("failed to remove stale lock file: %w", err)
+		}
+	}
+
+	return createLock(lockPath)
+}
+
+// holdLock refreshes the modification time of the lock file until the returned function releases it.
+func holdLock(lockPath string) func() {
+	done := make(chan struct{})
+
+	var waitGroup sync.WaitGroup
+
+	waitGroup.Go(func() {
+		ticker := time.NewTicker(gitCacheLockRefresh)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				_ = os.Chtimes(lockPath, now, now)
+			}
+		}
+	})
+
+	return func() {
+		close(done)
+		waitGroup.Wait()
+
+		if err := os.Remove(lockPath); err != nil {
+			zap.L().Warn("Failed to release git cache lock", zap.String("path", lockPath), zap.Error(err))
+		}
+	}
+}
+
+// Evict removes the least recently used entries of the cache until its size fits the maximum size.
+// The entries locked by a run are skipped. A nil or unbounded cache evicts nothing.
+func (c *GitCache) Evict() error {
+	if c == nil || c.MaxSize <= 0 {
+		return nil
+	}
+
+	entries, err := c.entries()
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for _, entry := range entries {
+		total += entry.size
+	}
+
+	slices.SortFunc(entries, func(a, b *gitCacheEntry) int {
+		return a.usedAt.Compare(b.usedAt)
+	})
+
+	var (
+		errs    []error
+		evicted int
+	)
+
+	for _, entry := range entries {
+		if total <= int64(c.MaxSize) {
+			break
+		}
+
+		lockPath := entry.path + gitCacheLockSuffix
+
+		locked, err := tryLock(lockPath, time.Now())
+		if err != nil {
+			errs = append(errs, err)
+
+			continue
+		}
+
+		if !locked {
+			continue
+		}
+
+		err = os.RemoveAll(entry.path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to evict git cache entry %s: %w", entry.path, err))
+		} else {
+			total -= entry.size
+			evicted++
+		}
+
+		_ = os.Remove(lockPath)
+	}
+
+	zap.L().Info("Git cache eviction done", zap.Int("evicted", evicted), zap.Stringer("size", ByteSize(total)), zap.Stringer("max_size", c.MaxSize))
+
+	return errors.Join(errs...)
+}
+
+// entries lists the entries of the cache, with their size and last use time.
+func (c *GitCache) entries() ([]*gitCacheEntry, error) {
+	hosts, err := os.ReadDir(c.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read git cache directory: %w", err)
+	}
+
+	var entries []*gitCacheEntry
+
+	for _, host := range hosts {
+		if !host.IsDir() {
+			continue
+		}
+
+		projects, err := os.ReadDir(filepath.Join(c.Dir, host.Name()))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to read git cache directory: %w", err)
+		}
+
+		for _, project := range projects {
+			if !project.IsDir() {
+				continue
+			}
+
+			// The entries evicted in the meantime by a concurrent run are skipped
+			entry, err := readEntry(filepath.Join(c.Dir, host.Name(), project.Name()))
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+
+			if err != nil {
+				return nil, err
+			}
+
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, nil
+}
+
+// readEntry returns the size and last use time of a cache entry.
+func readEntry(path string) (*gitCacheEntry, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read git cache entry %s: %w", path, err)
+	}
+
+	entry := &gitCacheEntry{path: path, usedAt: info.ModTime()}
+
+	// The files removed in the meantime by a run updating the entry are skipped
+	err = filepath.WalkDir(path, func(_ string, file fs.DirEntry, err error) error {
+		if err != nil {
+			return ignoreNotExist(err)
+		}
+
+		if !file.Type().IsRegular() {
+			return nil
+		}
+
+		fileInfo, err := file.Info()
+		if err != nil {
+			return ignoreNotExist(err)
+		}
+
+		entry.size += fileInfo.Size()
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute the size of git cache entry %s: %w", path, err)
+	}
+
+	return entry, nil
+}
+
+// ignoreNotExist returns nil for the errors of missing files.
+func ignoreNotExist(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
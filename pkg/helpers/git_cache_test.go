@@ -0,0 +1,457 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+const (
+	TEST_CACHE_PROJECT_ID = 42
+	TEST_CACHE_BRANCH     = "refs/heads/feature"
+)
+
+// commitFile writes a file in the worktree of the repository and commits it, returning the commit hash.
+func commitFile(t *testing.T, repo *git.Repository, dir, content string) plumbing.Hash {
+	t.Helper()
+
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := worktree.Add("README.md"); err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := worktree.Commit(content, &git.CommitOptions{Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return hash
+}
+
+// newCacheTestRemotes initializes a source repository with a commit and an empty bare destination repository.
+func newCacheTestRemotes(t *testing.T) (*git.Repository, string, *GitRemote, *GitRemote) {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+	sourceDir := filepath.Join(tmpDir, "source")
+
+	sourceRepo, err := git.PlainInit(sourceDir, false)
+	if err != nil {
+		t.Fatalf("failed to initialize source repository: %v", err)
+	}
+
+	commitFile(t, sourceRepo, sourceDir, "initial commit")
+
+	destDir := filepath.Join(tmpDir, "destination.git")
+	if _, err := git.PlainInit(destDir, true); err != nil {
+		t.Fatalf("failed to initialize bare repository at destination: %v", err)
+	}
+
+	return sourceRepo, sourceDir, &GitRemote{URL: FILE_SCHEME + sourceDir}, &GitRemote{URL: FILE_SCHEME + destDir}
+}
+
+// resolveRef returns the hash of a reference of the repository at the given path, zero when missing.
+func resolveRef(t *testing.T, path string, name plumbing.ReferenceName) plumbing.Hash {
+	t.Helper()
+
+	repo, err := git.PlainOpen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ref, err := repo.Reference(name, true)
+	if err != nil {
+		return plumbing.ZeroHash
+	}
+
+	return ref.Hash()
+}
+
+func TestGitCacheMirrorRepo(t *testing.T) {
+	t.Parallel()
+
+	sourceRepo, sourceDir, source, destination := newCacheTestRemotes(t)
+	destDir := destination.URL[len(FILE_SCHEME):]
+
+	cache, err := NewGitCache(filepath.Join(t.TempDir(), "cache"), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	head, err := sourceRepo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sourceRepo.Storer.SetReference(plumbing.NewHashReference(TEST_CACHE_BRANCH, head.Hash())); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first run clones the cache entry
+	if err := cache.MirrorRepo(t.Context(), TEST_CACHE_PROJECT_ID, source, destination); err != nil {
+		t.Fatalf("first MirrorRepo failed: %v", err)
+	}
+
+	entry := cache.entryPath(TEST_CACHE_PROJECT_ID, source.URL)
+	if got := resolveRef(t, entry, TEST_CACHE_BRANCH); got != head.Hash() {
+		t.Errorf("expected the cache entry to hold the source branch, got %s", got)
+	}
+
+	if _, err := os.Stat(entry + gitCacheLockSuffix); !os.IsNotExist(err) {
+		t.Errorf("expected the lock file to be released, got %v", err)
+	}
+
+	// The second run fetches the new commit and prunes the deleted branch
+	newHead := commitFile(t, sourceRepo, sourceDir, "second commit")
+	if err := sourceRepo.Storer.RemoveReference(TEST_CACHE_BRANCH); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cache.MirrorRepo(t.Context(), TEST_CACHE_PROJECT_ID, source, destination); err != nil {
+		t.Fatalf("second MirrorRepo failed: %v", err)
+	}
+
+	if got := resolveRef(t, entry, head.Name()); got != newHead {
+		t.Errorf("expected the cache entry to be updated to %s, got %s", newHead, got)
+	}
+
+	if got := resolveRef(t, entry, TEST_CACHE_BRANCH); !got.IsZero() {
+		t.Errorf("expected the deleted branch to be pruned from the cache entry, got %s", got)
+	}
+
+	if got := resolveRef(t, destDir, head.Name()); got != newHead {
+		t.Errorf("expected the destination to be updated to %s, got %s", newHead, got)
+	}
+
+	// A run without any update pushes nothing
+	if err := cache.MirrorRepo(t.Context(), TEST_CACHE_PROJECT_ID, source, destination); err != nil {
+		t.Fatalf("up to date MirrorRepo failed: %v", err)
+	}
+}
+
+func TestGitCacheMirrorRepoDefaultBranchChange(t *testing.T) {
+	t.Parallel()
+
+	sourceRepo, _, source, destination := newCacheTestRemotes(t)
+	destDir := destination.URL[len(FILE_SCHEME):]
+
+	cache, err := NewGitCache(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := cache.MirrorRepo(t.Context(), TEST_CACHE_PROJECT_ID, source, destination); err != nil {
+		t.Fatalf("first MirrorRepo failed: %v", err)
+	}
+
+	// The source default branch is changed after the cache entry was cloned
+	head, err := sourceRepo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sourceRepo.Storer.SetReference(plumbing.NewHashReference(TEST_CACHE_BRANCH, head.Hash())); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sourceRepo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, TEST_CACHE_BRANCH)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cache.MirrorRepo(t.Context(), TEST_CACHE_PROJECT_ID, source, destination); err != nil {
+		t.Fatalf("second MirrorRepo failed: %v", err)
+	}
+
+	for _, path := range []string{cache.entryPath(TEST_CACHE_PROJECT_ID, source.URL), destDir} {
+		repo, err := git.PlainOpen(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ref, err := repo.Reference(plumbing.HEAD, false)
+		if err != nil || ref.Target() != TEST_CACHE_BRANCH {
+			t.Errorf("expected the HEAD of %s to point to %s, got %v (%v)", path, TEST_CACHE_BRANCH, ref, err)
+		}
+	}
+}
+
+func TestGitCacheMirrorRepoNilCache(t *testing.T) {
+	t.Parallel()
+
+	sourceRepo, _, source, destination := newCacheTestRemotes(t)
+
+	head, err := sourceRepo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cache *GitCache
+	if err := cache.MirrorRepo(t.Context(), TEST_CACHE_PROJECT_ID, source, destination); err != nil {
+		t.Fatalf("MirrorRepo without cache failed: %v", err)
+	}
+
+	if got := resolveRef(t, destination.URL[len(FILE_SCHEME):], head.Name()); got != head.Hash() {
+		t.Errorf("expected the destination to be mirrored, got %s", got)
+	}
+}
+
+func TestGitCacheMirrorRepoUnusableEntry(t *testing.T) {
+	t.Parallel()
+
+	_, _, source, destination := newCacheTestRemotes(t)
+
+	cache, err := NewGitCache(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A leftover of an interrupted clone, without any git metadata
+	entry := cache.entryPath(TEST_CACHE_PROJECT_ID, source.URL)
+	if err := os.MkdirAll(entry, 0o750); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cache.MirrorRepo(t.Context(), TEST_CACHE_PROJECT_ID, source, destination); err != nil {
+		t.Fatalf("MirrorRepo failed: %v", err)
+	}
+
+	if _, err := git.PlainOpen(entry); err != nil {
+		t.Errorf("expected the cache entry to be cloned again, got %v", err)
+	}
+}
+
+func TestGitCacheMirrorRepoUnreachableSource(t *testing.T) {
+	t.Parallel()
+
+	sourceRepo, sourceDir, source, destination := newCacheTestRemotes(t)
+
+	cache, err := NewGitCache(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := cache.MirrorRepo(t.Context(), TEST_CACHE_PROJECT_ID, source, destination); err != nil {
+		t.Fatalf("first MirrorRepo failed: %v", err)
+	}
+
+	head, err := sourceRepo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A source failing to be fetched keeps the cache entry for the next run
+	if err := os.Rename(sourceDir, sourceDir+".moved"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cache.MirrorRepo(t.Context(), TEST_CACHE_PROJECT_ID, source, destination); err == nil {
+		t.Fatal("expected MirrorRepo to fail for an unreachable source")
+	}
+
+	entry := cache.entryPath(TEST_CACHE_PROJECT_ID, source.URL)
+	if got := resolveRef(t, entry, head.Name()); got != head.Hash() {
+		t.Errorf("expected the cache entry to be kept, got %s", got)
+	}
+}
+
+func TestGitCacheLock(t *testing.T) {
+	tests := []struct {
+		name        string
+		lockAge     time.Duration
+		markerAge   time.Duration
+		marker      bool
+		expectError bool
+	}{
+		{
+			name:        "Entry locked by another run",
+			lockAge:     0,
+			expectError: true,
+		},
+		{
+			name:    "Stale lock taken over",
+			lockAge: 2 * gitCacheLockStale,
+		},
+		{
+			name:        "Stale lock taken over by another run",
+			lockAge:     2 * gitCacheLockStale,
+			marker:      true,
+			expectError: true,
+		},
+		{
+			name:      "Takeover marker left by a crashed run",
+			lockAge:   2 * gitCacheLockStale,
+			marker:    true,
+			markerAge: 2 * gitCacheLockStale,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, _, source, destination := newCacheTestRemotes(t)
+
+			cache, err := NewGitCache(t.TempDir(), 0)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			lockPath := cache.entryPath(TEST_CACHE_PROJECT_ID, source.URL) + gitCacheLockSuffix
+			if err := os.MkdirAll(filepath.Dir(lockPath), 0o750); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := os.WriteFile(lockPath, []byte("1\n"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			lockedAt := time.Now().Add(-tt.lockAge)
+			if err := os.Chtimes(lockPath, lockedAt, lockedAt); err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.marker {
+				if err := os.WriteFile(lockPath+gitCacheTakeoverSuffix, nil, 0o600); err != nil {
+					t.Fatal(err)
+				}
+
+				markedAt := time.Now().Add(-tt.markerAge)
+				if err := os.Chtimes(lockPath+gitCacheTakeoverSuffix, markedAt, markedAt); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+			defer cancel()
+
+			err = cache.MirrorRepo(ctx, TEST_CACHE_PROJECT_ID, source, destination)
+			if (err != nil) != tt.expectError {
+				t.Fatalf(EXPECTED_ERROR_MESSAGE, tt.expectError, err)
+			}
+
+			if tt.expectError && !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("expected the wait for the lock to time out, got %v", err)
+			}
+
+			if _, err := os.Stat(lockPath + gitCacheTakeoverSuffix); !tt.expectError && !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("expected the takeover marker to be removed, got %v", err)
+			}
+		})
+	}
+}
+
+func TestGitCacheEvict(t *testing.T) {
+	t.Parallel()
+
+	cache, err := NewGitCache(t.TempDir(), 250)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	now := time.Now()
+	entries := []struct {
+		host     string
+		id       string
+		age      time.Duration
+		locked   bool
+		expected bool
+	}{
+		// The oldest entry is locked by a running mirroring, it is kept
+		{host: "gitlab.example.com", id: "1", age: 4 * time.Hour, locked: true, expected: true},
+		{host: "gitlab.example.com", id: "2", age: 3 * time.Hour, expected: false},
+		{host: "gitlab.example.com_8443", id: "3", age: 2 * time.Hour, expected: false},
+		{host: "gitlab.example.com", id: "4", age: time.Hour, expected: false},
+		{host: "gitlab.example.com_8443", id: "5", age: 0, expected: true},
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(cache.Dir, entry.host, entry.id)
+		if err := os.MkdirAll(filepath.Join(path, "objects"), 0o750); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(filepath.Join(path, "objects", "pack"), make([]byte, 100), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		if entry.locked {
+			if err := os.WriteFile(path+gitCacheLockSuffix, nil, 0o600); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		usedAt := now.Add(-entry.age)
+		if err := os.Chtimes(path, usedAt, usedAt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := cache.Evict(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(cache.Dir, entry.host, entry.id)
+		if _, err := os.Stat(path); (err == nil) != entry.expected {
+			t.Errorf("entry %s/%s: expected kept %t, got %v", entry.host, entry.id, entry.expected, err)
+		}
+
+		if !entry.locked {
+			if _, err := os.Stat(path + gitCacheLockSuffix); !os.IsNotExist(err) {
+				t.Errorf("entry %s/%s: expected no lock file left, got %v", entry.host, entry.id, err)
+			}
+		}
+	}
+
+	// An unbounded cache evicts nothing
+	unbounded := &GitCache{Dir: cache.Dir}
+	if err := unbounded.Evict(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if remaining, _ := filepath.Glob(filepath.Join(cache.Dir, "*", "[0-9]")); len(remaining) != 2 {
+		t.Errorf("expected the unbounded cache to keep the 2 remaining entries, got %v", remaining)
+	}
+
+	// An entry evicted by a concurrent run while listing is reported as missing, so that the listing skips it
+	if _, err := readEntry(filepath.Join(cache.Dir, "gitlab.example.com", "2")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected a missing entry error, got %v", err)
+	}
+}
+
+func TestGitCacheEntryPath(t *testing.T) {
+	t.Parallel()
+
+	cache := &GitCache{Dir: "cache"}
+
+	tests := []struct {
+		sourceURL string
+		expected  string
+	}{
+		{"https://gitlab.example.com/group/project.git", filepath.Join("cache", "gitlab.example.com", "42")},
+		{"https://gitlab.example.com:8443/group/project.git", filepath.Join("cache", "gitlab.example.com_8443", "42")},
+		{"file:///srv/git/project", filepath.Join("cache", gitCacheLocalHost, "42")},
+	}
+
+	for _, tt := range tests {
+		if got := cache.entryPath(TEST_CACHE_PROJECT_ID, tt.sourceURL); got != tt.expected {
+			t.Errorf("entryPath(%s) = %s; want %s", tt.sourceURL, got, tt.expected)
+		}
+	}
+}
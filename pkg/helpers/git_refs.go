@@ -0,0 +1,112 @@
+package helpers
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// mirrorAllRefSpec force-updates every ref (branches, tags, etc) of the destination.
+const mirrorAllRefSpec = config.RefSpec("+refs/*:refs/*")
+
+// RefFilter selects the refs of a bare mirror pushed to the destination.
+// - include / exclude: the refspec patterns of the refs pushed / left out (refs/heads/*, refs/tags/v*), every ref being included when empty
+// - branch_regex / tag_regex: the regular expressions the short branch / tag names must match (every branch / tag when nil)
+// - branches: the only short branch names pushed (every branch when nil)
+// A nil filter pushes every ref.
+type RefFilter struct {
+	BranchRegex *regexp.Regexp
+	TagRegex    *regexp.Regexp
+	Branches    map[string]struct{}
+	Include     []string
+	Exclude     []string
+}
+
+// CheckRefPattern checks that a refspec pattern is a full ref name (refs/...) with at most one * wildcard.
+func CheckRefPattern(pattern string) error {
+	if !strings.HasPrefix(pattern, "refs/") {
+		return fmt.Errorf("invalid ref pattern %q (must start with refs/)", pattern)
+	}
+
+	if err := refPatternSpec(pattern).Validate(); err != nil {
+		return fmt.Errorf("invalid ref pattern %q: %w", pattern, err)
+	}
+
+	return nil
+}
+
+// refPatternSpec returns the refspec mapping the refs matched by a pattern onto themselves.
+func refPatternSpec(pattern string) config.RefSpec {
+	return config.RefSpec("+" + pattern + ":" + pattern)
+}
+
+// matchRefPatterns reports whether the ref name matches one of the refspec patterns.
+func matchRefPatterns(patterns []string, name plumbing.ReferenceName) bool {
+	for _, pattern := range patterns {
+		if refPatternSpec(pattern).Match(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Match reports whether the ref is pushed to the destination.
+func (f *RefFilter) Match(name plumbing.ReferenceName) bool {
+	if f == nil {
+		return true
+	}
+
+	if len(f.Include) > 0 && !matchRefPatterns(f.Include, name) || matchRefPatterns(f.Exclude, name) {
+		return false
+	}
+
+	switch {
+	case name.IsBranch():
+		if f.BranchRegex != nil && !f.BranchRegex.MatchString(name.Short()) {
+			return false
+		}
+
+		if f.Branches != nil {
+			_, ok := f.Branches[name.Short()]
+
+			return ok
+		}
+	case name.IsTag():
+		return f.TagRegex == nil || f.TagRegex.MatchString(name.Short())
+	}
+
+	return true
+}
+
+// refSpecs returns the refspecs pushing the refs of the bare mirror matched by the filter.
+// A nil filter pushes every ref with a single refspec.
+func (f *RefFilter) refSpecs(repo *git.Repository) ([]config.RefSpec, error) {
+	if f == nil {
+		return []config.RefSpec{mirrorAllRefSpec}, nil
+	}
+
+	refs, err := repo.References()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the refs of the repository: %w", err)
+	}
+
+	var refSpecs []config.RefSpec
+
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() == plumbing.HashReference && f.Match(ref.Name()) {
+			refSpecs = append(refSpecs, refPatternSpec(ref.Name().String()))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the refs of the repository: %w", err)
+	}
+
+	return refSpecs, nil
+}
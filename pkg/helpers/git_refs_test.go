@@ -0,0 +1,141 @@
+package helpers
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+func TestCheckRefPattern(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pattern     string
+		expectError bool
+	}{
+		{pattern: "refs/heads/*"},
+		{pattern: "refs/tags/v*"},
+		{pattern: "refs/heads/main"},
+		{pattern: "heads/*", expectError: true},
+		{pattern: "refs/*/foo/*", expectError: true},
+		{pattern: "refs/heads/a:refs/heads/b", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			t.Parallel()
+
+			if err := CheckRefPattern(tt.pattern); (err != nil) != tt.expectError {
+				t.Errorf(EXPECTED_ERROR_MESSAGE, tt.expectError, err)
+			}
+		})
+	}
+}
+
+func TestRefFilterMatch(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		filter   *RefFilter
+		expected map[plumbing.ReferenceName]bool
+	}{
+		{
+			name:   "Nil filter",
+			filter: nil,
+			expected: map[plumbing.ReferenceName]bool{
+				"refs/heads/main":            true,
+				"refs/merge-requests/1/head": true,
+			},
+		},
+		{
+			name:   "Include and exclude patterns",
+			filter: &RefFilter{Include: []string{"refs/heads/*", "refs/tags/*"}, Exclude: []string{"refs/heads/tmp/*"}},
+			expected: map[plumbing.ReferenceName]bool{
+				"refs/heads/main":            true,
+				"refs/heads/tmp/wip":         false,
+				"refs/tags/v1.0.0":           true,
+				"refs/merge-requests/1/head": false,
+				"refs/keep-around/abc":       false,
+			},
+		},
+		{
+			name:   "Branch and tag regexes",
+			filter: &RefFilter{BranchRegex: regexp.MustCompile(`^(main|release/.*)$`), TagRegex: regexp.MustCompile(`^v\d+`)},
+			expected: map[plumbing.ReferenceName]bool{
+				"refs/heads/main":        true,
+				"refs/heads/release/1.x": true,
+				"refs/heads/feature":     false,
+				"refs/tags/v2.1":         true,
+				"refs/tags/nightly":      false,
+				"refs/pipelines/42":      true,
+			},
+		},
+		{
+			name:   "Protected branches",
+			filter: &RefFilter{Branches: map[string]struct{}{"main": {}}},
+			expected: map[plumbing.ReferenceName]bool{
+				"refs/heads/main":    true,
+				"refs/heads/feature": false,
+				"refs/tags/v1.0.0":   true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			for name, expected := range tt.expected {
+				if got := tt.filter.Match(name); got != expected {
+					t.Errorf("Match(%s) = %t; want %t", name, got, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestMirrorRepoRefFilter(t *testing.T) {
+	t.Parallel()
+
+	sourceRepo, _, source, destination := newCacheTestRemotes(t)
+	destDir := destination.URL[len(FILE_SCHEME):]
+
+	head, err := sourceRepo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	internalRef := plumbing.ReferenceName("refs/merge-requests/1/head")
+	for _, name := range []plumbing.ReferenceName{TEST_CACHE_BRANCH, "refs/tags/v1.0.0", internalRef} {
+		if err := sourceRepo.Storer.SetReference(plumbing.NewHashReference(name, head.Hash())); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	destination.Refs = &RefFilter{Include: []string{"refs/heads/*", "refs/tags/*"}, Branches: map[string]struct{}{head.Name().Short(): {}}}
+
+	if err := MirrorRepo(t.Context(), source, destination); err != nil {
+		t.Fatalf("MirrorRepo failed: %v", err)
+	}
+
+	expected := map[plumbing.ReferenceName]bool{
+		head.Name():        true,
+		"refs/tags/v1.0.0": true,
+		TEST_CACHE_BRANCH:  false,
+		internalRef:        false,
+	}
+
+	for name, pushed := range expected {
+		if got := !resolveRef(t, destDir, name).IsZero(); got != pushed {
+			t.Errorf("ref %s: expected pushed %t, got %t", name, pushed, got)
+		}
+	}
+
+	// No ref left by the filter skips the push
+	destination.Refs = &RefFilter{Include: []string{"refs/notes/*"}}
+	if err := MirrorRepo(t.Context(), source, destination); err != nil {
+		t.Fatalf("MirrorRepo without any ref to push failed: %v", err)
+	}
+}
@@ -1,11 +1,15 @@
 package helpers
 
 import (
+	"context"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-git/go-git/v5/plumbing/storer"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
 )
@@ -43,6 +47,50 @@ func TestBuildHTTPAuth(t *testing.T) {
 }
 
 func TestMirrorRepo(t *testing.T) {
+	t.Run("cancelled context removes the bare clone", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		t.Setenv("TMPDIR", tmpDir)
+
+		sourceDir := filepath.Join(tmpDir, "source")
+		sourceRepo, err := git.PlainInit(sourceDir, false)
+		if err != nil {
+			t.Fatalf("failed to initialize source repository: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(sourceDir, "README.md"), []byte("mirror"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		worktree, err := sourceRepo.Worktree()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := worktree.Add("README.md"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := worktree.Commit("initial commit", &git.CommitOptions{Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}}); err != nil {
+			t.Fatal(err)
+		}
+
+		destDir := filepath.Join(tmpDir, "destination.git")
+		if _, err := git.PlainInit(destDir, true); err != nil {
+			t.Fatalf("failed to initialize bare repository at destination: %v", err)
+		}
+
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+
+		if err := MirrorRepo(ctx, &GitRemote{URL: FILE_SCHEME + sourceDir}, &GitRemote{URL: FILE_SCHEME + destDir}); err == nil {
+			t.Error("expected error for cancelled context, got nil")
+		}
+
+		leftovers, err := filepath.Glob(filepath.Join(tmpDir, "bare-mirror-*"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(leftovers) > 0 {
+			t.Errorf("expected the temporary bare clone to be removed, found %v", leftovers)
+		}
+	})
+
 	t.Run("mirror via HTTPS public repo", func(t *testing.T) {
 		t.Parallel()
 		destDir, err := os.MkdirTemp("/tmp", "destrepo-*.git")
@@ -57,7 +105,7 @@ func TestMirrorRepo(t *testing.T) {
 			t.Fatalf("failed to initialize bare repository at destination: %v", err)
 		}
 
-		if err := MirrorRepo(githubHTTPURL, FILE_SCHEME+destDir, nil, nil); err != nil {
+		if err := MirrorRepo(t.Context(), &GitRemote{URL: githubHTTPURL}, &GitRemote{URL: FILE_SCHEME + destDir}); err != nil {
 			t.Fatalf("MirrorRepo(HTTPS) failed: %v", err)
 		}
 
@@ -97,7 +145,7 @@ func TestMirrorRepo(t *testing.T) {
 			t.Fatalf("failed to initialize bare repository at destination: %v", err)
 		}
 
-		err = MirrorRepo("file:///no/such/path", FILE_SCHEME+destDir, nil, nil)
+		err = MirrorRepo(t.Context(), &GitRemote{URL: "file:///no/such/path"}, &GitRemote{URL: FILE_SCHEME + destDir})
 		if err == nil {
 			t.Error("expected error for invalid source URL, got nil")
 		}
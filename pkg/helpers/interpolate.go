@@ -0,0 +1,46 @@
+package helpers
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// envReferenceRegex matches the ${VAR} and ${VAR:-default} environment variable references.
+// Names must not start with a digit, so the ${1} capture group references of the path templates are left untouched.
+var envReferenceRegex = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// InterpolateEnv replaces the ${VAR} references of a value with the values of the environment variables.
+// ${VAR:-default} falls back to the default value when VAR is unset or empty.
+// It returns an error listing the referenced variables that are unset (and have no default value).
+func InterpolateEnv(value string) (string, error) {
+	var missing []string
+
+	interpolated := envReferenceRegex.ReplaceAllStringFunc(value, func(reference string) string {
+		submatches := envReferenceRegex.FindStringSubmatch(reference)
+		name, hasDefault, defaultValue := submatches[1], submatches[2] != "", submatches[3]
+
+		envValue, ok := os.LookupEnv(name)
+
+		switch {
+		case ok && (envValue != "" || !hasDefault):
+			return envValue
+		case hasDefault:
+			return defaultValue
+		default:
+			missing = append(missing, name)
+
+			return reference
+		}
+	})
+
+	if len(missing) > 0 {
+		slices.Sort(missing)
+
+		return "", fmt.Errorf("undefined environment variable(s) %s in %q", strings.Join(slices.Compact(missing), ", "), value)
+	}
+
+	return interpolated, nil
+}
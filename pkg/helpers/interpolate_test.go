@@ -0,0 +1,64 @@
+package helpers
+
+import "testing"
+
+func TestInterpolateEnv(t *testing.T) {
+	t.Setenv("GITLAB_SYNC_TEST_ROOT", "staging")
+	t.Setenv("GITLAB_SYNC_TEST_EMPTY", "")
+
+	tests := []struct {
+		name          string
+		value         string
+		expected      string
+		expectedError bool
+	}{
+		{
+			name:     "No reference",
+			value:    "mirror/team",
+			expected: "mirror/team",
+		},
+		{
+			name:     "Defined variable",
+			value:    "${GITLAB_SYNC_TEST_ROOT}/team",
+			expected: "staging/team",
+		},
+		{
+			name:     "Default value of an unset variable",
+			value:    "${GITLAB_SYNC_TEST_UNSET:-production}/team",
+			expected: "production/team",
+		},
+		{
+			name:     "Default value of an empty variable",
+			value:    "${GITLAB_SYNC_TEST_EMPTY:-production}/team",
+			expected: "production/team",
+		},
+		{
+			name:     "Empty variable without default",
+			value:    "mirror${GITLAB_SYNC_TEST_EMPTY}/team",
+			expected: "mirror/team",
+		},
+		{
+			name:     "Capture group references are left untouched",
+			value:    "${GITLAB_SYNC_TEST_ROOT}/${1}/$2",
+			expected: "staging/${1}/$2",
+		},
+		{
+			name:          "Unset variable",
+			value:         "${GITLAB_SYNC_TEST_UNSET}/team",
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := InterpolateEnv(tt.value)
+			if (err != nil) != tt.expectedError {
+				t.Fatalf(EXPECTED_ERROR_MESSAGE, tt.expectedError, err)
+			}
+
+			if got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
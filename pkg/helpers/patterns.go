@@ -0,0 +1,284 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// PATTERN_REGEX_PREFIX is the prefix of path patterns written as regular expressions.
+	PATTERN_REGEX_PREFIX = "re:"
+	// globMetaCharacters are the characters that turn a path into a glob pattern.
+	globMetaCharacters = "*?["
+)
+
+var (
+	// ErrEmptyPattern is returned when compiling an empty path pattern.
+	ErrEmptyPattern = errors.New("empty path pattern")
+
+	// templatePlaceholderPattern matches the placeholders of a destination path template:
+	// $1, ${1}, {name}, {parent} and {path}.
+	templatePlaceholderPattern = regexp.MustCompile(`\$\{(\d+)\}|\$(\d+)|\{([a-z]+)\}`)
+)
+
+// PathPattern is a compiled glob or regular expression matching GitLab full paths.
+//
+// Glob patterns support:
+//   - "*" matching any sequence of characters within a single path segment
+//   - "?" matching any single character within a path segment
+//   - "[...]" character classes ("[!...]" for negation)
+//   - "**" as a full segment, matching any number of path segments
+//
+// Each wildcard of a glob pattern is a capture group, numbered from left to right.
+// Regular expression patterns are prefixed by "re:" and must match the whole path.
+type PathPattern struct {
+	expression *regexp.Regexp
+	raw        string
+	root       string
+}
+
+// IsPathPattern reports whether the given mapping key is a pattern (glob or regular expression)
+// rather than a literal GitLab path.
+func IsPathPattern(key string) bool {
+	return strings.HasPrefix(key, PATTERN_REGEX_PREFIX) || strings.ContainsAny(key, globMetaCharacters)
+}
+
+// CompilePathPattern compiles a glob or regular expression ("re:" prefixed) path pattern.
+func CompilePathPattern(pattern string) (*PathPattern, error) {
+	if strings.TrimPrefix(pattern, PATTERN_REGEX_PREFIX) == "" {
+		return nil, ErrEmptyPattern
+	}
+
+	var (
+		expression string
+		root       string
+	)
+
+	rawExpression, isRegex := strings.CutPrefix(pattern, PATTERN_REGEX_PREFIX)
+	if isRegex {
+		rawExpression = "(?:" + strings.TrimSuffix(strings.TrimPrefix(rawExpression, "^"), "$") + ")"
+		expression = "^" + rawExpression + "$"
+	} else {
+		var err error
+
+		expression, root, err = globToRegexp(pattern)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	compiled, err := regexp.Compile(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path pattern %s: %w", pattern, err)
+	}
+
+	if isRegex {
+		// Anchored expressions have no literal prefix, compute it on the bare expression
+		root = regexpRoot(regexp.MustCompile(rawExpression))
+	}
+
+	return &PathPattern{
+		expression: compiled,
+		raw:        pattern,
+		root:       root,
+	}, nil
+}
+
+// String returns the pattern as written in the mapping.
+func (p *PathPattern) String() string {
+	return p.raw
+}
+
+// Root returns the longest literal group path that contains every path matched by the pattern.
+// It is empty when the pattern can match paths at the top level of the instance.
+func (p *PathPattern) Root() string {
+	return p.root
+}
+
+// NumCaptures returns the number of capture groups of the pattern.
+func (p *PathPattern) NumCaptures() int {
+	return p.expression.NumSubexp()
+}
+
+// Match matches the given full path against the pattern.
+// It returns the captured values (index 0 being the whole path) and whether the path matched.
+func (p *PathPattern) Match(fullPath string) ([]string, bool) {
+	captures := p.expression.FindStringSubmatch(fullPath)
+
+	return captures, captures != nil
+}
+
+// CheckTemplate validates a destination path template against the pattern.
+// It ensures every placeholder is known and every capture reference exists.
+func (p *PathPattern) CheckTemplate(template string) error {
+	_, err := expandTemplate(template, "", make([]string, p.NumCaptures()+1))
+
+	return err
+}
+
+// ExpandPathTemplate builds a destination path from a template, for a matched source path.
+// Supported placeholders are:
+//   - $N or ${N}: the Nth capture group of the pattern
+//   - {name}: the last segment of the source path
+//   - {parent}: the parent path of the source path
+//   - {path}: the full source path
+//
+// Empty segments produced by the expansion are removed.
+func ExpandPathTemplate(template, sourcePath string, captures []string) (string, error) {
+	expanded, err := expandTemplate(template, sourcePath, captures)
+	if err != nil {
+		return "", err
+	}
+
+	segments := strings.Split(expanded, "/")
+	kept := segments[:0]
+
+	for _, segment := range segments {
+		if segment != "" {
+			kept = append(kept, segment)
+		}
+	}
+
+	return strings.Join(kept, "/"), nil
+}
+
+// expandTemplate replaces the placeholders of a destination path template.
+func expandTemplate(template, sourcePath string, captures []string) (string, error) {
+	var expandErr error
+
+	expanded := templatePlaceholderPattern.ReplaceAllStringFunc(template, func(placeholder string) string {
+		parts := templatePlaceholderPattern.FindStringSubmatch(placeholder)
+
+		switch {
+		case parts[1] != "" || parts[2] != "":
+			index, err := strconv.Atoi(parts[1] + parts[2])
+			if err != nil || index >= len(captures) {
+				expandErr = errors.Join(expandErr, fmt.Errorf("unknown capture group %s in destination path %s", placeholder, template))
+
+				return ""
+			}
+
+			return captures[index]
+		case parts[3] == "name":
+			return path.Base(sourcePath)
+		case parts[3] == "parent":
+			if parent := path.Dir(sourcePath); parent != "." {
+				return parent
+			}
+
+			return ""
+		case parts[3] == "path":
+			return sourcePath
+		default:
+			expandErr = errors.Join(expandErr, fmt.Errorf("unknown placeholder %s in destination path %s", placeholder, template))
+
+			return ""
+		}
+	})
+
+	return expanded, expandErr
+}
+
+// globToRegexp converts a glob pattern into an anchored regular expression.
+// It also returns the literal leading segments of the pattern (its root).
+func globToRegexp(glob string) (string, string, error) {
+	segments := strings.Split(glob, "/")
+	rootSegments := make([]string, 0, len(segments))
+	rootDone := false
+
+	var builder strings.Builder
+
+	builder.WriteString("^")
+
+	for index, segment := range segments {
+		if segment == "" {
+			return "", "", fmt.Errorf("invalid path pattern %s: empty path segment", glob)
+		}
+
+		if !rootDone && !strings.ContainsAny(segment, globMetaCharacters) && index < len(segments)-1 {
+			rootSegments = append(rootSegments, segment)
+		} else {
+			rootDone = true
+		}
+
+		isLast := index == len(segments)-1
+
+		if segment == "**" {
+			if isLast {
+				builder.WriteString("(.+)")
+			} else {
+				builder.WriteString("(?:(.+)/)?")
+			}
+
+			continue
+		}
+
+		translated, err := globSegmentToRegexp(segment)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid path pattern %s: %w", glob, err)
+		}
+
+		builder.WriteString(translated)
+
+		if !isLast {
+			builder.WriteString("/")
+		}
+	}
+
+	builder.WriteString("$")
+
+	return builder.String(), strings.Join(rootSegments, "/"), nil
+}
+
+// globSegmentToRegexp converts a single glob path segment into a regular expression.
+func globSegmentToRegexp(segment string) (string, error) {
+	if strings.Contains(segment, "**") {
+		return "", errors.New("** must be a full path segment")
+	}
+
+	var builder strings.Builder
+
+	for index := 0; index < len(segment); index++ {
+		switch character := segment[index]; character {
+		case '*':
+			builder.WriteString("([^/]*)")
+		case '?':
+			builder.WriteString("([^/])")
+		case '[':
+			end := strings.IndexByte(segment[index+1:], ']')
+			if end < 0 {
+				return "", errors.New("unterminated character class")
+			}
+
+			class := segment[index+1 : index+1+end]
+			if negated, ok := strings.CutPrefix(class, "!"); ok {
+				// A negated class must not match across path segments either
+				class = "^" + negated + "/"
+			}
+
+			builder.WriteString("[" + class + "]")
+
+			index += end + 1
+		default:
+			builder.WriteString(regexp.QuoteMeta(string(character)))
+		}
+	}
+
+	return builder.String(), nil
+}
+
+// regexpRoot returns the literal group path every match of the expression starts with.
+func regexpRoot(expression *regexp.Regexp) string {
+	prefix, _ := expression.LiteralPrefix()
+
+	lastSlash := strings.LastIndexByte(prefix, '/')
+	if lastSlash <= 0 {
+		return ""
+	}
+
+	return prefix[:lastSlash]
+}
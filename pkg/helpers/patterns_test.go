@@ -0,0 +1,306 @@
+package helpers
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestIsPathPattern(t *testing.T) {
+	tests := []struct {
+		key      string
+		expected bool
+	}{
+		{"group/project", false},
+		{"group/sub-group_1.x", false},
+		{"platform/*/charts", true},
+		{"platform/chart?", true},
+		{"platform/[ab]", true},
+		{"re:^infra/.+$", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.key, func(t *testing.T) {
+			t.Parallel()
+
+			if got := IsPathPattern(test.key); got != test.expected {
+				t.Errorf("IsPathPattern(%s) = %t, expected %t", test.key, got, test.expected)
+			}
+		})
+	}
+}
+
+func TestCompilePathPattern(t *testing.T) {
+	tests := []struct {
+		name             string
+		pattern          string
+		expectedRoot     string
+		matching         map[string][]string
+		notMatching      []string
+		expectedCaptures int
+		expectedError    bool
+	}{
+		{
+			name:             "Single segment wildcard",
+			pattern:          "platform/*/charts",
+			expectedRoot:     "platform",
+			expectedCaptures: 1,
+			matching: map[string][]string{
+				"platform/team-a/charts": {"platform/team-a/charts", "team-a"},
+			},
+			notMatching: []string{"platform/charts", "platform/a/b/charts", "other/a/charts"},
+		},
+		{
+			name:             "Double star in the middle",
+			pattern:          "platform/**/charts",
+			expectedRoot:     "platform",
+			expectedCaptures: 1,
+			matching: map[string][]string{
+				"platform/charts":       {"platform/charts", ""},
+				"platform/a/b/c/charts": {"platform/a/b/c/charts", "a/b/c"},
+			},
+			notMatching: []string{"platform/a/charts-x"},
+		},
+		{
+			name:             "Trailing double star",
+			pattern:          "platform/**",
+			expectedRoot:     "platform",
+			expectedCaptures: 1,
+			matching: map[string][]string{
+				"platform/a/b": {"platform/a/b", "a/b"},
+			},
+			notMatching: []string{"platform"},
+		},
+		{
+			name:             "Top level wildcard",
+			pattern:          "*-mirror",
+			expectedRoot:     "",
+			expectedCaptures: 1,
+			matching: map[string][]string{
+				"team-mirror": {"team-mirror", "team"},
+			},
+			notMatching: []string{"a/team-mirror"},
+		},
+		{
+			name:             "Question mark and character classes",
+			pattern:          "apps/v?/[!x]*",
+			expectedRoot:     "apps",
+			expectedCaptures: 2,
+			matching: map[string][]string{
+				"apps/v1/api": {"apps/v1/api", "1", "pi"},
+			},
+			notMatching: []string{"apps/v10/api", "apps/v1/xapi"},
+		},
+		{
+			name:             "Negated character class does not match a slash",
+			pattern:          "apps/v[!a]1",
+			expectedRoot:     "apps",
+			expectedCaptures: 0,
+			matching: map[string][]string{
+				"apps/vb1": {"apps/vb1"},
+			},
+			notMatching: []string{"apps/va1", "apps/v/1"},
+		},
+		{
+			name:             "Special characters are escaped",
+			pattern:          "group.name/*",
+			expectedRoot:     "group.name",
+			expectedCaptures: 1,
+			matching: map[string][]string{
+				"group.name/project": {"group.name/project", "project"},
+			},
+			notMatching: []string{"groupxname/project"},
+		},
+		{
+			name:             "Regular expression",
+			pattern:          "re:^infra/(.+)-terraform$",
+			expectedRoot:     "infra",
+			expectedCaptures: 1,
+			matching: map[string][]string{
+				"infra/aws/network-terraform": {"infra/aws/network-terraform", "aws/network"},
+			},
+			notMatching: []string{"infra/network-terraform-old", "x/infra/a-terraform"},
+		},
+		{
+			name:             "Regular expression is anchored",
+			pattern:          "re:team-[0-9]+",
+			expectedRoot:     "",
+			expectedCaptures: 0,
+			matching: map[string][]string{
+				"team-42": {"team-42"},
+			},
+			notMatching: []string{"my-team-42", "team-42/project"},
+		},
+		{
+			name:          "Invalid regular expression",
+			pattern:       "re:infra/(",
+			expectedError: true,
+		},
+		{
+			name:          "Unterminated character class",
+			pattern:       "infra/[ab",
+			expectedError: true,
+		},
+		{
+			name:          "Double star inside a segment",
+			pattern:       "infra/a**",
+			expectedError: true,
+		},
+		{
+			name:          "Empty segment",
+			pattern:       "infra//*",
+			expectedError: true,
+		},
+		{
+			name:          "Empty regular expression",
+			pattern:       "re:",
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			pattern, err := CompilePathPattern(test.pattern)
+			if (err != nil) != test.expectedError {
+				t.Fatalf(EXPECTED_ERROR_MESSAGE, test.expectedError, err)
+			}
+
+			if test.expectedError {
+				return
+			}
+
+			if pattern.String() != test.pattern {
+				t.Errorf("expected pattern string %s, got %s", test.pattern, pattern.String())
+			}
+
+			if pattern.Root() != test.expectedRoot {
+				t.Errorf("expected root %q, got %q", test.expectedRoot, pattern.Root())
+			}
+
+			if pattern.NumCaptures() != test.expectedCaptures {
+				t.Errorf("expected %d captures, got %d", test.expectedCaptures, pattern.NumCaptures())
+			}
+
+			for fullPath, expectedCaptures := range test.matching {
+				captures, ok := pattern.Match(fullPath)
+				if !ok {
+					t.Errorf("expected %s to match %s", fullPath, test.pattern)
+				} else if !reflect.DeepEqual(captures, expectedCaptures) {
+					t.Errorf("expected captures %q for %s, got %q", expectedCaptures, fullPath, captures)
+				}
+			}
+
+			for _, fullPath := range test.notMatching {
+				if _, ok := pattern.Match(fullPath); ok {
+					t.Errorf("expected %s not to match %s", fullPath, test.pattern)
+				}
+			}
+		})
+	}
+}
+
+func TestCompilePathPatternEmpty(t *testing.T) {
+	t.Parallel()
+
+	if _, err := CompilePathPattern(""); !errors.Is(err, ErrEmptyPattern) {
+		t.Errorf(EXPECTED_ERROR_MESSAGE, ErrEmptyPattern, err)
+	}
+}
+
+func TestExpandPathTemplate(t *testing.T) {
+	tests := []struct {
+		name          string
+		template      string
+		sourcePath    string
+		captures      []string
+		expected      string
+		expectedError bool
+	}{
+		{
+			name:       "Capture groups",
+			template:   "mirror/$1/${2}",
+			sourcePath: "platform/a/b",
+			captures:   []string{"platform/a/b", "a", "b"},
+			expected:   "mirror/a/b",
+		},
+		{
+			name:       "Name and parent placeholders",
+			template:   "mirror/{parent}/{name}",
+			sourcePath: "platform/team/charts",
+			captures:   []string{"platform/team/charts"},
+			expected:   "mirror/platform/team/charts",
+		},
+		{
+			name:       "Path placeholder",
+			template:   "archive/{path}",
+			sourcePath: "platform/charts",
+			captures:   []string{"platform/charts"},
+			expected:   "archive/platform/charts",
+		},
+		{
+			name:       "Empty segments are removed",
+			template:   "mirror/$1/{parent}/{name}",
+			sourcePath: "charts",
+			captures:   []string{"charts", ""},
+			expected:   "mirror/charts",
+		},
+		{
+			name:          "Unknown capture group",
+			template:      "mirror/$2",
+			sourcePath:    "platform/charts",
+			captures:      []string{"platform/charts", "charts"},
+			expectedError: true,
+		},
+		{
+			name:          "Unknown placeholder",
+			template:      "mirror/{owner}",
+			sourcePath:    "platform/charts",
+			captures:      []string{"platform/charts"},
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			expanded, err := ExpandPathTemplate(test.template, test.sourcePath, test.captures)
+			if (err != nil) != test.expectedError {
+				t.Fatalf(EXPECTED_ERROR_MESSAGE, test.expectedError, err)
+			}
+
+			if expanded != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, expanded)
+			}
+		})
+	}
+}
+
+func TestPathPatternCheckTemplate(t *testing.T) {
+	pattern, err := CompilePathPattern("platform/*/charts")
+	if err != nil {
+		t.Fatalf("failed to compile pattern: %v", err)
+	}
+
+	tests := []struct {
+		template      string
+		expectedError bool
+	}{
+		{"mirror/$1/charts", false},
+		{"mirror/{name}", false},
+		{"mirror/$2", true},
+		{"mirror/{unknown}", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.template, func(t *testing.T) {
+			t.Parallel()
+
+			if err := pattern.CheckTemplate(test.template); (err != nil) != test.expectedError {
+				t.Errorf(EXPECTED_ERROR_MESSAGE, test.expectedError, err)
+			}
+		})
+	}
+}
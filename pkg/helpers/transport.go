@@ -0,0 +1,219 @@
+package helpers
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// TransportOptions defines the TLS and proxy settings used to reach a GitLab instance
+// - ca_file: the PEM bundle of the certificate authorities trusted on top of the system ones
+// - client_cert_file / client_key_file: the PEM client certificate and key used for mutual TLS
+// - proxy_url: the proxy the requests go through (defaults to the HTTP_PROXY / HTTPS_PROXY / NO_PROXY environment variables)
+// - insecure_skip_verify: whether to skip the server certificate verification.
+type TransportOptions struct {
+	CAFile             string
+	ClientCertFile     string
+	ClientKeyFile      string
+	ProxyURL           string
+	InsecureSkipVerify bool
+}
+
+// TransportConfig holds the loaded TLS material and proxy of a GitLab instance,
+// applied to both its API client and the git operations.
+type TransportConfig struct {
+	proxyURL           *url.URL
+	caBundle           []byte
+	clientCert         []byte
+	clientKey          []byte
+	certificate        *tls.Certificate
+	insecureSkipVerify bool
+}
+
+// LoadTransportConfig reads and validates the files of the transport options.
+// It returns a nil config when no option is set, meaning the default transport is used.
+func LoadTransportConfig(opts *TransportOptions) (*TransportConfig, error) {
+	if opts == nil || *opts == (TransportOptions{}) {
+		return nil, nil //nolint:nilnil // a nil config means the default transport
+	}
+
+	config := &TransportConfig{insecureSkipVerify: opts.InsecureSkipVerify}
+
+	var errs []error
+
+	if opts.CAFile != "" {
+		caBundle, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to read CA file: %w", err))
+		} else if !x509.NewCertPool().AppendCertsFromPEM(caBundle) {
+			errs = append(errs, fmt.Errorf("CA file %s contains no PEM certificate", opts.CAFile))
+		}
+
+		config.caBundle = caBundle
+	}
+
+	if (opts.ClientCertFile == "") != (opts.ClientKeyFile == "") {
+		errs = append(errs, errors.New("client certificate and client key must be set together"))
+	} else if opts.ClientCertFile != "" {
+		err := config.loadClientCertificate(opts.ClientCertFile, opts.ClientKeyFile)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if opts.ProxyURL != "" {
+		proxyURL, err := url.Parse(opts.ProxyURL)
+		if err != nil || proxyURL.Scheme == "" || proxyURL.Host == "" {
+			errs = append(errs, fmt.Errorf("invalid proxy URL %s", opts.ProxyURL))
+		}
+
+		config.proxyURL = proxyURL
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return config, nil
+}
+
+// loadClientCertificate reads the client certificate and key files and checks they form a valid pair.
+func (c *TransportConfig) loadClientCertificate(certFile, keyFile string) error {
+	clientCert, err := os.ReadFile(certFile)
+	if err != nil {
+		return fmt.Errorf("failed to read client certificate: %w", err)
+	}
+
+	clientKey, err := os.ReadFile(keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to read client key: %w", err)
+	}
+
+	certificate, err := tls.X509KeyPair(clientCert, clientKey)
+	if err != nil {
+		return fmt.Errorf("invalid client certificate / key pair: %w", err)
+	}
+
+	c.clientCert = clientCert
+	c.clientKey = clientKey
+	c.certificate = &certificate
+
+	return nil
+}
+
+// HTTPClient builds an HTTP client using the TLS and proxy settings of the config.
+// A nil config returns nil, meaning the default client is used.
+func (c *TransportConfig) HTTPClient() (*http.Client, error) {
+	if c == nil {
+		return nil, nil //nolint:nilnil // a nil client means the default client
+	}
+
+	defaultTransport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return nil, errors.New("unexpected default HTTP transport type")
+	}
+
+	httpTransport := defaultTransport.Clone()
+	httpTransport.TLSClientConfig = &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: c.insecureSkipVerify, //nolint:gosec // explicitly requested by the user
+	}
+
+	if len(c.caBundle) > 0 {
+		rootCAs, err := x509.SystemCertPool()
+		if err != nil {
+			rootCAs = x509.NewCertPool()
+		}
+
+		rootCAs.AppendCertsFromPEM(c.caBundle)
+		httpTransport.TLSClientConfig.RootCAs = rootCAs
+	}
+
+	if c.certificate != nil {
+		httpTransport.TLSClientConfig.Certificates = []tls.Certificate{*c.certificate}
+	}
+
+	if c.proxyURL != nil {
+		httpTransport.Proxy = http.ProxyURL(c.proxyURL)
+	}
+
+	return &http.Client{Transport: httpTransport}, nil
+}
+
+// proxyOptions returns the go-git proxy options of the config.
+func (c *TransportConfig) proxyOptions() transport.ProxyOptions {
+	if c.proxyURL == nil {
+		return transport.ProxyOptions{}
+	}
+
+	proxyURL := *c.proxyURL
+	proxyURL.User = nil
+
+	password, _ := c.proxyURL.User.Password()
+
+	return transport.ProxyOptions{
+		URL:      strings.TrimSuffix(proxyURL.String(), "/"),
+		Username: c.proxyURL.User.Username(),
+		Password: password,
+	}
+}
+
+// applyToClone sets the TLS and proxy settings of the config on git clone options.
+func (c *TransportConfig) applyToClone(opts *git.CloneOptions) {
+	if c == nil {
+		return
+	}
+
+	opts.CABundle = c.caBundle
+	opts.ClientCert = c.clientCert
+	opts.ClientKey = c.clientKey
+	opts.InsecureSkipTLS = c.insecureSkipVerify
+	opts.ProxyOptions = c.proxyOptions()
+}
+
+// applyToPush sets the TLS and proxy settings of the config on git push options.
+func (c *TransportConfig) applyToPush(opts *git.PushOptions) {
+	if c == nil {
+		return
+	}
+
+	opts.CABundle = c.caBundle
+	opts.ClientCert = c.clientCert
+	opts.ClientKey = c.clientKey
+	opts.InsecureSkipTLS = c.insecureSkipVerify
+	opts.ProxyOptions = c.proxyOptions()
+}
+
+// applyToFetch sets the TLS and proxy settings of the config on git fetch options.
+func (c *TransportConfig) applyToFetch(opts *git.FetchOptions) {
+	if c == nil {
+		return
+	}
+
+	opts.CABundle = c.caBundle
+	opts.ClientCert = c.clientCert
+	opts.ClientKey = c.clientKey
+	opts.InsecureSkipTLS = c.insecureSkipVerify
+	opts.ProxyOptions = c.proxyOptions()
+}
+
+// applyToList sets the TLS and proxy settings of the config on git remote listing options.
+func (c *TransportConfig) applyToList(opts *git.ListOptions) {
+	if c == nil {
+		return
+	}
+
+	opts.CABundle = c.caBundle
+	opts.ClientCert = c.clientCert
+	opts.ClientKey = c.clientKey
+	opts.InsecureSkipTLS = c.insecureSkipVerify
+	opts.ProxyOptions = c.proxyOptions()
+}